@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/client"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// minBits is the smallest group accepted from the service, i.e. P-256.
+const minBits = 256
+
+// defaultProfile is the profile used if neither --profile, $OSCLI_PROFILE
+// nor the default-profile of the config file choose one.
+const defaultProfile = "default"
+
+// profileName restricts names of profiles to keys of the config file.
+var profileName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// profileKeys map the keys of a profile to the flags overriding them.
+// Environment variables override them as well, e.g. OSCLI_SERVER_URL.
+var profileKeys = map[string]string{
+	"server.url":               "server",
+	"protocol.hash":            "hash",
+	"protocol.bits":            "bits",
+	"tls.ca":                   "tls-ca",
+	"tls.insecure-skip-verify": "tls-insecure-skip-verify",
+	"users":                    "users",
+}
+
+// configKeys map the keys shared by all profiles to the flags overriding them.
+var configKeys = map[string]string{
+	"default-profile": "profile",
+	"timeout.request": "timeout",
+	"timeout.idle":    "idle-timeout",
+}
+
+// config is the configuration of oscli for one profile read from the config file, e.g.
+//
+//	default-profile: production
+//	profiles:
+//	  production:
+//	    server:
+//	      url: https://sphinx.example.com
+//	    protocol:
+//	      hash: sha256
+//	      bits: 256
+//	    tls:
+//	      ca: ""
+//	      insecure-skip-verify: false
+//	    users: ~/.oscli/production.users.json
+//	  staging:
+//	    server:
+//	      url: https://localhost:8443
+//	    tls:
+//	      ca: ./certs/server.crt
+//	timeout:
+//	  request: 30s
+//	  idle: 15m
+type config struct {
+	profile     string
+	server      string
+	hash        string
+	bits        int
+	tlsCA       string
+	tlsInsecure bool
+	users       string
+	timeout     time.Duration
+	idle        time.Duration
+}
+
+// defaultConfigPath returns $OSCLI_CONFIG or ~/.oscli/config.yaml.
+func defaultConfigPath() string {
+	if path := os.Getenv("OSCLI_CONFIG"); path != "" {
+		return path
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "oscli.yaml"
+	}
+	return filepath.Join(home, ".oscli", "config.yaml")
+}
+
+// defaultUsers returns the user store of profile,
+// the default profile keeps the store of oscli without profiles.
+func defaultUsers(profile string) string {
+	if profile == defaultProfile {
+		return "~/.oscli.users.json"
+	}
+	return "~/.oscli/" + profile + ".users.json"
+}
+
+// addProfileFlags adds the flags overriding the profile of the config file to cmd.
+func addProfileFlags(cmd *cobra.Command) {
+	cmd.Flags().String("server", "https://localhost:443", "URL of the Online SPHINX service")
+	cmd.Flags().String("hash", "sha256", "hash function, one of sha256 or sha512")
+	cmd.Flags().Int("bits", minBits, "minimum bit length of the group of the service")
+	cmd.Flags().String("tls-ca", "", "PEM file of the CA certificates trusted for the service, e.g. a self-signed certificate")
+	cmd.Flags().Bool("tls-insecure-skip-verify", false, "do not verify the certificate of the service, for testing only")
+	cmd.Flags().String("users", "", "file storing the users registered at the service, defaults to ~/.oscli.users.json for the default profile and ~/.oscli/<profile>.users.json for others")
+}
+
+// addIdleFlag adds the flag overriding the idle timeout of the config file to cmd.
+func addIdleFlag(cmd *cobra.Command) {
+	cmd.Flags().Duration("idle-timeout", 15*time.Minute, "logout after being idle for the timeout, 0 disables it")
+}
+
+// readConfigFile returns the settings of the config file at path without overrides.
+// A missing config file has no settings.
+func readConfigFile(path string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return nil, errors.Wrapf(err, "failed to read config %s", path)
+	}
+	return v, nil
+}
+
+// loadConfig returns the config of the profile chosen by cmd, read from the config file if readFile,
+// overridden by environment variables and the flags of cmd.
+// A missing config file is ignored, a missing profile is not.
+func loadConfig(cmd *cobra.Command, readFile bool) (config, error) {
+	v := viper.New()
+	if readFile {
+		path, _ := cmd.Flags().GetString("config")
+		var err error
+		if v, err = readConfigFile(path); err != nil {
+			return config{}, err
+		}
+	}
+	bind(v, cmd, "", configKeys)
+	v.BindEnv("default-profile", "OSCLI_PROFILE")
+	v.SetDefault("default-profile", defaultProfile)
+	v.SetDefault("timeout.request", 30*time.Second)
+	v.SetDefault("timeout.idle", 15*time.Minute)
+
+	name := v.GetString("default-profile")
+	if !profileName.MatchString(name) {
+		return config{}, errors.Errorf("invalid profile name %q, want lower case letters, digits, '-' and '_'", name)
+	}
+	if readFile && name != defaultProfile && !v.IsSet("profiles."+name) {
+		return config{}, errors.Errorf("profile %s not found, add it with 'oscli profile add %s'", name, name)
+	}
+
+	prefix := "profiles." + name + "."
+	bind(v, cmd, prefix, profileKeys)
+	v.SetDefault(prefix+"server.url", "https://localhost:443")
+	v.SetDefault(prefix+"protocol.hash", "sha256")
+	v.SetDefault(prefix+"protocol.bits", minBits)
+	v.SetDefault(prefix+"users", defaultUsers(name))
+
+	return config{
+		profile:     name,
+		server:      v.GetString(prefix + "server.url"),
+		hash:        v.GetString(prefix + "protocol.hash"),
+		bits:        v.GetInt(prefix + "protocol.bits"),
+		tlsCA:       v.GetString(prefix + "tls.ca"),
+		tlsInsecure: v.GetBool(prefix + "tls.insecure-skip-verify"),
+		users:       v.GetString(prefix + "users"),
+		timeout:     v.GetDuration("timeout.request"),
+		idle:        v.GetDuration("timeout.idle"),
+	}, nil
+}
+
+// bind overrides the keys of v below prefix by their environment variables, e.g. OSCLI_SERVER_URL,
+// and by their flags of cmd.
+func bind(v *viper.Viper, cmd *cobra.Command, prefix string, keys map[string]string) {
+	env := strings.NewReplacer(".", "_", "-", "_")
+	for key, name := range keys {
+		v.BindEnv(prefix+key, "OSCLI_"+strings.ToUpper(env.Replace(key)))
+		if f := cmd.Flags().Lookup(name); f != nil {
+			v.BindPFlag(prefix+key, f)
+		}
+	}
+}
+
+// validate returns an error if the service can not be reached with cfg.
+func (cfg config) validate() error {
+	if _, err := cfg.clientConfiguration(); err != nil {
+		return err
+	}
+	if _, err := cfg.tlsConfig(); err != nil {
+		return err
+	}
+	if cfg.timeout <= 0 || cfg.idle < 0 {
+		return errors.Errorf("invalid timeouts: request %v must be positive, idle %v must not be negative", cfg.timeout, cfg.idle)
+	}
+	return nil
+}
+
+// clientConfiguration returns the client.Configuration of cfg.
+func (cfg config) clientConfiguration() (client.Configuration, error) {
+	if cfg.bits < minBits {
+		return client.Configuration{}, errors.Errorf("bits %d accept groups smaller than %d bits", cfg.bits, minBits)
+	}
+	hashFn, err := client.HashByName(cfg.hash)
+	if err != nil {
+		return client.Configuration{}, err
+	}
+	return client.NewConfiguration(cfg.server, cfg.bits, hashFn)
+}
+
+// tlsConfig returns the TLS configuration for the service.
+func (cfg config) tlsConfig() (*tls.Config, error) {
+	tc := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.tlsInsecure,
+	}
+	if cfg.tlsCA == "" {
+		return tc, nil
+	}
+
+	pem, err := ioutil.ReadFile(cfg.tlsCA)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read TLS CA")
+	}
+	tc.RootCAs = x509.NewCertPool()
+	if !tc.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("TLS CA %s contains no PEM certificate", cfg.tlsCA)
+	}
+	return tc, nil
+}
+
+// profileSettings returns the settings of the profile of cfg in the config file.
+func (cfg config) profileSettings() map[string]interface{} {
+	return map[string]interface{}{
+		"server":   map[string]interface{}{"url": cfg.server},
+		"protocol": map[string]interface{}{"hash": cfg.hash, "bits": cfg.bits},
+		"tls":      map[string]interface{}{"ca": cfg.tlsCA, "insecure-skip-verify": cfg.tlsInsecure},
+		"users":    cfg.users,
+	}
+}
+
+// timeoutSettings returns the timeouts of cfg in the config file.
+func (cfg config) timeoutSettings() map[string]interface{} {
+	return map[string]interface{}{"request": cfg.timeout.String(), "idle": cfg.idle.String()}
+}
+
+// writeConfigFile writes settings to path, which is only readable by the user.
+func writeConfigFile(path string, settings map[string]interface{}) error {
+	v := viper.New()
+	for key, value := range settings {
+		v.Set(key, value)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrapf(err, "failed to create directory of %s", path)
+	}
+	if err := v.WriteConfigAs(path); err != nil {
+		return errors.Wrapf(err, "failed to write config %s", path)
+	}
+	return errors.Wrapf(os.Chmod(path, 0600), "failed to restrict config %s to the user", path)
+}
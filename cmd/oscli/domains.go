@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/client"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// sortKeys are the orders of list by the value of --sort.
+var sortKeys = map[string]func(domain string) string{
+	// name orders alphabetically
+	"name": func(domain string) string { return domain },
+	// domain orders by the reversed labels, so that subdomains follow their parent domain
+	"domain": func(domain string) string {
+		labels := strings.Split(domain, ".")
+		for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+			labels[i], labels[j] = labels[j], labels[i]
+		}
+		return strings.Join(labels, ".")
+	},
+}
+
+// filterDomains returns the domains matching the shell pattern, ignoring case.
+func filterDomains(domains []string, pattern string) ([]string, error) {
+	if pattern == "" {
+		return domains, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, errors.Wrapf(err, "invalid filter %s", pattern)
+	}
+
+	filtered := []string{}
+	for _, d := range domains {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(d)); ok {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered, nil
+}
+
+// sortDomains sorts domains by the sort key by, in reverse if reverse.
+func sortDomains(domains []string, by string, reverse bool) error {
+	key, ok := sortKeys[by]
+	if !ok {
+		return errors.Errorf("invalid sort %s, want name or domain", by)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if reverse {
+			i, j = j, i
+		}
+		return key(domains[i]) < key(domains[j])
+	})
+	return nil
+}
+
+// confirm asks on the terminal whether to proceed with question, unless --yes of cmd is set.
+func confirm(cmd *cobra.Command, question string) (bool, error) {
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		return true, nil
+	}
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return false, errors.New("stdin is not a terminal, confirm with --yes")
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read confirmation")
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// domains returns the domains of the user from the agent of cmd and exits on errors.
+func (c *cli) domains(cmd *cobra.Command) []string {
+	clt := c.dial(cmd)
+	defer clt.Close()
+	ctx, cancel := c.context(cmd)
+	defer cancel()
+
+	domains, err := clt.Domains(ctx)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	return domains
+}
+
+func (c *cli) listRun(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Help()
+		os.Exit(-1)
+	}
+
+	pattern, _ := cmd.Flags().GetString("filter")
+	by, _ := cmd.Flags().GetString("sort")
+	reverse, _ := cmd.Flags().GetBool("reverse")
+
+	domains, err := filterDomains(c.domains(cmd), pattern)
+	if err == nil {
+		err = sortDomains(domains, by, reverse)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	for _, d := range domains {
+		fmt.Println(d)
+	}
+}
+
+func (c *cli) searchRun(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Help()
+		os.Exit(-1)
+	}
+
+	matches := client.Search(c.domains(cmd), args[0])
+	if limit, _ := cmd.Flags().GetInt("limit"); limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	if len(matches) == 0 {
+		fmt.Printf("no domain matches %s\n", args[0])
+		os.Exit(-1)
+	}
+	for _, m := range matches {
+		fmt.Println(m.Domain)
+	}
+}
+
+func (c *cli) removeRun(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Help()
+		os.Exit(-1)
+	}
+	domain := args[0]
+
+	found := false
+	for _, d := range c.domains(cmd) {
+		found = found || d == domain
+	}
+	if !found {
+		fmt.Printf("domain %s not found, see 'oscli list'\n", domain)
+		os.Exit(-1)
+	}
+
+	ok, err := confirm(cmd, fmt.Sprintf("Remove %s? Its passwords can not be derived anymore.", domain))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	if !ok {
+		fmt.Println("aborted")
+		os.Exit(-1)
+	}
+
+	clt := c.dial(cmd)
+	defer clt.Close()
+	ctx, cancel := c.context(cmd)
+	defer cancel()
+
+	err = clt.Delete(ctx, domain)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+}
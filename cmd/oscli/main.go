@@ -1,20 +1,21 @@
 package main
 
 import (
-	"crypto/rand"
+	"context"
 	"fmt"
-	"log"
-	"math/big"
 	"net/http"
 	"net/http/cookiejar"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 	"time"
 
+	"github.com/LAtanassov/go-online-sphinx/pkg/agent"
 	"github.com/LAtanassov/go-online-sphinx/pkg/client"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 func main() {
@@ -25,62 +26,114 @@ func main() {
 	}
 }
 
+// cli runs the commands as thin clients of the oscli agent, which holds the session.
 type cli struct {
-	clt *client.Client
+	config config
 }
 
 func newCommand() *cobra.Command {
 
-	tr := &http.Transport{
-		IdleConnTimeout: 30 * time.Second,
-	}
+	c := cli{}
 
-	cookieJar, _ := cookiejar.New(nil)
+	var rootCmd = cobra.Command{
+		Use:              "oscli",
+		Short:            "Online SPHINX CLI",
+		Long:             `Online SPHINX CLI is a new password mananger inspired by SPHINX`,
+		PersistentPreRun: c.loadConfigRun,
+	}
+	rootCmd.PersistentFlags().String("config", defaultConfigPath(), "config file written by 'oscli init', defaults to $OSCLI_CONFIG")
+	rootCmd.PersistentFlags().Duration("timeout", 30*time.Second, "timeout of a command including all its requests to the service")
+	rootCmd.PersistentFlags().String("profile", "", "profile of the service, defaults to $OSCLI_PROFILE or the default profile of the config file")
+	rootCmd.PersistentFlags().String("socket", "", "socket of the oscli agent, defaults to $OSCLI_AGENT_SOCK or ~/.oscli/agent[-<profile>].sock")
+
+	var agentCmd = &cobra.Command{
+		Use:   "agent",
+		Short: "Run the agent holding the session of oscli",
+		Long:  `Run the agent holding the session of oscli in the foreground. The session is kept in memory locked against swapping and wiped on logout, after the idle timeout and on exit. All other commands require a running agent.`,
+		Run:   c.agentRun,
+	}
+	addProfileFlags(agentCmd)
+	addIdleFlag(agentCmd)
+
+	var initCmd = &cobra.Command{
+		Use:   "init",
+		Short: "Write the config file of oscli",
+		Long:  `Write the config file of oscli with the timeouts and the default profile, i.e. the URL, hash function and group size of the service, the TLS settings and the user store, given by flags or environment variables, e.g. OSCLI_SERVER_URL. The config is validated before it is written. Add further profiles with 'oscli profile add'.`,
+		Run:   c.initRun,
+		// init writes the config file instead of loading it
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	}
+	addProfileFlags(initCmd)
+	addIdleFlag(initCmd)
+	initCmd.Flags().Bool("force", false, "overwrite an existing config file")
+
+	var lockCmd = &cobra.Command{
+		Use:   "lock",
+		Short: "Lock the agent with a passphrase",
+		Long:  `Lock the agent with a passphrase. A locked agent refuses all commands but unlock and logout.`,
+		Run:   c.lockRun,
+	}
+	addPasswordFlags(lockCmd)
 
-	c := cli{
-		client.New(
-			&http.Client{
-				Jar:       cookieJar,
-				Transport: tr,
-			},
-			getConfiguration(),
-			client.NewSQLiteUserRepository("~/.oscli.sqli.db"),
-		),
+	var unlockCmd = &cobra.Command{
+		Use:   "unlock",
+		Short: "Unlock the agent with the passphrase of lock",
+		Long:  `Unlock the agent with the passphrase of lock`,
+		Run:   c.unlockRun,
 	}
+	addPasswordFlags(unlockCmd)
 
-	var rootCmd = cobra.Command{
-		Use:   "oscli",
-		Short: "Online SPHINX CLI",
-		Long:  `Online SPHINX CLI is a new password mananger inspired by SPHINX`,
+	var statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show whether the agent holds a session and is locked",
+		Long:  `Show whether the agent holds a session and is locked`,
+		Run:   c.statusRun,
 	}
 
 	var registerCmd = &cobra.Command{
 		Use:   "register <username>",
 		Short: "Registers a new user to Online SPHINX",
-		Long:  `Registers a New User to Online SPHINX`,
+		Long:  `Registers a New User to Online SPHINX. The master password is read from a no-echo prompt of the terminal, stdin, a file descriptor or a pinentry program.`,
 		Run:   c.registerRun,
 	}
+	addPasswordFlags(registerCmd)
+
+	var enrollCmd = &cobra.Command{
+		Use:   "enroll <username>",
+		Short: "Enroll an user registered before the authenticated login",
+		Long:  `Enroll an user registered before the authenticated login, who can not login otherwise, with the one-time code the operator of the service issued for the client ID of the user. Without --code the client ID to ask for is printed. The master password is read like at register and has to be the one the domains were added with.`,
+		Run:   c.enrollRun,
+	}
+	addPasswordFlags(enrollCmd)
+	enrollCmd.Flags().String("code", "", "enrollment code issued by the operator of the service")
 
 	var loginCmd = &cobra.Command{
-		Use:   "login <username> <password>",
+		Use:   "login <username>",
 		Short: "Login with an existing user to Online SPHINX",
-		Long:  `Login with an existing user to Online SPHINX. WARNING: Shoulder Surfing, Bash History !!! SHOULD BE IMPROVED !!!`,
+		Long:  `Login with an existing user to Online SPHINX. The master password is read from a no-echo prompt of the terminal, stdin, a file descriptor or a pinentry program.`,
 		Run:   c.loginRun,
 	}
+	addPasswordFlags(loginCmd)
 
 	var logoutCmd = &cobra.Command{
 		Use:   "logout",
-		Short: "Logout deletes the associated session cookie",
-		Long:  "Logout deletes the associated session cookie",
+		Short: "Logout ends the session and wipes it from the agent",
+		Long:  "Logout ends the session at the service and wipes it from the agent, also if the agent is locked",
 		Run:   c.logoutRun,
 	}
 
 	var addCmd = &cobra.Command{
 		Use:   "add <domain>",
 		Short: "Add a new domain to Online SPHINX",
-		Long:  `Add a new domain to Online SPHINX`,
+		Long:  `Add a new domain to Online SPHINX with the password policy of the domain. Character classes are given as letters l(ower), u(pper), d(igits) and s(ymbols).`,
 		Run:   c.addRun,
 	}
+	defaultPolicy := client.DefaultPolicy()
+	addCmd.Flags().Int("length", defaultPolicy.Length, "length of the password")
+	addCmd.Flags().Int("max-length", 0, "maximum password length accepted by the domain")
+	addCmd.Flags().String("allow", defaultPolicy.Allowed, "allowed character classes")
+	addCmd.Flags().String("require", defaultPolicy.Required, "required character classes")
+	addCmd.Flags().String("symbols", client.DefaultSymbols, "symbols accepted by the domain")
 
 	var getCmd = &cobra.Command{
 		Use:   "get <domain>",
@@ -89,69 +142,318 @@ func newCommand() *cobra.Command {
 		Run:   c.getRun,
 	}
 
+	getCmd.Flags().Bool("previous", false, "get the password before the last rotation")
+
+	var rotateCmd = &cobra.Command{
+		Use:   "rotate <domain>",
+		Short: "Rotate the password of a domain",
+		Long:  `Rotate the password of a domain. The previous password stays retrievable with 'get --previous' during a grace period to change it at the domain.`,
+		Run:   c.rotateRun,
+	}
+
+	var listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the domains of the user",
+		Long:  `List the domains of the user, optionally filtered by a shell pattern, e.g. '*.example.com', and sorted by name or by domain, which puts subdomains after their parent domain.`,
+		Run:   c.listRun,
+	}
+	listCmd.Flags().String("filter", "", "shell pattern the domains have to match, ignoring case")
+	listCmd.Flags().String("sort", "name", "order of the domains, one of name or domain")
+	listCmd.Flags().Bool("reverse", false, "reverse the order")
+
+	var searchCmd = &cobra.Command{
+		Use:   "search <query>",
+		Short: "Search domains by a fuzzy query",
+		Long:  `Search domains containing the characters of the query in order, ignoring case, e.g. 'gml' finds 'gmail.com'. The best matches come first.`,
+		Run:   c.searchRun,
+	}
+	searchCmd.Flags().Int("limit", 10, "maximum number of matches, 0 shows all")
+
+	var removeCmd = &cobra.Command{
+		Use:   "remove <domain>",
+		Short: "Remove a domain from Online SPHINX",
+		Long:  `Remove a domain from Online SPHINX after confirmation. WARNING: its passwords can not be derived anymore, adding the domain again derives new ones !!!`,
+		Run:   c.removeRun,
+	}
+	removeCmd.Flags().Bool("yes", false, "remove without asking for confirmation")
+
+	var migrateCmd = &cobra.Command{
+		Use:   "migrate <username>",
+		Short: "Migrate an existing user to the current password hashing",
+		Long:  `Migrate an existing user to the current password hashing. WARNING: all passwords change, get the passwords of all domains before and change them afterwards !!!`,
+		Run:   c.migrateRun,
+	}
+
+	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(registerCmd)
+	rootCmd.AddCommand(enrollCmd)
 	rootCmd.AddCommand(loginCmd)
 	rootCmd.AddCommand(logoutCmd)
 	rootCmd.AddCommand(addCmd)
 	rootCmd.AddCommand(getCmd)
+	rootCmd.AddCommand(rotateCmd)
+	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(removeCmd)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(agentCmd)
+	rootCmd.AddCommand(lockCmd)
+	rootCmd.AddCommand(unlockCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(c.newSessionsCommand())
+	rootCmd.AddCommand(c.newProfileCommand())
 
 	return &rootCmd
 
 }
 
-func getConfiguration() client.Configuration {
-	config := viper.New()
+// context returns the context of a command run, which is cancelled after the timeout of cmd.
+func (c *cli) context(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.config.timeout)
+}
+
+// loadConfigRun loads and validates the config of cmd and exits on errors.
+func (c *cli) loadConfigRun(cmd *cobra.Command, args []string) {
+	cfg, err := loadConfig(cmd, true)
+	if err == nil {
+		err = cfg.validate()
+	}
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println("fix the config file or write a new one with 'oscli init --force'")
+		os.Exit(-1)
+	}
+	c.config = cfg
+}
+
+func (c *cli) initRun(cmd *cobra.Command, args []string) {
+	path, _ := cmd.Flags().GetString("config")
+	force, _ := cmd.Flags().GetBool("force")
+	if _, err := os.Stat(path); err == nil && !force {
+		fmt.Printf("config file %s exists, use --force to overwrite it\n", path)
+		os.Exit(-1)
+	}
 
+	cfg, err := loadConfig(cmd, false)
+	if err == nil {
+		err = cfg.validate()
+	}
+	if err == nil {
+		err = writeConfigFile(path, map[string]interface{}{
+			"default-profile": cfg.profile,
+			"profiles":        map[string]interface{}{cfg.profile: cfg.profileSettings()},
+			"timeout":         cfg.timeoutSettings(),
+		})
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	if cfg.tlsInsecure {
+		fmt.Println("WARNING: the certificate of the service is not verified, use it for testing only")
+	}
+	fmt.Printf("config written to %s\n", path)
+}
+
+// socket returns the socket of the agent of cmd, i.e. --socket, $OSCLI_AGENT_SOCK
+// or ~/.oscli/agent.sock of the default profile and ~/.oscli/agent-<profile>.sock of others.
+func (c *cli) socket(cmd *cobra.Command) string {
+	if path, _ := cmd.Flags().GetString("socket"); path != "" {
+		return path
+	}
+	if path := os.Getenv("OSCLI_AGENT_SOCK"); path != "" {
+		return path
+	}
+
+	name := "agent.sock"
+	if c.config.profile != defaultProfile {
+		name = "agent-" + c.config.profile + ".sock"
+	}
 	home, err := homedir.Dir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "oscli", name)
+	}
+	return filepath.Join(home, ".oscli", name)
+}
+
+// dial connects to the agent of cmd and exits if it is not running.
+func (c *cli) dial(cmd *cobra.Command) *agent.Client {
+	clt, err := agent.Dial(c.socket(cmd))
 	if err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		fmt.Printf("start the agent with 'oscli agent --profile %s &'\n", c.config.profile)
+		os.Exit(-1)
 	}
+	return clt
+}
 
-	config.AddConfigPath(home)
-	config.SetConfigName(".osctl")
-	config.ReadInConfig()
+// newClient returns the Online SPHINX client configured by cfg whose session the agent holds.
+func newClient(cfg config) (*client.Client, error) {
+	repo, err := client.NewFileUserRepository(cfg.users)
+	if err != nil {
+		return nil, err
+	}
+	conf, err := cfg.clientConfiguration()
+	if err != nil {
+		return nil, err
+	}
+	tc, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
 
-	return client.Configuration{}
+	cookieJar, _ := cookiejar.New(nil)
+	return client.New(
+		&http.Client{
+			Jar:       cookieJar,
+			Transport: &http.Transport{IdleConnTimeout: 30 * time.Second, TLSClientConfig: tc},
+		},
+		conf,
+		repo,
+	), nil
 }
 
-func initRun(cmd *cobra.Command, args []string) {
+func (c *cli) agentRun(cmd *cobra.Command, args []string) {
+	socket := c.socket(cmd)
+	clt, err := newClient(c.config)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
 
-	config := viper.New()
-	config.Set("app.name", "online-sphinx")
-	config.Set("app.version", "0.1.0")
+	l, err := agent.Listen(socket)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
+	a := agent.New(clt, c.config.idle)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	stopped := make(chan struct{})
+	go func() {
+		<-sig
+		close(stopped)
+		l.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "oscli agent of profile %s listening on %s\n", c.config.profile, socket)
+	err = a.Serve(l)
+	a.Close()
+	select {
+	case <-stopped:
+	default:
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+}
+
+func (c *cli) lockRun(cmd *cobra.Command, args []string) {
+	c.passphraseRun(cmd, args, prompt{label: "Passphrase", confirm: true}, (*agent.Client).Lock)
+}
 
-	config.Set("client.id", "client")
-	config.Set("client.protocol.bit", 8)
+func (c *cli) unlockRun(cmd *cobra.Command, args []string) {
+	c.passphraseRun(cmd, args, prompt{label: "Passphrase"}, (*agent.Client).Unlock)
+}
+
+func (c *cli) passphraseRun(cmd *cobra.Command, args []string, p prompt, fn func(*agent.Client, context.Context, string) error) {
+	if len(args) > 1 {
+		cmd.Help()
+		os.Exit(-1)
+	}
+
+	clt := c.dial(cmd)
+	defer clt.Close()
+	passphrase := c.password(cmd, args, 0, p)
+	ctx, cancel := c.context(cmd)
+	defer cancel()
 
-	k, err := rand.Int(rand.Reader, big.NewInt(256))
+	err := fn(clt, ctx, passphrase)
 	if err != nil {
-		log.Fatal(err)
+		fmt.Println(err)
+		os.Exit(-1)
 	}
-	config.Set("client.secret.k", k.Text(16))
-	config.Set("client.protocol.hash", "sha256")
+}
 
-	q, err := rand.Prime(rand.Reader, 8)
+// password reads the password of cmd before the timeout of cmd starts and exits on errors.
+func (c *cli) password(cmd *cobra.Command, args []string, n int, p prompt) string {
+	pwd, err := password(cmd, args, n, p)
 	if err != nil {
-		log.Fatal(err)
+		fmt.Println(err)
+		os.Exit(-1)
 	}
-	config.Set("client.protocol.q", q.Text(16))
+	return pwd
+}
 
-	config.Set("server.address", ":8080")
+func (c *cli) statusRun(cmd *cobra.Command, args []string) {
+	clt := c.dial(cmd)
+	defer clt.Close()
+	ctx, cancel := c.context(cmd)
+	defer cancel()
 
-	err = config.WriteConfig()
+	status, err := clt.Status(ctx)
 	if err != nil {
 		fmt.Println(err)
+		os.Exit(-1)
 	}
+	fmt.Printf("logged in: %v locked: %v\n", status.LoggedIn, status.Locked)
 }
 
 func (c *cli) registerRun(cmd *cobra.Command, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Help()
+		os.Exit(-1)
+	}
+
+	clt := c.dial(cmd)
+	defer clt.Close()
+	pwd := c.password(cmd, args, 1, prompt{label: "Master password", confirm: true})
+	ctx, cancel := c.context(cmd)
+	defer cancel()
+
+	err := clt.Register(ctx, args[0], pwd)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+}
+
+func (c *cli) enrollRun(cmd *cobra.Command, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Help()
+		os.Exit(-1)
+	}
+
+	clt := c.dial(cmd)
+	defer clt.Close()
+	// without code Enroll fails with the client ID to ask the operator for, so skip the prompt
+	code, _ := cmd.Flags().GetString("code")
+	var pwd string
+	if code != "" {
+		pwd = c.password(cmd, args, 1, prompt{label: "Master password", confirm: true})
+	}
+	ctx, cancel := c.context(cmd)
+	defer cancel()
+
+	err := clt.Enroll(ctx, args[0], pwd, code)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+}
+
+func (c *cli) migrateRun(cmd *cobra.Command, args []string) {
 	if len(args) != 1 {
 		cmd.Help()
 		os.Exit(-1)
 	}
 
-	err := c.clt.Register(args[0])
+	clt := c.dial(cmd)
+	defer clt.Close()
+	ctx, cancel := c.context(cmd)
+	defer cancel()
+
+	err := clt.Migrate(ctx, args[0])
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(-1)
@@ -159,12 +461,18 @@ func (c *cli) registerRun(cmd *cobra.Command, args []string) {
 }
 
 func (c *cli) loginRun(cmd *cobra.Command, args []string) {
-	if len(args) != 2 {
+	if len(args) < 1 || len(args) > 2 {
 		cmd.Help()
 		os.Exit(-1)
 	}
 
-	err := c.clt.Login(args[0], args[1])
+	clt := c.dial(cmd)
+	defer clt.Close()
+	pwd := c.password(cmd, args, 1, prompt{label: "Master password"})
+	ctx, cancel := c.context(cmd)
+	defer cancel()
+
+	err := clt.Login(ctx, args[0], pwd)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(-1)
@@ -177,7 +485,19 @@ func (c *cli) addRun(cmd *cobra.Command, args []string) {
 		os.Exit(-1)
 	}
 
-	err := c.clt.Add(args[0])
+	var p client.Policy
+	p.Length, _ = cmd.Flags().GetInt("length")
+	p.MaxLength, _ = cmd.Flags().GetInt("max-length")
+	p.Allowed, _ = cmd.Flags().GetString("allow")
+	p.Required, _ = cmd.Flags().GetString("require")
+	p.Symbols, _ = cmd.Flags().GetString("symbols")
+
+	clt := c.dial(cmd)
+	defer clt.Close()
+	ctx, cancel := c.context(cmd)
+	defer cancel()
+
+	err := clt.AddWithPolicy(ctx, args[0], p)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(-1)
@@ -190,7 +510,17 @@ func (c *cli) getRun(cmd *cobra.Command, args []string) {
 		os.Exit(-1)
 	}
 
-	pwd, err := c.clt.Get(args[0])
+	clt := c.dial(cmd)
+	defer clt.Close()
+	get := clt.Get
+	if previous, _ := cmd.Flags().GetBool("previous"); previous {
+		get = clt.GetPrevious
+	}
+
+	ctx, cancel := c.context(cmd)
+	defer cancel()
+
+	pwd, err := get(ctx, args[0])
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(-1)
@@ -198,8 +528,32 @@ func (c *cli) getRun(cmd *cobra.Command, args []string) {
 	fmt.Println(pwd)
 }
 
+func (c *cli) rotateRun(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		cmd.Help()
+		os.Exit(-1)
+	}
+
+	clt := c.dial(cmd)
+	defer clt.Close()
+	ctx, cancel := c.context(cmd)
+	defer cancel()
+
+	previousUntil, err := clt.Rotate(ctx, args[0])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	fmt.Printf("previous password retrievable with 'oscli get --previous %s' until %s\n", args[0], previousUntil.Local().Format(time.RFC1123))
+}
+
 func (c *cli) logoutRun(cmd *cobra.Command, args []string) {
-	err := c.clt.Logout()
+	clt := c.dial(cmd)
+	defer clt.Close()
+	ctx, cancel := c.context(cmd)
+	defer cancel()
+
+	err := clt.Logout(ctx)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(-1)
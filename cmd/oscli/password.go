@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/pinentry"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// prompt describes the password a command asks for.
+type prompt struct {
+	// label of the password, e.g. "Master password".
+	label string
+	// confirm asks twice for new passwords.
+	confirm bool
+}
+
+// addPasswordFlags adds the flags choosing the source of the password of cmd,
+// which is read from a no-echo prompt of the terminal by default.
+func addPasswordFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("password-stdin", false, "read the password from the first line of stdin")
+	cmd.Flags().Int("password-fd", -1, "read the password from the first line of the file descriptor")
+	cmd.Flags().String("pinentry", os.Getenv("OSCLI_PINENTRY"), "read the password by a pinentry program, e.g. pinentry-curses, defaults to $OSCLI_PINENTRY")
+	cmd.Flags().Bool("unsafe-password-arg", false, "accept the password as argument, which leaks it to the shell history and the process list")
+}
+
+// password returns args[n] if allowed by --unsafe-password-arg,
+// otherwise the password read from the source chosen by the flags of cmd.
+func password(cmd *cobra.Command, args []string, n int, p prompt) (string, error) {
+	if len(args) > n {
+		if unsafe, _ := cmd.Flags().GetBool("unsafe-password-arg"); !unsafe {
+			return "", errors.New("passing the password as argument leaks it to the shell history and the process list, use --unsafe-password-arg to do it anyway")
+		}
+		return args[n], nil
+	}
+
+	stdin, _ := cmd.Flags().GetBool("password-stdin")
+	fd, _ := cmd.Flags().GetInt("password-fd")
+	program, _ := cmd.Flags().GetString("pinentry")
+
+	var pwd string
+	var err error
+	switch {
+	case stdin:
+		pwd, err = readLine(os.Stdin)
+	case fd >= 0:
+		pwd, err = readLine(os.NewFile(uintptr(fd), "password-fd"))
+	case program != "":
+		pwd, err = pinentry.GetPin(program, pinentryOptions(p))
+	default:
+		pwd, err = readTerminal(p)
+	}
+	if err != nil {
+		return "", err
+	}
+	if pwd == "" {
+		return "", errors.Errorf("%s must not be empty", p.label)
+	}
+	return pwd, nil
+}
+
+// readLine returns the first line of r without line break.
+func readLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", errors.Wrap(err, "failed to read password")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readTerminal reads the password from the terminal without echo.
+func readTerminal(p prompt) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", errors.New("stdin is not a terminal, use --password-stdin, --password-fd or --pinentry")
+	}
+
+	read := func(label string) (string, error) {
+		fmt.Fprintf(os.Stderr, "%s: ", label)
+		pwd, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(pwd), errors.Wrap(err, "failed to read password")
+	}
+
+	pwd, err := read(p.label)
+	if err != nil || !p.confirm {
+		return pwd, err
+	}
+	repeated, err := read("Repeat " + strings.ToLower(p.label))
+	if err != nil {
+		return "", err
+	}
+	if repeated != pwd {
+		return "", errors.New("passwords do not match")
+	}
+	return pwd, nil
+}
+
+func pinentryOptions(p prompt) pinentry.Options {
+	opts := pinentry.Options{
+		Title:       "oscli",
+		Description: "Enter the " + strings.ToLower(p.label) + " of Online SPHINX",
+		Prompt:      p.label + ":",
+	}
+	if p.confirm {
+		opts.Repeat = "Repeat:"
+	}
+	return opts
+}
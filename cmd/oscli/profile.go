@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// newProfileCommand returns the profile command managing the profiles of the config file.
+func (c *cli) newProfileCommand() *cobra.Command {
+	var profileCmd = &cobra.Command{
+		Use:   "profile",
+		Short: "Manage the profiles of the services",
+		Long:  `Manage the profiles of the services, e.g. staging and production. Each profile has its own server, hash function, group size, TLS trust and user store. Commands use the default profile unless --profile or $OSCLI_PROFILE choose another one.`,
+		// profile edits the config file instead of loading it
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	}
+
+	var listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the profiles, the default one marked by '*'",
+		Long:  `List the profiles and their services, the default one marked by '*'`,
+		Run:   c.profileListRun,
+	}
+
+	var addCmd = &cobra.Command{
+		Use:   "add <name>",
+		Short: "Add a profile to the config file",
+		Long:  `Add a profile with the service given by flags or environment variables, e.g. OSCLI_SERVER_URL, to the config file. The profile is validated before it is written. The first profile becomes the default.`,
+		Run:   c.profileAddRun,
+	}
+	addProfileFlags(addCmd)
+	addCmd.Flags().Bool("force", false, "overwrite an existing profile")
+
+	var removeCmd = &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove a profile from the config file",
+		Long:  `Remove a profile from the config file. Its user store is kept. The default profile can not be removed, use another one first.`,
+		Run:   c.profileRemoveRun,
+	}
+
+	var useCmd = &cobra.Command{
+		Use:   "use <name>",
+		Short: "Make a profile the default",
+		Long:  `Make a profile the default of all commands without --profile`,
+		Run:   c.profileUseRun,
+	}
+
+	profileCmd.AddCommand(listCmd)
+	profileCmd.AddCommand(addCmd)
+	profileCmd.AddCommand(removeCmd)
+	profileCmd.AddCommand(useCmd)
+	return profileCmd
+}
+
+// configFile are the settings of the config file without overrides.
+type configFile struct {
+	path     string
+	settings map[string]interface{}
+	profiles map[string]interface{}
+}
+
+// openConfigFile reads the config file of cmd.
+func openConfigFile(cmd *cobra.Command) (*configFile, error) {
+	path, _ := cmd.Flags().GetString("config")
+	v, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &configFile{path: path, settings: v.AllSettings()}
+	if f.settings["profiles"] == nil {
+		f.settings["profiles"] = map[string]interface{}{}
+	}
+	ps, ok := f.settings["profiles"].(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("invalid profiles in config %s", path)
+	}
+	f.profiles = ps
+	return f, nil
+}
+
+// defaultProfile returns the name of the default profile, if any.
+func (f *configFile) defaultProfile() string {
+	name, _ := f.settings["default-profile"].(string)
+	return name
+}
+
+// server returns the URL of the service of profile name.
+func (f *configFile) server(name string) string {
+	p, _ := f.profiles[name].(map[string]interface{})
+	server, _ := p["server"].(map[string]interface{})
+	url, _ := server["url"].(string)
+	return url
+}
+
+// write writes the settings back to the config file.
+func (f *configFile) write() error {
+	return writeConfigFile(f.path, f.settings)
+}
+
+// profileArg returns the name of the profile in args and exits if it is missing or invalid.
+func profileArg(cmd *cobra.Command, args []string) string {
+	if len(args) != 1 {
+		cmd.Help()
+		os.Exit(-1)
+	}
+	if !profileName.MatchString(args[0]) {
+		fmt.Printf("invalid profile name %q, want lower case letters, digits, '-' and '_'\n", args[0])
+		os.Exit(-1)
+	}
+	return args[0]
+}
+
+func (c *cli) profileListRun(cmd *cobra.Command, args []string) {
+	f, err := openConfigFile(cmd)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
+	names := make([]string, 0, len(f.profiles))
+	for name := range f.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		mark := " "
+		if name == f.defaultProfile() {
+			mark = "*"
+		}
+		fmt.Printf("%s %s\t%s\n", mark, name, f.server(name))
+	}
+}
+
+func (c *cli) profileAddRun(cmd *cobra.Command, args []string) {
+	name := profileArg(cmd, args)
+	if err := cmd.Flags().Set("profile", name); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
+	f, err := openConfigFile(cmd)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	if force, _ := cmd.Flags().GetBool("force"); f.profiles[name] != nil && !force {
+		fmt.Printf("profile %s exists, use --force to overwrite it\n", name)
+		os.Exit(-1)
+	}
+
+	cfg, err := loadConfig(cmd, false)
+	if err == nil {
+		err = cfg.validate()
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+
+	f.profiles[name] = cfg.profileSettings()
+	if f.defaultProfile() == "" {
+		f.settings["default-profile"] = name
+	}
+	if err := f.write(); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	if cfg.tlsInsecure {
+		fmt.Println("WARNING: the certificate of the service is not verified, use it for testing only")
+	}
+}
+
+func (c *cli) profileRemoveRun(cmd *cobra.Command, args []string) {
+	name := profileArg(cmd, args)
+	f, err := openConfigFile(cmd)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	if f.profiles[name] == nil {
+		fmt.Printf("profile %s not found\n", name)
+		os.Exit(-1)
+	}
+	if f.defaultProfile() == name {
+		fmt.Printf("profile %s is the default, use another one first\n", name)
+		os.Exit(-1)
+	}
+
+	delete(f.profiles, name)
+	if err := f.write(); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+}
+
+func (c *cli) profileUseRun(cmd *cobra.Command, args []string) {
+	name := profileArg(cmd, args)
+	f, err := openConfigFile(cmd)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	if f.profiles[name] == nil {
+		fmt.Printf("profile %s not found\n", name)
+		os.Exit(-1)
+	}
+
+	f.settings["default-profile"] = name
+	if err := f.write(); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func (c *cli) newSessionsCommand() *cobra.Command {
+	var sessionsCmd = &cobra.Command{
+		Use:   "sessions",
+		Short: "List the sessions of the user, the one of the agent marked by '*'",
+		Long:  `List the active sessions of the user at the service with their creation and last use, the one of the agent marked by '*'`,
+		Run:   c.sessionsRun,
+	}
+
+	var revokeCmd = &cobra.Command{
+		Use:   "revoke [<id>]",
+		Short: "Revoke a session of the user",
+		Long:  `Revoke a session of the user, e.g. of a lost device, or all sessions with --all. Revoking all sessions also ends the one of the agent, login again afterwards.`,
+		Run:   c.sessionsRevokeRun,
+	}
+	revokeCmd.Flags().Bool("all", false, "revoke all sessions of the user")
+
+	sessionsCmd.AddCommand(revokeCmd)
+	return sessionsCmd
+}
+
+func (c *cli) sessionsRun(cmd *cobra.Command, args []string) {
+	if len(args) != 0 {
+		cmd.Help()
+		os.Exit(-1)
+	}
+
+	clt := c.dial(cmd)
+	defer clt.Close()
+	ctx, cancel := c.context(cmd)
+	defer cancel()
+
+	sessions, err := clt.Sessions(ctx)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+	for _, s := range sessions {
+		mark := " "
+		if s.Current {
+			mark = "*"
+		}
+		fmt.Printf("%s %s  created %s  last seen %s\n", mark, s.ID,
+			s.Created.Local().Format(time.RFC3339), s.LastSeen.Local().Format(time.RFC3339))
+	}
+}
+
+func (c *cli) sessionsRevokeRun(cmd *cobra.Command, args []string) {
+	all, _ := cmd.Flags().GetBool("all")
+	if all == (len(args) == 1) || len(args) > 1 {
+		cmd.Help()
+		os.Exit(-1)
+	}
+
+	clt := c.dial(cmd)
+	defer clt.Close()
+	ctx, cancel := c.context(cmd)
+	defer cancel()
+
+	var err error
+	if all {
+		err = clt.RevokeAllSessions(ctx)
+	} else {
+		err = clt.RevokeSession(ctx, args[0])
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+}
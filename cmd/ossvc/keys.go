@@ -0,0 +1,281 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/crypto"
+	"github.com/pkg/errors"
+)
+
+// keys contains the long term key material of an Online SPHINX service.
+type keys struct {
+	group crypto.Group // group all exponentiations happen in
+	id    *big.Int     // service ID
+	k     *big.Int     // service key k
+	q0    *big.Int     // common ElGammal component Q_0
+}
+
+type keyFile struct {
+	Group  string `json:"group"`
+	GroupQ string `json:"groupQ,omitempty"`
+	ID     string `json:"id"`
+	K      string `json:"k"`
+	Q0     string `json:"q0"`
+}
+
+// customGroup is the name of a group generated by ossvc and persisted in the key file.
+const customGroup = "custom"
+
+// standardGroups maps key lengths to vetted groups.
+var standardGroups = map[int]string{
+	1536: "modp1536",
+	2048: "ffdhe2048",
+	3072: "ffdhe3072",
+	4096: "ffdhe4096",
+}
+
+// loadOrGenerateKeys returns the key material of the service.
+// Values given in hex take precedence, missing values are loaded from the sealed key file
+// or generated and written to the key file afterwards.
+// Without a key file generated values are lost on restart.
+func loadOrGenerateKeys(groupName, idhex, khex, q0hex string, keyLength int, path string, passphrase []byte) (keys, bool, error) {
+	var stored keyFile
+	var exists bool
+	if path != "" {
+		var err error
+		stored, exists, err = readKeyFile(path, passphrase)
+		if err != nil {
+			return keys{}, false, err
+		}
+	}
+
+	var ks keys
+	var err error
+	var generated bool
+	ks.group, generated, err = loadOrGenerateGroup(groupName, stored, keyLength)
+	if err != nil {
+		return keys{}, false, err
+	}
+
+	for _, v := range []struct {
+		name     string
+		hex      string
+		stored   string
+		dst      **big.Int
+		generate func() (*big.Int, error)
+		validate func(*big.Int) error
+	}{
+		{"id", idhex, stored.ID, &ks.id,
+			func() (*big.Int, error) { return generateKey(keyLength) },
+			func(n *big.Int) error { return validateKey(n, keyLength) }},
+		{"k", khex, stored.K, &ks.k,
+			ks.group.RandomScalar,
+			func(n *big.Int) error { return validateScalar(n, ks.group) }},
+		{"q0", q0hex, stored.Q0, &ks.q0,
+			ks.group.RandomElement,
+			func(n *big.Int) error { return validateElement(n, ks.group) }},
+	} {
+		h := v.hex
+		if h == "" {
+			h = v.stored
+		}
+
+		var n *big.Int
+		if h == "" {
+			n, err = generateValid(v.generate, v.validate)
+			generated = true
+		} else {
+			n, err = parseKey(h)
+		}
+		if err != nil {
+			return keys{}, false, errors.Wrapf(err, "key %s", v.name)
+		}
+
+		if err := v.validate(n); err != nil {
+			return keys{}, false, errors.Wrapf(err, "key %s", v.name)
+		}
+		*v.dst = n
+	}
+
+	if path != "" && (generated || !exists) {
+		kf := keyFile{Group: ks.group.Name(), ID: ks.id.Text(16), K: ks.k.Text(16), Q0: ks.q0.Text(16)}
+		if ks.group.Name() == customGroup {
+			kf.GroupQ = ks.group.Order().Text(16)
+		}
+		err := writeKeyFile(path, passphrase, kf)
+		if err != nil {
+			return keys{}, false, err
+		}
+	}
+
+	return ks, generated, nil
+}
+
+// loadOrGenerateGroup returns the configured or stored group, the vetted group of keyLength bits
+// or generates a custom group for key lengths without a vetted group.
+func loadOrGenerateGroup(name string, stored keyFile, keyLength int) (crypto.Group, bool, error) {
+	if name == "" {
+		name = stored.Group
+	}
+	if name == "" {
+		var ok bool
+		name, ok = standardGroups[keyLength]
+		if !ok {
+			name = customGroup
+		}
+	}
+
+	if name != customGroup {
+		g, err := crypto.GroupByName(name)
+		return g, false, err
+	}
+
+	if stored.GroupQ != "" {
+		q, err := parseKey(stored.GroupQ)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "group q")
+		}
+		g, err := crypto.NewGroup(customGroup, q)
+		return g, false, err
+	}
+
+	g, err := crypto.GenerateGroup(customGroup, keyLength)
+	return g, true, err
+}
+
+// generateKey returns a random integer of exactly keyLength bits.
+func generateKey(keyLength int) (*big.Int, error) {
+	if keyLength < 2 {
+		return nil, errors.Errorf("key length %d too short", keyLength)
+	}
+	half := new(big.Int).Lsh(big.NewInt(1), uint(keyLength-1))
+	n, err := rand.Int(rand.Reader, half)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate random key")
+	}
+	return n.Add(n, half), nil
+}
+
+// generateValid generates values until one passes validation,
+// which only matters for tiny test groups.
+func generateValid(generate func() (*big.Int, error), validate func(*big.Int) error) (*big.Int, error) {
+	for {
+		n, err := generate()
+		if err != nil {
+			return nil, err
+		}
+		if validate(n) == nil {
+			return n, nil
+		}
+	}
+}
+
+func parseKey(h string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(h, 16)
+	if !ok {
+		return nil, errors.Errorf("invalid hex value %q", h)
+	}
+	return n, nil
+}
+
+// validateKey refuses degenerate values like 0 or 1, as well as values
+// much shorter or longer than keyLength bits.
+func validateKey(n *big.Int, keyLength int) error {
+	if n.Sign() <= 0 || n.Cmp(big.NewInt(1)) == 0 {
+		return errors.Errorf("degenerate value %v", n)
+	}
+	if n.BitLen() < keyLength/2 {
+		return errors.Errorf("weak value with %d bits, want at least %d bits", n.BitLen(), keyLength/2)
+	}
+	if n.BitLen() > keyLength {
+		return errors.Errorf("value with %d bits exceeds key length of %d bits", n.BitLen(), keyLength)
+	}
+	return nil
+}
+
+// validateScalar refuses degenerate exponents and exponents much shorter than q.
+func validateScalar(n *big.Int, g crypto.Group) error {
+	q := g.Order()
+	if n.Cmp(big.NewInt(1)) <= 0 || n.Cmp(q) >= 0 {
+		return errors.Errorf("value not within [2, q) of group %s", g.Name())
+	}
+	if n.BitLen() < q.BitLen()/2 {
+		return errors.Errorf("weak value with %d bits, want at least %d bits", n.BitLen(), q.BitLen()/2)
+	}
+	return nil
+}
+
+// validateElement refuses values outside of the prime order subgroup.
+func validateElement(n *big.Int, g crypto.Group) error {
+	if !g.IsElement(n) {
+		return errors.Errorf("value is not an element of group %s", g.Name())
+	}
+	return nil
+}
+
+func readKeyFile(path string, passphrase []byte) (keyFile, bool, error) {
+	sealed, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return keyFile{}, false, nil
+	}
+	if err != nil {
+		return keyFile{}, false, errors.Wrapf(err, "failed to read key file %s", path)
+	}
+
+	buf, err := crypto.Open(passphrase, sealed)
+	if err != nil {
+		return keyFile{}, false, errors.Wrapf(err, "failed to open key file %s", path)
+	}
+
+	var kf keyFile
+	if err := json.Unmarshal(buf, &kf); err != nil {
+		return keyFile{}, false, errors.Wrapf(err, "failed to parse key file %s", path)
+	}
+	return kf, true, nil
+}
+
+// writeKeyFile seals and replaces the key file atomically.
+func writeKeyFile(path string, passphrase []byte, kf keyFile) error {
+	if len(passphrase) == 0 {
+		return errors.Errorf("refuse to write key file %s without passphrase", path)
+	}
+
+	buf, err := json.Marshal(kf)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal keys")
+	}
+
+	sealed, err := crypto.Seal(passphrase, buf)
+	if err != nil {
+		return errors.Wrap(err, "failed to seal keys")
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temporary key file for %s", path)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "failed to chmod %s", tmp.Name())
+	}
+	if _, err := tmp.Write(sealed); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "failed to write %s", tmp.Name())
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "failed to sync %s", tmp.Name())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close %s", tmp.Name())
+	}
+
+	return errors.Wrapf(os.Rename(tmp.Name(), path), "failed to replace key file %s", path)
+}
@@ -4,17 +4,24 @@ import (
 	"context"
 	"crypto/sha256"
 	"crypto/sha512"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"hash"
 	"math/big"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"time"
 
+	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
+	"github.com/LAtanassov/go-online-sphinx/pkg/crypto"
+	"github.com/LAtanassov/go-online-sphinx/pkg/pb"
 	"github.com/LAtanassov/go-online-sphinx/pkg/service"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	kitlog "github.com/go-kit/kit/log"
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
@@ -27,26 +34,75 @@ import (
 // Configuration represents a set of environment variables loaded at startup e.g.:
 // #!/bin/sh
 // export OSSVC_ADDR=443
+// export OSSVC_GRPCADDR=:8443
 // export OSSVC_KEYPATH=./certs/server.key
 // export OSSVC_CERTPATH=./certs/server.crt
 // export OSSVC_TIMEOUTSEC=15
-// export OSSVC_KEYLENGTH=1024
+// export OSSVC_KEYLENGTH=2048
+// export OSSVC_GROUP=ffdhe2048
 // export OSSVC_HASH=sha256
 // export OSSVC_IDHEX=1A3F1
 // export OSSVC_KHEX=AFFEE
-// export OSSVC_QHEX=BEEFF
-// export OSSVC_KHEX=AFFEE
+// export OSSVC_Q0HEX=BEEFF
+// export OSSVC_STORE=bolt # single replica only, redis for several
+// export OSSVC_STOREPATH=./ossvc.db
+// export OSSVC_REDISURL=redis://:<password>@localhost:6379/0
+// export OSSVC_KEYFILE=./ossvc.keys
+// export OSSVC_KEYFILEPASSPHRASE=...
+// export OSSVC_ROTATIONGRACE=168h
+// export OSSVC_SESSIONKEYS=<new key hex>,<old key hex>
+// export OSSVC_SESSIONIDLETIMEOUT=15m
+// export OSSVC_SESSIONLIFETIME=12h
+// export OSSVC_SESSIONPRUNEINTERVAL=5m
+// export OSSVC_ENROLLMENTVALIDITY=168h
+// export OSSVC_THROTTLECLIENTATTEMPTS=5
+// export OSSVC_THROTTLECLIENTLOCKOUTATTEMPTS=20
+// export OSSVC_THROTTLESOURCEATTEMPTS=50
+// export OSSVC_THROTTLESOURCELOCKOUTATTEMPTS=200
+// export OSSVC_THROTTLELOCKOUT=15m
+// export OSSVC_FORWARDEDFOR=X-Forwarded-For # only behind a proxy setting it
+// export OSSVC_LOGLEVEL=info
+// export OSSVC_LOGHASHKEY=<key hex>
 type Configuration struct {
 	Addr     string `default:":443"`
+	GRPCAddr string `default:":8443"`
 	KeyPath  string `default:"./certs/server.key"`
 	CertPath string `default:"./certs/server.crt"`
 
-	TimeoutSec int    `default:"15"`
-	KeyLength  int    `default:"1024"`
+	TimeoutSec int `default:"15"`
+	KeyLength  int `default:"2048"`
+	Group      string
 	Hash       string `default:"sha256"`
 	IDHex      string
 	KHex       string
 	Q0Hex      string
+
+	Store     string `default:"bolt"`
+	StorePath string `default:"./ossvc.db"`
+	RedisURL  string `default:"redis://localhost:6379/0"`
+
+	KeyFile           string
+	KeyFilePassphrase string
+
+	RotationGrace time.Duration `default:"168h"`
+
+	SessionKeys          []string
+	SessionIdleTimeout   time.Duration `default:"15m"`
+	SessionLifetime      time.Duration `default:"12h"`
+	SessionPruneInterval time.Duration `default:"5m"`
+
+	EnrollmentValidity time.Duration `default:"168h"`
+
+	ThrottleClientAttempts        int           `default:"5"`
+	ThrottleClientLockoutAttempts int           `default:"20"`
+	ThrottleSourceAttempts        int           `default:"50"`
+	ThrottleSourceLockoutAttempts int           `default:"200"`
+	ThrottleLockout               time.Duration `default:"15m"`
+
+	ForwardedFor string
+
+	LogLevel   string `default:"info"`
+	LogHashKey string
 }
 
 func main() {
@@ -65,34 +121,89 @@ func main() {
 	}
 
 	httpAddr := flag.String("ossvc.addr", c.Addr, "http listen address")
+	grpcAddr := flag.String("ossvc.grpc.addr", c.GRPCAddr, "grpc listen address, empty to disable")
 	keyPath := flag.String("ossvc.key.path", c.KeyPath, "server key path")
 	certPath := flag.String("ossvc.cert.path", c.CertPath, "server cert path")
 	timeoutSec := flag.Int("ossvc.timeout.sec", c.TimeoutSec, "http timeout in seconds")
-	keyLength := flag.Int("ossvc.key.length", c.KeyLength, "key length")
+	keyLength := flag.Int("ossvc.key.length", c.KeyLength, "key length, selects the group unless ossvc.group is set")
+	groupName := flag.String("ossvc.group", c.Group, fmt.Sprintf("group, one of %v", crypto.GroupNames()))
 	hashName := flag.String("ossvc.hash", c.Hash, "hash function")
-	idhex := flag.String("ossvc.id.hex", c.IDHex, "secret k in hex")
+	idhex := flag.String("ossvc.id.hex", c.IDHex, "service ID in hex")
 	khex := flag.String("ossvc.k.hex", c.KHex, "secret k in hex")
 	q0hex := flag.String("ossvc.q0.hex", c.Q0Hex, "secret Q0 in hex")
+	storeName := flag.String("ossvc.store", c.Store, "store: memory or bolt for a single replica, redis for several")
+	storePath := flag.String("ossvc.store.path", c.StorePath, "store path of bolt")
+	keyFilePath := flag.String("ossvc.key.file", c.KeyFile, "sealed key file to load or persist generated keys, passphrase is read from OSSVC_KEYFILEPASSPHRASE")
+	logLevel := flag.String("ossvc.log.level", c.LogLevel, "log level: error, info or debug")
+	enrollHex := flag.String("ossvc.enroll", "", "issue an enrollment code for the client ID in hex, print it and exit - stop the service first with the bolt store")
 	flag.Parse()
 
+	redactor, err := getRedactor(*logLevel, c.LogHashKey)
+	if err != nil {
+		logger.Log("err", fmt.Sprintf("%+v", errors.Wrap(err, "invalid log configuration")))
+		os.Exit(1)
+	}
+
 	hashFn := getHashBy(*hashName)
-	id := getOrGenerate(*idhex, *keyLength)
-	k := getOrGenerate(*khex, *keyLength)
-	q0 := getOrGenerate(*q0hex, *keyLength)
+	ks, generated, err := loadOrGenerateKeys(*groupName, *idhex, *khex, *q0hex, *keyLength, *keyFilePath, []byte(c.KeyFilePassphrase))
+	if err != nil {
+		logger.Log("err", fmt.Sprintf("%+v", errors.Wrap(err, "failed to load keys")))
+		os.Exit(1)
+	}
+	if generated && *keyFilePath == "" {
+		logger.Log("warn", "generated keys are not persisted, all derived passwords change on restart - configure OSSVC_KEYFILE")
+	}
 
-	logger.Log("service", "starting", "state", "configured")
+	logger.Log("service", "starting", "state", "configured", "group", ks.group.Name())
 
 	// === service layer ===
 
-	logger.Log("service", "starting")
-	users := service.NewUserRepository()
+	repos, err := getRepositories(*storeName, *storePath, c.RedisURL)
+	if err != nil {
+		logger.Log("err", fmt.Sprintf("%+v", errors.Wrap(err, "failed to open store")))
+		os.Exit(1)
+	}
+	defer repos.close()
+	repos.warn(logger)
+
+	sessionKeys, err := getSessionKeys(c.SessionKeys)
+	if err != nil {
+		logger.Log("err", fmt.Sprintf("%+v", errors.Wrap(err, "failed to load session keys")))
+		os.Exit(1)
+	}
+	if len(sessionKeys) == 0 {
+		logger.Log("warn", "generated session key is not persisted, all sessions end on restart - configure OSSVC_SESSIONKEYS")
+		sessionKeys = [][]byte{service.GenerateSessionKey()}
+	}
+	sessions, err := service.NewSessionManager(repos.sessions, service.SessionConfig{
+		Keys:        sessionKeys,
+		IdleTimeout: c.SessionIdleTimeout,
+		Lifetime:    c.SessionLifetime,
+	})
+	if err != nil {
+		logger.Log("err", fmt.Sprintf("%+v", errors.Wrap(err, "invalid session configuration")))
+		os.Exit(1)
+	}
 
 	fieldKeys := []string{"method"}
-	cfg := service.NewConfiguration(id, k, q0, big.NewInt(int64(*keyLength)), hashFn)
+	cfg, err := service.NewConfiguration(ks.id, ks.k, ks.q0, ks.group, hashFn)
+	if err != nil {
+		logger.Log("err", fmt.Sprintf("%+v", errors.Wrap(err, "invalid configuration")))
+		os.Exit(1)
+	}
+	cfg = cfg.WithGracePeriod(c.RotationGrace)
+
+	sphinx := service.New(repos.users, repos.vaults, cfg)
+	if *enrollHex != "" {
+		err := issueEnrollment(sphinx, *enrollHex, c.EnrollmentValidity)
+		repos.close()
+		exitOnError(logger, err, "failed to issue enrollment code")
+		return
+	}
 
-	var svc service.Service
-	svc = service.New(users, cfg)
-	svc = service.NewLoggingMiddleware(kitlog.With(logger, "component", "online_sphinx"))(svc)
+	var svc service.Service = sphinx
+	logger.Log("service", "starting", "pk", svc.PublicKey().Text(16))
+	svc = service.NewLoggingMiddleware(kitlog.With(logger, "component", "online_sphinx"), redactor)(svc)
 	svc = service.NewInstrumentingMiddleware(
 		kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
 			Namespace: "api",
@@ -107,27 +218,60 @@ func main() {
 			Help:      "Total duration of requests in microseconds.",
 		}, fieldKeys))(svc)
 
+	throttle := service.NewThrottle(repos.throttle, getThrottleConfig(c),
+		kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+			Namespace: "api",
+			Subsystem: "online_sphinx",
+			Name:      "throttled_count",
+			Help:      "Number of throttled logins.",
+		}, []string{"key"}))
+
 	// === transport layer ===
 
-	t := service.NewHTTPTransport(svc, kitlog.With(logger, "component", "transport"))
+	t := service.NewHTTPTransport(svc, sessions, kitlog.With(logger, "component", "transport")).
+		WithThrottle(throttle).
+		WithForwardedFor(c.ForwardedFor).
+		WithRedactor(redactor)
 
 	mux := http.NewServeMux()
 
+	mux.Handle("/v1/group", t.MakeGroupHandler())
+	mux.Handle("/v1/register/expk", t.MakeRegisterExpKHandler())
 	mux.Handle("/v1/register", t.MakeRegisterHandler())
+	mux.Handle("/v1/enroll", t.MakeEnrollHandler())
 	mux.Handle("/v1/login/expk", t.MakeExpKHandler())
 	mux.Handle("/v1/login/challenge", t.MakeChallengeHandler())
 	mux.Handle("/v1/logout", t.MakeLogoutHandler())
+	mux.Handle("/v1/sessions", t.MakeSessionsHandler())
+	mux.Handle("/v1/sessions/revoke", t.MakeRevokeSessionsHandler())
 
 	mux.Handle("/v1/metadata", t.MakeMetadataHandler())
 	mux.Handle("/v1/add", t.MakeAddHandler())
 	mux.Handle("/v1/get", t.MakeGetHandler())
+	mux.Handle("/v1/rotate", t.MakeRotateHandler())
+	mux.Handle("/v1/delete", t.MakeDeleteHandler())
 
 	handler := http.NewServeMux()
-	handler.Handle("/", service.MakeAccessControl(mux))
+	handler.Handle("/", service.MakeAccessControl(service.MakeRequestContext(mux, time.Duration(*timeoutSec)*time.Second)))
 	handler.Handle("/metrics", promhttp.Handler())
 	handler.Handle("/_status/liveness", t.MakeLivenessHandler())
 	handler.Handle("/_status/readiness", t.MakeReadinessHandler())
 
+	creds, err := credentials.NewServerTLSFromFile(*certPath, *keyPath)
+	if err != nil {
+		logger.Log("err", fmt.Sprintf("%+v", errors.Wrap(err, "failed to load grpc credentials")))
+		os.Exit(1)
+	}
+	grpcServer := grpc.NewServer(
+		grpc.Creds(creds),
+		grpc.MaxRecvMsgSize(contract.MaxRequestSize),
+		grpc.UnaryInterceptor(service.UnaryRequestContext(time.Duration(*timeoutSec)*time.Second)),
+	)
+	pb.RegisterOnlineSphinxServer(grpcServer, service.NewGRPCTransport(svc, sessions, kitlog.With(logger, "component", "transport")).
+		WithThrottle(throttle).
+		WithForwardedFor(c.ForwardedFor).
+		WithRedactor(redactor))
+
 	// === startup ===
 
 	// https://blog.cloudflare.com/the-complete-guide-to-golang-net-http-timeouts/
@@ -146,9 +290,31 @@ func main() {
 		}
 	}(server)
 
+	if *grpcAddr != "" {
+		go func(server *grpc.Server) {
+			lis, err := net.Listen("tcp", *grpcAddr)
+			if err != nil {
+				logger.Log("err", fmt.Sprintf("%+v", errors.Wrap(err, "failed to listen for grpc service")))
+				os.Exit(1)
+			}
+			logger.Log("service", "started", "listening", *grpcAddr, "transport", "grpc")
+			err = server.Serve(lis)
+			if err != nil {
+				logger.Log("err", fmt.Sprintf("%+v", errors.Wrap(err, "failed to start grpc service")))
+				os.Exit(1)
+			}
+		}(grpcServer)
+	}
+
+	pruneCtx, stopPrune := context.WithCancel(context.Background())
+	go sessions.PruneEvery(pruneCtx, c.SessionPruneInterval, func(err error) {
+		logger.Log("err", fmt.Sprintf("%+v", errors.Wrap(err, "failed to prune sessions")))
+	})
+
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Interrupt)
 	<-signals
+	stopPrune()
 
 	// === shutdown ===
 
@@ -156,6 +322,7 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	grpcServer.GracefulStop()
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Log("err", fmt.Sprintf("%+v", errors.Wrap(err, "failed to shutdown http service")))
 		os.Exit(1)
@@ -177,6 +344,127 @@ func getHashBy(name string) func() hash.Hash {
 	}
 }
 
-func getOrGenerate(hex string, keyLength int) *big.Int {
-	return big.NewInt(1)
+// repositories are the stores of the service, a func to close them
+// and warnings about stores, which replicas of the service can not share.
+type repositories struct {
+	users    service.UserRepository
+	vaults   service.VaultRepository
+	sessions service.SessionStore
+	throttle service.ThrottleStore
+	close    func() error
+	warnings []string
+}
+
+// perProcess warns about the memory and the bolt store, whose sessions and login attempts
+// are not seen by other replicas, so that logins fail on them and guesses are spread over them.
+const perProcess = "the store is not shared between processes, run a single replica - configure OSSVC_STORE=redis for several"
+
+// warn logs the warnings about the stores.
+func (r repositories) warn(logger kitlog.Logger) {
+	for _, warning := range r.warnings {
+		logger.Log("warn", warning)
+	}
+}
+
+func getRepositories(name, path, redisURL string) (repositories, error) {
+	switch name {
+	case "memory":
+		return repositories{
+			users:    service.NewUserRepository(),
+			vaults:   service.NewVaultRepository(),
+			sessions: service.NewSessionStore(),
+			throttle: service.NewThrottleStore(),
+			close:    func() error { return nil },
+			warnings: []string{perProcess},
+		}, nil
+	case "bolt":
+		db, err := service.OpenBolt(path)
+		if err != nil {
+			return repositories{}, err
+		}
+		return repositories{
+			users:    service.NewBoltUserRepository(db),
+			vaults:   service.NewBoltVaultRepository(db),
+			sessions: service.NewBoltSessionStore(db),
+			throttle: service.NewBoltThrottleStore(db),
+			close:    db.Close,
+			warnings: []string{perProcess},
+		}, nil
+	case "redis":
+		c, err := service.OpenRedis(redisURL)
+		if err != nil {
+			return repositories{}, err
+		}
+		return repositories{
+			users:    service.NewRedisUserRepository(c),
+			vaults:   service.NewRedisVaultRepository(c),
+			sessions: service.NewRedisSessionStore(c),
+			throttle: service.NewRedisThrottleStore(c),
+			close:    c.Close,
+		}, nil
+	default:
+		return repositories{}, errors.Errorf("unknown store %s", name)
+	}
+}
+
+// exitOnError logs err with msg and exits unless err is nil.
+func exitOnError(logger kitlog.Logger, err error, msg string) {
+	if err != nil {
+		logger.Log("err", fmt.Sprintf("%+v", errors.Wrap(err, msg)))
+		os.Exit(1)
+	}
+}
+
+// issueEnrollment prints a code, which lets the user with the hex encoded client ID enroll
+// a verifier within validFor. The code is handed to the user out of band.
+func issueEnrollment(sphinx *service.OnlineSphinx, cIDHex string, validFor time.Duration) error {
+	cID, ok := new(big.Int).SetString(cIDHex, 16)
+	if !ok {
+		return errors.Errorf("client ID %s is not hex", cIDHex)
+	}
+	code, err := sphinx.IssueEnrollment(context.Background(), cID, validFor)
+	if err != nil {
+		return err
+	}
+	fmt.Println(code)
+	return nil
+}
+
+// getSessionKeys decodes the hex encoded session signing keys, the first key signs new sessions.
+func getSessionKeys(hexKeys []string) ([][]byte, error) {
+	var keys [][]byte
+	for i, h := range hexKeys {
+		key, err := hex.DecodeString(h)
+		if err != nil {
+			return nil, errors.Wrapf(err, "session key %d", i)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// getThrottleConfig returns the default throttle policies with the configured attempts and lockout.
+func getThrottleConfig(c Configuration) service.ThrottleConfig {
+	cfg := service.DefaultThrottleConfig()
+	cfg.Client.FreeAttempts = c.ThrottleClientAttempts
+	cfg.Client.LockoutAttempts = c.ThrottleClientLockoutAttempts
+	cfg.Client.Lockout = c.ThrottleLockout
+	cfg.Source.FreeAttempts = c.ThrottleSourceAttempts
+	cfg.Source.LockoutAttempts = c.ThrottleSourceLockoutAttempts
+	cfg.Source.Lockout = c.ThrottleLockout
+	return cfg
+}
+
+// getRedactor returns the redaction policy of the logs. Without hash key identifiers
+// are hashed with a random key, so their logs only correlate until restart.
+func getRedactor(level, hexKey string) (*service.Redactor, error) {
+	l, err := service.ParseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "log hash key")
+	}
+	return service.NewRedactor(l, key), nil
 }
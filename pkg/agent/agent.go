@@ -0,0 +1,218 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/client"
+	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrLocked is returned for requests to a locked agent, which has to be unlocked first
+	ErrLocked = errors.New("agent locked")
+	// ErrWrongPassphrase is returned when unlocking the agent with another passphrase than locking it
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+)
+
+// defaultTimeout limits requests without timeout and the logout after the idle timeout.
+const defaultTimeout = 30 * time.Second
+
+// unlockDelay slows down guessing the passphrase of a locked agent.
+var unlockDelay = time.Second
+
+// Sphinx is the Online SPHINX client whose session the agent holds, e.g. *client.Client.
+type Sphinx interface {
+	Register(ctx context.Context, username, pwd string) error
+	Enroll(ctx context.Context, username, pwd, code string) error
+	Login(ctx context.Context, username, pwd string) error
+	Logout(ctx context.Context) error
+	AddWithPolicy(ctx context.Context, domain string, p client.Policy) error
+	Get(ctx context.Context, domain string) (string, error)
+	GetPrevious(ctx context.Context, domain string) (string, error)
+	Rotate(ctx context.Context, domain string) (time.Time, error)
+	GetMetadata(ctx context.Context) ([]string, error)
+	Delete(ctx context.Context, domain string) error
+	Sessions(ctx context.Context) ([]contract.SessionInfo, error)
+	RevokeSession(ctx context.Context, id string) error
+	RevokeAllSessions(ctx context.Context) error
+	Migrate(username string) error
+	LoggedIn() bool
+	Wipe()
+}
+
+// Agent holds the session of a Sphinx client for the oscli commands, like ssh-agent holds keys.
+// It serves one request at a time and
+// * logs out after being idle for the idle timeout,
+// * refuses all requests but unlock and logout while locked.
+type Agent struct {
+	clt  Sphinx
+	idle time.Duration
+
+	mu     sync.Mutex
+	timer  *time.Timer
+	gen    uint64
+	locked []byte
+	salt   []byte
+}
+
+// New returns an Agent holding the session of clt, which logs out after being idle for idle.
+// Zero disables the idle timeout.
+func New(clt Sphinx, idle time.Duration) *Agent {
+	return &Agent{clt: clt, idle: idle}
+}
+
+// Serve accepts connections of l until l is closed.
+func (a *Agent) Serve(l net.Listener) error {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("Agent", &handler{a}); err != nil {
+		return errors.Wrap(err, "Serve: failed to register handler")
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return errors.Wrap(err, "Serve: failed to accept connection")
+		}
+		go srv.ServeConn(conn)
+	}
+}
+
+// Close wipes the session without logging out.
+func (a *Agent) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.stopTimer()
+	a.clt.Wipe()
+	a.locked = nil
+}
+
+// do runs fn on behalf of req, unless the agent is locked.
+func (a *Agent) do(req Request, fn func(ctx context.Context) error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.locked != nil {
+		return ErrLocked
+	}
+	return a.run(req, fn)
+}
+
+// run runs fn within the timeout of req and restarts the idle timeout, a.mu must be held.
+func (a *Agent) run(req Request, fn func(ctx context.Context) error) error {
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := fn(ctx)
+	a.restartTimer()
+	return err
+}
+
+// restartTimer restarts the idle timeout if the client is logged in.
+func (a *Agent) restartTimer() {
+	a.stopTimer()
+	if a.idle > 0 && a.clt.LoggedIn() {
+		gen := a.gen
+		a.timer = time.AfterFunc(a.idle, func() { a.expire(gen) })
+	}
+}
+
+// stopTimer stops the idle timeout, also if it fired but waits for a.mu.
+func (a *Agent) stopTimer() {
+	a.gen++
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+}
+
+// expire logs out after the idle timeout gen, the session is wiped even if the service is offline.
+func (a *Agent) expire(gen uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if gen != a.gen {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	a.clt.Logout(ctx)
+	a.timer = nil
+	a.locked = nil
+}
+
+// logout logs out even if the agent is locked, since it only destroys the session.
+func (a *Agent) logout(req Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.locked = nil
+	return a.run(req, func(ctx context.Context) error {
+		return a.clt.Logout(ctx)
+	})
+}
+
+// lock locks the agent with passphrase. Only the salted hash of passphrase is kept,
+// the agent holds the session anyway.
+func (a *Agent) lock(passphrase string) error {
+	if passphrase == "" {
+		return errors.New("Lock: passphrase required")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.locked != nil {
+		return ErrLocked
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return errors.Wrap(err, "Lock: failed to generate salt")
+	}
+	a.salt = salt
+	a.locked = hash(salt, passphrase)
+	return nil
+}
+
+// unlock unlocks the agent if passphrase matches the one of lock.
+func (a *Agent) unlock(passphrase string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.locked == nil {
+		return errors.New("Unlock: agent not locked")
+	}
+	if subtle.ConstantTimeCompare(a.locked, hash(a.salt, passphrase)) != 1 {
+		time.Sleep(unlockDelay)
+		return ErrWrongPassphrase
+	}
+	a.locked = nil
+	return nil
+}
+
+// status returns the state of the agent.
+func (a *Agent) status() Status {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return Status{LoggedIn: a.clt.LoggedIn(), Locked: a.locked != nil}
+}
+
+func hash(salt []byte, passphrase string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(passphrase))
+	return h.Sum(nil)
+}
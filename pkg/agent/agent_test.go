@@ -0,0 +1,405 @@
+package agent
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/client"
+	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
+	"github.com/pkg/errors"
+)
+
+// fakeSphinx is a client logged in with the password "password", which returns the domain as its password.
+type fakeSphinx struct {
+	mu       sync.Mutex
+	loggedIn bool
+	logouts  int
+	domains  []string
+	sessions []contract.SessionInfo
+}
+
+func (f *fakeSphinx) Register(ctx context.Context, username, pwd string) error { return nil }
+
+func (f *fakeSphinx) Enroll(ctx context.Context, username, pwd, code string) error { return nil }
+
+func (f *fakeSphinx) Login(ctx context.Context, username, pwd string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if pwd != "password" {
+		return errors.Wrap(client.ErrAuthenticationFailed, "Login: service derived another session key")
+	}
+	f.loggedIn = true
+	return nil
+}
+
+func (f *fakeSphinx) Logout(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loggedIn = false
+	f.logouts++
+	return nil
+}
+
+func (f *fakeSphinx) AddWithPolicy(ctx context.Context, domain string, p client.Policy) error {
+	return nil
+}
+
+func (f *fakeSphinx) Get(ctx context.Context, domain string) (string, error) {
+	if !f.LoggedIn() {
+		return "", client.ErrLoginRequired
+	}
+	return domain, nil
+}
+
+func (f *fakeSphinx) GetPrevious(ctx context.Context, domain string) (string, error) {
+	return "", client.ErrNoPreviousVersion
+}
+
+func (f *fakeSphinx) Rotate(ctx context.Context, domain string) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func (f *fakeSphinx) GetMetadata(ctx context.Context) ([]string, error) {
+	if !f.LoggedIn() {
+		return nil, client.ErrLoginRequired
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.domains, nil
+}
+
+func (f *fakeSphinx) Delete(ctx context.Context, domain string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, d := range f.domains {
+		if d == domain {
+			f.domains = append(f.domains[:i], f.domains[i+1:]...)
+			return nil
+		}
+	}
+	return client.ErrDomainNotFound
+}
+
+func (f *fakeSphinx) Sessions(ctx context.Context) ([]contract.SessionInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sessions, nil
+}
+
+func (f *fakeSphinx) RevokeSession(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, s := range f.sessions {
+		if s.ID == id {
+			f.sessions = append(f.sessions[:i], f.sessions[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeSphinx) RevokeAllSessions(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions = nil
+	f.loggedIn = false
+	return nil
+}
+
+func (f *fakeSphinx) Migrate(username string) error { return nil }
+
+func (f *fakeSphinx) LoggedIn() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.loggedIn
+}
+
+func (f *fakeSphinx) Wipe() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loggedIn = false
+}
+
+// startAgent serves an agent for sphinx on a temporary socket and returns its path.
+func startAgent(t *testing.T, sphinx Sphinx, idle time.Duration) (string, func()) {
+	dir, err := ioutil.TempDir("", "oscli-agent")
+	if err != nil {
+		t.Fatalf("TempDir() error = %v", err)
+	}
+	path := filepath.Join(dir, "agent.sock")
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	a := New(sphinx, idle)
+	go a.Serve(l)
+	return path, func() {
+		l.Close()
+		a.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func dial(t *testing.T, path string) *Client {
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	return c
+}
+
+func TestAgent(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should hold the session across commands", func(t *testing.T) {
+		// given
+		path, stop := startAgent(t, &fakeSphinx{}, 0)
+		defer stop()
+		login := dial(t, path)
+		defer login.Close()
+		if err := login.Login(ctx, "username", "password"); err != nil {
+			t.Fatalf("Login() error = %v", err)
+		}
+
+		// when
+		get := dial(t, path)
+		defer get.Close()
+		pwd, err := get.Get(ctx, "example.com")
+
+		// then
+		if err != nil || pwd != "example.com" {
+			t.Errorf("Get() = %v, %v, want %v", pwd, err, "example.com")
+		}
+	})
+
+	t.Run("should list and delete domains", func(t *testing.T) {
+		// given
+		path, stop := startAgent(t, &fakeSphinx{domains: []string{"a.com", "b.com"}}, 0)
+		defer stop()
+		c := dial(t, path)
+		defer c.Close()
+		c.Login(ctx, "username", "password")
+
+		// when
+		err := c.Delete(ctx, "a.com")
+
+		// then
+		if err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if domains, err := c.Domains(ctx); err != nil || !reflect.DeepEqual(domains, []string{"b.com"}) {
+			t.Errorf("Domains() = %v, %v, want %v", domains, err, []string{"b.com"})
+		}
+		if err := c.Delete(ctx, "a.com"); errors.Cause(err) != client.ErrDomainNotFound {
+			t.Errorf("Delete() error = %v, want %v", err, client.ErrDomainNotFound)
+		}
+	})
+
+	t.Run("should list and revoke sessions", func(t *testing.T) {
+		// given
+		sphinx := &fakeSphinx{sessions: []contract.SessionInfo{{ID: "a", Current: true}, {ID: "b"}}}
+		path, stop := startAgent(t, sphinx, 0)
+		defer stop()
+		c := dial(t, path)
+		defer c.Close()
+		c.Login(ctx, "username", "password")
+
+		// when
+		err := c.RevokeSession(ctx, "b")
+
+		// then
+		if err != nil {
+			t.Fatalf("RevokeSession() error = %v", err)
+		}
+		want := []contract.SessionInfo{{ID: "a", Current: true}}
+		if sessions, err := c.Sessions(ctx); err != nil || !reflect.DeepEqual(sessions, want) {
+			t.Errorf("Sessions() = %v, %v, want %v", sessions, err, want)
+		}
+		if err := c.RevokeAllSessions(ctx); err != nil || sphinx.LoggedIn() {
+			t.Errorf("RevokeAllSessions() error = %v, logged in = %v, want logged out", err, sphinx.LoggedIn())
+		}
+	})
+
+	t.Run("should keep the cause of errors", func(t *testing.T) {
+		path, stop := startAgent(t, &fakeSphinx{}, 0)
+		defer stop()
+		c := dial(t, path)
+		defer c.Close()
+
+		err := c.Login(ctx, "username", "wrong password")
+		if errors.Cause(err) != client.ErrAuthenticationFailed {
+			t.Errorf("Login() error = %v, want %v", err, client.ErrAuthenticationFailed)
+		}
+		_, err = c.Get(ctx, "example.com")
+		if errors.Cause(err) != client.ErrLoginRequired {
+			t.Errorf("Get() error = %v, want %v", err, client.ErrLoginRequired)
+		}
+	})
+
+	t.Run("should return the error of the context", func(t *testing.T) {
+		path, stop := startAgent(t, &fakeSphinx{}, 0)
+		defer stop()
+		c := dial(t, path)
+		defer c.Close()
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := c.Get(ctx, "example.com")
+
+		if errors.Cause(err) != context.Canceled {
+			t.Errorf("Get() error = %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestAgent_Lock(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should refuse requests while locked", func(t *testing.T) {
+		// given
+		path, stop := startAgent(t, &fakeSphinx{}, 0)
+		defer stop()
+		c := dial(t, path)
+		defer c.Close()
+		c.Login(ctx, "username", "password")
+
+		// when
+		err := c.Lock(ctx, "passphrase")
+
+		// then
+		if err != nil {
+			t.Fatalf("Lock() error = %v", err)
+		}
+		if _, err := c.Get(ctx, "example.com"); errors.Cause(err) != ErrLocked {
+			t.Errorf("Get() error = %v, want %v", err, ErrLocked)
+		}
+		if err := c.Unlock(ctx, "wrong passphrase"); errors.Cause(err) != ErrWrongPassphrase {
+			t.Errorf("Unlock() error = %v, want %v", err, ErrWrongPassphrase)
+		}
+		if err := c.Unlock(ctx, "passphrase"); err != nil {
+			t.Errorf("Unlock() error = %v", err)
+		}
+		if pwd, err := c.Get(ctx, "example.com"); err != nil || pwd != "example.com" {
+			t.Errorf("Get() after unlock = %v, %v", pwd, err)
+		}
+	})
+
+	t.Run("should wipe the session on logout even if locked", func(t *testing.T) {
+		sphinx := &fakeSphinx{}
+		path, stop := startAgent(t, sphinx, 0)
+		defer stop()
+		c := dial(t, path)
+		defer c.Close()
+		c.Login(ctx, "username", "password")
+		c.Lock(ctx, "passphrase")
+
+		err := c.Logout(ctx)
+
+		status, _ := c.Status(ctx)
+		if err != nil || status.LoggedIn || status.Locked {
+			t.Errorf("Logout() error = %v, status = %+v", err, status)
+		}
+	})
+
+	t.Run("should log out after the idle timeout", func(t *testing.T) {
+		// given
+		sphinx := &fakeSphinx{}
+		path, stop := startAgent(t, sphinx, 50*time.Millisecond)
+		defer stop()
+		c := dial(t, path)
+		defer c.Close()
+
+		// when
+		c.Login(ctx, "username", "password")
+		time.Sleep(30 * time.Millisecond)
+		c.Get(ctx, "example.com")
+		time.Sleep(30 * time.Millisecond)
+
+		// then
+		if !sphinx.LoggedIn() {
+			t.Fatalf("agent logged out before the idle timeout")
+		}
+		time.Sleep(100 * time.Millisecond)
+		sphinx.mu.Lock()
+		defer sphinx.mu.Unlock()
+		if sphinx.loggedIn || sphinx.logouts != 1 {
+			t.Errorf("agent did not log out after the idle timeout, logouts = %v", sphinx.logouts)
+		}
+	})
+}
+
+func TestListen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oscli-agent")
+	if err != nil {
+		t.Fatalf("TempDir() error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "agent", "agent.sock")
+
+	t.Run("should listen on a socket of the user", func(t *testing.T) {
+		l, err := Listen(path)
+		if err != nil {
+			t.Fatalf("Listen() error = %v", err)
+		}
+		defer l.Close()
+
+		fi, err := os.Stat(path)
+		if err != nil || fi.Mode().Perm() != 0600 {
+			t.Errorf("Listen() socket mode = %v, %v, want %v", fi.Mode().Perm(), err, os.FileMode(0600))
+		}
+		fi, err = os.Stat(filepath.Dir(path))
+		if err != nil || fi.Mode().Perm() != 0700 {
+			t.Errorf("Listen() directory mode = %v, %v, want %v", fi.Mode().Perm(), err, os.FileMode(0700))
+		}
+
+		if _, err := Listen(path); err == nil {
+			t.Errorf("Listen() expect an error of the running agent")
+		}
+	})
+
+	t.Run("should refuse a directory accessible by others", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("windows restricts directories by ACLs")
+		}
+		shared := filepath.Join(dir, "shared")
+		if err := os.Mkdir(shared, 0700); err != nil {
+			t.Fatalf("Mkdir() error = %v", err)
+		}
+		if err := os.Chmod(shared, 0755); err != nil {
+			t.Fatalf("Chmod() error = %v", err)
+		}
+
+		l, err := Listen(filepath.Join(shared, "agent.sock"))
+		if err == nil {
+			l.Close()
+			t.Errorf("Listen() expect an error of the directory accessible by others")
+		}
+	})
+
+	t.Run("should accept connections of the user", func(t *testing.T) {
+		l, err := Listen(path)
+		if err != nil {
+			t.Fatalf("Listen() error = %v", err)
+		}
+		defer l.Close()
+		go func() {
+			if conn, err := net.Dial("unix", path); err == nil {
+				conn.Close()
+			}
+		}()
+
+		conn, err := l.Accept()
+		if err != nil {
+			t.Fatalf("Accept() error = %v", err)
+		}
+		conn.Close()
+	})
+}
@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"time"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/client"
+	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
+	"github.com/pkg/errors"
+)
+
+// Client is a thin client of the agent used by the oscli commands.
+// The timeout of a request is the deadline of its context.
+type Client struct {
+	rpc *rpc.Client
+}
+
+// Dial connects to the agent listening on the Unix socket path.
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Dial: failed to connect to agent at %s", path)
+	}
+	return &Client{rpc: rpc.NewClient(conn)}, nil
+}
+
+// Close closes the connection to the agent.
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+// Register a new user by the agent.
+func (c *Client) Register(ctx context.Context, username, pwd string) error {
+	_, err := c.call(ctx, "Agent.Register", Request{Username: username, Password: pwd})
+	return err
+}
+
+// Enroll sets the verifier of an user registered before verifiers with the enrollment code by the agent.
+func (c *Client) Enroll(ctx context.Context, username, pwd, code string) error {
+	_, err := c.call(ctx, "Agent.Enroll", Request{Username: username, Password: pwd, Code: code})
+	return err
+}
+
+// Login an existing user, the agent holds the session until logout, the idle timeout or its exit.
+func (c *Client) Login(ctx context.Context, username, pwd string) error {
+	_, err := c.call(ctx, "Agent.Login", Request{Username: username, Password: pwd})
+	return err
+}
+
+// Logout ends the session and wipes it from the agent, also if it is locked.
+func (c *Client) Logout(ctx context.Context) error {
+	_, err := c.call(ctx, "Agent.Logout", Request{})
+	return err
+}
+
+// AddWithPolicy adds domain with the password policy p.
+func (c *Client) AddWithPolicy(ctx context.Context, domain string, p client.Policy) error {
+	_, err := c.call(ctx, "Agent.Add", Request{Domain: domain, Policy: p})
+	return err
+}
+
+// Get returns the password of domain.
+func (c *Client) Get(ctx context.Context, domain string) (string, error) {
+	resp, err := c.call(ctx, "Agent.Get", Request{Domain: domain})
+	return resp.Password, err
+}
+
+// GetPrevious returns the password of domain before its last rotation.
+func (c *Client) GetPrevious(ctx context.Context, domain string) (string, error) {
+	resp, err := c.call(ctx, "Agent.Get", Request{Domain: domain, Previous: true})
+	return resp.Password, err
+}
+
+// Rotate rotates the password of domain and returns the end of the grace period.
+func (c *Client) Rotate(ctx context.Context, domain string) (time.Time, error) {
+	resp, err := c.call(ctx, "Agent.Rotate", Request{Domain: domain})
+	return resp.PreviousUntil, err
+}
+
+// Domains returns the domains of the user in ascending order.
+func (c *Client) Domains(ctx context.Context) ([]string, error) {
+	resp, err := c.call(ctx, "Agent.Domains", Request{})
+	return resp.Domains, err
+}
+
+// Delete removes domain, its passwords can not be derived anymore.
+func (c *Client) Delete(ctx context.Context, domain string) error {
+	_, err := c.call(ctx, "Agent.Delete", Request{Domain: domain})
+	return err
+}
+
+// Sessions returns the active sessions of the user, the one of the agent is marked as current.
+func (c *Client) Sessions(ctx context.Context) ([]contract.SessionInfo, error) {
+	resp, err := c.call(ctx, "Agent.Sessions", Request{})
+	return resp.Sessions, err
+}
+
+// RevokeSession ends the session with id of the user.
+func (c *Client) RevokeSession(ctx context.Context, id string) error {
+	_, err := c.call(ctx, "Agent.RevokeSessions", Request{SessionID: id})
+	return err
+}
+
+// RevokeAllSessions ends all sessions of the user, the agent has to login again.
+func (c *Client) RevokeAllSessions(ctx context.Context) error {
+	_, err := c.call(ctx, "Agent.RevokeSessions", Request{All: true})
+	return err
+}
+
+// Migrate migrates the user to the current password hashing.
+func (c *Client) Migrate(ctx context.Context, username string) error {
+	_, err := c.call(ctx, "Agent.Migrate", Request{Username: username})
+	return err
+}
+
+// Lock locks the agent with passphrase, it refuses all requests but Unlock and Logout until unlocked.
+func (c *Client) Lock(ctx context.Context, passphrase string) error {
+	_, err := c.call(ctx, "Agent.Lock", Request{Passphrase: passphrase})
+	return err
+}
+
+// Unlock unlocks the agent with the passphrase of Lock.
+func (c *Client) Unlock(ctx context.Context, passphrase string) error {
+	_, err := c.call(ctx, "Agent.Unlock", Request{Passphrase: passphrase})
+	return err
+}
+
+// Status returns the state of the agent.
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	resp, err := c.call(ctx, "Agent.Status", Request{})
+	return resp.Status, err
+}
+
+// call calls method of the agent with req, whose timeout is the deadline of ctx.
+func (c *Client) call(ctx context.Context, method string, req Request) (Response, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Timeout = time.Until(deadline)
+	}
+
+	var resp Response
+	select {
+	case call := <-c.rpc.Go(method, req, &resp, make(chan *rpc.Call, 1)).Done:
+		if call.Error != nil {
+			return resp, errors.Wrapf(call.Error, "%s: failed to call agent", method)
+		}
+	case <-ctx.Done():
+		return resp, errors.Wrapf(ctx.Err(), "%s: agent did not respond", method)
+	}
+	return resp, resp.err()
+}
@@ -0,0 +1,3 @@
+// Package agent implements the oscli agent, which holds the session of an Online SPHINX client
+// across oscli commands like ssh-agent holds keys, and the thin client used by the commands.
+package agent
@@ -0,0 +1,216 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/client"
+	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
+	"github.com/pkg/errors"
+)
+
+// Request is the request of an oscli command to the agent.
+type Request struct {
+	// Timeout of the command, zero for the default timeout of the agent.
+	Timeout    time.Duration
+	Username   string
+	Password   string
+	Code       string
+	Passphrase string
+	Domain     string
+	Previous   bool
+	Policy     client.Policy
+	SessionID  string
+	All        bool
+}
+
+// Response is the response of the agent. Errors are part of the response,
+// so that their causes are kept across the socket.
+type Response struct {
+	Password      string
+	Domains       []string
+	Sessions      []contract.SessionInfo
+	PreviousUntil time.Time
+	Status        Status
+	Err           string
+	Cause         string
+}
+
+// Status is the state of the agent.
+type Status struct {
+	LoggedIn bool
+	Locked   bool
+}
+
+// causes are the user facing errors, whose causes are kept across the socket.
+var causes = []error{
+	client.ErrLoginRequired,
+	client.ErrOperationFailed,
+	client.ErrInvalidResponse,
+	client.ErrInvalidRequest,
+	client.ErrNotRegistered,
+	client.ErrUserAlreadyExists,
+	client.ErrDomainNotFound,
+	client.ErrDomainAlreadyExists,
+	client.ErrNoPreviousVersion,
+	client.ErrLoginOutOfOrder,
+	client.ErrAuthenticationFailed,
+	client.ErrTooManyAttempts,
+	client.ErrEnrollmentInvalid,
+	context.DeadlineExceeded,
+	context.Canceled,
+	ErrLocked,
+	ErrWrongPassphrase,
+}
+
+// Error is an error of the agent. Its cause is one of the user facing errors
+// of package client or this package, so that callers can react with errors.Cause.
+type Error struct {
+	cause   error
+	Message string
+}
+
+// Error returns the message of the agent.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Cause returns the user facing error.
+func (e *Error) Cause() error {
+	return e.cause
+}
+
+// setErr sets the error of resp to err.
+func (resp *Response) setErr(err error) {
+	if err == nil {
+		return
+	}
+	resp.Err = err.Error()
+	resp.Cause = errors.Cause(err).Error()
+}
+
+// err returns the *Error of resp or an error with its message if the cause is unknown.
+func (resp *Response) err() error {
+	if resp.Err == "" {
+		return nil
+	}
+	for _, cause := range causes {
+		if cause.Error() == resp.Cause {
+			return &Error{cause: cause, Message: resp.Err}
+		}
+	}
+	return errors.New(resp.Err)
+}
+
+// handler serves the requests of the agent by net/rpc.
+type handler struct {
+	agent *Agent
+}
+
+func (h *handler) Register(req Request, resp *Response) error {
+	resp.setErr(h.agent.do(req, func(ctx context.Context) error {
+		return h.agent.clt.Register(ctx, req.Username, req.Password)
+	}))
+	return nil
+}
+
+func (h *handler) Enroll(req Request, resp *Response) error {
+	resp.setErr(h.agent.do(req, func(ctx context.Context) error {
+		return h.agent.clt.Enroll(ctx, req.Username, req.Password, req.Code)
+	}))
+	return nil
+}
+
+func (h *handler) Login(req Request, resp *Response) error {
+	resp.setErr(h.agent.do(req, func(ctx context.Context) error {
+		return h.agent.clt.Login(ctx, req.Username, req.Password)
+	}))
+	return nil
+}
+
+func (h *handler) Logout(req Request, resp *Response) error {
+	resp.setErr(h.agent.logout(req))
+	return nil
+}
+
+func (h *handler) Add(req Request, resp *Response) error {
+	resp.setErr(h.agent.do(req, func(ctx context.Context) error {
+		return h.agent.clt.AddWithPolicy(ctx, req.Domain, req.Policy)
+	}))
+	return nil
+}
+
+func (h *handler) Get(req Request, resp *Response) error {
+	get := h.agent.clt.Get
+	if req.Previous {
+		get = h.agent.clt.GetPrevious
+	}
+	resp.setErr(h.agent.do(req, func(ctx context.Context) (err error) {
+		resp.Password, err = get(ctx, req.Domain)
+		return err
+	}))
+	return nil
+}
+
+func (h *handler) Rotate(req Request, resp *Response) error {
+	resp.setErr(h.agent.do(req, func(ctx context.Context) (err error) {
+		resp.PreviousUntil, err = h.agent.clt.Rotate(ctx, req.Domain)
+		return err
+	}))
+	return nil
+}
+
+func (h *handler) Domains(req Request, resp *Response) error {
+	resp.setErr(h.agent.do(req, func(ctx context.Context) (err error) {
+		resp.Domains, err = h.agent.clt.GetMetadata(ctx)
+		return err
+	}))
+	return nil
+}
+
+func (h *handler) Delete(req Request, resp *Response) error {
+	resp.setErr(h.agent.do(req, func(ctx context.Context) error {
+		return h.agent.clt.Delete(ctx, req.Domain)
+	}))
+	return nil
+}
+
+func (h *handler) Sessions(req Request, resp *Response) error {
+	resp.setErr(h.agent.do(req, func(ctx context.Context) (err error) {
+		resp.Sessions, err = h.agent.clt.Sessions(ctx)
+		return err
+	}))
+	return nil
+}
+
+func (h *handler) RevokeSessions(req Request, resp *Response) error {
+	resp.setErr(h.agent.do(req, func(ctx context.Context) error {
+		if req.All {
+			return h.agent.clt.RevokeAllSessions(ctx)
+		}
+		return h.agent.clt.RevokeSession(ctx, req.SessionID)
+	}))
+	return nil
+}
+
+func (h *handler) Migrate(req Request, resp *Response) error {
+	resp.setErr(h.agent.do(req, func(ctx context.Context) error {
+		return h.agent.clt.Migrate(req.Username)
+	}))
+	return nil
+}
+
+func (h *handler) Lock(req Request, resp *Response) error {
+	resp.setErr(h.agent.lock(req.Passphrase))
+	return nil
+}
+
+func (h *handler) Unlock(req Request, resp *Response) error {
+	resp.setErr(h.agent.unlock(req.Passphrase))
+	return nil
+}
+
+func (h *handler) Status(req Request, resp *Response) error {
+	resp.Status = h.agent.status()
+	return nil
+}
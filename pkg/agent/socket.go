@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Listen listens on the Unix socket path, which only the user may connect to.
+// The directory of path is created with mode 0700 if missing, an existing one has to
+// belong to the user and must not be accessible by others, e.g. not /tmp.
+// A stale socket of an exited agent is replaced, a running agent is not.
+// Connections of peers running as another user are refused, where the OS reports them.
+func Listen(path string) (net.Listener, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "Listen: failed to create directory of %s", path)
+	}
+	if err := checkDir(dir); err != nil {
+		return nil, errors.Wrapf(err, "Listen: unsafe directory of %s", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, errors.Errorf("Listen: agent already running at %s", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "Listen: failed to remove stale socket %s", path)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Listen: failed to listen on %s", path)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, errors.Wrapf(err, "Listen: failed to restrict %s to the user", path)
+	}
+	return &userListener{l}, nil
+}
+
+// userListener refuses connections of peers running as another user.
+type userListener struct {
+	net.Listener
+}
+
+// Accept waits for the next connection of the user, connections of others are closed.
+func (l *userListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkPeer(conn); err != nil {
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
@@ -0,0 +1,40 @@
+//go:build linux
+// +build linux
+
+package agent
+
+import (
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// checkPeer returns an error unless the peer of conn runs as the user, see SO_PEERCRED.
+func checkPeer(conn net.Conn) error {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errors.Errorf("checkPeer: %T is no unix connection", conn)
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return errors.Wrap(err, "checkPeer: failed to get raw connection")
+	}
+
+	var cred *unix.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil {
+		return errors.Wrap(err, "checkPeer: failed to control connection")
+	}
+	if credErr != nil {
+		return errors.Wrap(credErr, "checkPeer: failed to get peer credentials")
+	}
+	if int(cred.Uid) != os.Getuid() {
+		return errors.Errorf("checkPeer: peer uid %d is not the user", cred.Uid)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package agent
+
+import "net"
+
+// checkPeer is a no-op, peers are only checked on linux, elsewhere the directory
+// of the socket keeps other users out, see Listen.
+func checkPeer(conn net.Conn) error {
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package agent
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// checkDir returns an error unless dir is a directory of the user inaccessible by others.
+func checkDir(dir string) error {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat %s", dir)
+	}
+	if !fi.IsDir() {
+		return errors.Errorf("%s is no directory", dir)
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); !ok || int(st.Uid) != os.Getuid() {
+		return errors.Errorf("%s does not belong to the user", dir)
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		return errors.Errorf("%s is accessible by others, restrict it with chmod 700", dir)
+	}
+	return nil
+}
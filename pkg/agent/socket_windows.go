@@ -0,0 +1,9 @@
+//go:build windows
+// +build windows
+
+package agent
+
+// checkDir is a no-op, windows restricts directories by ACLs inherited from the profile of the user.
+func checkDir(dir string) error {
+	return nil
+}
@@ -1,10 +1,12 @@
 package client
 
 import (
+	"context"
 	"crypto/rand"
 	"io"
 	"math/big"
 	"net/http"
+	"time"
 
 	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
 	"github.com/pkg/errors"
@@ -18,8 +20,27 @@ var (
 	ErrLoginRequired = errors.New("login required")
 	// ErrOperationFailed is returned when an operation failed and should needs to try again later
 	ErrOperationFailed = errors.New("operation failed")
+	// ErrInvalidResponse is returned when the service responds with invalid group parameters or elements
+	ErrInvalidResponse = errors.New("invalid response")
+	// ErrInvalidRequest is returned when the service rejects a request as malformed
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrNotRegistered is returned when the service does not know the user
+	ErrNotRegistered = errors.New("not registered")
+	// ErrDomainNotFound is returned when the user never added the domain
+	ErrDomainNotFound = errors.New("domain not found")
+	// ErrDomainAlreadyExists is returned when the user added the domain before
+	ErrDomainAlreadyExists = errors.New("domain already exists")
+	// ErrNoPreviousVersion is returned when the domain was not rotated within the grace period
+	ErrNoPreviousVersion = errors.New("no previous version")
+	// ErrLoginOutOfOrder is returned when the service receives the steps of a login out of order
+	ErrLoginOutOfOrder = errors.New("login step out of order")
+	// ErrAuthenticationFailed is returned when the login fails because of a wrong password
+	ErrAuthenticationFailed = errors.New("authentication failed")
+	// ErrTooManyAttempts is returned when the service throttles logins, RetryAfter tells when to try again
+	ErrTooManyAttempts = errors.New("too many login attempts")
+	// ErrEnrollmentInvalid is returned when the enrollment code is wrong, used or expired
+	ErrEnrollmentInvalid = errors.New("invalid enrollment code")
 )
-var two = big.NewInt(2)
 
 // New creates and returns a new Online SPHINX Client.
 func New(pst Poster, cfg Configuration, repo Repository) *Client {
@@ -38,34 +59,53 @@ type Client struct {
 	session *Session
 }
 
-// Poster provides a Post operation used e.g. http.DefaultClient
+// Poster sends the requests of a Client e.g. http.DefaultClient.
+// Requests carry the context of the Client call, which cancels them.
 type Poster interface {
-	Post(url, contentType string, body io.Reader) (resp *http.Response, err error)
+	Do(req *http.Request) (resp *http.Response, err error)
 }
 
 // Repository provides a basic user configuration repository interface
 type Repository interface {
 	Add(u User) error
+	Update(u User) error
 	Get(username string) (User, error)
 }
 
-// Register a new user by calling an Online SPHINX service.
+// Register a new user with its master password by calling an Online SPHINX service.
+// The service only learns the verifier of the authentication key derived from
+// the master key, neither the password nor the master key itself.
 // It might fail in case
 // * an user with the same ID already exists and
 // * Online SPHINX service is offline.
-func (clt *Client) Register(username string) error {
+func (clt *Client) Register(ctx context.Context, username, pwd string) error {
+	group, pk, err := clt.group(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Register: failed to get group of service")
+	}
+
+	cID, err := newClientID()
+	if err != nil {
+		return errors.Wrap(err, "Register: failed to create client ID")
+	}
 
-	user, err := newUser(username, clt.config.bits)
+	user, err := newUser(username, cID, group, pk)
 	if err != nil {
 		return errors.Wrap(err, "Register: failed to create new User")
 	}
 
-	rd, err := contract.MarshalRegisterRequest(contract.RegisterRequest{CID: user.cID})
+	mk, err := clt.registerExpK(ctx, user, pwd)
+	if err != nil {
+		return errors.Wrap(err, "Register: failed to derive master key")
+	}
+
+	verifier := crypto.Verifier(user.group, crypto.AuthKey(user.group, mk))
+	rd, err := contract.MarshalRegisterRequest(contract.RegisterRequest{CID: cID, Verifier: verifier})
 	if err != nil {
 		return errors.Wrap(err, "Register: failed to marshal RegisterRequest")
 	}
 
-	r, err := clt.poster.Post(clt.config.registerPath, clt.config.contentType, rd)
+	r, err := clt.send(ctx, http.MethodPost, clt.config.registerPath, rd)
 	if err != nil {
 		return errors.Wrap(err, "Register: failed to post RegisterRequest")
 	}
@@ -73,7 +113,7 @@ func (clt *Client) Register(username string) error {
 
 	err = contract.UnmarshalIfError(r)
 	if err != nil {
-		return errors.Wrap(err, "Register: failed to unmarshal error from response")
+		return errors.Wrap(serviceError(err), "Register: service failed")
 	}
 
 	err = clt.repo.Add(user)
@@ -84,98 +124,341 @@ func (clt *Client) Register(username string) error {
 	return nil
 }
 
+// Enroll sets the verifier of an user registered before verifiers, who can not login without one.
+// The user authenticates with the one-time code the operator of the service issued for its client ID,
+// Enroll without code fails with the client ID to ask for. The verifier is derived from pwd like
+// at registration, so enroll with the master password the domains of the user were added with.
+func (clt *Client) Enroll(ctx context.Context, username, pwd, code string) error {
+	user, err := clt.repo.Get(username)
+	if err != nil {
+		return errors.Wrap(err, "Enroll: failed to get user from local repo")
+	}
+	if code == "" {
+		return errors.Wrapf(ErrEnrollmentInvalid, "Enroll: ask the operator of the service for the enrollment code of client ID %s", user.cID.Text(16))
+	}
+	user, pinned, err := clt.pinKey(ctx, user)
+	if err != nil {
+		return errors.Wrap(err, "Enroll: failed to pin public key of service")
+	}
+
+	mk, err := clt.registerExpK(ctx, user, pwd)
+	if err != nil {
+		return errors.Wrap(err, "Enroll: failed to derive master key")
+	}
+
+	ak := crypto.AuthKey(user.group, mk)
+	proof, err := crypto.ProvePossession(user.group, ak, user.cID)
+	if err != nil {
+		return errors.Wrap(err, "Enroll: failed to prove possession of the authentication key")
+	}
+
+	rd, err := contract.MarshalEnrollRequest(contract.EnrollRequest{
+		CID:      user.cID,
+		Code:     code,
+		Verifier: crypto.Verifier(user.group, ak),
+		E:        proof.E,
+		ProofC:   proof.C,
+		ProofS:   proof.S,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Enroll: failed to marshal EnrollRequest")
+	}
+
+	r, err := clt.send(ctx, http.MethodPost, clt.config.enrollPath, rd)
+	if err != nil {
+		return errors.Wrap(err, "Enroll: failed to post EnrollRequest")
+	}
+	defer r.Body.Close()
+
+	err = contract.UnmarshalIfError(r)
+	if err != nil {
+		return errors.Wrap(serviceError(err), "Enroll: service failed")
+	}
+	if pinned {
+		err = clt.repo.Update(user)
+		if err != nil {
+			return errors.Wrap(err, "Enroll: failed to update user in local repo")
+		}
+	}
+	return nil
+}
+
+// group returns the verified group and public key of the service.
+func (clt *Client) group(ctx context.Context) (crypto.Group, *big.Int, error) {
+	r, err := clt.send(ctx, http.MethodGet, clt.config.groupPath, nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "group: failed to get GroupResponse")
+	}
+	defer r.Body.Close()
+
+	err = contract.UnmarshalIfError(r)
+	if err != nil {
+		return nil, nil, errors.Wrap(serviceError(err), "group: service failed")
+	}
+
+	groupResp, err := contract.UnmarshalGroupResponse(r.Body)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "group: failed to unmarshal GroupResponse")
+	}
+
+	group, err := clt.verifyGroup(groupResp)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "group: service uses an invalid group")
+	}
+
+	if !group.IsElement(groupResp.PK) {
+		return nil, nil, errors.Wrap(ErrInvalidResponse, "group: service public key is not a group element")
+	}
+	return group, groupResp.PK, nil
+}
+
+// pinKey returns user with the public key of the service pinned on first use. Users registered
+// before the service published its key have none, so the key is fetched once and must match
+// the group of the user. pinned reports a new key, which the caller stores after the service
+// proved to hold it.
+func (clt *Client) pinKey(ctx context.Context, user User) (u User, pinned bool, err error) {
+	if user.pk != nil {
+		return user, false, nil
+	}
+	group, pk, err := clt.group(ctx)
+	if err != nil {
+		return User{}, false, errors.Wrap(err, "pinKey: failed to get group of service")
+	}
+	if group.Name() != user.group.Name() || group.Order().Cmp(user.group.Order()) != 0 {
+		return User{}, false, errors.Wrapf(ErrInvalidResponse, "pinKey: service uses group %s, user registered with %s", group.Name(), user.group.Name())
+	}
+	user.pk = pk
+	return user, true, nil
+}
+
+// registerExpK derives the master key of the new user from pwd with the help of the service.
+func (clt *Client) registerExpK(ctx context.Context, user User, pwd string) (*big.Int, error) {
+	oprf := crypto.NewOPRF(user.group, crypto.ModeVOPRF)
+	b, blind, err := oprf.Blind([]byte(pwd))
+	if err != nil {
+		return nil, errors.Wrap(err, "registerExpK: failed to blind password")
+	}
+
+	rd, err := contract.MarshalRegisterExpKRequest(contract.RegisterExpKRequest{B: b, Q: user.group.Order()})
+	if err != nil {
+		return nil, errors.Wrap(err, "registerExpK: failed to marshal RegisterExpKRequest")
+	}
+
+	r, err := clt.send(ctx, http.MethodPost, clt.config.registerExpKPath, rd)
+	if err != nil {
+		return nil, errors.Wrap(err, "registerExpK: failed to post RegisterExpKRequest")
+	}
+	defer r.Body.Close()
+
+	err = contract.UnmarshalIfError(r)
+	if err != nil {
+		return nil, errors.Wrap(serviceError(err), "registerExpK: service failed")
+	}
+
+	regResp, err := contract.UnmarshalRegisterExpKResponse(r.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "registerExpK: failed to unmarshal RegisterExpKResponse")
+	}
+
+	proof := crypto.Proof{C: regResp.ProofC, S: regResp.ProofS}
+	return masterKey(user, oprf, b, blind, regResp.BD, regResp.Q0, proof)
+}
+
+// masterKey verifies the evaluation bd of the blinded password b by the service
+// and returns the master key mk = B0**k * q0 of user.
+func masterKey(user User, oprf crypto.OPRF, b, blind, bd, q0 *big.Int, proof crypto.Proof) (*big.Int, error) {
+	group := user.group
+	if !group.IsElement(bd) || !group.IsElement(q0) {
+		return nil, errors.Wrap(ErrInvalidResponse, "response contains values outside of the group")
+	}
+
+	if user.pk == nil {
+		return nil, errors.Wrap(ErrInvalidResponse, "no public key of the service to verify the response against")
+	}
+	err := oprf.Verify(user.pk, []*big.Int{b}, []*big.Int{bd}, proof)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidResponse, "response is not computed with the key of the registered service")
+	}
+
+	B0 := oprf.Unblind(bd, blind)
+	return group.Mul(group.Exp(B0, user.k), q0), nil
+}
+
+// verifyGroup accepts vetted groups and custom safe prime groups
+// with a field of at least the configured bit length.
+func (clt *Client) verifyGroup(r contract.GroupResponse) (crypto.Group, error) {
+	g, err := crypto.NewGroup(r.Name, r.Q)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidResponse, err.Error())
+	}
+	if g.Bits() < clt.config.bits {
+		return nil, errors.Wrapf(ErrInvalidResponse, "group %s has %d bits, want at least %d bits", g.Name(), g.Bits(), clt.config.bits)
+	}
+	return g, nil
+}
+
+// Migrate switches an existing user to the current mapping of passwords into the group.
+// It changes all derived passwords, so get the passwords of all domains before
+// and change them at every domain afterwards. Migrating a current user does nothing.
+func (clt *Client) Migrate(username string) error {
+	user, err := clt.repo.Get(username)
+	if err != nil {
+		return errors.Wrap(err, "Migrate: failed to get user from local repo")
+	}
+	if user.hashVersion == crypto.HashVersionCurrent {
+		return nil
+	}
+
+	user, err = user.withHashVersion(crypto.HashVersionCurrent)
+	if err != nil {
+		return errors.Wrap(err, "Migrate: failed to switch hash version")
+	}
+
+	err = clt.repo.Update(user)
+	if err != nil {
+		return errors.Wrap(err, "Migrate: failed to update user in local repo")
+	}
+
+	clt.Wipe()
+	return nil
+}
+
 // Login an existing user by calling Online SPHINX service.
+// Client and service derive the session key SKi by an authenticated key exchange,
+// which only succeeds with the master password of the user, and confirm it with a challenge.
 // It might fail in case
 // * local user configuration does not exist,
+// * the password is wrong, which fails with ErrAuthenticationFailed,
 // * Online SPHINX service is offline.
-func (clt *Client) Login(username, pwd string) error {
+func (clt *Client) Login(ctx context.Context, username, pwd string) error {
 
 	user, err := clt.repo.Get(username)
 	if err != nil {
 		return errors.Wrap(err, "failed to get user from local repo")
 	}
 
-	g := crypto.HashInGroup(pwd, clt.config.hash, user.q)
-
-	cNonce, err := rand.Int(rand.Reader, user.q)
+	clt.Wipe()
+	user, pinned, err := clt.pinKey(ctx, user)
 	if err != nil {
-		return errors.Wrap(err, "failed to generate random cNonce")
+		return err
+	}
+	session, err := clt.expK(ctx, user, pwd)
+	if err != nil {
+		return err
 	}
 
-	// blind factor
-	k, err := rand.Int(rand.Reader, user.q)
+	clt.session = session
+	err = clt.challenge(ctx)
 	if err != nil {
-		return errors.Wrap(err, "failed to generate random k")
+		clt.Wipe()
+		return err
+	}
+
+	if pinned {
+		err = clt.repo.Update(user)
+		if err != nil {
+			return errors.Wrap(err, "failed to update user in local repo")
+		}
 	}
+	return nil
+}
 
-	kinv := new(big.Int)
-	kinv.ModInverse(k, user.q)
+// expK runs the key exchange with the service and returns the unconfirmed session.
+func (clt *Client) expK(ctx context.Context, user User, pwd string) (*Session, error) {
+	group := user.group
+	oprf := crypto.NewOPRF(group, crypto.ModeVOPRF)
+
+	cNonce, err := rand.Int(rand.Reader, group.Order())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate random cNonce")
+	}
 
-	if kinv == nil {
-		kinv = big.NewInt(0)
+	x, err := group.RandomScalar()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate random x")
 	}
+	X := group.Exp(group.Generator(), x)
 
-	b := crypto.ExpInGroup(g, k, user.q)
+	b, blind, err := oprf.Blind([]byte(pwd))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to blind password")
+	}
 
-	rd, err := contract.MarshalExpKRequest(contract.ExpKRequest{CID: user.cID, CNonce: cNonce, B: b, Q: user.q})
+	rd, err := contract.MarshalExpKRequest(contract.ExpKRequest{CID: user.cID, CNonce: cNonce, B: b, Q: group.Order(), X: X})
 	if err != nil {
-		return errors.Wrap(err, "failed to marshal ExpKRequest")
+		return nil, errors.Wrap(err, "failed to marshal ExpKRequest")
 	}
 
-	r, err := clt.poster.Post(clt.config.expkPath, clt.config.contentType, rd)
+	r, err := clt.send(ctx, http.MethodPost, clt.config.expkPath, rd)
 	if err != nil {
-		return errors.Wrap(err, "failed to post ExpKRequest")
+		return nil, errors.Wrap(err, "failed to post ExpKRequest")
 	}
 	defer r.Body.Close()
 
 	err = contract.UnmarshalIfError(r)
 	if err != nil {
-		return errors.Wrap(err, "failed to unmarshal error")
+		return nil, serviceError(err)
 	}
 
 	expKResp, err := contract.UnmarshalExpKResponse(r.Body)
 	if err != nil {
-		return errors.Wrap(err, "failed to unmarshal ExpKResponse")
+		return nil, errors.Wrap(err, "failed to unmarshal ExpKResponse")
 	}
 
-	B0 := crypto.ExpInGroup(expKResp.BD, kinv, user.q)
+	if !group.IsElement(expKResp.Y) {
+		return nil, errors.Wrap(ErrInvalidResponse, "ExpKResponse contains values outside of the group")
+	}
 
-	SKi := new(big.Int)
-	SKi.SetBytes(crypto.HmacData(clt.config.hash, expKResp.KV.Bytes(), user.cID.Bytes(), expKResp.SID.Bytes(), cNonce.Bytes(), expKResp.SNonce.Bytes()))
-	mk := new(big.Int)
-	mk.Mul(crypto.ExpInGroup(B0, user.k, user.q), expKResp.Q0)
+	proof := crypto.Proof{C: expKResp.ProofC, S: expKResp.ProofS}
+	mk, err := masterKey(user, oprf, b, blind, expKResp.BD, expKResp.Q0, proof)
+	if err != nil {
+		return nil, errors.Wrap(err, "ExpKResponse")
+	}
 
-	clt.session = NewSession(user, expKResp.SID, SKi, mk)
+	transcript := crypto.Transcript{
+		CID: user.cID, SID: expKResp.SID, CNonce: cNonce, SNonce: expKResp.SNonce,
+		B: b, BD: expKResp.BD, X: X, Y: expKResp.Y,
+	}
+	ak := crypto.AuthKey(group, mk)
+	SKi := crypto.SessionKey(clt.config.hash, group, group.Exp(expKResp.Y, x), group.Exp(expKResp.Y, ak), transcript)
 
-	return nil
+	session := NewSession(user, expKResp.SID, SKi, mk)
+	wipeInt(SKi)
+	wipeInt(mk)
+	return session, nil
 }
 
-// Challenge session key SKi
-func (clt *Client) Challenge() error {
-
-	if clt.session == nil {
-		return ErrLoginRequired
-	}
+// challenge completes the login: the client proves and verifies that both sides derived
+// the same session key SKi. The service rejects all operations of a session before.
+// Different session keys mean a wrong password and fail with ErrAuthenticationFailed.
+func (clt *Client) challenge(ctx context.Context) error {
 
-	g, err := rand.Int(rand.Reader, clt.session.user.q)
+	group := clt.session.user.group
+	g, err := group.RandomElement()
 	if err != nil {
 		return errors.Wrap(err, "failed to generate random g")
 	}
 
-	rd, err := contract.MarshalChallengeRequest(contract.ChallengeRequest{G: g, Q: clt.session.user.q})
+	challReq := contract.ChallengeRequest{Counter: clt.session.next(), Timestamp: time.Now(), G: g, Q: group.Order()}
+	challReq.MAC = clt.mac(challReq.Canonical())
+
+	rd, err := contract.MarshalChallengeRequest(challReq)
 	if err != nil {
 		return errors.Wrap(err, "failed to marshal ChallengeRequest")
 	}
 
-	r, err := clt.poster.Post(clt.config.challengePath, clt.config.contentType, rd)
+	r, err := clt.send(ctx, http.MethodPost, clt.config.challengePath, rd)
 	if err != nil {
 		return errors.Wrap(err, "failed to post ChallengeRequest")
 	}
 	defer r.Body.Close()
 
 	err = contract.UnmarshalIfError(r)
+	if e, ok := errors.Cause(err).(*contract.Error); ok && e.Code == contract.CodeMACMismatch {
+		return errors.Wrap(ErrAuthenticationFailed, "service derived another session key")
+	}
 	if err != nil {
-		return errors.Wrap(err, "failed to unmarshal error")
+		return serviceError(err)
 	}
 
 	response, err := contract.UnmarshalChallengeResponse(r.Body)
@@ -183,29 +466,30 @@ func (clt *Client) Challenge() error {
 		return errors.Wrap(err, "failed to unmarshal ChallengeResponse")
 	}
 
-	verifier := crypto.ExpInGroup(g, clt.session.ski, clt.session.user.q)
+	verifier := group.Exp(g, clt.session.ski)
 	if response.R.Cmp(verifier) != 0 {
-		return ErrOperationFailed
+		return errors.Wrap(ErrAuthenticationFailed, "service derived another session key")
 	}
 
 	return nil
 }
 
 // GetMetadata ...
-func (clt *Client) GetMetadata() ([]string, error) {
+func (clt *Client) GetMetadata(ctx context.Context) ([]string, error) {
 
 	if clt.session == nil {
 		return nil, ErrLoginRequired
 	}
 
-	mac := crypto.HmacData(clt.config.hash, clt.session.ski.Bytes(), []byte("metadata"))
+	metaReq := contract.MetadataRequest{Counter: clt.session.next(), Timestamp: time.Now()}
+	metaReq.MAC = clt.mac(metaReq.Canonical())
 
-	rd, err := contract.MarshalMetadataRequest(contract.MetadataRequest{MAC: mac})
+	rd, err := contract.MarshalMetadataRequest(metaReq)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to marshal MetadataRequest")
 	}
 
-	r, err := clt.poster.Post(clt.config.metadataPath, clt.config.contentType, rd)
+	r, err := clt.send(ctx, http.MethodPost, clt.config.metadataPath, rd)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to post MetadataRequest")
 	}
@@ -213,7 +497,7 @@ func (clt *Client) GetMetadata() ([]string, error) {
 
 	err = contract.UnmarshalIfError(r)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to unmarshal error")
+		return nil, serviceError(err)
 	}
 
 	metaResp, err := contract.UnmarshalMetadataResponse(r.Body)
@@ -224,24 +508,43 @@ func (clt *Client) GetMetadata() ([]string, error) {
 
 }
 
-// Add ...
-func (clt *Client) Add(domain string) error {
+// Add a domain with the DefaultPolicy.
+func (clt *Client) Add(ctx context.Context, domain string) error {
+	return clt.AddWithPolicy(ctx, domain, DefaultPolicy())
+}
+
+// AddWithPolicy adds a domain whose passwords are rendered according to policy p.
+// The policy is stored with the domain, so that every device derives the same password.
+func (clt *Client) AddWithPolicy(ctx context.Context, domain string, p Policy) error {
 
 	if clt.session == nil {
 		return ErrLoginRequired
 	}
 
-	mac := crypto.HmacData(clt.config.hash, clt.session.ski.Bytes(), []byte(domain))
+	err := p.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "AddWithPolicy %s", domain)
+	}
 
-	rd, err := contract.MarshalAddRequest(contract.AddRequest{
-		Domain: domain,
-		MAC:    mac,
-	})
+	metadata, err := marshalDomainMetadata(domainMetadata{Policy: &p})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal metadata")
+	}
+
+	addReq := contract.AddRequest{
+		Counter:   clt.session.next(),
+		Timestamp: time.Now(),
+		Domain:    domain,
+		Metadata:  metadata,
+	}
+	addReq.MAC = clt.mac(addReq.Canonical())
+
+	rd, err := contract.MarshalAddRequest(addReq)
 	if err != nil {
 		return errors.Wrap(err, "failed to marshal AddRequest")
 	}
 
-	r, err := clt.poster.Post(clt.config.addPath, clt.config.contentType, rd)
+	r, err := clt.send(ctx, http.MethodPost, clt.config.addPath, rd)
 	if err != nil {
 		return errors.Wrap(err, "failed to post AddRequest")
 	}
@@ -249,7 +552,7 @@ func (clt *Client) Add(domain string) error {
 
 	err = contract.UnmarshalIfError(r)
 	if err != nil {
-		return errors.Wrap(err, "failed to unmarshal error")
+		return serviceError(err)
 	}
 
 	if r.StatusCode != http.StatusCreated {
@@ -259,39 +562,46 @@ func (clt *Client) Add(domain string) error {
 	return nil
 }
 
-// Get ...
-func (clt *Client) Get(domain string) (string, error) {
+// Get the password of domain rendered according to the policy of the domain.
+// Domains added without policy keep the hex encoding of rwd.
+func (clt *Client) Get(ctx context.Context, domain string) (string, error) {
+	return clt.get(ctx, domain, false)
+}
+
+// GetPrevious returns the password of domain before its last rotation,
+// which is only possible during the grace period of the rotation.
+func (clt *Client) GetPrevious(ctx context.Context, domain string) (string, error) {
+	return clt.get(ctx, domain, true)
+}
+
+func (clt *Client) get(ctx context.Context, domain string, previous bool) (string, error) {
 
 	if clt.session == nil {
 		return "", ErrLoginRequired
 	}
 
-	k, err := rand.Int(rand.Reader, clt.session.user.q)
+	group := clt.session.user.group
+	bmk, blind, err := crypto.Blind(group, clt.session.mk)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to generate random k")
+		return "", errors.Wrap(err, "failed to blind mk")
 	}
-	kinv := new(big.Int)
-	kinv.ModInverse(k, clt.session.user.q)
 
-	if kinv == nil {
-		kinv = big.NewInt(0)
+	getReq := contract.GetRequest{
+		Counter:   clt.session.next(),
+		Timestamp: time.Now(),
+		Domain:    domain,
+		BMK:       bmk,
+		Q:         group.Order(),
+		Previous:  previous,
 	}
+	getReq.MAC = clt.mac(getReq.Canonical())
 
-	bmk := crypto.ExpInGroup(clt.session.mk, k, clt.session.user.q)
-
-	mac := crypto.HmacData(clt.config.hash, clt.session.ski.Bytes(), bmk.Bytes())
-
-	rd, err := contract.MarshalGetRequest(contract.GetRequest{
-		Domain: domain,
-		MAC:    mac,
-		BMK:    bmk,
-		Q:      clt.session.user.q,
-	})
+	rd, err := contract.MarshalGetRequest(getReq)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to marshal GetRequest")
 	}
 
-	r, err := clt.poster.Post(clt.config.getPath, clt.config.contentType, rd)
+	r, err := clt.send(ctx, http.MethodPost, clt.config.getPath, rd)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to post GetRequest")
 	}
@@ -299,7 +609,7 @@ func (clt *Client) Get(domain string) (string, error) {
 
 	err = contract.UnmarshalIfError(r)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to unmarshal error")
+		return "", serviceError(err)
 	}
 
 	getResp, err := contract.UnmarshalGetResponse(r.Body)
@@ -307,22 +617,224 @@ func (clt *Client) Get(domain string) (string, error) {
 		return "", errors.Wrap(err, "failed to unmarshal GetResponse")
 	}
 
-	B0 := crypto.ExpInGroup(getResp.Bj, kinv, clt.session.user.q)
+	if !group.IsElement(getResp.Bj) || !group.IsElement(getResp.Qj) {
+		return "", errors.Wrap(ErrInvalidResponse, "GetResponse contains values outside of the group")
+	}
+
+	B0 := crypto.Unblind(group, getResp.Bj, blind)
+
+	rwd := group.Mul(group.Exp(B0, clt.session.user.k), getResp.Qj)
+
+	metadata, err := unmarshalDomainMetadata(getResp.Metadata)
+	if err != nil {
+		return "", errors.Wrap(ErrInvalidResponse, err.Error())
+	}
+	if metadata.Policy == nil {
+		return rwd.Text(16), nil
+	}
+
+	pwd, err := metadata.Policy.Password(group.Encode(rwd), domain)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to render password")
+	}
+	return pwd, nil
+}
+
+// Rotate replaces the password of domain by a new one. The previous password stays
+// retrievable with GetPrevious until the returned time, so that it can be changed at the domain.
+func (clt *Client) Rotate(ctx context.Context, domain string) (time.Time, error) {
+
+	if clt.session == nil {
+		return time.Time{}, ErrLoginRequired
+	}
+
+	rotReq := contract.RotateRequest{
+		Counter:   clt.session.next(),
+		Timestamp: time.Now(),
+		Domain:    domain,
+	}
+	rotReq.MAC = clt.mac(rotReq.Canonical())
+
+	rd, err := contract.MarshalRotateRequest(rotReq)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to marshal RotateRequest")
+	}
+
+	r, err := clt.send(ctx, http.MethodPost, clt.config.rotatePath, rd)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to post RotateRequest")
+	}
+	defer r.Body.Close()
+
+	err = contract.UnmarshalIfError(r)
+	if err != nil {
+		return time.Time{}, serviceError(err)
+	}
+
+	rotResp, err := contract.UnmarshalRotateResponse(r.Body)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to unmarshal RotateResponse")
+	}
+	return rotResp.PreviousUntil, nil
+}
+
+// Delete removes domain at the service. Its passwords can not be derived anymore,
+// adding domain again derives new ones.
+func (clt *Client) Delete(ctx context.Context, domain string) error {
+
+	if clt.session == nil {
+		return ErrLoginRequired
+	}
+
+	delReq := contract.DeleteRequest{
+		Counter:   clt.session.next(),
+		Timestamp: time.Now(),
+		Domain:    domain,
+	}
+	delReq.MAC = clt.mac(delReq.Canonical())
+
+	rd, err := contract.MarshalDeleteRequest(delReq)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal DeleteRequest")
+	}
+
+	r, err := clt.send(ctx, http.MethodPost, clt.config.deletePath, rd)
+	if err != nil {
+		return errors.Wrap(err, "failed to post DeleteRequest")
+	}
+	defer r.Body.Close()
+
+	err = contract.UnmarshalIfError(r)
+	if err != nil {
+		return serviceError(err)
+	}
+	return nil
+}
+
+// Sessions returns the active sessions of the user at the service ordered by creation,
+// the session of the client is marked as current.
+func (clt *Client) Sessions(ctx context.Context) ([]contract.SessionInfo, error) {
+
+	if clt.session == nil {
+		return nil, ErrLoginRequired
+	}
 
-	rwd := new(big.Int)
-	rwd.Mul(crypto.ExpInGroup(B0, clt.session.user.k, clt.session.user.q), getResp.Qj)
+	sessReq := contract.SessionsRequest{Counter: clt.session.next(), Timestamp: time.Now()}
+	sessReq.MAC = clt.mac(sessReq.Canonical())
 
-	return rwd.Text(16), nil
+	rd, err := contract.MarshalSessionsRequest(sessReq)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal SessionsRequest")
+	}
+
+	r, err := clt.send(ctx, http.MethodPost, clt.config.sessionsPath, rd)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to post SessionsRequest")
+	}
+	defer r.Body.Close()
+
+	err = contract.UnmarshalIfError(r)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+
+	sessResp, err := contract.UnmarshalSessionsResponse(r.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal SessionsResponse")
+	}
+	return sessResp.Sessions, nil
+}
+
+// RevokeSession ends the session with id of the user at the service, e.g. of a lost device.
+// Unknown sessions are ignored.
+func (clt *Client) RevokeSession(ctx context.Context, id string) error {
+	return clt.revokeSessions(ctx, contract.RevokeSessionsRequest{ID: id})
 }
 
-// Logout ...
-func (clt *Client) Logout() error {
-	r, err := clt.poster.Post(clt.config.logoutPath, clt.config.contentType, nil)
+// RevokeAllSessions ends all sessions of the user at the service including the one of the client,
+// which is wiped.
+func (clt *Client) RevokeAllSessions(ctx context.Context) error {
+	err := clt.revokeSessions(ctx, contract.RevokeSessionsRequest{All: true})
+	if err != nil {
+		return err
+	}
+	clt.Wipe()
+	return nil
+}
+
+func (clt *Client) revokeSessions(ctx context.Context, revReq contract.RevokeSessionsRequest) error {
+
+	if clt.session == nil {
+		return ErrLoginRequired
+	}
+
+	revReq.Counter = clt.session.next()
+	revReq.Timestamp = time.Now()
+	revReq.MAC = clt.mac(revReq.Canonical())
+
+	rd, err := contract.MarshalRevokeSessionsRequest(revReq)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal RevokeSessionsRequest")
+	}
+
+	r, err := clt.send(ctx, http.MethodPost, clt.config.revokePath, rd)
+	if err != nil {
+		return errors.Wrap(err, "failed to post RevokeSessionsRequest")
+	}
+	defer r.Body.Close()
+
+	err = contract.UnmarshalIfError(r)
+	if err != nil {
+		return serviceError(err)
+	}
+	return nil
+}
+
+// send sends a request with the JSON body to url, which is cancelled with ctx
+// and carries the request ID of ctx if it has one, see contract.WithRequestID.
+func (clt *Client) send(ctx context.Context, method, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", clt.config.contentType)
+	}
+	if id := contract.RequestID(ctx); id != "" {
+		req.Header.Set(contract.RequestIDHeader, id)
+	}
+	return clt.poster.Do(req)
+}
+
+// mac authenticates the canonical encoding of a request with the session key SKi.
+func (clt *Client) mac(canonical []byte) []byte {
+	return crypto.HmacData(clt.config.hash, clt.session.ski.Bytes(), canonical)
+}
+
+// Logout ends the session at the service and wipes it, even if the service is offline.
+func (clt *Client) Logout(ctx context.Context) error {
+	defer clt.Wipe()
+
+	r, err := clt.send(ctx, http.MethodPost, clt.config.logoutPath, nil)
 	if err != nil {
 		return errors.Wrap(err, "failed to post LogoutRequest")
 	}
 	defer r.Body.Close()
 
-	clt.session = nil
 	return nil
 }
+
+// LoggedIn returns whether the client holds a session.
+func (clt *Client) LoggedIn() bool {
+	return clt.session != nil
+}
+
+// Wipe overwrites the keys of the session and forgets it without calling the service,
+// which expires the session on its own.
+func (clt *Client) Wipe() {
+	if clt.session == nil {
+		return
+	}
+	clt.session.wipe()
+	clt.session = nil
+}
@@ -1,6 +1,7 @@
 package client_test
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/tls"
@@ -49,7 +50,7 @@ func benchmarkClient_Register(b *testing.B, bits int, hashfn func() hash.Hash) {
 	b.ResetTimer()
 
 	for n := 0; n < b.N; n++ {
-		err := clt.Register(users[n])
+		err := clt.Register(context.Background(), users[n], "password")
 		if err != nil {
 			b.Errorf("Register() error = %v", err)
 		}
@@ -91,7 +92,7 @@ func benchmarkClient_Login(b *testing.B, bits int, hashfn func() hash.Hash) {
 	users := generator(b, b.N)
 
 	for i := 0; i < b.N; i++ {
-		err := clt.Register(users[i])
+		err := clt.Register(context.Background(), users[i], "password")
 		if err != nil {
 			b.Errorf("Register() error = %v", err)
 		}
@@ -100,15 +101,11 @@ func benchmarkClient_Login(b *testing.B, bits int, hashfn func() hash.Hash) {
 	b.ResetTimer()
 
 	for n := 0; n < b.N; n++ {
-		err := clt.Login(users[n], "password")
+		err := clt.Login(context.Background(), users[n], "password")
 		if err != nil {
 			b.Errorf("Register() error = %v", err)
 		}
-		err = clt.Challenge()
-		if err != nil {
-			b.Errorf("Challenge() error = %v", err)
-		}
-		clt.Logout()
+		clt.Logout(context.Background())
 	}
 
 	b.StopTimer()
@@ -143,27 +140,22 @@ func benchmarkClient_Add(b *testing.B, bits int, hashfn func() hash.Hash) {
 		b.Errorf("creating oscli() error = %v", err)
 	}
 
-	err = clt.Register("add-domain-user")
+	err = clt.Register(context.Background(), "add-domain-user", "password")
 	if err != nil {
 		b.Errorf("Register() error = %+v", err)
 	}
 
-	err = clt.Login("add-domain-user", "password")
+	err = clt.Login(context.Background(), "add-domain-user", "password")
 	if err != nil {
 		b.Errorf("Login() error = %+v", err)
 	}
 
-	err = clt.Challenge()
-	if err != nil {
-		b.Errorf("Challenge() error = %+v", err)
-	}
-
 	domains := generator(b, b.N)
 
 	b.ResetTimer()
 
 	for n := 0; n < b.N; n++ {
-		err = clt.Add(domains[n])
+		err = clt.Add(context.Background(), domains[n])
 		if err != nil {
 			b.Errorf("Add() error = %v", err)
 		}
@@ -203,22 +195,17 @@ func benchmarkClient_GetMetadata(b *testing.B, bits int, hashfn func() hash.Hash
 		b.Errorf("creating oscli() error = %v", err)
 	}
 
-	err = clt.Register("add-domain-user")
+	err = clt.Register(context.Background(), "add-domain-user", "password")
 	if err != nil {
 		b.Errorf("Register() error = %+v", err)
 	}
 
-	err = clt.Login("add-domain-user", "password")
+	err = clt.Login(context.Background(), "add-domain-user", "password")
 	if err != nil {
 		b.Errorf("Login() error = %+v", err)
 	}
 
-	err = clt.Challenge()
-	if err != nil {
-		b.Errorf("Challenge() error = %+v", err)
-	}
-
-	err = clt.Add("google.com")
+	err = clt.Add(context.Background(), "google.com")
 	if err != nil {
 		b.Errorf("Add() error = %v", err)
 	}
@@ -226,7 +213,7 @@ func benchmarkClient_GetMetadata(b *testing.B, bits int, hashfn func() hash.Hash
 	b.ResetTimer()
 
 	for n := 0; n < b.N; n++ {
-		ds, err := clt.GetMetadata()
+		ds, err := clt.GetMetadata(context.Background())
 		if err != nil {
 			b.Errorf("GetMetadata() error = %v", err)
 		}
@@ -267,22 +254,17 @@ func benchmarkClient_Get(b *testing.B, bits int, hashfn func() hash.Hash) {
 		b.Errorf("creating oscli() error = %v", err)
 	}
 
-	err = clt.Register("get-domain-user")
+	err = clt.Register(context.Background(), "get-domain-user", "password")
 	if err != nil {
 		b.Errorf("Register() error = %v", err)
 	}
 
-	err = clt.Login("get-domain-user", "password")
+	err = clt.Login(context.Background(), "get-domain-user", "password")
 	if err != nil {
 		b.Errorf("Login() error = %v", err)
 	}
 
-	err = clt.Challenge()
-	if err != nil {
-		b.Errorf("Challenge() error = %v", err)
-	}
-
-	err = clt.Add("new-domain")
+	err = clt.Add(context.Background(), "new-domain")
 	if err != nil {
 		b.Errorf("Add() error = %v", err)
 	}
@@ -290,7 +272,7 @@ func benchmarkClient_Get(b *testing.B, bits int, hashfn func() hash.Hash) {
 	b.ResetTimer()
 
 	for n := 0; n < b.N; n++ {
-		gpwd, err := clt.Get("new-domain")
+		gpwd, err := clt.Get(context.Background(), "new-domain")
 		if err != nil {
 			b.Errorf("Add() error = %v", err)
 		}
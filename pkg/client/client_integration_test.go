@@ -3,6 +3,7 @@
 package client_test
 
 import (
+	"context"
 	"crypto/sha256"
 	"crypto/tls"
 	"hash"
@@ -12,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/LAtanassov/go-online-sphinx/pkg/client"
+	"github.com/pkg/errors"
 )
 
 func TestITClient_Register(t *testing.T) {
@@ -22,7 +24,7 @@ func TestITClient_Register(t *testing.T) {
 
 	t.Run("should register a new user ID", func(t *testing.T) {
 
-		err = clt.Register("registered-user")
+		err = clt.Register(context.Background(), "registered-user", "password")
 		if err != nil {
 			t.Errorf("Register() error = %v", err)
 		}
@@ -30,12 +32,12 @@ func TestITClient_Register(t *testing.T) {
 
 	t.Run("should not be able to register with an existing user ID", func(t *testing.T) {
 
-		err = clt.Register("double-registered-user")
+		err = clt.Register(context.Background(), "double-registered-user", "password")
 		if err != nil {
 			t.Errorf("Register() error = %v", err)
 		}
 		// when
-		err = clt.Register("double-registered-user")
+		err = clt.Register(context.Background(), "double-registered-user", "password")
 		if err == nil {
 			t.Errorf("Register() no error but got err = %v", err)
 		}
@@ -50,33 +52,25 @@ func TestITClient_Login(t *testing.T) {
 		t.Errorf("creating oscli() error = %v", err)
 	}
 
-	err = clt.Register("login-username")
+	err = clt.Register(context.Background(), "login-username", "password")
 	if err != nil {
 		t.Errorf("Register() error = %v", err)
 	}
 
 	t.Run("should login with a valid password", func(t *testing.T) {
-		err := clt.Login("login-username", "password")
+		err := clt.Login(context.Background(), "login-username", "password")
 		if err != nil {
 			t.Errorf("Login() error = %v", err)
 		}
-		err = clt.Challenge()
-		if err != nil {
-			t.Errorf("Login() error = %v", err)
-		}
-		clt.Logout()
+		clt.Logout(context.Background())
 	})
 
 	t.Run("should recv. common error if wrong password", func(t *testing.T) {
-		err := clt.Login("login-username", "wrong-password")
-		if err == nil {
-			t.Errorf("Login() want error but got wantErr = %v", err)
-		}
-		err = clt.Challenge()
-		if err != nil {
-			t.Errorf("Login() error = %v", err)
+		err := clt.Login(context.Background(), "login-username", "wrong-password")
+		if errors.Cause(err) != client.ErrAuthenticationFailed {
+			t.Errorf("Login() error = %v wantErr = %v", err, client.ErrAuthenticationFailed)
 		}
-		clt.Logout()
+		clt.Logout(context.Background())
 	})
 }
 
@@ -87,23 +81,18 @@ func TestITClient_GetMetadata(t *testing.T) {
 		t.Errorf("creating oscli() error = %v", err)
 	}
 
-	err = clt.Register("get-metadata-username")
+	err = clt.Register(context.Background(), "get-metadata-username", "password")
 	if err != nil {
 		t.Errorf("Register() error = %v", err)
 	}
 
 	t.Run("should have no domains", func(t *testing.T) {
-		err := clt.Login("get-metadata-username", "password")
+		err := clt.Login(context.Background(), "get-metadata-username", "password")
 		if err != nil {
 			t.Errorf("Login() error = %v", err)
 		}
 
-		err = clt.Challenge()
-		if err != nil {
-			t.Errorf("Challenge() error = %v", err)
-		}
-
-		domains, err := clt.GetMetadata()
+		domains, err := clt.GetMetadata(context.Background())
 		if err != nil {
 			t.Errorf("GetMetadata() error = %v", err)
 		}
@@ -116,23 +105,18 @@ func TestITClient_GetMetadata(t *testing.T) {
 	t.Run("should have google.com domain", func(t *testing.T) {
 		// given
 		wantDomains := []string{"google.com"}
-		err := clt.Login("get-metadata-username", "password")
+		err := clt.Login(context.Background(), "get-metadata-username", "password")
 		if err != nil {
 			t.Errorf("Login() error = %v", err)
 		}
 
-		err = clt.Challenge()
-		if err != nil {
-			t.Errorf("Challenge() error = %v", err)
-		}
-
-		err = clt.Add(wantDomains[0])
+		err = clt.Add(context.Background(), wantDomains[0])
 		if err != nil {
 			t.Errorf("Add() error = %v", err)
 		}
 
 		// when
-		domains, err := clt.GetMetadata()
+		domains, err := clt.GetMetadata(context.Background())
 		if err != nil {
 			t.Errorf("GetMetadata() error = %v", err)
 		}
@@ -150,7 +134,7 @@ func TestITClient_Add(t *testing.T) {
 		t.Errorf("creating oscli() error = %v", err)
 	}
 
-	err = clt.Register("add-domain-username")
+	err = clt.Register(context.Background(), "add-domain-username", "password")
 	if err != nil {
 		t.Errorf("Register() error = %v", err)
 	}
@@ -158,23 +142,18 @@ func TestITClient_Add(t *testing.T) {
 	t.Run("should add a new domain with name google.com", func(t *testing.T) {
 		// given
 		wantDomains := []string{"google.com"}
-		err := clt.Login("add-domain-username", "password")
+		err := clt.Login(context.Background(), "add-domain-username", "password")
 		if err != nil {
 			t.Errorf("Login() error = %v", err)
 		}
 
-		err = clt.Challenge()
-		if err != nil {
-			t.Errorf("Challenge() error = %v", err)
-		}
-
-		err = clt.Add(wantDomains[0])
+		err = clt.Add(context.Background(), wantDomains[0])
 		if err != nil {
 			t.Errorf("Add() error = %v", err)
 		}
 
 		// when
-		domains, err := clt.GetMetadata()
+		domains, err := clt.GetMetadata(context.Background())
 		if err != nil {
 			t.Errorf("GetMetadata() error = %v", err)
 		}
@@ -192,7 +171,7 @@ func TestITClient_Get(t *testing.T) {
 		t.Errorf("creating oscli() error = %v", err)
 	}
 
-	err = clt.Register("get-domain-username")
+	err = clt.Register(context.Background(), "get-domain-username", "password")
 	if err != nil {
 		t.Errorf("Register() error = %v", err)
 	}
@@ -200,28 +179,23 @@ func TestITClient_Get(t *testing.T) {
 	t.Run("should get the same password within one session", func(t *testing.T) {
 		// given
 		domain := "google.com"
-		err := clt.Login("get-domain-username", "password")
+		err := clt.Login(context.Background(), "get-domain-username", "password")
 		if err != nil {
 			t.Errorf("Login() error = %v", err)
 		}
 
-		err = clt.Challenge()
-		if err != nil {
-			t.Errorf("Challenge() error = %v", err)
-		}
-
-		err = clt.Add(domain)
+		err = clt.Add(context.Background(), domain)
 		if err != nil {
 			t.Errorf("Add() error = %v", err)
 		}
 
 		// when
-		pwda, err := clt.Get(domain)
+		pwda, err := clt.Get(context.Background(), domain)
 		if err != nil {
 			t.Errorf("Get() error = %v", err)
 		}
 
-		pwdb, err := clt.Get(domain)
+		pwdb, err := clt.Get(context.Background(), domain)
 		if err != nil {
 			t.Errorf("Get() error = %v", err)
 		}
@@ -230,46 +204,36 @@ func TestITClient_Get(t *testing.T) {
 			t.Errorf("pwda = %v pwdb = %v", pwda, pwdb)
 		}
 
-		clt.Logout()
+		clt.Logout(context.Background())
 	})
 
 	t.Run("should get the same password from two different sessions", func(t *testing.T) {
 		// given
-		domain := "google.com"
-		err := clt.Login("get-domain-username", "password")
+		domain := "github.com"
+		err := clt.Login(context.Background(), "get-domain-username", "password")
 		if err != nil {
 			t.Errorf("Login() error = %v", err)
 		}
 
-		err = clt.Challenge()
-		if err != nil {
-			t.Errorf("Challenge() error = %v", err)
-		}
-
-		err = clt.Add(domain)
+		err = clt.Add(context.Background(), domain)
 		if err != nil {
 			t.Errorf("Add() error = %v", err)
 		}
 
 		// when
-		pwda, err := clt.Get(domain)
+		pwda, err := clt.Get(context.Background(), domain)
 		if err != nil {
 			t.Errorf("Get() error = %v", err)
 		}
 
-		clt.Logout()
+		clt.Logout(context.Background())
 
-		err = clt.Login("get-domain-username", "password")
+		err = clt.Login(context.Background(), "get-domain-username", "password")
 		if err != nil {
 			t.Errorf("Login() error = %v", err)
 		}
 
-		err = clt.Challenge()
-		if err != nil {
-			t.Errorf("Challenge() error = %v", err)
-		}
-
-		pwdb, err := clt.Get(domain)
+		pwdb, err := clt.Get(context.Background(), domain)
 		if err != nil {
 			t.Errorf("Get() error = %v", err)
 		}
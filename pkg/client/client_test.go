@@ -3,25 +3,152 @@ package client
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/hmac"
 	"crypto/sha256"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
+	"github.com/LAtanassov/go-online-sphinx/pkg/crypto"
 	"github.com/pkg/errors"
 )
 
+// testGroup is a tiny safe prime group with q = 1019 and p = 2039.
+var testGroup = mustNewGroup("test", big.NewInt(1019))
+
+func mustNewGroup(name string, q *big.Int) crypto.Group {
+	g, err := crypto.NewGroup(name, q)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// akeServer is a fake Online SPHINX service, which registers users with key k
+// and logs them in by the password-bound key exchange.
+type akeServer struct {
+	*httptest.Server
+	t         *testing.T
+	g         crypto.Group
+	k         *big.Int
+	q0        *big.Int
+	verifiers map[string]*big.Int
+	codes     map[string]string
+	ski       *big.Int
+}
+
+func newAKEServer(t *testing.T, g crypto.Group, k *big.Int) *akeServer {
+	s := &akeServer{t: t, g: g, k: k, q0: g.Generator(), verifiers: make(map[string]*big.Int), codes: make(map[string]string)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/group", s.group)
+	mux.HandleFunc("/v1/register/expk", s.registerExpK)
+	mux.HandleFunc("/v1/register", s.register)
+	mux.HandleFunc("/v1/enroll", s.enroll)
+	mux.HandleFunc("/v1/login/expk", s.expK)
+	mux.HandleFunc("/v1/login/challenge", s.challenge)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *akeServer) group(w http.ResponseWriter, r *http.Request) {
+	pk := crypto.NewOPRF(s.g, crypto.ModeVOPRF).PublicKey(s.k)
+	contract.MarshalGroupResponse(w, contract.GroupResponse{Name: s.g.Name(), Q: s.g.Order(), PK: pk})
+}
+
+func (s *akeServer) evaluate(b *big.Int) (*big.Int, crypto.Proof) {
+	oprf := crypto.NewOPRF(s.g, crypto.ModeVOPRF)
+	bd := oprf.BlindEvaluate(s.k, b)
+	proof, err := oprf.Prove(s.k, []*big.Int{b}, []*big.Int{bd})
+	if err != nil {
+		s.t.Errorf("Prove() error = %v", err)
+	}
+	return bd, proof
+}
+
+func (s *akeServer) registerExpK(w http.ResponseWriter, r *http.Request) {
+	req, err := contract.UnmarshalRegisterExpKRequest(r.Body)
+	if err != nil {
+		s.t.Errorf("UnmarshalRegisterExpKRequest() error = %v", err)
+	}
+	bd, proof := s.evaluate(req.B)
+	contract.MarshalRegisterExpKResponse(w, contract.RegisterExpKResponse{BD: bd, Q0: s.q0, ProofC: proof.C, ProofS: proof.S})
+}
+
+func (s *akeServer) register(w http.ResponseWriter, r *http.Request) {
+	req, err := contract.UnmarshalRegisterRequest(r.Body)
+	if err != nil {
+		s.t.Errorf("UnmarshalRegisterRequest() error = %v", err)
+	}
+	s.verifiers[req.CID.String()] = req.Verifier
+	w.WriteHeader(http.StatusCreated)
+	s.group(w, r)
+}
+
+func (s *akeServer) enroll(w http.ResponseWriter, r *http.Request) {
+	req, err := contract.UnmarshalEnrollRequest(r.Body)
+	if err != nil {
+		s.t.Errorf("UnmarshalEnrollRequest() error = %v", err)
+	}
+	proof := crypto.PossessionProof{E: req.E, Proof: crypto.Proof{C: req.ProofC, S: req.ProofS}}
+	if err := crypto.VerifyPossession(s.g, req.Verifier, req.CID, proof); err != nil {
+		s.t.Errorf("VerifyPossession() error = %v", err)
+	}
+	if code, ok := s.codes[req.CID.String()]; !ok || code != req.Code {
+		contract.MarshalError(w, contract.NewError(contract.CodeEnrollmentInvalid, "invalid enrollment code"))
+		return
+	}
+	delete(s.codes, req.CID.String())
+	s.verifiers[req.CID.String()] = req.Verifier
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *akeServer) expK(w http.ResponseWriter, r *http.Request) {
+	req, err := contract.UnmarshalExpKRequest(r.Body)
+	if err != nil {
+		s.t.Errorf("UnmarshalExpKRequest() error = %v", err)
+	}
+	verifier, ok := s.verifiers[req.CID.String()]
+	if !ok {
+		contract.MarshalError(w, contract.NewError(contract.CodeUserNotFound, "user not found"))
+		return
+	}
+	bd, proof := s.evaluate(req.B)
+	y, _ := s.g.RandomScalar()
+	t := crypto.Transcript{
+		CID: req.CID, SID: big.NewInt(2), CNonce: req.CNonce, SNonce: big.NewInt(3),
+		B: req.B, BD: bd, X: req.X, Y: s.g.Exp(s.g.Generator(), y),
+	}
+	s.ski = crypto.SessionKey(sha256.New, s.g, s.g.Exp(req.X, y), s.g.Exp(verifier, y), t)
+	contract.MarshalExpKResponse(w, contract.ExpKResponse{
+		SID: t.SID, SNonce: t.SNonce, BD: bd, Q0: s.q0, Y: t.Y, ProofC: proof.C, ProofS: proof.S,
+	})
+}
+
+func (s *akeServer) challenge(w http.ResponseWriter, r *http.Request) {
+	req, err := contract.UnmarshalChallengeRequest(r.Body)
+	if err != nil {
+		s.t.Errorf("UnmarshalChallengeRequest() error = %v", err)
+	}
+	if !hmac.Equal(req.MAC, crypto.HmacData(sha256.New, s.ski.Bytes(), req.Canonical())) {
+		contract.MarshalError(w, contract.NewError(contract.CodeMACMismatch, "mac mismatch"))
+		return
+	}
+	contract.MarshalChallengeResponse(w, contract.ChallengeResponse{R: s.g.Exp(req.G, s.ski)})
+}
+
 func TestClient_Register(t *testing.T) {
 
 	repo := NewInMemoryUserRepository()
 
 	t.Run("should register a new user", func(t *testing.T) {
 		// given
-		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusCreated)
-		}))
+		ts := newAKEServer(t, testGroup, big.NewInt(7))
 		defer ts.Close()
 
 		// when
@@ -29,7 +156,7 @@ func TestClient_Register(t *testing.T) {
 		if err != nil {
 			t.Errorf("NewConfiguration() error = %v", err)
 		}
-		err = New(http.DefaultClient, cfg, repo).Register("username")
+		err = New(http.DefaultClient, cfg, repo).Register(context.Background(), "username", "password")
 		if err != nil {
 			t.Errorf("Register() error = %v", err)
 		}
@@ -54,44 +181,350 @@ func TestClient_Register(t *testing.T) {
 		if err != nil {
 			t.Errorf("NewConfiguration() error = %v", err)
 		}
-		err = New(http.DefaultClient, cfg, repo).Register("username")
+		err = New(http.DefaultClient, cfg, repo).Register(context.Background(), "username", "password")
 
 		if err == nil {
 			t.Errorf("Register() error = %v wantErr = %v", err, ErrTest)
 		}
 	})
+
+	t.Run("should return ErrInvalidResponse if the service uses no safe prime group", func(t *testing.T) {
+		// given
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contract.MarshalGroupResponse(w, contract.GroupResponse{Name: "smooth", Q: big.NewInt(1021)})
+		}))
+		defer ts.Close()
+
+		// when
+		cfg, err := NewConfiguration(ts.URL, 8, sha256.New)
+		if err != nil {
+			t.Errorf("NewConfiguration() error = %v", err)
+		}
+		err = New(http.DefaultClient, cfg, NewInMemoryUserRepository()).Register(context.Background(), "username", "password")
+
+		if errors.Cause(err) != ErrInvalidResponse {
+			t.Errorf("Register() error = %v wantErr = %v", err, ErrInvalidResponse)
+		}
+	})
+
+	t.Run("should return ErrInvalidResponse if the service uses a smaller group than configured", func(t *testing.T) {
+		// given
+		ts := newAKEServer(t, testGroup, big.NewInt(7))
+		defer ts.Close()
+
+		// when
+		cfg, err := NewConfiguration(ts.URL, 2048, sha256.New)
+		if err != nil {
+			t.Errorf("NewConfiguration() error = %v", err)
+		}
+		err = New(http.DefaultClient, cfg, NewInMemoryUserRepository()).Register(context.Background(), "username", "password")
+
+		if errors.Cause(err) != ErrInvalidResponse {
+			t.Errorf("Register() error = %v wantErr = %v", err, ErrInvalidResponse)
+		}
+	})
+
+	t.Run("should return ErrInvalidResponse if the service publishes no public key", func(t *testing.T) {
+		// given
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contract.MarshalGroupResponse(w, contract.GroupResponse{Name: testGroup.Name(), Q: testGroup.Order()})
+		}))
+		defer ts.Close()
+
+		// when
+		cfg, err := NewConfiguration(ts.URL, 8, sha256.New)
+		if err != nil {
+			t.Errorf("NewConfiguration() error = %v", err)
+		}
+		err = New(http.DefaultClient, cfg, NewInMemoryUserRepository()).Register(context.Background(), "username", "password")
+
+		if errors.Cause(err) != ErrInvalidResponse {
+			t.Errorf("Register() error = %v wantErr = %v", err, ErrInvalidResponse)
+		}
+	})
+
+	t.Run("should send the request ID of the context", func(t *testing.T) {
+		// given
+		var got string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header.Get(contract.RequestIDHeader)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer ts.Close()
+		ctx := contract.WithRequestID(context.Background(), "request-1")
+
+		// when
+		cfg, err := NewConfiguration(ts.URL, 8, sha256.New)
+		if err != nil {
+			t.Errorf("NewConfiguration() error = %v", err)
+		}
+		New(http.DefaultClient, cfg, NewInMemoryUserRepository()).Register(ctx, "username", "password")
+
+		if got != "request-1" {
+			t.Errorf("Register() request ID = %v, want %v", got, "request-1")
+		}
+	})
+
+	t.Run("should cancel the request with the context", func(t *testing.T) {
+		// given
+		ts := newAKEServer(t, testGroup, big.NewInt(7))
+		defer ts.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// when
+		cfg, err := NewConfiguration(ts.URL, 8, sha256.New)
+		if err != nil {
+			t.Errorf("NewConfiguration() error = %v", err)
+		}
+		err = New(http.DefaultClient, cfg, NewInMemoryUserRepository()).Register(ctx, "username", "password")
+
+		if err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+			t.Errorf("Register() error = %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestClient_Enroll(t *testing.T) {
+	// given a user whose verifier the service lost in a migration
+	ts := newAKEServer(t, testGroup, big.NewInt(7))
+	defer ts.Close()
+	cfg, err := NewConfiguration(ts.URL, 8, sha256.New)
+	if err != nil {
+		t.Fatalf("NewConfiguration() error = %v", err)
+	}
+	repo := NewInMemoryUserRepository()
+	clt := New(http.DefaultClient, cfg, repo)
+	if err := clt.Register(context.Background(), "username", "password"); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	user, _ := repo.Get("username")
+	ts.verifiers = make(map[string]*big.Int)
+	if err := clt.Login(context.Background(), "username", "password"); errors.Cause(err) != ErrNotRegistered {
+		t.Fatalf("Login() error = %v, want %v", err, ErrNotRegistered)
+	}
+
+	t.Run("should ask for the code of the client ID", func(t *testing.T) {
+		err := clt.Enroll(context.Background(), "username", "password", "")
+		if errors.Cause(err) != ErrEnrollmentInvalid || !strings.Contains(err.Error(), user.cID.Text(16)) {
+			t.Errorf("Enroll() error = %v, want %v with client ID", err, ErrEnrollmentInvalid)
+		}
+	})
+
+	t.Run("should reject a wrong code", func(t *testing.T) {
+		ts.codes[user.cID.String()] = "c0de"
+		err := clt.Enroll(context.Background(), "username", "password", "0bad")
+		if errors.Cause(err) != ErrEnrollmentInvalid {
+			t.Errorf("Enroll() error = %v, want %v", err, ErrEnrollmentInvalid)
+		}
+	})
+
+	t.Run("should enroll with the issued code and login", func(t *testing.T) {
+		ts.codes[user.cID.String()] = "c0de"
+
+		err := clt.Enroll(context.Background(), "username", "password", "c0de")
+
+		if err != nil {
+			t.Fatalf("Enroll() error = %v", err)
+		}
+		if err := clt.Login(context.Background(), "username", "password"); err != nil {
+			t.Errorf("Login() after Enroll() error = %v", err)
+		}
+		if err := clt.Enroll(context.Background(), "username", "password", "c0de"); errors.Cause(err) != ErrEnrollmentInvalid {
+			t.Errorf("Enroll() with used code error = %v, want %v", err, ErrEnrollmentInvalid)
+		}
+	})
+}
+
+func TestClient_Login_VOPRF(t *testing.T) {
+	g, err := crypto.GroupByName(crypto.P256)
+	if err != nil {
+		t.Fatalf("GroupByName() error = %v", err)
+	}
+	k, _ := g.RandomScalar()
+
+	ts := newAKEServer(t, g, k)
+	defer ts.Close()
+	cfg, err := NewConfiguration(ts.URL, 256, sha256.New)
+	if err != nil {
+		t.Fatalf("NewConfiguration() error = %v", err)
+	}
+	repo := NewInMemoryUserRepository()
+	err = New(http.DefaultClient, cfg, repo).Register(context.Background(), "username", "password")
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	t.Run("should login if the proof verifies against the registered key", func(t *testing.T) {
+		// when
+		clt := New(http.DefaultClient, cfg, repo)
+		err := clt.Login(context.Background(), "username", "password")
+
+		// then
+		if err != nil {
+			t.Errorf("Login() error = %v", err)
+		}
+		if clt.session == nil {
+			t.Errorf("Login() expect a session")
+		}
+	})
+
+	t.Run("should return ErrAuthenticationFailed with a wrong password", func(t *testing.T) {
+		clt := New(http.DefaultClient, cfg, repo)
+		err := clt.Login(context.Background(), "username", "wrong password")
+
+		if errors.Cause(err) != ErrAuthenticationFailed {
+			t.Errorf("Login() error = %v wantErr = %v", err, ErrAuthenticationFailed)
+		}
+		if clt.session != nil {
+			t.Errorf("Login() expect no session after a failed login")
+		}
+	})
+
+	t.Run("should return ErrInvalidResponse if the service uses another key", func(t *testing.T) {
+		// given
+		ts.k, _ = g.RandomScalar()
+		defer func() { ts.k = k }()
+
+		// when
+		err := New(http.DefaultClient, cfg, repo).Login(context.Background(), "username", "password")
+
+		// then
+		if errors.Cause(err) != ErrInvalidResponse {
+			t.Errorf("Login() error = %v wantErr = %v", err, ErrInvalidResponse)
+		}
+	})
+
+	t.Run("should pin the key of the service on the first login of a user without key", func(t *testing.T) {
+		// given
+		registered, _ := repo.Get("username")
+		user := registered
+		user.pk = nil
+		repo.Update(user)
+		defer repo.Update(registered)
+
+		// when
+		err := New(http.DefaultClient, cfg, repo).Login(context.Background(), "username", "password")
+
+		// then
+		if err != nil {
+			t.Fatalf("Login() error = %v", err)
+		}
+		got, _ := repo.Get("username")
+		if got.pk == nil || got.pk.Cmp(registered.pk) != 0 {
+			t.Errorf("Login() pinned pk = %v, want %v", got.pk, registered.pk)
+		}
+		ts.k, _ = g.RandomScalar()
+		defer func() { ts.k = k }()
+		if err := New(http.DefaultClient, cfg, repo).Login(context.Background(), "username", "password"); errors.Cause(err) != ErrInvalidResponse {
+			t.Errorf("Login() with another key error = %v wantErr = %v", err, ErrInvalidResponse)
+		}
+	})
+
+	t.Run("should not pin the key of a service in another group", func(t *testing.T) {
+		// given
+		registered, _ := repo.Get("username")
+		user, _ := newUser("username", registered.cID, testGroup, nil)
+		repo.Update(user)
+		defer repo.Update(registered)
+
+		// when
+		err := New(http.DefaultClient, cfg, repo).Login(context.Background(), "username", "password")
+
+		// then
+		if errors.Cause(err) != ErrInvalidResponse {
+			t.Errorf("Login() error = %v wantErr = %v", err, ErrInvalidResponse)
+		}
+		if got, _ := repo.Get("username"); got.pk != nil {
+			t.Errorf("Login() pinned pk = %v, want none", got.pk)
+		}
+	})
+
+	t.Run("should not pin the key if the login fails", func(t *testing.T) {
+		// given
+		registered, _ := repo.Get("username")
+		user := registered
+		user.pk = nil
+		repo.Update(user)
+		defer repo.Update(registered)
+
+		// when
+		err := New(http.DefaultClient, cfg, repo).Login(context.Background(), "username", "wrong password")
+
+		// then
+		if errors.Cause(err) != ErrAuthenticationFailed {
+			t.Errorf("Login() error = %v wantErr = %v", err, ErrAuthenticationFailed)
+		}
+		if got, _ := repo.Get("username"); got.pk != nil {
+			t.Errorf("Login() pinned pk = %v, want none", got.pk)
+		}
+	})
 }
 
 func TestClient_Login(t *testing.T) {
 	// before
-	user, err := newUser("username", 8)
+	user, err := newUser("username", big.NewInt(1), testGroup, testGroup.Generator())
 	if err != nil {
 		t.Errorf("before test started - error = %v", err)
 	}
 	repo := NewInMemoryUserRepository()
 	repo.Add(user)
 
-	t.Run("should login with password", func(t *testing.T) {
+	t.Run("should return ErrNotRegistered if the service does not know the user", func(t *testing.T) {
+		// given
+		ts := newAKEServer(t, testGroup, big.NewInt(7))
+		defer ts.Close()
+
 		// when
-		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			n := big.NewInt(42)
+		cfg, err := NewConfiguration(ts.URL, 8, sha256.New)
+		if err != nil {
+			t.Errorf("NewConfiguration() error = %v", err)
+		}
+		err = New(http.DefaultClient, cfg, repo).Login(context.Background(), "username", "password")
 
-			var buf bytes.Buffer
-			wr := bufio.NewWriter(&buf)
-			err := contract.MarshalExpKResponse(wr, contract.ExpKResponse{
+		// then
+		if errors.Cause(err) != ErrNotRegistered {
+			t.Errorf("Login() error = %v wantErr = %v", err, ErrNotRegistered)
+		}
+	})
+
+	t.Run("should return ErrInvalidResponse if bd is not a group element", func(t *testing.T) {
+		// when
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := big.NewInt(4)
+			w.WriteHeader(http.StatusOK)
+			contract.MarshalExpKResponse(w, contract.ExpKResponse{
 				SID:    n,
 				SNonce: n,
-				BD:     n,
+				BD:     big.NewInt(2038),
 				Q0:     n,
-				KV:     n})
-			if err != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				return
-			}
-			wr.Flush()
+				Y:      n})
+		}))
+		defer ts.Close()
+
+		// then
+		cfg, err := NewConfiguration(ts.URL, 8, sha256.New)
+		if err != nil {
+			t.Errorf("NewConfiguration() error = %v", err)
+		}
+		err = New(http.DefaultClient, cfg, repo).Login(context.Background(), "username", "password")
 
+		if errors.Cause(err) != ErrInvalidResponse {
+			t.Errorf("Login() error = %v wantErr = %v", err, ErrInvalidResponse)
+		}
+	})
+
+	t.Run("should return ErrInvalidResponse if y is not a group element", func(t *testing.T) {
+		// when
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := big.NewInt(4)
 			w.WriteHeader(http.StatusOK)
-			w.Write(buf.Bytes())
+			contract.MarshalExpKResponse(w, contract.ExpKResponse{
+				SID:    n,
+				SNonce: n,
+				BD:     n,
+				Q0:     n,
+				Y:      big.NewInt(2038)})
 		}))
 		defer ts.Close()
 
@@ -100,10 +533,10 @@ func TestClient_Login(t *testing.T) {
 		if err != nil {
 			t.Errorf("NewConfiguration() error = %v", err)
 		}
-		err = New(http.DefaultClient, cfg, repo).Login("username", "password")
+		err = New(http.DefaultClient, cfg, repo).Login(context.Background(), "username", "password")
 
-		if err != nil {
-			t.Errorf("Login() error = %v", err)
+		if errors.Cause(err) != ErrInvalidResponse {
+			t.Errorf("Login() error = %v wantErr = %v", err, ErrInvalidResponse)
 		}
 	})
 
@@ -111,7 +544,7 @@ func TestClient_Login(t *testing.T) {
 
 func TestClient_Challenge(t *testing.T) {
 	// before
-	user, err := newUser("username", 8)
+	user, err := newUser("username", big.NewInt(1), testGroup, nil)
 	if err != nil {
 		t.Errorf("before test started - error = %v", err)
 	}
@@ -148,16 +581,16 @@ func TestClient_Challenge(t *testing.T) {
 		clt := New(http.DefaultClient, cfg, repo)
 		clt.session = NewSession(user, sID, ski, mk)
 
-		err = clt.Challenge()
-		if errors.Cause(err) != ErrOperationFailed {
-			t.Errorf("Challenge() error = %v wantErr = %v", err, ErrOperationFailed)
+		err = clt.challenge(context.Background())
+		if errors.Cause(err) != ErrAuthenticationFailed {
+			t.Errorf("challenge() error = %v wantErr = %v", err, ErrAuthenticationFailed)
 		}
 	})
 }
 
 func TestClient_GetMetadata(t *testing.T) {
 	// before
-	user, err := newUser("username", 8)
+	user, err := newUser("username", big.NewInt(1), testGroup, nil)
 	if err != nil {
 		t.Errorf("before test started - error = %v", err)
 	}
@@ -192,7 +625,7 @@ func TestClient_GetMetadata(t *testing.T) {
 		clt := New(http.DefaultClient, cfg, repo)
 		clt.session = NewSession(user, sID, ski, mk)
 
-		_, err = clt.GetMetadata()
+		_, err = clt.GetMetadata(context.Background())
 		if err != nil {
 			t.Errorf("GetMetadata() error = %v", err)
 		}
@@ -201,7 +634,7 @@ func TestClient_GetMetadata(t *testing.T) {
 
 func TestClient_Add(t *testing.T) {
 	// before
-	user, err := newUser("username", 8)
+	user, err := newUser("username", big.NewInt(1), testGroup, nil)
 	if err != nil {
 		t.Errorf("before test started - error = %v", err)
 	}
@@ -226,7 +659,7 @@ func TestClient_Add(t *testing.T) {
 		clt := New(http.DefaultClient, cfg, repo)
 		clt.session = NewSession(user, sID, ski, mk)
 
-		err = clt.Add("google.com")
+		err = clt.Add(context.Background(), "google.com")
 		if err != nil {
 			t.Errorf("Add() error = %v", err)
 		}
@@ -235,7 +668,7 @@ func TestClient_Add(t *testing.T) {
 
 func TestClient_Get(t *testing.T) {
 	// before
-	user, err := newUser("username", 8)
+	user, err := newUser("username", big.NewInt(1), testGroup, nil)
 	if err != nil {
 		t.Errorf("before test started - error = %v", err)
 	}
@@ -251,7 +684,7 @@ func TestClient_Get(t *testing.T) {
 		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			var buf bytes.Buffer
 			wr := bufio.NewWriter(&buf)
-			err = contract.MarshalGetResponse(wr, contract.GetResponse{Bj: big.NewInt(1), Qj: big.NewInt(1)})
+			err = contract.MarshalGetResponse(wr, contract.GetResponse{Bj: big.NewInt(4), Qj: big.NewInt(4)})
 			if err != nil {
 				w.WriteHeader(http.StatusBadRequest)
 				return
@@ -270,9 +703,432 @@ func TestClient_Get(t *testing.T) {
 		clt := New(http.DefaultClient, cfg, repo)
 		clt.session = NewSession(user, sID, ski, mk)
 
-		_, err = clt.Get("google.com")
+		_, err = clt.Get(context.Background(), "google.com")
 		if err != nil {
 			t.Errorf("Get() error = %v", err)
 		}
 	})
+
+	t.Run("should render the password according to the policy of the domain", func(t *testing.T) {
+		// given
+		policy := Policy{Length: 12, Allowed: "d", Required: "d"}
+		metadata, err := marshalDomainMetadata(domainMetadata{Policy: &policy})
+		if err != nil {
+			t.Fatalf("marshalDomainMetadata() error = %v", err)
+		}
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			contract.MarshalGetResponse(w, contract.GetResponse{Bj: big.NewInt(4), Qj: big.NewInt(4), Metadata: metadata})
+		}))
+		defer ts.Close()
+		// when
+		cfg, err := NewConfiguration(ts.URL, 8, sha256.New)
+		if err != nil {
+			t.Errorf("NewConfiguration() error = %v", err)
+		}
+		clt := New(http.DefaultClient, cfg, repo)
+		clt.session = NewSession(user, sID, ski, mk)
+
+		pwd, err := clt.Get(context.Background(), "google.com")
+		// then
+		if err != nil {
+			t.Errorf("Get() error = %v", err)
+		}
+		if len(pwd) != 12 || strings.Trim(pwd, digits) != "" {
+			t.Errorf("Get() = %v, want 12 digits", pwd)
+		}
+	})
+}
+
+func TestClient_ServiceError(t *testing.T) {
+	user, err := newUser("username", big.NewInt(1), testGroup, nil)
+	if err != nil {
+		t.Fatalf("newUser() error = %v", err)
+	}
+	tests := []struct {
+		name string
+		err  *contract.Error
+		want error
+	}{
+		{"should map login_required", contract.NewError(contract.CodeLoginRequired, "login required"), ErrLoginRequired},
+		{"should map mac_mismatch", contract.NewError(contract.CodeMACMismatch, "MAC mismatch"), ErrLoginRequired},
+		{"should map replayed_request", contract.NewError(contract.CodeReplayedRequest, "replayed request"), ErrLoginRequired},
+		{"should map login_timeout", contract.NewError(contract.CodeLoginTimeout, "login timed out"), ErrLoginRequired},
+		{"should map login_out_of_order", contract.NewError(contract.CodeLoginOutOfOrder, "login step out of order"), ErrLoginOutOfOrder},
+		{"should map too_many_attempts", &contract.Error{Code: contract.CodeTooManyAttempts, Message: "too many login attempts", RetryAfter: time.Second}, ErrTooManyAttempts},
+		{"should map domain_not_found", contract.NewError(contract.CodeDomainNotFound, "domain not found"), ErrDomainNotFound},
+		{"should map bad_request", contract.NewError(contract.CodeBadRequest, "unexpected EOF"), ErrInvalidRequest},
+		{"should map unavailable", &contract.Error{Code: contract.CodeUnavailable, Message: "unavailable", RetryAfter: time.Second}, ErrOperationFailed},
+		{"should map unknown codes", contract.NewError("from_the_future", "?"), ErrOperationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contract.MarshalError(w, tt.err)
+			}))
+			defer ts.Close()
+
+			cfg, err := NewConfiguration(ts.URL, 8, sha256.New)
+			if err != nil {
+				t.Errorf("NewConfiguration() error = %v", err)
+			}
+			clt := New(http.DefaultClient, cfg, NewInMemoryUserRepository())
+			clt.session = NewSession(user, big.NewInt(10), big.NewInt(10), big.NewInt(10))
+
+			// when
+			_, err = clt.Get(context.Background(), "domain")
+
+			// then
+			if errors.Cause(err) != tt.want {
+				t.Errorf("Get() error = %v, want %v", err, tt.want)
+			}
+			retryAfter, ok := RetryAfter(err)
+			if retryAfter != tt.err.RetryAfter || ok != (tt.err.RetryAfter > 0) {
+				t.Errorf("RetryAfter() = %v, %v, want %v", retryAfter, ok, tt.err.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestClient_Rotate(t *testing.T) {
+	// before
+	user, err := newUser("username", big.NewInt(1), testGroup, nil)
+	if err != nil {
+		t.Errorf("before test started - error = %v", err)
+	}
+	repo := NewInMemoryUserRepository()
+	repo.Add(user)
+
+	sID := big.NewInt(10)
+	ski := big.NewInt(10)
+	mk := big.NewInt(10)
+
+	t.Run("should rotate and return the end of the grace period", func(t *testing.T) {
+		// given
+		want := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, err := contract.UnmarshalRotateRequest(r.Body)
+			if err != nil {
+				t.Errorf("UnmarshalRotateRequest() error = %v", err)
+			}
+			canonical := contract.RotateRequest{Counter: 1, Timestamp: req.Timestamp, Domain: "domain"}.Canonical()
+			mac := crypto.HmacData(sha256.New, ski.Bytes(), canonical)
+			if req.Domain != "domain" || req.Counter != 1 || !bytes.Equal(req.MAC, mac) {
+				t.Errorf("RotateRequest = %v, want MAC %v", req, mac)
+			}
+			w.WriteHeader(http.StatusOK)
+			contract.MarshalRotateResponse(w, contract.RotateResponse{Version: 1, PreviousUntil: want})
+		}))
+		defer ts.Close()
+		// when
+		cfg, err := NewConfiguration(ts.URL, 8, sha256.New)
+		if err != nil {
+			t.Errorf("NewConfiguration() error = %v", err)
+		}
+		clt := New(http.DefaultClient, cfg, repo)
+		clt.session = NewSession(user, sID, ski, mk)
+
+		got, err := clt.Rotate(context.Background(), "domain")
+		// then
+		if err != nil {
+			t.Errorf("Rotate() error = %v", err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("Rotate() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("should require login", func(t *testing.T) {
+		clt := New(http.DefaultClient, Configuration{}, repo)
+		_, err := clt.Rotate(context.Background(), "domain")
+		if err != ErrLoginRequired {
+			t.Errorf("Rotate() error = %v, want %v", err, ErrLoginRequired)
+		}
+	})
+}
+
+func TestClient_Delete(t *testing.T) {
+	// before
+	user, err := newUser("username", big.NewInt(1), testGroup, nil)
+	if err != nil {
+		t.Errorf("before test started - error = %v", err)
+	}
+	repo := NewInMemoryUserRepository()
+	repo.Add(user)
+
+	sID := big.NewInt(10)
+	ski := big.NewInt(10)
+	mk := big.NewInt(10)
+
+	newClient := func(t *testing.T, handler http.HandlerFunc) (*Client, func()) {
+		ts := httptest.NewServer(handler)
+		cfg, err := NewConfiguration(ts.URL, 8, sha256.New)
+		if err != nil {
+			t.Errorf("NewConfiguration() error = %v", err)
+		}
+		clt := New(http.DefaultClient, cfg, repo)
+		clt.session = NewSession(user, sID, ski, mk)
+		return clt, ts.Close
+	}
+
+	t.Run("should delete the domain", func(t *testing.T) {
+		// given
+		clt, stop := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			req, err := contract.UnmarshalDeleteRequest(r.Body)
+			if err != nil {
+				t.Errorf("UnmarshalDeleteRequest() error = %v", err)
+			}
+			canonical := contract.DeleteRequest{Counter: 1, Timestamp: req.Timestamp, Domain: "domain"}.Canonical()
+			mac := crypto.HmacData(sha256.New, ski.Bytes(), canonical)
+			if r.URL.Path != "/v1/delete" || req.Domain != "domain" || req.Counter != 1 || !bytes.Equal(req.MAC, mac) {
+				t.Errorf("DeleteRequest %v = %v, want MAC %v", r.URL.Path, req, mac)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+		defer stop()
+		// when
+		err := clt.Delete(context.Background(), "domain")
+		// then
+		if err != nil {
+			t.Errorf("Delete() error = %v", err)
+		}
+	})
+
+	t.Run("should return ErrDomainNotFound", func(t *testing.T) {
+		clt, stop := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			contract.MarshalError(w, contract.NewError(contract.CodeDomainNotFound, "domain not found"))
+		})
+		defer stop()
+
+		err := clt.Delete(context.Background(), "unknown")
+
+		if errors.Cause(err) != ErrDomainNotFound {
+			t.Errorf("Delete() error = %v, want %v", err, ErrDomainNotFound)
+		}
+	})
+
+	t.Run("should require login", func(t *testing.T) {
+		clt := New(http.DefaultClient, Configuration{}, repo)
+		err := clt.Delete(context.Background(), "domain")
+		if err != ErrLoginRequired {
+			t.Errorf("Delete() error = %v, want %v", err, ErrLoginRequired)
+		}
+	})
+}
+
+func TestClient_Sessions(t *testing.T) {
+	// before
+	user, err := newUser("username", big.NewInt(1), testGroup, nil)
+	if err != nil {
+		t.Errorf("before test started - error = %v", err)
+	}
+	repo := NewInMemoryUserRepository()
+	repo.Add(user)
+
+	ski := big.NewInt(10)
+
+	newClient := func(t *testing.T, handler http.HandlerFunc) (*Client, func()) {
+		ts := httptest.NewServer(handler)
+		cfg, err := NewConfiguration(ts.URL, 8, sha256.New)
+		if err != nil {
+			t.Errorf("NewConfiguration() error = %v", err)
+		}
+		clt := New(http.DefaultClient, cfg, repo)
+		clt.session = NewSession(user, big.NewInt(10), new(big.Int).Set(ski), big.NewInt(10))
+		return clt, ts.Close
+	}
+
+	t.Run("should return the sessions", func(t *testing.T) {
+		// given
+		want := []contract.SessionInfo{{ID: "a", Created: time.Unix(1, 0).UTC(), LastSeen: time.Unix(2, 0).UTC(), Current: true}}
+		clt, stop := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			req, err := contract.UnmarshalSessionsRequest(r.Body)
+			if err != nil {
+				t.Errorf("UnmarshalSessionsRequest() error = %v", err)
+			}
+			canonical := contract.SessionsRequest{Counter: 1, Timestamp: req.Timestamp}.Canonical()
+			mac := crypto.HmacData(sha256.New, ski.Bytes(), canonical)
+			if r.URL.Path != "/v1/sessions" || req.Counter != 1 || !bytes.Equal(req.MAC, mac) {
+				t.Errorf("SessionsRequest %v = %v, want MAC %v", r.URL.Path, req, mac)
+			}
+			contract.MarshalSessionsResponse(w, contract.SessionsResponse{Sessions: want})
+		})
+		defer stop()
+		// when
+		got, err := clt.Sessions(context.Background())
+		// then
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("Sessions() = %v, %v, want %v", got, err, want)
+		}
+	})
+
+	t.Run("should require login", func(t *testing.T) {
+		clt := New(http.DefaultClient, Configuration{}, repo)
+		if _, err := clt.Sessions(context.Background()); err != ErrLoginRequired {
+			t.Errorf("Sessions() error = %v, want %v", err, ErrLoginRequired)
+		}
+		if err := clt.RevokeAllSessions(context.Background()); err != ErrLoginRequired {
+			t.Errorf("RevokeAllSessions() error = %v, want %v", err, ErrLoginRequired)
+		}
+	})
+}
+
+func TestClient_RevokeSessions(t *testing.T) {
+	// before
+	user, err := newUser("username", big.NewInt(1), testGroup, nil)
+	if err != nil {
+		t.Errorf("before test started - error = %v", err)
+	}
+	repo := NewInMemoryUserRepository()
+	repo.Add(user)
+
+	ski := big.NewInt(10)
+
+	newClient := func(t *testing.T, handler http.HandlerFunc) (*Client, func()) {
+		ts := httptest.NewServer(handler)
+		cfg, err := NewConfiguration(ts.URL, 8, sha256.New)
+		if err != nil {
+			t.Errorf("NewConfiguration() error = %v", err)
+		}
+		clt := New(http.DefaultClient, cfg, repo)
+		clt.session = NewSession(user, big.NewInt(10), new(big.Int).Set(ski), big.NewInt(10))
+		return clt, ts.Close
+	}
+
+	t.Run("should revoke a session and keep the own one", func(t *testing.T) {
+		// given
+		clt, stop := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			req, err := contract.UnmarshalRevokeSessionsRequest(r.Body)
+			if err != nil {
+				t.Errorf("UnmarshalRevokeSessionsRequest() error = %v", err)
+			}
+			canonical := contract.RevokeSessionsRequest{Counter: 1, Timestamp: req.Timestamp, ID: "a"}.Canonical()
+			mac := crypto.HmacData(sha256.New, ski.Bytes(), canonical)
+			if r.URL.Path != "/v1/sessions/revoke" || req.ID != "a" || req.All || !bytes.Equal(req.MAC, mac) {
+				t.Errorf("RevokeSessionsRequest %v = %v, want MAC %v", r.URL.Path, req, mac)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+		defer stop()
+		// when
+		err := clt.RevokeSession(context.Background(), "a")
+		// then
+		if err != nil || !clt.LoggedIn() {
+			t.Errorf("RevokeSession() error = %v, logged in = %v, want logged in", err, clt.LoggedIn())
+		}
+	})
+
+	t.Run("should revoke all sessions and wipe the own one", func(t *testing.T) {
+		// given
+		clt, stop := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			req, err := contract.UnmarshalRevokeSessionsRequest(r.Body)
+			if err != nil || !req.All {
+				t.Errorf("UnmarshalRevokeSessionsRequest() = %v, %v, want all", req, err)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+		defer stop()
+		// when
+		err := clt.RevokeAllSessions(context.Background())
+		// then
+		if err != nil || clt.LoggedIn() {
+			t.Errorf("RevokeAllSessions() error = %v, logged in = %v, want logged out", err, clt.LoggedIn())
+		}
+	})
+}
+
+func TestClient_Migrate(t *testing.T) {
+	user, err := newUser("username", big.NewInt(1), testGroup, nil)
+	if err != nil {
+		t.Fatalf("newUser() error = %v", err)
+	}
+	legacy, err := user.withHashVersion(crypto.HashVersionLegacy)
+	if err != nil {
+		t.Fatalf("withHashVersion() error = %v", err)
+	}
+	repo := NewInMemoryUserRepository()
+	repo.Add(legacy)
+
+	// given
+	cfg, err := NewConfiguration("http://localhost", 8, sha256.New)
+	if err != nil {
+		t.Errorf("NewConfiguration() error = %v", err)
+	}
+	clt := New(http.DefaultClient, cfg, repo)
+
+	// when
+	err = clt.Migrate("username")
+
+	// then
+	if err != nil {
+		t.Errorf("Migrate() error = %v", err)
+	}
+	got, err := repo.Get("username")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.hashVersion != crypto.HashVersionCurrent {
+		t.Errorf("Migrate() hashVersion = %v, want %v", got.hashVersion, crypto.HashVersionCurrent)
+	}
+	msg, dst := []byte("password"), []byte("dst")
+	if got.group.HashToGroup(msg, dst).Cmp(legacy.group.HashToGroup(msg, dst)) == 0 {
+		t.Errorf("Migrate() kept the legacy hash to group")
+	}
+
+	err = clt.Migrate("unknown")
+	if errors.Cause(err) != ErrUserNotFound {
+		t.Errorf("Migrate() error = %v wantErr = %v", err, ErrUserNotFound)
+	}
+}
+
+func TestClient_Logout(t *testing.T) {
+	user, err := newUser("username", big.NewInt(1), testGroup, nil)
+	if err != nil {
+		t.Fatalf("newUser() error = %v", err)
+	}
+
+	t.Run("should wipe the session", func(t *testing.T) {
+		// given
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+		cfg, err := NewConfiguration(ts.URL, 8, sha256.New)
+		if err != nil {
+			t.Fatalf("NewConfiguration() error = %v", err)
+		}
+		clt := New(http.DefaultClient, cfg, NewInMemoryUserRepository())
+		clt.session = NewSession(user, big.NewInt(10), big.NewInt(10), big.NewInt(10))
+		ski := clt.session.ski
+
+		// when
+		err = clt.Logout(context.Background())
+
+		// then
+		if err != nil {
+			t.Errorf("Logout() error = %v", err)
+		}
+		if clt.LoggedIn() || ski.Sign() != 0 {
+			t.Errorf("Logout() expect a wiped session, got ski %v", ski)
+		}
+	})
+
+	t.Run("should wipe the session if the service is offline", func(t *testing.T) {
+		cfg, err := NewConfiguration("http://127.0.0.1:0", 8, sha256.New)
+		if err != nil {
+			t.Fatalf("NewConfiguration() error = %v", err)
+		}
+		clt := New(http.DefaultClient, cfg, NewInMemoryUserRepository())
+		clt.session = NewSession(user, big.NewInt(10), big.NewInt(10), big.NewInt(10))
+
+		err = clt.Logout(context.Background())
+
+		if err == nil {
+			t.Errorf("Logout() expect an error of the offline service")
+		}
+		if clt.LoggedIn() {
+			t.Errorf("Logout() expect no session")
+		}
+	})
 }
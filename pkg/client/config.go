@@ -1,30 +1,64 @@
 package client
 
 import (
+	"crypto/sha256"
+	"crypto/sha512"
 	"hash"
 	"net/url"
+
+	"github.com/pkg/errors"
 )
 
+// HashNames are the names of the hash functions accepted by HashByName.
+var HashNames = []string{"sha256", "sha512"}
+
+// HashByName returns the hash function named sha256 or sha512.
+func HashByName(name string) (func() hash.Hash, error) {
+	switch name {
+	case "sha256":
+		return sha256.New, nil
+	case "sha512":
+		return sha512.New, nil
+	}
+	return nil, errors.Errorf("HashByName: unknown hash %s, want one of %v", name, HashNames)
+}
+
 // Configuration ...
 type Configuration struct {
-	hash          func() hash.Hash
-	bits          int
-	contentType   string
-	baseURL       string
-	registerPath  string
-	expkPath      string
-	challengePath string
-	metadataPath  string
-	addPath       string
-	getPath       string
-	logoutPath    string
+	hash             func() hash.Hash
+	bits             int
+	contentType      string
+	baseURL          string
+	groupPath        string
+	registerPath     string
+	enrollPath       string
+	registerExpKPath string
+	expkPath         string
+	challengePath    string
+	metadataPath     string
+	addPath          string
+	getPath          string
+	rotatePath       string
+	deletePath       string
+	logoutPath       string
+	sessionsPath     string
+	revokePath       string
 }
 
 // NewConfiguration return default configuration.
+// bits is the minimum bit length of the field of the group accepted from the service,
+// e.g. 256 accepts P-256 as well as all vetted safe prime groups.
+// baseURL has to be an absolute http or https URL.
 func NewConfiguration(baseURL string, bits int, hashFn func() hash.Hash) (Configuration, error) {
 	u, err := url.Parse(baseURL)
 	if err != nil {
-		return Configuration{}, err
+		return Configuration{}, errors.Wrap(err, "NewConfiguration: invalid URL")
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Configuration{}, errors.Errorf("NewConfiguration: URL %s is not an absolute http or https URL", baseURL)
+	}
+	if hashFn == nil || bits <= 0 {
+		return Configuration{}, errors.New("NewConfiguration: hash and bits required")
 	}
 
 	c := Configuration{
@@ -33,9 +67,18 @@ func NewConfiguration(baseURL string, bits int, hashFn func() hash.Hash) (Config
 		contentType: "application/json",
 		baseURL:     baseURL,
 	}
+	u.Path = "/v1/group"
+	c.groupPath = u.String()
+
+	u.Path = "/v1/register/expk"
+	c.registerExpKPath = u.String()
+
 	u.Path = "/v1/register"
 	c.registerPath = u.String()
 
+	u.Path = "/v1/enroll"
+	c.enrollPath = u.String()
+
 	u.Path = "/v1/login/expk"
 	c.expkPath = u.String()
 
@@ -51,8 +94,20 @@ func NewConfiguration(baseURL string, bits int, hashFn func() hash.Hash) (Config
 	u.Path = "/v1/get"
 	c.getPath = u.String()
 
+	u.Path = "/v1/rotate"
+	c.rotatePath = u.String()
+
+	u.Path = "/v1/delete"
+	c.deletePath = u.String()
+
 	u.Path = "/v1/logout"
 	c.logoutPath = u.String()
 
+	u.Path = "/v1/sessions"
+	c.sessionsPath = u.String()
+
+	u.Path = "/v1/sessions/revoke"
+	c.revokePath = u.String()
+
 	return c, nil
 }
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestNewConfiguration(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		bits    int
+		wantErr bool
+	}{
+		{"should accept https", "https://localhost:443", 256, false},
+		{"should accept http", "http://127.0.0.1:8080", 256, false},
+		{"should reject other schemes", "ftp://localhost", 256, true},
+		{"should reject relative URLs", "localhost:443", 256, true},
+		{"should reject URLs without host", "https://", 256, true},
+		{"should reject zero bits", "https://localhost", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := NewConfiguration(tt.baseURL, tt.bits, sha256.New)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && cfg.groupPath != tt.baseURL+"/v1/group" {
+				t.Errorf("NewConfiguration() groupPath = %v", cfg.groupPath)
+			}
+		})
+	}
+}
+
+func TestHashByName(t *testing.T) {
+	for _, name := range HashNames {
+		if _, err := HashByName(name); err != nil {
+			t.Errorf("HashByName(%v) error = %v", name, err)
+		}
+	}
+	if _, err := HashByName("md5"); err == nil {
+		t.Errorf("HashByName(md5) expect an error")
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package client
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// syncDir flushes directory entries e.g. after a rename.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open %s", dir)
+	}
+	defer d.Close()
+
+	return errors.Wrapf(d.Sync(), "failed to sync %s", dir)
+}
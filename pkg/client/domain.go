@@ -15,14 +15,36 @@ type Session struct {
 	mk   *big.Int
 	sID  *big.Int
 	user User
+
+	counter uint64
+	release []func()
 }
 
-// NewSession ...
+// NewSession returns a session keeping copies of the session key ski and the master key mk
+// in locked memory until it is wiped.
 func NewSession(user User, sID, ski, mk *big.Int) *Session {
-	return &Session{
-		ski:  ski,
-		mk:   mk,
+	s := &Session{
 		sID:  sID,
 		user: user,
 	}
+	var release func()
+	s.ski, release = lockedInt(ski)
+	s.release = append(s.release, release)
+	s.mk, release = lockedInt(mk)
+	s.release = append(s.release, release)
+	return s
+}
+
+// wipe overwrites the keys of the session, which must not be used afterwards.
+func (s *Session) wipe() {
+	for _, release := range s.release {
+		release()
+	}
+	s.release = nil
+}
+
+// next returns the counter of the next authenticated request of the session.
+func (s *Session) next() uint64 {
+	s.counter++
+	return s.counter
 }
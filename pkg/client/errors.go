@@ -0,0 +1,85 @@
+package client
+
+import (
+	"time"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
+	"github.com/pkg/errors"
+)
+
+// ServiceError is an error reported by the Online SPHINX service.
+// Its cause is one of the user facing errors, so that callers can react with errors.Cause.
+type ServiceError struct {
+	cause error
+	// Code is the error code of the service.
+	Code contract.Code
+	// Message of the service.
+	Message string
+	// RetryAfter hints when the request may succeed if retried, zero if retrying does not help.
+	RetryAfter time.Duration
+}
+
+// Error returns the message of the service.
+func (e *ServiceError) Error() string {
+	return e.cause.Error() + ": " + e.Message
+}
+
+// Cause returns the user facing error.
+func (e *ServiceError) Cause() error {
+	return e.cause
+}
+
+// causes maps the error codes of the service to user facing errors.
+var causes = map[contract.Code]error{
+	contract.CodeLoginRequired:       ErrLoginRequired,
+	contract.CodeMACMismatch:         ErrLoginRequired,
+	contract.CodeReplayedRequest:     ErrLoginRequired,
+	contract.CodeLoginTimeout:        ErrLoginRequired,
+	contract.CodeLoginOutOfOrder:     ErrLoginOutOfOrder,
+	contract.CodeTooManyAttempts:     ErrTooManyAttempts,
+	contract.CodeEnrollmentInvalid:   ErrEnrollmentInvalid,
+	contract.CodeUserNotFound:        ErrNotRegistered,
+	contract.CodeUserAlreadyExists:   ErrUserAlreadyExists,
+	contract.CodeDomainNotFound:      ErrDomainNotFound,
+	contract.CodeDomainAlreadyExists: ErrDomainAlreadyExists,
+	contract.CodeNoPreviousVersion:   ErrNoPreviousVersion,
+	contract.CodeBadRequest:          ErrInvalidRequest,
+	contract.CodeGroupMismatch:       ErrInvalidRequest,
+	contract.CodeInvalidElement:      ErrInvalidRequest,
+	contract.CodeMetadataTooLong:     ErrInvalidRequest,
+	contract.CodeRequestTooLarge:     ErrInvalidRequest,
+}
+
+// serviceError returns the *ServiceError of a *contract.Error.
+// Unknown codes, internal and temporary errors of the service are caused by ErrOperationFailed.
+func serviceError(err error) error {
+	e, ok := errors.Cause(err).(*contract.Error)
+	if !ok {
+		return err
+	}
+	cause, ok := causes[e.Code]
+	if !ok {
+		cause = ErrOperationFailed
+	}
+	return &ServiceError{
+		cause:      cause,
+		Code:       e.Code,
+		Message:    e.Message,
+		RetryAfter: e.RetryAfter,
+	}
+}
+
+// RetryAfter returns the retry hint of the service if err is caused by a *ServiceError.
+func RetryAfter(err error) (time.Duration, bool) {
+	for err != nil {
+		if e, ok := err.(*ServiceError); ok {
+			return e.RetryAfter, e.RetryAfter > 0
+		}
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = c.Cause()
+	}
+	return 0, false
+}
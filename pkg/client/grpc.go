@@ -0,0 +1,364 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
+	"github.com/LAtanassov/go-online-sphinx/pkg/pb"
+)
+
+// GRPCPoster is a Poster which sends the requests of a Client to the gRPC service of pb
+// instead of the JSON HTTP API, e.g. New(NewGRPCPoster(conn), cfg, repo).
+// Like the cookie jar of an http.Client it keeps the session of the service.
+type GRPCPoster struct {
+	client pb.OnlineSphinxClient
+
+	mu      sync.Mutex
+	session string
+}
+
+// NewGRPCPoster returns a Poster using the gRPC connection cc.
+func NewGRPCPoster(cc grpc.ClientConnInterface) *GRPCPoster {
+	return &GRPCPoster{
+		client: pb.NewOnlineSphinxClient(cc),
+	}
+}
+
+// Do calls the gRPC method of the method and path of req with its JSON body and
+// returns its result as JSON HTTP response, so that Client is unaware of the transport.
+// The context of req cancels the call, its deadline and request ID are sent to the service.
+// Errors of the service are returned as error responses, only transport failures as error.
+func (p *GRPCPoster) Do(req *http.Request) (*http.Response, error) {
+	calls := map[string]grpcCall{
+		"GET /v1/group":            p.group,
+		"POST /v1/register/expk":   p.registerExpK,
+		"POST /v1/register":        p.register,
+		"POST /v1/enroll":          p.enroll,
+		"POST /v1/login/expk":      p.expk,
+		"POST /v1/login/challenge": p.challenge,
+		"POST /v1/logout":          p.logout,
+		"POST /v1/sessions":        p.sessions,
+		"POST /v1/sessions/revoke": p.revokeSessions,
+		"POST /v1/metadata":        p.metadata,
+		"POST /v1/add":             p.add,
+		"POST /v1/get":             p.get,
+		"POST /v1/rotate":          p.rotate,
+		"POST /v1/delete":          p.delete,
+	}
+	call, ok := calls[req.Method+" "+req.URL.Path]
+	if !ok {
+		return nil, errors.Errorf("Do: no gRPC method for %s %s", req.Method, req.URL.Path)
+	}
+
+	var body io.Reader = http.NoBody
+	if req.Body != nil {
+		defer req.Body.Close()
+		body = req.Body
+	}
+
+	var header, trailer metadata.MD
+	resp := newJSONResponse()
+	err := call(p.outgoingContext(req), body, resp, grpc.Header(&header), grpc.Trailer(&trailer))
+	if _, ok := status.FromError(err); !ok {
+		return nil, errors.Wrap(err, "Do")
+	}
+	if err != nil {
+		return errorResponse(err, trailer)
+	}
+
+	if tokens := header.Get(pb.SessionKey); len(tokens) > 0 {
+		p.setSession(tokens[0])
+	}
+	if ids := header.Get(pb.RequestIDKey); len(ids) > 0 {
+		resp.Header().Set(contract.RequestIDHeader, ids[0])
+	}
+	return resp.response(), nil
+}
+
+// grpcCall unmarshals the JSON request from body, calls the gRPC method and marshals its response to w.
+// Errors of the gRPC method are returned unwrapped.
+type grpcCall func(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error
+
+func (p *GRPCPoster) group(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error {
+	r, err := p.client.Group(ctx, &pb.GroupRequest{}, opts...)
+	if err != nil {
+		return err
+	}
+	return contract.MarshalGroupResponse(w, contract.GroupResponse{Name: r.Name, Q: new(big.Int).SetBytes(r.Q), PK: optionalInt(r.Pk)})
+}
+
+func (p *GRPCPoster) registerExpK(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error {
+	req, err := contract.UnmarshalRegisterExpKRequest(body)
+	if err != nil {
+		return err
+	}
+	r, err := p.client.RegisterExpK(ctx, &pb.RegisterExpKRequest{B: req.B.Bytes(), Q: req.Q.Bytes()}, opts...)
+	if err != nil {
+		return err
+	}
+	return contract.MarshalRegisterExpKResponse(w, contract.RegisterExpKResponse{
+		BD:     new(big.Int).SetBytes(r.Bd),
+		Q0:     new(big.Int).SetBytes(r.Q0),
+		ProofC: new(big.Int).SetBytes(r.ProofC),
+		ProofS: new(big.Int).SetBytes(r.ProofS),
+	})
+}
+
+func (p *GRPCPoster) register(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error {
+	req, err := contract.UnmarshalRegisterRequest(body)
+	if err != nil {
+		return err
+	}
+	r, err := p.client.Register(ctx, &pb.RegisterRequest{CId: req.CID.Bytes(), Verifier: req.Verifier.Bytes()}, opts...)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return contract.MarshalGroupResponse(w, contract.GroupResponse{Name: r.Name, Q: new(big.Int).SetBytes(r.Q), PK: optionalInt(r.Pk)})
+}
+
+func (p *GRPCPoster) enroll(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error {
+	req, err := contract.UnmarshalEnrollRequest(body)
+	if err != nil {
+		return err
+	}
+	_, err = p.client.Enroll(ctx, &pb.EnrollRequest{
+		CId: req.CID.Bytes(), Code: req.Code, Verifier: req.Verifier.Bytes(), E: req.E.Bytes(), ProofC: req.ProofC.Bytes(), ProofS: req.ProofS.Bytes(),
+	}, opts...)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (p *GRPCPoster) expk(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error {
+	req, err := contract.UnmarshalExpKRequest(body)
+	if err != nil {
+		return err
+	}
+	r, err := p.client.ExpK(ctx, &pb.ExpKRequest{CId: req.CID.Bytes(), CNonce: req.CNonce.Bytes(), B: req.B.Bytes(), Q: req.Q.Bytes(), X: req.X.Bytes()}, opts...)
+	if err != nil {
+		return err
+	}
+	return contract.MarshalExpKResponse(w, contract.ExpKResponse{
+		SID:    new(big.Int).SetBytes(r.SId),
+		SNonce: new(big.Int).SetBytes(r.SNonce),
+		BD:     new(big.Int).SetBytes(r.Bd),
+		Q0:     new(big.Int).SetBytes(r.Q0),
+		Y:      new(big.Int).SetBytes(r.Y),
+		ProofC: optionalInt(r.ProofC),
+		ProofS: optionalInt(r.ProofS),
+	})
+}
+
+func (p *GRPCPoster) challenge(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error {
+	req, err := contract.UnmarshalChallengeRequest(body)
+	if err != nil {
+		return err
+	}
+	r, err := p.client.Challenge(ctx, &pb.ChallengeRequest{Mac: req.MAC, Counter: req.Counter, Timestamp: contract.UnixMilli(req.Timestamp), G: req.G.Bytes(), Q: req.Q.Bytes()}, opts...)
+	if err != nil {
+		return err
+	}
+	return contract.MarshalChallengeResponse(w, contract.ChallengeResponse{R: new(big.Int).SetBytes(r.R)})
+}
+
+func (p *GRPCPoster) logout(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error {
+	p.setSession("")
+	_, err := p.client.Logout(ctx, &pb.LogoutRequest{}, opts...)
+	return err
+}
+
+func (p *GRPCPoster) sessions(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error {
+	req, err := contract.UnmarshalSessionsRequest(body)
+	if err != nil {
+		return err
+	}
+	r, err := p.client.Sessions(ctx, &pb.SessionsRequest{Mac: req.MAC, Counter: req.Counter, Timestamp: contract.UnixMilli(req.Timestamp)}, opts...)
+	if err != nil {
+		return err
+	}
+	var resp contract.SessionsResponse
+	for _, s := range r.Sessions {
+		resp.Sessions = append(resp.Sessions, contract.SessionInfo{ID: s.Id, Created: time.Unix(0, s.Created), LastSeen: time.Unix(0, s.LastSeen), Current: s.Current})
+	}
+	return contract.MarshalSessionsResponse(w, resp)
+}
+
+func (p *GRPCPoster) revokeSessions(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error {
+	req, err := contract.UnmarshalRevokeSessionsRequest(body)
+	if err != nil {
+		return err
+	}
+	_, err = p.client.RevokeSessions(ctx, &pb.RevokeSessionsRequest{Mac: req.MAC, Counter: req.Counter, Timestamp: contract.UnixMilli(req.Timestamp), Id: req.ID, All: req.All}, opts...)
+	if err != nil {
+		return err
+	}
+	if req.All {
+		p.setSession("")
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (p *GRPCPoster) metadata(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error {
+	req, err := contract.UnmarshalMetadataRequest(body)
+	if err != nil {
+		return err
+	}
+	r, err := p.client.Metadata(ctx, &pb.MetadataRequest{Mac: req.MAC, Counter: req.Counter, Timestamp: contract.UnixMilli(req.Timestamp)}, opts...)
+	if err != nil {
+		return err
+	}
+	domains := r.Domains
+	if domains == nil {
+		domains = []string{}
+	}
+	return contract.MarshalMetadataResponse(w, contract.MetadataResponse{Domains: domains})
+}
+
+func (p *GRPCPoster) add(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error {
+	req, err := contract.UnmarshalAddRequest(body)
+	if err != nil {
+		return err
+	}
+	_, err = p.client.Add(ctx, &pb.AddRequest{Mac: req.MAC, Counter: req.Counter, Timestamp: contract.UnixMilli(req.Timestamp), Domain: req.Domain, Metadata: req.Metadata}, opts...)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+func (p *GRPCPoster) get(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error {
+	req, err := contract.UnmarshalGetRequest(body)
+	if err != nil {
+		return err
+	}
+	r, err := p.client.Get(ctx, &pb.GetRequest{Mac: req.MAC, Counter: req.Counter, Timestamp: contract.UnixMilli(req.Timestamp), Domain: req.Domain, Bmk: req.BMK.Bytes(), Q: req.Q.Bytes(), Previous: req.Previous}, opts...)
+	if err != nil {
+		return err
+	}
+	return contract.MarshalGetResponse(w, contract.GetResponse{Bj: new(big.Int).SetBytes(r.Bj), Qj: new(big.Int).SetBytes(r.Qj), Metadata: r.Metadata})
+}
+
+func (p *GRPCPoster) rotate(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error {
+	req, err := contract.UnmarshalRotateRequest(body)
+	if err != nil {
+		return err
+	}
+	r, err := p.client.Rotate(ctx, &pb.RotateRequest{Mac: req.MAC, Counter: req.Counter, Timestamp: contract.UnixMilli(req.Timestamp), Domain: req.Domain}, opts...)
+	if err != nil {
+		return err
+	}
+	return contract.MarshalRotateResponse(w, contract.RotateResponse{Version: int(r.Version), PreviousUntil: time.Unix(0, r.PreviousUntil)})
+}
+
+func (p *GRPCPoster) delete(ctx context.Context, body io.Reader, w *jsonResponse, opts ...grpc.CallOption) error {
+	req, err := contract.UnmarshalDeleteRequest(body)
+	if err != nil {
+		return err
+	}
+	_, err = p.client.Delete(ctx, &pb.DeleteRequest{Mac: req.MAC, Counter: req.Counter, Timestamp: contract.UnixMilli(req.Timestamp), Domain: req.Domain}, opts...)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// outgoingContext returns the context of req carrying the session and the request ID of req as metadata.
+func (p *GRPCPoster) outgoingContext(req *http.Request) context.Context {
+	ctx := req.Context()
+	if id := req.Header.Get(contract.RequestIDHeader); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, pb.RequestIDKey, id)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.session == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, pb.SessionKey, p.session)
+}
+
+func (p *GRPCPoster) setSession(session string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.session = session
+}
+
+// errorResponse returns errors of the service, which carry a code in the trailer, as error response.
+func errorResponse(err error, trailer metadata.MD) (*http.Response, error) {
+	codes := trailer.Get(pb.CodeKey)
+	if len(codes) == 0 {
+		return nil, errors.Wrap(err, "Do: gRPC call failed")
+	}
+
+	e := contract.NewError(contract.Code(codes[0]), status.Convert(err).Message())
+	if ms := trailer.Get(pb.RetryAfterKey); len(ms) > 0 {
+		if n, err := strconv.ParseInt(ms[0], 10, 64); err == nil {
+			e.RetryAfter = time.Duration(n) * time.Millisecond
+		}
+	}
+
+	resp := newJSONResponse()
+	err = contract.MarshalError(resp, e)
+	if err != nil {
+		return nil, errors.Wrap(err, "Do: failed to marshal error")
+	}
+	return resp.response(), nil
+}
+
+// optionalInt returns the big-endian integer b or nil if b is empty.
+func optionalInt(b []byte) *big.Int {
+	if len(b) == 0 {
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+// jsonResponse is a http.ResponseWriter building the *http.Response read by Client.
+type jsonResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newJSONResponse() *jsonResponse {
+	return &jsonResponse{header: make(http.Header), status: http.StatusOK}
+}
+
+func (r *jsonResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *jsonResponse) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+func (r *jsonResponse) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *jsonResponse) response() *http.Response {
+	return &http.Response{
+		Status:     http.StatusText(r.status),
+		StatusCode: r.status,
+		Header:     r.header,
+		Body:       ioutil.NopCloser(&r.body),
+	}
+}
@@ -0,0 +1,211 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"math/big"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
+	"github.com/LAtanassov/go-online-sphinx/pkg/pb"
+)
+
+// fakeGRPCServer issues the session "token" and requires it for Metadata.
+type fakeGRPCServer struct {
+	pb.UnimplementedOnlineSphinxServer
+}
+
+func (s *fakeGRPCServer) Group(ctx context.Context, req *pb.GroupRequest) (*pb.GroupResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	grpc.SetHeader(ctx, metadata.Pairs(pb.RequestIDKey, strings.Join(md.Get(pb.RequestIDKey), ",")))
+	return &pb.GroupResponse{Name: testGroup.Name(), Q: testGroup.Order().Bytes(), Pk: testGroup.Generator().Bytes()}, nil
+}
+
+func (s *fakeGRPCServer) ExpK(ctx context.Context, req *pb.ExpKRequest) (*pb.ExpKResponse, error) {
+	grpc.SetHeader(ctx, metadata.Pairs(pb.SessionKey, "token"))
+	return &pb.ExpKResponse{SId: []byte{1}, SNonce: []byte{2}, Bd: []byte{3}, Q0: []byte{4}, Y: []byte{5}}, nil
+}
+
+func (s *fakeGRPCServer) Metadata(ctx context.Context, req *pb.MetadataRequest) (*pb.MetadataResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if tokens := md.Get(pb.SessionKey); len(tokens) != 1 || tokens[0] != "token" {
+		grpc.SetTrailer(ctx, metadata.Pairs(pb.CodeKey, string(contract.CodeLoginRequired)))
+		return nil, status.Error(codes.Unauthenticated, "login required")
+	}
+	return &pb.MetadataResponse{Domains: []string{"domain"}}, nil
+}
+
+func (s *fakeGRPCServer) Logout(ctx context.Context, req *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+	return &pb.LogoutResponse{}, nil
+}
+
+func (s *fakeGRPCServer) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	grpc.SetTrailer(ctx, metadata.Pairs(pb.CodeKey, string(contract.CodeDomainNotFound)))
+	return nil, status.Error(codes.NotFound, "domain not found")
+}
+
+func newTestGRPCPoster(t *testing.T) (*GRPCPoster, func()) {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pb.RegisterOnlineSphinxServer(server, &fakeGRPCServer{})
+	go server.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		t.Fatalf("grpc.Dial() error = %v", err)
+	}
+	return NewGRPCPoster(conn), func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func TestGRPCPoster(t *testing.T) {
+	pst, stop := newTestGRPCPoster(t)
+	defer stop()
+
+	cfg, err := NewConfiguration("https://localhost", 8, sha256.New)
+	if err != nil {
+		t.Fatalf("NewConfiguration() error = %v", err)
+	}
+	user, err := newUser("username", big.NewInt(1), testGroup, nil)
+	if err != nil {
+		t.Fatalf("newUser() error = %v", err)
+	}
+	clt := New(pst, cfg, NewInMemoryUserRepository())
+	clt.session = NewSession(user, big.NewInt(10), big.NewInt(10), big.NewInt(10))
+
+	t.Run("should require the session of the service", func(t *testing.T) {
+		// when
+		_, err := clt.GetMetadata(context.Background())
+
+		// then
+		if errors.Cause(err) != ErrLoginRequired {
+			t.Errorf("GetMetadata() error = %v, want %v", err, ErrLoginRequired)
+		}
+	})
+
+	t.Run("should get the group of the service", func(t *testing.T) {
+		// when
+		group, pk, err := clt.group(context.Background())
+
+		// then
+		if err != nil || group.Name() != testGroup.Name() || pk.Cmp(testGroup.Generator()) != 0 {
+			t.Errorf("group() = %v, %v, %v, want %v", group, pk, err, testGroup.Name())
+		}
+	})
+
+	t.Run("should send the request ID", func(t *testing.T) {
+		// given
+		req, err := http.NewRequest(http.MethodGet, cfg.groupPath, nil)
+		if err != nil {
+			t.Fatalf("http.NewRequest() error = %v", err)
+		}
+		req.Header.Set(contract.RequestIDHeader, "request-1")
+
+		// when
+		resp, err := pst.Do(req)
+
+		// then
+		if err != nil || resp.Header.Get(contract.RequestIDHeader) != "request-1" {
+			t.Errorf("Do() request ID = %v, %v, want request-1", resp.Header.Get(contract.RequestIDHeader), err)
+		}
+	})
+
+	t.Run("should cancel calls with the context", func(t *testing.T) {
+		// given
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		// when
+		_, err := clt.GetMetadata(ctx)
+
+		// then
+		if status.Code(errors.Cause(err)) != codes.Canceled {
+			t.Errorf("GetMetadata() error = %v, want %v", err, codes.Canceled)
+		}
+	})
+
+	t.Run("should return errors of the service", func(t *testing.T) {
+		// when
+		_, err := clt.Get(context.Background(), "domain")
+
+		// then
+		if errors.Cause(err) != ErrDomainNotFound {
+			t.Errorf("Get() error = %v, want %v", err, ErrDomainNotFound)
+		}
+	})
+}
+
+func TestGRPCPoster_Session(t *testing.T) {
+	pst, stop := newTestGRPCPoster(t)
+	defer stop()
+
+	cfg, err := NewConfiguration("https://localhost", 8, sha256.New)
+	if err != nil {
+		t.Fatalf("NewConfiguration() error = %v", err)
+	}
+	user, err := newUser("username", big.NewInt(1), testGroup, nil)
+	if err != nil {
+		t.Fatalf("newUser() error = %v", err)
+	}
+	clt := New(pst, cfg, NewInMemoryUserRepository())
+	clt.session = NewSession(user, big.NewInt(10), big.NewInt(10), big.NewInt(10))
+
+	t.Run("should keep the session of ExpK", func(t *testing.T) {
+		// given
+		rd, err := contract.MarshalExpKRequest(contract.ExpKRequest{CID: big.NewInt(1), CNonce: big.NewInt(2), B: big.NewInt(4), Q: testGroup.Order(), X: big.NewInt(4)})
+		if err != nil {
+			t.Fatalf("MarshalExpKRequest() error = %v", err)
+		}
+		req, err := http.NewRequest(http.MethodPost, cfg.expkPath, rd)
+		if err != nil {
+			t.Fatalf("http.NewRequest() error = %v", err)
+		}
+		resp, err := pst.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		expk, err := contract.UnmarshalExpKResponse(resp.Body)
+		if err != nil || expk.BD.Cmp(big.NewInt(3)) != 0 {
+			t.Fatalf("UnmarshalExpKResponse() = %v, %v", expk, err)
+		}
+
+		// when
+		domains, err := clt.GetMetadata(context.Background())
+
+		// then
+		if err != nil || !reflect.DeepEqual(domains, []string{"domain"}) {
+			t.Errorf("GetMetadata() = %v, %v, want [domain]", domains, err)
+		}
+	})
+
+	t.Run("should drop the session on logout", func(t *testing.T) {
+		// when
+		err := clt.Logout(context.Background())
+		if err != nil {
+			t.Fatalf("Logout() error = %v", err)
+		}
+		clt.session = NewSession(user, big.NewInt(10), big.NewInt(10), big.NewInt(10))
+		_, err = clt.GetMetadata(context.Background())
+
+		// then
+		if errors.Cause(err) != ErrLoginRequired {
+			t.Errorf("GetMetadata() error = %v, want %v", err, ErrLoginRequired)
+		}
+	})
+}
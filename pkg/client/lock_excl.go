@@ -0,0 +1,35 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package client
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// lockTimeout is how long lockFile waits for a lock file held by another process.
+const lockTimeout = 10 * time.Second
+
+// lockFile acquires an exclusive lock by creating fn, on platforms without advisory locks.
+// fn outlives a crashed process, so lockFile gives up after lockTimeout and names the file to remove.
+// The returned function releases the lock.
+func lockFile(fn string) (func(), error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(fn) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, errors.Wrapf(err, "failed to create lock file %s", fn)
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.Wrapf(err, "failed to lock %s, remove it if no other oscli runs", fn)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build aix || solaris
+// +build aix solaris
+
+package client
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// lockFile acquires an exclusive advisory fcntl lock on fn, which is created if needed,
+// as solaris, illumos and aix have no flock. The returned function releases the lock.
+func lockFile(fn string) (func(), error) {
+	f, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open lock file %s", fn)
+	}
+
+	lk := unix.Flock_t{Type: unix.F_WRLCK, Whence: io.SeekStart}
+	if err := unix.FcntlFlock(f.Fd(), unix.F_SETLKW, &lk); err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "failed to lock %s", fn)
+	}
+
+	return func() {
+		lk.Type = unix.F_UNLCK
+		unix.FcntlFlock(f.Fd(), unix.F_SETLK, &lk)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,38 @@
+//go:build windows
+// +build windows
+
+package client
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+// lockFile acquires an exclusive lock on fn, which is created if needed.
+// The lock belongs to the open handle, so that windows releases it when the process exits.
+// The returned function releases the lock.
+func lockFile(fn string) (func(), error) {
+	f, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open lock file %s", fn)
+	}
+
+	h := windows.Handle(f.Fd())
+	ol := new(windows.Overlapped)
+	if err := windows.LockFileEx(h, windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, ol); err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "failed to lock %s", fn)
+	}
+
+	return func() {
+		windows.UnlockFileEx(h, 0, 1, 0, ol)
+		f.Close()
+	}, nil
+}
+
+// syncDir is a no-op, directories can not be synced on windows.
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"math/big"
+)
+
+// heapInt returns a copy of x on the heap and a function wiping it.
+func heapInt(x *big.Int) (*big.Int, func()) {
+	y := new(big.Int).Set(x)
+	return y, func() { wipeInt(y) }
+}
+
+// wipeInt overwrites the words of x with zeros and sets x to zero.
+func wipeInt(x *big.Int) {
+	if x == nil {
+		return
+	}
+	wipeWords(x.Bits())
+	x.SetBits(nil)
+}
+
+func wipeWords(words []big.Word) {
+	for i := range words {
+		words[i] = 0
+	}
+}
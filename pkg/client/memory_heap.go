@@ -0,0 +1,14 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package client
+
+import (
+	"math/big"
+)
+
+// lockedInt returns a copy of x kept on the heap on platforms without mlock, e.g. windows.
+// The returned function wipes the copy.
+func lockedInt(x *big.Int) (*big.Int, func()) {
+	return heapInt(x)
+}
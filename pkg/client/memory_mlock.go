@@ -0,0 +1,42 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package client
+
+import (
+	"math/big"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockedInt returns a copy of x whose words are kept in memory locked against swapping,
+// e.g. the session key. Without locked memory, e.g. beyond RLIMIT_MEMLOCK, the copy is kept on the heap.
+// The returned function wipes the copy and releases the memory.
+func lockedInt(x *big.Int) (*big.Int, func()) {
+	words := x.Bits()
+	if len(words) == 0 {
+		return heapInt(x)
+	}
+
+	size := len(words) * int(unsafe.Sizeof(words[0]))
+	mem, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return heapInt(x)
+	}
+	if err := unix.Mlock(mem); err != nil {
+		unix.Munmap(mem)
+		return heapInt(x)
+	}
+
+	locked := (*[1 << 20]big.Word)(unsafe.Pointer(&mem[0]))[:len(words):len(words)]
+	copy(locked, words)
+	y := new(big.Int).SetBits(locked)
+
+	return y, func() {
+		wipeWords(locked)
+		y.SetBits(nil)
+		unix.Munlock(mem)
+		unix.Munmap(mem)
+	}
+}
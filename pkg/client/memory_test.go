@@ -0,0 +1,50 @@
+package client
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestLockedInt(t *testing.T) {
+	t.Run("should copy x and wipe the copy on release", func(t *testing.T) {
+		// given
+		x, _ := new(big.Int).SetString("123456789abcdef0123456789abcdef0123456789", 16)
+
+		// when
+		y, release := lockedInt(x)
+
+		// then
+		if y.Cmp(x) != 0 {
+			t.Fatalf("lockedInt() = %v, want %v", y, x)
+		}
+		release()
+		if y.Sign() != 0 {
+			t.Errorf("lockedInt() after release = %v, want 0", y)
+		}
+		if x.Sign() == 0 {
+			t.Errorf("lockedInt() release wiped x")
+		}
+	})
+
+	t.Run("should overwrite the words of a heap copy", func(t *testing.T) {
+		x, _ := new(big.Int).SetString("123456789abcdef0123456789abcdef0123456789", 16)
+		y, release := heapInt(x)
+		words := y.Bits()
+
+		release()
+
+		for i, w := range words {
+			if w != 0 {
+				t.Errorf("heapInt() word %d = %x after release, want 0", i, w)
+			}
+		}
+	})
+
+	t.Run("should copy zero", func(t *testing.T) {
+		y, release := lockedInt(new(big.Int))
+		defer release()
+		if y.Sign() != 0 {
+			t.Errorf("lockedInt() = %v, want 0", y)
+		}
+	})
+}
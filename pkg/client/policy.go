@@ -0,0 +1,197 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/hkdf"
+)
+
+// ErrInvalidPolicy is returned when a password policy can not be satisfied.
+var ErrInvalidPolicy = errors.New("invalid password policy")
+
+// Character classes of a Policy.
+const (
+	ClassLower  = 'l'
+	ClassUpper  = 'u'
+	ClassDigit  = 'd'
+	ClassSymbol = 's'
+)
+
+const (
+	lowers = "abcdefghijklmnopqrstuvwxyz"
+	uppers = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digits = "0123456789"
+	// DefaultSymbols are the symbols used if a policy allows symbols but does not list them.
+	DefaultSymbols = "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+	// MaxPasswordLength is the longest password a policy can render.
+	MaxPasswordLength = 128
+)
+
+// passwordInfo separates the key derivation of domain passwords, the version
+// allows to change the rendering without silently changing existing passwords.
+const passwordInfo = "online-sphinx password v1 "
+
+// Policy describes the passwords a domain accepts.
+// Classes are given as string of the letters 'l' lower case, 'u' upper case, 'd' digits and 's' symbols.
+type Policy struct {
+	// Length of the password.
+	Length int `json:"length"`
+	// MaxLength accepted by the domain, shortens Length if set.
+	MaxLength int `json:"maxLength,omitempty"`
+	// Allowed character classes.
+	Allowed string `json:"allowed"`
+	// Required character classes, the password contains at least one character of each.
+	Required string `json:"required"`
+	// Symbols allowed by the domain, DefaultSymbols if empty.
+	Symbols string `json:"symbols,omitempty"`
+}
+
+// DefaultPolicy returns the policy of domains added without policy,
+// 20 characters containing all character classes.
+func DefaultPolicy() Policy {
+	return Policy{
+		Length:   20,
+		Allowed:  "luds",
+		Required: "luds",
+	}
+}
+
+// Validate returns ErrInvalidPolicy if no password satisfies the policy.
+func (p Policy) Validate() error {
+	n := p.length()
+	if n < 4 || n > MaxPasswordLength {
+		return errors.Wrapf(ErrInvalidPolicy, "length %d not in [4, %d]", n, MaxPasswordLength)
+	}
+	if p.Allowed == "" {
+		return errors.Wrap(ErrInvalidPolicy, "no character class allowed")
+	}
+	for _, c := range p.Allowed {
+		if !strings.ContainsRune("luds", c) || strings.Count(p.Allowed, string(c)) > 1 {
+			return errors.Wrapf(ErrInvalidPolicy, "invalid allowed classes %q", p.Allowed)
+		}
+	}
+	for _, c := range p.Required {
+		if !strings.ContainsRune(p.Allowed, c) || strings.Count(p.Required, string(c)) > 1 {
+			return errors.Wrapf(ErrInvalidPolicy, "invalid required classes %q", p.Required)
+		}
+	}
+	if len(p.Required) > n {
+		return errors.Wrapf(ErrInvalidPolicy, "%d required classes do not fit into %d characters", len(p.Required), n)
+	}
+	for i, c := range p.Symbols {
+		if c < '!' || c > '~' || strings.ContainsRune(lowers+uppers+digits, c) || strings.IndexRune(p.Symbols, c) != i {
+			return errors.Wrapf(ErrInvalidPolicy, "invalid symbols %q", p.Symbols)
+		}
+	}
+	return nil
+}
+
+// Password renders the password of domain from secret according to the policy.
+// The secret is expanded with HKDF-SHA256 bound to the domain, so the same secret
+// and policy always give the same password.
+func (p Policy) Password(secret []byte, domain string) (string, error) {
+	err := p.Validate()
+	if err != nil {
+		return "", errors.Wrap(err, "Password")
+	}
+	r := hkdf.New(sha256.New, secret, nil, []byte(passwordInfo+domain))
+
+	var alphabet string
+	for _, c := range p.Allowed {
+		alphabet += p.characters(c)
+	}
+
+	pwd := make([]byte, 0, p.length())
+	for _, c := range p.Required {
+		chars := p.characters(c)
+		i, err := uniform(r, len(chars))
+		if err != nil {
+			return "", errors.Wrap(err, "Password")
+		}
+		pwd = append(pwd, chars[i])
+	}
+	for len(pwd) < p.length() {
+		i, err := uniform(r, len(alphabet))
+		if err != nil {
+			return "", errors.Wrap(err, "Password")
+		}
+		pwd = append(pwd, alphabet[i])
+	}
+
+	// shuffle so that required characters are not always in front
+	for i := len(pwd) - 1; i > 0; i-- {
+		j, err := uniform(r, i+1)
+		if err != nil {
+			return "", errors.Wrap(err, "Password")
+		}
+		pwd[i], pwd[j] = pwd[j], pwd[i]
+	}
+	return string(pwd), nil
+}
+
+func (p Policy) length() int {
+	if p.MaxLength > 0 && p.MaxLength < p.Length {
+		return p.MaxLength
+	}
+	return p.Length
+}
+
+func (p Policy) characters(class rune) string {
+	switch class {
+	case ClassLower:
+		return lowers
+	case ClassUpper:
+		return uppers
+	case ClassDigit:
+		return digits
+	case ClassSymbol:
+		if p.Symbols == "" {
+			return DefaultSymbols
+		}
+		return p.Symbols
+	}
+	return ""
+}
+
+// uniform returns an unbiased random number in [0, n) for n <= 256 by rejection sampling.
+func uniform(r io.Reader, n int) (int, error) {
+	limit := 256 - 256%n
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return 0, errors.Wrap(err, "uniform: failed to read random byte")
+		}
+		if int(b[0]) < limit {
+			return int(b[0]) % n, nil
+		}
+	}
+}
+
+// domainMetadata is stored by the service with every domain,
+// domains added before policies have none and keep their hex encoded password.
+type domainMetadata struct {
+	Policy *Policy `json:"policy,omitempty"`
+}
+
+func marshalDomainMetadata(m domainMetadata) (string, error) {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return "", errors.Wrap(err, "marshalDomainMetadata")
+	}
+	return string(buf), nil
+}
+
+func unmarshalDomainMetadata(s string) (domainMetadata, error) {
+	var m domainMetadata
+	if s == "" {
+		return m, nil
+	}
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return m, errors.Wrap(err, "unmarshalDomainMetadata")
+	}
+	return m, nil
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{"should accept default policy", DefaultPolicy(), false},
+		{"should accept digits only", Policy{Length: 6, Allowed: "d", Required: "d"}, false},
+		{"should accept max length shorter than length", Policy{Length: 64, MaxLength: 16, Allowed: "lu"}, false},
+		{"should reject too short passwords", Policy{Length: 3, Allowed: "l"}, true},
+		{"should reject too long passwords", Policy{Length: MaxPasswordLength + 1, Allowed: "l"}, true},
+		{"should reject max length shorter than required classes", Policy{Length: 20, MaxLength: 3, Allowed: "luds", Required: "luds"}, true},
+		{"should reject no allowed class", Policy{Length: 20}, true},
+		{"should reject unknown class", Policy{Length: 20, Allowed: "lx"}, true},
+		{"should reject required but not allowed class", Policy{Length: 20, Allowed: "l", Required: "d"}, true},
+		{"should reject alphanumeric symbols", Policy{Length: 20, Allowed: "s", Symbols: "!a"}, true},
+		{"should reject duplicate symbols", Policy{Length: 20, Allowed: "s", Symbols: "!!"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && errors.Cause(err) != ErrInvalidPolicy {
+				t.Errorf("Validate() error = %v, want %v", err, ErrInvalidPolicy)
+			}
+		})
+	}
+}
+
+func TestPolicy_Password(t *testing.T) {
+	secret := []byte("secret")
+
+	t.Run("should be deterministic", func(t *testing.T) {
+		p := DefaultPolicy()
+		got, err := p.Password(secret, "example.com")
+		if err != nil {
+			t.Fatalf("Password() error = %v", err)
+		}
+		again, _ := p.Password(secret, "example.com")
+		if got != again {
+			t.Errorf("Password() = %v and %v", got, again)
+		}
+	})
+
+	t.Run("should not change between versions", func(t *testing.T) {
+		got, err := DefaultPolicy().Password(secret, "example.com")
+		if err != nil {
+			t.Fatalf("Password() error = %v", err)
+		}
+		if want := "u8l7?|bmN4u0iGR^$!4="; got != want {
+			t.Errorf("Password() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("should depend on domain and secret", func(t *testing.T) {
+		p := DefaultPolicy()
+		a, _ := p.Password(secret, "example.com")
+		b, _ := p.Password(secret, "example.org")
+		c, _ := p.Password([]byte("other"), "example.com")
+		if a == b || a == c {
+			t.Errorf("Password() = %v, %v, %v", a, b, c)
+		}
+	})
+
+	t.Run("should satisfy the policy", func(t *testing.T) {
+		p := Policy{Length: 32, MaxLength: 10, Allowed: "lds", Required: "lds", Symbols: "-_"}
+		for _, domain := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+			pwd, err := p.Password(secret, domain)
+			if err != nil {
+				t.Fatalf("Password() error = %v", err)
+			}
+			if len(pwd) != 10 {
+				t.Errorf("Password() = %v, want length 10", pwd)
+			}
+			if strings.Trim(pwd, lowers+digits+"-_") != "" {
+				t.Errorf("Password() = %v contains characters which are not allowed", pwd)
+			}
+			for _, chars := range []string{lowers, digits, "-_"} {
+				if !strings.ContainsAny(pwd, chars) {
+					t.Errorf("Password() = %v contains none of %v", pwd, chars)
+				}
+			}
+		}
+	})
+
+	t.Run("should return ErrInvalidPolicy", func(t *testing.T) {
+		_, err := Policy{Length: 20}.Password(secret, "example.com")
+		if errors.Cause(err) != ErrInvalidPolicy {
+			t.Errorf("Password() error = %v, want %v", err, ErrInvalidPolicy)
+		}
+	})
+}
@@ -2,9 +2,15 @@ package client
 
 import (
 	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
 	"math/big"
+	"os"
+	"path/filepath"
 	"sync"
 
+	"github.com/LAtanassov/go-online-sphinx/pkg/crypto"
+	homedir "github.com/mitchellh/go-homedir"
 	"github.com/pkg/errors"
 )
 
@@ -13,6 +19,8 @@ var (
 	ErrUserAlreadyExists = errors.New("user already exists")
 	// ErrUserNotFound in repostory
 	ErrUserNotFound = errors.New("user not found")
+	// ErrUnsupportedFileVersion is returned when a user file was written by an unknown oscli version
+	ErrUnsupportedFileVersion = errors.New("unsupported user file version")
 )
 
 // UserRepository contains user specific configuration.
@@ -27,38 +35,62 @@ type UserRepository struct {
 }
 
 // User specific configuration contains
-// a client ID and important login-specific variables like prime q and secret k.
+// a client ID and important login-specific variables like the group of the service and secret k.
+// pk is the public key of the service pinned at registration, users registered before
+// the service published its key pin it on their first successful login or enrollment.
+// hashVersion selects how the password is mapped into group, it is kept until the user migrates.
 type User struct {
-	username string
-	cID      *big.Int
-	q        *big.Int
-	k        *big.Int
+	username    string
+	cID         *big.Int
+	group       crypto.Group
+	k           *big.Int
+	pk          *big.Int
+	hashVersion int
 }
 
-// newUser generates new user with username.
-func newUser(username string, bits int) (User, error) {
-	q, err := rand.Prime(rand.Reader, bits)
+// clientIDBits is the length of a random client ID.
+const clientIDBits = 256
+
+// newClientID generates a random client ID.
+func newClientID() (*big.Int, error) {
+	cID, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), clientIDBits))
 	if err != nil {
-		return User{}, errors.Wrapf(err, "newUser %s, %d bits: failed to generate radnom prime", username, bits)
+		return nil, errors.Wrap(err, "newClientID: failed to generate random int")
 	}
+	return cID, nil
+}
 
-	cID, err := rand.Int(rand.Reader, q)
+// newUser generates new user with username within the group of the service with public key pk.
+func newUser(username string, cID *big.Int, g crypto.Group, pk *big.Int) (User, error) {
+	k, err := g.RandomScalar()
 	if err != nil {
-		return User{}, errors.Wrapf(err, "newUser %s, %d bits: failed to generate random int", username, bits)
+		return User{}, errors.Wrapf(err, "newUser %s, group %s: failed to generate random int", username, g.Name())
 	}
-
-	k, err := rand.Int(rand.Reader, q)
+	g, err = crypto.WithHashVersion(g, crypto.HashVersionCurrent)
 	if err != nil {
-		return User{}, errors.Wrapf(err, "newUser %s, %d bits: failed to generate random int", username, bits)
+		return User{}, errors.Wrapf(err, "newUser %s", username)
 	}
 	return User{
-		username: username,
-		cID:      cID,
-		k:        k,
-		q:        q,
+		username:    username,
+		cID:         cID,
+		group:       g,
+		k:           k,
+		pk:          pk,
+		hashVersion: crypto.HashVersionCurrent,
 	}, nil
 }
 
+// withHashVersion returns u mapping its password into the group with the given version.
+func (u User) withHashVersion(version int) (User, error) {
+	g, err := crypto.WithHashVersion(u.group, version)
+	if err != nil {
+		return User{}, errors.Wrapf(err, "withHashVersion %s", u.username)
+	}
+	u.group = g
+	u.hashVersion = version
+	return u, nil
+}
+
 // NewInMemoryUserRepository return an in memory UserRepository.
 // using pointer semantic allocated in heap once for sharing
 func NewInMemoryUserRepository() *UserRepository {
@@ -82,6 +114,20 @@ func (r *UserRepository) Add(u User) error {
 	return nil
 }
 
+// Update an existing user
+func (r *UserRepository) Update(u User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, ok := r.users[u.username]
+	if !ok {
+		return errors.Wrapf(ErrUserNotFound, "Update: %s", u.username)
+	}
+
+	r.users[u.username] = u
+	return nil
+}
+
 // Get an existing user
 func (r *UserRepository) Get(username string) (User, error) {
 	r.mutex.Lock()
@@ -94,7 +140,236 @@ func (r *UserRepository) Get(username string) (User, error) {
 	return u, nil
 }
 
-// NewSQLiteUserRepository ...
-func NewSQLiteUserRepository(fn string) *UserRepository {
-	return nil
+// userFileVersion is the version of the file format written by FileUserRepository.
+const userFileVersion = 1
+
+// FileUserRepository stores all users within a single JSON file,
+// so that users can easily copy and transfer it between devices.
+// The file is only readable by its owner, guarded by an advisory lock
+// shared between oscli processes and replaced atomically on every write.
+type FileUserRepository struct {
+	mutex sync.Mutex
+	path  string
+}
+
+type userFile struct {
+	Version int                       `json:"version"`
+	Users   map[string]userFileRecord `json:"users"`
+}
+
+// userFileRecord of users registered before named groups have no group
+// and are restored as custom safe prime group of order q.
+// Records without hash version are users of crypto.HashVersionLegacy.
+type userFileRecord struct {
+	CID         string `json:"cID"`
+	Group       string `json:"group,omitempty"`
+	Q           string `json:"q"`
+	K           string `json:"k"`
+	PK          string `json:"pk,omitempty"`
+	HashVersion int    `json:"hashVersion,omitempty"`
+}
+
+// NewFileUserRepository returns a UserRepository stored in file fn.
+// A leading '~' is expanded to the home directory of the current user.
+// The file is created on the first Add.
+func NewFileUserRepository(fn string) (*FileUserRepository, error) {
+	path, err := homedir.Expand(fn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "NewFileUserRepository: failed to expand %s", fn)
+	}
+
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "NewFileUserRepository: failed to resolve %s", fn)
+	}
+
+	return &FileUserRepository{
+		mutex: sync.Mutex{},
+		path:  path,
+	}, nil
+}
+
+// Add new user to user repository if does not exists
+func (r *FileUserRepository) Add(u User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	unlock, err := lockFile(r.path + ".lock")
+	if err != nil {
+		return errors.Wrapf(err, "Add: failed to lock %s", r.path)
+	}
+	defer unlock()
+
+	f, err := r.read()
+	if err != nil {
+		return errors.Wrapf(err, "Add: %s", u.username)
+	}
+
+	_, ok := f.Users[u.username]
+	if ok {
+		return errors.Wrapf(ErrUserAlreadyExists, "Add: %s", u.username)
+	}
+
+	f.Users[u.username] = newUserFileRecord(u)
+
+	return errors.Wrapf(r.write(f), "Add: %s", u.username)
+}
+
+// Update an existing user
+func (r *FileUserRepository) Update(u User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	unlock, err := lockFile(r.path + ".lock")
+	if err != nil {
+		return errors.Wrapf(err, "Update: failed to lock %s", r.path)
+	}
+	defer unlock()
+
+	f, err := r.read()
+	if err != nil {
+		return errors.Wrapf(err, "Update: %s", u.username)
+	}
+
+	_, ok := f.Users[u.username]
+	if !ok {
+		return errors.Wrapf(ErrUserNotFound, "Update: %s", u.username)
+	}
+
+	f.Users[u.username] = newUserFileRecord(u)
+
+	return errors.Wrapf(r.write(f), "Update: %s", u.username)
+}
+
+func newUserFileRecord(u User) userFileRecord {
+	rec := userFileRecord{
+		CID:         u.cID.Text(16),
+		Group:       u.group.Name(),
+		Q:           u.group.Order().Text(16),
+		K:           u.k.Text(16),
+		HashVersion: u.hashVersion,
+	}
+	if u.pk != nil {
+		rec.PK = u.pk.Text(16)
+	}
+	return rec
+}
+
+// Get an existing user
+func (r *FileUserRepository) Get(username string) (User, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	unlock, err := lockFile(r.path + ".lock")
+	if err != nil {
+		return User{}, errors.Wrapf(err, "Get: failed to lock %s", r.path)
+	}
+	defer unlock()
+
+	f, err := r.read()
+	if err != nil {
+		return User{}, errors.Wrapf(err, "Get %s", username)
+	}
+
+	rec, ok := f.Users[username]
+	if !ok {
+		return User{}, errors.Wrapf(ErrUserNotFound, "Get %s", username)
+	}
+
+	u := User{username: username}
+	var q *big.Int
+	for _, v := range []struct {
+		dst **big.Int
+		hex string
+	}{{&u.cID, rec.CID}, {&q, rec.Q}, {&u.k, rec.K}} {
+		n, ok := new(big.Int).SetString(v.hex, 16)
+		if !ok {
+			return User{}, errors.Errorf("Get %s: corrupt user record in %s", username, r.path)
+		}
+		*v.dst = n
+	}
+
+	u.group, err = crypto.NewGroup(rec.Group, q)
+	if err != nil {
+		return User{}, errors.Wrapf(err, "Get %s: corrupt user record in %s", username, r.path)
+	}
+
+	u, err = u.withHashVersion(rec.HashVersion)
+	if err != nil {
+		return User{}, errors.Wrapf(err, "Get %s: corrupt user record in %s", username, r.path)
+	}
+
+	if rec.PK != "" {
+		pk, ok := new(big.Int).SetString(rec.PK, 16)
+		if !ok || !u.group.IsElement(pk) {
+			return User{}, errors.Errorf("Get %s: corrupt user record in %s", username, r.path)
+		}
+		u.pk = pk
+	}
+	return u, nil
+}
+
+// read loads the user file, a missing file is an empty repository.
+func (r *FileUserRepository) read() (userFile, error) {
+	f := userFile{Version: userFileVersion, Users: make(map[string]userFileRecord)}
+
+	buf, err := ioutil.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return f, errors.Wrapf(err, "failed to read %s", r.path)
+	}
+
+	if err := json.Unmarshal(buf, &f); err != nil {
+		return f, errors.Wrapf(err, "failed to parse %s", r.path)
+	}
+	if f.Version != userFileVersion {
+		return f, errors.Wrapf(ErrUnsupportedFileVersion, "%s has version %d", r.path, f.Version)
+	}
+	if f.Users == nil {
+		f.Users = make(map[string]userFileRecord)
+	}
+	return f, nil
+}
+
+// write replaces the user file atomically by writing and syncing a temporary file
+// within the same directory and renaming it afterwards.
+func (r *FileUserRepository) write(f userFile) error {
+	buf, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal users")
+	}
+
+	dir := filepath.Dir(r.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrapf(err, "failed to create %s", dir)
+	}
+
+	tmp, err := ioutil.TempFile(dir, filepath.Base(r.path)+".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temporary file in %s", dir)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "failed to chmod %s", tmp.Name())
+	}
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "failed to write %s", tmp.Name())
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "failed to sync %s", tmp.Name())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close %s", tmp.Name())
+	}
+
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return errors.Wrapf(err, "failed to replace %s", r.path)
+	}
+	return syncDir(dir)
 }
@@ -1,15 +1,25 @@
 package client
 
 import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
 	"testing"
 
 	"github.com/pkg/errors"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/crypto"
 )
 
 func TestUserRepository_Add(t *testing.T) {
 	t.Run("should add new user", func(t *testing.T) {
 		repo := NewInMemoryUserRepository()
-		user, err := newUser("username", 8)
+		user, err := newUser("username", big.NewInt(1), testGroup, nil)
 		if err != nil {
 			t.Errorf("newUser() failed error = %v", err)
 		}
@@ -21,7 +31,7 @@ func TestUserRepository_Add(t *testing.T) {
 
 	t.Run("should return error if an existing user is added again", func(t *testing.T) {
 		repo := NewInMemoryUserRepository()
-		user, err := newUser("username", 8)
+		user, err := newUser("username", big.NewInt(1), testGroup, nil)
 		if err != nil {
 			t.Errorf("newUser() failed error = %v", err)
 		}
@@ -39,7 +49,7 @@ func TestUserRepository_Add(t *testing.T) {
 
 	t.Run("should return an existing user", func(t *testing.T) {
 		repo := NewInMemoryUserRepository()
-		expUser, err := newUser("username", 8)
+		expUser, err := newUser("username", big.NewInt(1), testGroup, nil)
 		if err != nil {
 			t.Errorf("newUser() failed error = %v", err)
 		}
@@ -68,3 +78,248 @@ func TestUserRepository_Get(t *testing.T) {
 		t.Errorf("InMemoryRepository.Get() error = %v wantError = %v", err, ErrUserNotFound)
 	}
 }
+
+func TestFileUserRepository_Add(t *testing.T) {
+	t.Run("should add new user and get it from a new repository instance", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		fn := filepath.Join(dir, "users.json")
+		repo, err := NewFileUserRepository(fn)
+		if err != nil {
+			t.Fatalf("NewFileUserRepository() error = %v", err)
+		}
+		wantUser, err := newUser("username", big.NewInt(1), testGroup, nil)
+		if err != nil {
+			t.Errorf("newUser() failed error = %v", err)
+		}
+
+		err = repo.Add(wantUser)
+		if err != nil {
+			t.Errorf("FileUserRepository.Add() error = %v", err)
+		}
+
+		repo, err = NewFileUserRepository(fn)
+		if err != nil {
+			t.Fatalf("NewFileUserRepository() error = %v", err)
+		}
+		gotUser, err := repo.Get("username")
+		if err != nil {
+			t.Errorf("FileUserRepository.Get() error = %v", err)
+		}
+		if !reflect.DeepEqual(gotUser, wantUser) {
+			t.Errorf("FileUserRepository.Get() = %v, want %v", gotUser, wantUser)
+		}
+	})
+
+	t.Run("should return error if an existing user is added again", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		repo, err := NewFileUserRepository(filepath.Join(dir, "users.json"))
+		if err != nil {
+			t.Fatalf("NewFileUserRepository() error = %v", err)
+		}
+		user, err := newUser("username", big.NewInt(1), testGroup, nil)
+		if err != nil {
+			t.Errorf("newUser() failed error = %v", err)
+		}
+
+		err = repo.Add(user)
+		if err != nil {
+			t.Errorf("Add() failed error = %v", err)
+		}
+
+		err = repo.Add(user)
+		if errors.Cause(err) != ErrUserAlreadyExists {
+			t.Errorf("FileUserRepository.Add() error = %v wantErr = %v", err, ErrUserAlreadyExists)
+		}
+	})
+
+	t.Run("should not lose users added concurrently by several repositories", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		fn := filepath.Join(dir, "users.json")
+		n := 10
+
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				repo, err := NewFileUserRepository(fn)
+				if err != nil {
+					t.Errorf("NewFileUserRepository() error = %v", err)
+					return
+				}
+				user, err := newUser(fmt.Sprintf("username-%d", i), big.NewInt(1), testGroup, nil)
+				if err != nil {
+					t.Errorf("newUser() failed error = %v", err)
+					return
+				}
+				if err := repo.Add(user); err != nil {
+					t.Errorf("FileUserRepository.Add() error = %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		repo, err := NewFileUserRepository(fn)
+		if err != nil {
+			t.Fatalf("NewFileUserRepository() error = %v", err)
+		}
+		for i := 0; i < n; i++ {
+			if _, err := repo.Get(fmt.Sprintf("username-%d", i)); err != nil {
+				t.Errorf("FileUserRepository.Get() error = %v", err)
+			}
+		}
+	})
+}
+
+func TestFileUserRepository_Groups(t *testing.T) {
+	t.Run("should add user of P-256 and get the same", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		repo, err := NewFileUserRepository(filepath.Join(dir, "users.json"))
+		if err != nil {
+			t.Fatalf("NewFileUserRepository() error = %v", err)
+		}
+		g, err := crypto.GroupByName(crypto.P256)
+		if err != nil {
+			t.Fatalf("GroupByName() error = %v", err)
+		}
+		wantUser, err := newUser("username", big.NewInt(1), g, g.Generator())
+		if err != nil {
+			t.Errorf("newUser() failed error = %v", err)
+		}
+
+		err = repo.Add(wantUser)
+		if err != nil {
+			t.Errorf("FileUserRepository.Add() error = %v", err)
+		}
+		gotUser, err := repo.Get("username")
+		if err != nil {
+			t.Errorf("FileUserRepository.Get() error = %v", err)
+		}
+		if !reflect.DeepEqual(gotUser, wantUser) {
+			t.Errorf("FileUserRepository.Get() = %v, want %v", gotUser, wantUser)
+		}
+	})
+
+	t.Run("should get user without group as custom safe prime group", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		fn := filepath.Join(dir, "users.json")
+		err := ioutil.WriteFile(fn, []byte(`{"version":1,"users":{"username":{"cID":"1","q":"3fb","k":"2"}}}`), 0600)
+		if err != nil {
+			t.Fatalf("ioutil.WriteFile() error = %v", err)
+		}
+		repo, err := NewFileUserRepository(fn)
+		if err != nil {
+			t.Fatalf("NewFileUserRepository() error = %v", err)
+		}
+
+		gotUser, err := repo.Get("username")
+		if err != nil {
+			t.Fatalf("FileUserRepository.Get() error = %v", err)
+		}
+		if gotUser.group.Order().Cmp(big.NewInt(1019)) != 0 {
+			t.Errorf("FileUserRepository.Get() group order = %v, want 1019", gotUser.group.Order())
+		}
+		if gotUser.hashVersion != crypto.HashVersionLegacy {
+			t.Errorf("FileUserRepository.Get() hashVersion = %v, want %v", gotUser.hashVersion, crypto.HashVersionLegacy)
+		}
+	})
+}
+
+func TestFileUserRepository_Permissions(t *testing.T) {
+	t.Run("should create file only readable by owner", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		fn := filepath.Join(dir, "users.json")
+		repo, err := NewFileUserRepository(fn)
+		if err != nil {
+			t.Fatalf("NewFileUserRepository() error = %v", err)
+		}
+		user, err := newUser("username", big.NewInt(1), testGroup, nil)
+		if err != nil {
+			t.Errorf("newUser() failed error = %v", err)
+		}
+		err = repo.Add(user)
+		if err != nil {
+			t.Errorf("FileUserRepository.Add() error = %v", err)
+		}
+
+		fi, err := os.Stat(fn)
+		if err != nil {
+			t.Fatalf("os.Stat() error = %v", err)
+		}
+		if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+			t.Errorf("FileUserRepository.Add() mode = %v, want %v", fi.Mode().Perm(), os.FileMode(0600))
+		}
+	})
+}
+
+func TestFileUserRepository_Update(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "users.json")
+	repo, err := NewFileUserRepository(fn)
+	if err != nil {
+		t.Fatalf("NewFileUserRepository() error = %v", err)
+	}
+	user, err := newUser("username", big.NewInt(1), testGroup, nil)
+	if err != nil {
+		t.Fatalf("newUser() failed error = %v", err)
+	}
+
+	t.Run("should return ErrUserNotFound for an unknown user", func(t *testing.T) {
+		err := repo.Update(user)
+		if errors.Cause(err) != ErrUserNotFound {
+			t.Errorf("FileUserRepository.Update() error = %v wantErr = %v", err, ErrUserNotFound)
+		}
+	})
+
+	t.Run("should update an existing user", func(t *testing.T) {
+		legacy, err := user.withHashVersion(crypto.HashVersionLegacy)
+		if err != nil {
+			t.Fatalf("withHashVersion() error = %v", err)
+		}
+		if err := repo.Add(legacy); err != nil {
+			t.Fatalf("FileUserRepository.Add() error = %v", err)
+		}
+
+		err = repo.Update(user)
+		if err != nil {
+			t.Errorf("FileUserRepository.Update() error = %v", err)
+		}
+
+		gotUser, err := repo.Get("username")
+		if err != nil {
+			t.Errorf("FileUserRepository.Get() error = %v", err)
+		}
+		if !reflect.DeepEqual(gotUser, user) {
+			t.Errorf("FileUserRepository.Get() = %v, want %v", gotUser, user)
+		}
+	})
+}
+
+func TestFileUserRepository_Get(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	r, err := NewFileUserRepository(filepath.Join(dir, "users.json"))
+	if err != nil {
+		t.Fatalf("NewFileUserRepository() error = %v", err)
+	}
+
+	_, err = r.Get("username")
+	if errors.Cause(err) != ErrUserNotFound {
+		t.Errorf("FileUserRepository.Get() error = %v wantError = %v", err, ErrUserNotFound)
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "oscli")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() error = %v", err)
+	}
+	return dir
+}
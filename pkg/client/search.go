@@ -0,0 +1,112 @@
+package client
+
+import (
+	"sort"
+	"strings"
+)
+
+// scores of Search, a matched character scores more at the start of a label and after a matched one.
+const (
+	scoreMatch       = 16
+	bonusBoundary    = 8
+	bonusConsecutive = 8
+	penaltyGap       = 1
+)
+
+// Match is a domain found by Search with its score, higher scores match better.
+type Match struct {
+	Domain string
+	Score  int
+}
+
+// Search returns the domains containing the characters of query in order, ignoring case,
+// e.g. "gml" finds "gmail.com" and "git.example.org". Matches are ordered by descending score,
+// shorter and then alphabetically smaller domains first on equal scores.
+// An empty query matches all domains.
+func Search(domains []string, query string) []Match {
+	matches := []Match{}
+	for _, d := range domains {
+		if score, ok := fuzzyScore(d, query); ok {
+			matches = append(matches, Match{Domain: d, Score: score})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if len(a.Domain) != len(b.Domain) {
+			return len(a.Domain) < len(b.Domain)
+		}
+		return a.Domain < b.Domain
+	})
+	return matches
+}
+
+// alignment is the best score of the query so far with its last character matched at a position of the domain.
+type alignment struct {
+	score int
+	ok    bool
+}
+
+// fuzzyScore returns the best score of all alignments of query within domain.
+func fuzzyScore(domain, query string) (int, bool) {
+	d := []rune(strings.ToLower(domain))
+	q := []rune(strings.ToLower(query))
+	if len(q) == 0 {
+		return 0, true
+	}
+
+	row := make([]alignment, len(d))
+	for i, c := range q {
+		row = nextRow(d, c, i == 0, row)
+	}
+	best := alignment{}
+	for _, a := range row {
+		best = better(best, a)
+	}
+	return best.score, best.ok
+}
+
+// nextRow returns the alignments of the next character c of the query in d
+// given the alignments row of the characters before.
+func nextRow(d []rune, c rune, first bool, row []alignment) []alignment {
+	next := make([]alignment, len(d))
+	// gap is the best alignment ending before d[j-1], less a penalty per skipped character
+	gap := alignment{}
+	for j := range d {
+		if j >= 2 {
+			gap = better(gap, row[j-2])
+		}
+		gap.score -= penaltyGap
+		if d[j] != c {
+			continue
+		}
+
+		prev := better(alignment{ok: first}, gap)
+		if j > 0 && row[j-1].ok {
+			prev = better(prev, alignment{score: row[j-1].score + bonusConsecutive, ok: true})
+		}
+		if prev.ok {
+			next[j] = alignment{score: prev.score + scoreMatch + boundary(d, j), ok: true}
+		}
+	}
+	return next
+}
+
+// better returns the alignment with the higher score.
+func better(a, b alignment) alignment {
+	if !a.ok || (b.ok && b.score > a.score) {
+		return b
+	}
+	return a
+}
+
+// boundary returns the bonus of a character starting a label or a word of a label.
+func boundary(d []rune, j int) int {
+	if j == 0 || strings.ContainsRune(".-_", d[j-1]) {
+		return bonusBoundary
+	}
+	return 0
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	domains := []string{"mail.google.com", "gmail.com", "example.com", "github.com", "git.example.org"}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"should match all domains by an empty query", "", []string{"gmail.com", "github.com", "example.com", "git.example.org", "mail.google.com"}},
+		{"should match characters in order", "gml", []string{"gmail.com", "git.example.org"}},
+		{"should ignore case", "GitHub", []string{"github.com"}},
+		{"should prefer consecutive characters at the start of labels", "gm", []string{"gmail.com", "git.example.org", "github.com", "mail.google.com"}},
+		{"should prefer the start of labels", "exo", []string{"git.example.org", "example.com"}},
+		{"should not match characters out of order", "lmg", []string{}},
+		{"should not match longer queries", "github.com.org", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// when
+			matches := Search(domains, tt.query)
+
+			// then
+			got := []string{}
+			for _, m := range matches {
+				got = append(got, m.Domain)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search() = %v, want %v", matches, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package contract
+
+import (
+	"encoding/binary"
+	"math/big"
+	"time"
+)
+
+// canonicalTag versions the canonical encoding of authenticated requests.
+const canonicalTag = "online-sphinx request v1"
+
+// canonicalRequest returns the data covered by the MAC of an authenticated request:
+// method and path of the request, its counter within the session, its timestamp in
+// unix milliseconds and all body fields. Strings and fields are length prefixed,
+// so that no two different requests share an encoding.
+// gRPC requests are encoded with the method and path of their JSON HTTP equivalent.
+func canonicalRequest(method, path string, counter uint64, timestamp time.Time, fields ...[]byte) []byte {
+	buf := appendField(nil, []byte(canonicalTag))
+	buf = appendField(buf, []byte(method))
+	buf = appendField(buf, []byte(path))
+
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], counter)
+	buf = append(buf, n[:]...)
+	binary.BigEndian.PutUint64(n[:], uint64(UnixMilli(timestamp)))
+	buf = append(buf, n[:]...)
+
+	for _, f := range fields {
+		buf = appendField(buf, f)
+	}
+	return buf
+}
+
+func appendField(buf, field []byte) []byte {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(field)))
+	return append(append(buf, n[:]...), field...)
+}
+
+func intField(x *big.Int) []byte {
+	if x == nil {
+		return nil
+	}
+	return x.Bytes()
+}
+
+func boolField(b bool) []byte {
+	if b {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// UnixMilli returns t in milliseconds since the unix epoch, the resolution of request timestamps.
+func UnixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// FromUnixMilli returns the time of ms milliseconds since the unix epoch.
+func FromUnixMilli(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// Canonical returns the data covered by the MAC of the request.
+func (r ChallengeRequest) Canonical() []byte {
+	return canonicalRequest("POST", "/v1/login/challenge", r.Counter, r.Timestamp, intField(r.G), intField(r.Q))
+}
+
+// Canonical returns the data covered by the MAC of the request.
+func (r MetadataRequest) Canonical() []byte {
+	return canonicalRequest("POST", "/v1/metadata", r.Counter, r.Timestamp)
+}
+
+// Canonical returns the data covered by the MAC of the request.
+func (r AddRequest) Canonical() []byte {
+	return canonicalRequest("POST", "/v1/add", r.Counter, r.Timestamp, []byte(r.Domain), []byte(r.Metadata))
+}
+
+// Canonical returns the data covered by the MAC of the request.
+func (r GetRequest) Canonical() []byte {
+	return canonicalRequest("POST", "/v1/get", r.Counter, r.Timestamp, []byte(r.Domain), intField(r.BMK), intField(r.Q), boolField(r.Previous))
+}
+
+// Canonical returns the data covered by the MAC of the request.
+func (r RotateRequest) Canonical() []byte {
+	return canonicalRequest("POST", "/v1/rotate", r.Counter, r.Timestamp, []byte(r.Domain))
+}
+
+// Canonical returns the data covered by the MAC of the request.
+func (r DeleteRequest) Canonical() []byte {
+	return canonicalRequest("POST", "/v1/delete", r.Counter, r.Timestamp, []byte(r.Domain))
+}
+
+// Canonical returns the data covered by the MAC of the request.
+func (r SessionsRequest) Canonical() []byte {
+	return canonicalRequest("POST", "/v1/sessions", r.Counter, r.Timestamp)
+}
+
+// Canonical returns the data covered by the MAC of the request.
+func (r RevokeSessionsRequest) Canonical() []byte {
+	return canonicalRequest("POST", "/v1/sessions/revoke", r.Counter, r.Timestamp, []byte(r.ID), boolField(r.All))
+}
@@ -0,0 +1,42 @@
+package contract
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCanonical(t *testing.T) {
+	ts := FromUnixMilli(1571234567890)
+	get := GetRequest{Counter: 1, Timestamp: ts, Domain: "domain", BMK: big.NewInt(2), Q: big.NewInt(3)}
+	tests := []struct {
+		name  string
+		a, b  interface{ Canonical() []byte }
+		equal bool
+	}{
+		{"should be deterministic", get, get, true},
+		{"should ignore the MAC", get, GetRequest{MAC: []byte("mac"), Counter: 1, Timestamp: ts, Domain: "domain", BMK: big.NewInt(2), Q: big.NewInt(3)}, true},
+		{"should ignore sub-millisecond time", get, GetRequest{Counter: 1, Timestamp: ts.Add(time.Microsecond), Domain: "domain", BMK: big.NewInt(2), Q: big.NewInt(3)}, true},
+		{"should cover the counter", get, GetRequest{Counter: 2, Timestamp: ts, Domain: "domain", BMK: big.NewInt(2), Q: big.NewInt(3)}, false},
+		{"should cover the timestamp", get, GetRequest{Counter: 1, Timestamp: ts.Add(time.Millisecond), Domain: "domain", BMK: big.NewInt(2), Q: big.NewInt(3)}, false},
+		{"should cover the domain", get, GetRequest{Counter: 1, Timestamp: ts, Domain: "other", BMK: big.NewInt(2), Q: big.NewInt(3)}, false},
+		{"should cover previous", get, GetRequest{Counter: 1, Timestamp: ts, Domain: "domain", BMK: big.NewInt(2), Q: big.NewInt(3), Previous: true}, false},
+		{"should cover the path", AddRequest{Counter: 1, Timestamp: ts, Domain: "domain"}, RotateRequest{Counter: 1, Timestamp: ts, Domain: "domain"}, false},
+		{"should cover the revoked session", RevokeSessionsRequest{Counter: 1, Timestamp: ts, ID: "a"}, RevokeSessionsRequest{Counter: 1, Timestamp: ts, ID: "b"}, false},
+		{"should cover revoking all sessions", RevokeSessionsRequest{Counter: 1, Timestamp: ts}, RevokeSessionsRequest{Counter: 1, Timestamp: ts, All: true}, false},
+		{"should separate sessions from metadata", SessionsRequest{Counter: 1, Timestamp: ts}, MetadataRequest{Counter: 1, Timestamp: ts}, false},
+		{"should separate delete from rotate", DeleteRequest{Counter: 1, Timestamp: ts, Domain: "domain"}, RotateRequest{Counter: 1, Timestamp: ts, Domain: "domain"}, false},
+		{"should separate fields", AddRequest{Counter: 1, Timestamp: ts, Domain: "ab", Metadata: "c"}, AddRequest{Counter: 1, Timestamp: ts, Domain: "a", Metadata: "bc"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// when
+			equal := bytes.Equal(tt.a.Canonical(), tt.b.Canonical())
+			// then
+			if equal != tt.equal {
+				t.Errorf("Canonical() equal = %v, want %v", equal, tt.equal)
+			}
+		})
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"io"
+	"math"
 	"math/big"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -19,9 +22,11 @@ var (
 // MarshalRegisterRequest ...
 func MarshalRegisterRequest(r RegisterRequest) (io.Reader, error) {
 	body := struct {
-		CID string `json:"CID"`
+		CID      string `json:"CID"`
+		Verifier string `json:"verifier"`
 	}{
-		CID: r.CID.Text(16),
+		CID:      r.CID.Text(16),
+		Verifier: r.Verifier.Text(16),
 	}
 	buf, err := json.Marshal(body)
 	if err != nil {
@@ -33,38 +38,301 @@ func MarshalRegisterRequest(r RegisterRequest) (io.Reader, error) {
 // UnmarshalRegisterRequest from json as byte array to struct
 func UnmarshalRegisterRequest(r io.Reader) (RegisterRequest, error) {
 	var body struct {
-		CID string `json:"CID"`
+		CID      string `json:"CID"`
+		Verifier string `json:"verifier"`
+	}
+
+	if err := decodeRequest(r, &body); err != nil {
+		return RegisterRequest{}, errors.Wrap(err, "UnmarshalRegisterRequest")
+	}
+
+	cID, err := parseInt("CID", body.CID)
+	if err != nil {
+		return RegisterRequest{}, errors.Wrap(err, "UnmarshalRegisterRequest")
+	}
+
+	verifier, err := parseInt("verifier", body.Verifier)
+	if err != nil {
+		return RegisterRequest{}, errors.Wrap(err, "UnmarshalRegisterRequest")
+	}
+
+	req := RegisterRequest{
+		CID:      cID,
+		Verifier: verifier,
+	}
+	if err := req.Validate(); err != nil {
+		return RegisterRequest{}, errors.Wrap(err, "UnmarshalRegisterRequest")
+	}
+	return req, nil
+}
+
+// RegisterRequest registers the client ID cID together with the verifier AK = G**ak
+// of the authentication key ak, which the client derives from the result of RegisterExpK.
+type RegisterRequest struct {
+	CID      *big.Int
+	Verifier *big.Int
+}
+
+// MarshalEnrollRequest ...
+func MarshalEnrollRequest(r EnrollRequest) (io.Reader, error) {
+	body := struct {
+		CID      string `json:"CID"`
+		Code     string `json:"code"`
+		Verifier string `json:"verifier"`
+		E        string `json:"e"`
+		ProofC   string `json:"proofC"`
+		ProofS   string `json:"proofS"`
+	}{
+		CID:      r.CID.Text(16),
+		Code:     r.Code,
+		Verifier: r.Verifier.Text(16),
+		E:        r.E.Text(16),
+		ProofC:   r.ProofC.Text(16),
+		ProofS:   r.ProofS.Text(16),
+	}
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(buf), nil
+}
+
+// UnmarshalEnrollRequest from json as byte array to struct
+func UnmarshalEnrollRequest(r io.Reader) (EnrollRequest, error) {
+	var body struct {
+		CID      string `json:"CID"`
+		Code     string `json:"code"`
+		Verifier string `json:"verifier"`
+		E        string `json:"e"`
+		ProofC   string `json:"proofC"`
+		ProofS   string `json:"proofS"`
+	}
+
+	if err := decodeRequest(r, &body); err != nil {
+		return EnrollRequest{}, errors.Wrap(err, "UnmarshalEnrollRequest")
+	}
+
+	var ints [5]*big.Int
+	for i, f := range []struct{ name, value string }{
+		{"CID", body.CID}, {"verifier", body.Verifier}, {"e", body.E}, {"proofC", body.ProofC}, {"proofS", body.ProofS},
+	} {
+		x, err := parseInt(f.name, f.value)
+		if err != nil {
+			return EnrollRequest{}, errors.Wrap(err, "UnmarshalEnrollRequest")
+		}
+		ints[i] = x
+	}
+
+	req := EnrollRequest{
+		CID:      ints[0],
+		Code:     body.Code,
+		Verifier: ints[1],
+		E:        ints[2],
+		ProofC:   ints[3],
+		ProofS:   ints[4],
+	}
+	if err := req.Validate(); err != nil {
+		return EnrollRequest{}, errors.Wrap(err, "UnmarshalEnrollRequest")
+	}
+	return req, nil
+}
+
+// EnrollRequest sets the verifier AK = G**ak of a registered client ID cID, e.g. of an user registered
+// before verifiers, who authenticates with the one-time Code the operator of the service issued.
+// E = H(cID)**ak and the DLEQ proof (ProofC, ProofS) prove the possession of ak, see crypto.ProvePossession.
+type EnrollRequest struct {
+	CID      *big.Int
+	Code     string
+	Verifier *big.Int
+	E        *big.Int
+	ProofC   *big.Int
+	ProofS   *big.Int
+}
+
+// MarshalRegisterExpKRequest ...
+func MarshalRegisterExpKRequest(r RegisterExpKRequest) (io.Reader, error) {
+	body := struct {
+		B string `json:"b"`
+		Q string `json:"q"`
+	}{
+		B: r.B.Text(16),
+		Q: r.Q.Text(16),
+	}
+
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(buf), nil
+}
+
+// UnmarshalRegisterExpKRequest ...
+func UnmarshalRegisterExpKRequest(r io.Reader) (RegisterExpKRequest, error) {
+	var body struct {
+		B string `json:"b"`
+		Q string `json:"q"`
+	}
+
+	if err := decodeRequest(r, &body); err != nil {
+		return RegisterExpKRequest{}, errors.Wrap(err, "UnmarshalRegisterExpKRequest")
+	}
+
+	b, err := parseInt("b", body.B)
+	if err != nil {
+		return RegisterExpKRequest{}, errors.Wrap(err, "UnmarshalRegisterExpKRequest")
+	}
+
+	q, err := parseInt("q", body.Q)
+	if err != nil {
+		return RegisterExpKRequest{}, errors.Wrap(err, "UnmarshalRegisterExpKRequest")
+	}
+
+	req := RegisterExpKRequest{
+		B: b,
+		Q: q,
+	}
+	if err := req.Validate(); err != nil {
+		return RegisterExpKRequest{}, errors.Wrap(err, "UnmarshalRegisterExpKRequest")
+	}
+	return req, nil
+}
+
+// RegisterExpKRequest contains the blinded password b a client needs evaluated before
+// it registers, it is not bound to a user and does not start a session.
+type RegisterExpKRequest struct {
+	B *big.Int
+	Q *big.Int
+}
+
+// MarshalRegisterExpKResponse ...
+func MarshalRegisterExpKResponse(w io.Writer, r RegisterExpKResponse) error {
+	body := struct {
+		BD     string `json:"bd"`
+		Q0     string `json:"q0"`
+		ProofC string `json:"proofC"`
+		ProofS string `json:"proofS"`
+	}{
+		BD:     r.BD.Text(16),
+		Q0:     r.Q0.Text(16),
+		ProofC: r.ProofC.Text(16),
+		ProofS: r.ProofS.Text(16),
+	}
+
+	return json.NewEncoder(w).Encode(body)
+}
+
+// UnmarshalRegisterExpKResponse ...
+func UnmarshalRegisterExpKResponse(r io.Reader) (RegisterExpKResponse, error) {
+	var body struct {
+		BD     string `json:"bd"`
+		Q0     string `json:"q0"`
+		ProofC string `json:"proofC"`
+		ProofS string `json:"proofS"`
 	}
 
 	if err := json.NewDecoder(r).Decode(&body); err != nil {
-		return RegisterRequest{}, err
+		return RegisterExpKResponse{}, err
 	}
 
-	cID := new(big.Int)
-	cID.SetString(body.CID, 16)
+	var ints [4]*big.Int
+	for i, s := range []string{body.BD, body.Q0, body.ProofC, body.ProofS} {
+		x, ok := new(big.Int).SetString(s, 16)
+		if !ok {
+			return RegisterExpKResponse{}, ErrUnexpectedType
+		}
+		ints[i] = x
+	}
 
-	return RegisterRequest{
-		CID: cID,
+	return RegisterExpKResponse{
+		BD:     ints[0],
+		Q0:     ints[1],
+		ProofC: ints[2],
+		ProofS: ints[3],
 	}, nil
 }
 
-// RegisterRequest ...
-type RegisterRequest struct {
-	CID *big.Int
+// RegisterExpKResponse contains bd = b**k, q0 and the DLEQ proof (ProofC, ProofS) of RFC 9497
+// like ExpKResponse, so that the client derives its master key before it registers.
+type RegisterExpKResponse struct {
+	BD     *big.Int
+	Q0     *big.Int
+	ProofC *big.Int
+	ProofS *big.Int
+}
+
+// MarshalGroupResponse writes the group parameters published by the service,
+// returned by /v1/group and after a successful registration.
+func MarshalGroupResponse(w io.Writer, r GroupResponse) error {
+	body := struct {
+		Name string `json:"name"`
+		Q    string `json:"q"`
+		PK   string `json:"pk,omitempty"`
+	}{
+		Name: r.Name,
+		Q:    r.Q.Text(16),
+	}
+	if r.PK != nil {
+		body.PK = r.PK.Text(16)
+	}
+
+	return json.NewEncoder(w).Encode(body)
+}
+
+// UnmarshalGroupResponse ...
+func UnmarshalGroupResponse(r io.Reader) (GroupResponse, error) {
+	var body struct {
+		Name string `json:"name"`
+		Q    string `json:"q"`
+		PK   string `json:"pk"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return GroupResponse{}, err
+	}
+
+	q := new(big.Int)
+	_, ok := q.SetString(body.Q, 16)
+	if !ok {
+		return GroupResponse{}, ErrUnexpectedType
+	}
+
+	var pk *big.Int
+	if body.PK != "" {
+		pk, ok = new(big.Int).SetString(body.PK, 16)
+		if !ok {
+			return GroupResponse{}, ErrUnexpectedType
+		}
+	}
+
+	return GroupResponse{
+		Name: body.Name,
+		Q:    q,
+		PK:   pk,
+	}, nil
+}
+
+// GroupResponse contains the name and prime order q of the group used by the service
+// and the public key pk = k*G of the service, which verifies the proof in ExpKResponse.
+type GroupResponse struct {
+	Name string
+	Q    *big.Int
+	PK   *big.Int
 }
 
 // MarshalExpKRequest ...
 func MarshalExpKRequest(r ExpKRequest) (io.Reader, error) {
 	body := struct {
-		CID    string
-		CNonce string
-		B      string
-		Q      string
+		CID    string `json:"cID"`
+		CNonce string `json:"cNonce"`
+		B      string `json:"b"`
+		Q      string `json:"q"`
+		X      string `json:"x"`
 	}{
 		CID:    r.CID.Text(16),
 		CNonce: r.CNonce.Text(16),
 		B:      r.B.Text(16),
 		Q:      r.Q.Text(16),
+		X:      r.X.Text(16),
 	}
 
 	buf, err := json.Marshal(body)
@@ -81,38 +349,58 @@ func UnmarshalExpKRequest(r io.Reader) (ExpKRequest, error) {
 		CNonce string `json:"cNonce"`
 		B      string `json:"b"`
 		Q      string `json:"q"`
+		X      string `json:"x"`
 	}
 
-	if err := json.NewDecoder(r).Decode(&body); err != nil {
-		return ExpKRequest{}, err
+	if err := decodeRequest(r, &body); err != nil {
+		return ExpKRequest{}, errors.Wrap(err, "UnmarshalExpKRequest")
 	}
 
-	cID := new(big.Int)
-	cID.SetString(body.CID, 16)
+	cID, err := parseInt("cID", body.CID)
+	if err != nil {
+		return ExpKRequest{}, errors.Wrap(err, "UnmarshalExpKRequest")
+	}
 
-	cNonce := new(big.Int)
-	cNonce.SetString(body.CNonce, 16)
+	cNonce, err := parseInt("cNonce", body.CNonce)
+	if err != nil {
+		return ExpKRequest{}, errors.Wrap(err, "UnmarshalExpKRequest")
+	}
 
-	b := new(big.Int)
-	b.SetString(body.B, 16)
+	b, err := parseInt("b", body.B)
+	if err != nil {
+		return ExpKRequest{}, errors.Wrap(err, "UnmarshalExpKRequest")
+	}
 
-	q := new(big.Int)
-	q.SetString(body.Q, 16)
+	q, err := parseInt("q", body.Q)
+	if err != nil {
+		return ExpKRequest{}, errors.Wrap(err, "UnmarshalExpKRequest")
+	}
+
+	x, err := parseInt("x", body.X)
+	if err != nil {
+		return ExpKRequest{}, errors.Wrap(err, "UnmarshalExpKRequest")
+	}
 
-	return ExpKRequest{
+	req := ExpKRequest{
 		CID:    cID,
 		CNonce: cNonce,
 		B:      b,
 		Q:      q,
-	}, nil
+		X:      x,
+	}
+	if err := req.Validate(); err != nil {
+		return ExpKRequest{}, errors.Wrap(err, "UnmarshalExpKRequest")
+	}
+	return req, nil
 }
 
-// ExpKRequest ...
+// ExpKRequest starts the login with the blinded password b and the ephemeral key X = G**x of the client.
 type ExpKRequest struct {
 	CID    *big.Int
 	CNonce *big.Int
 	B      *big.Int
 	Q      *big.Int
+	X      *big.Int
 }
 
 // MarshalExpKResponse ...
@@ -123,13 +411,19 @@ func MarshalExpKResponse(w io.Writer, r ExpKResponse) error {
 		SNonce string `json:"sNonce"`
 		BD     string `json:"bd"`
 		Q0     string `json:"q0"`
-		KV     string `json:"kv"`
+		Y      string `json:"y"`
+		ProofC string `json:"proofC,omitempty"`
+		ProofS string `json:"proofS,omitempty"`
 	}{
-		r.SID.Text(16),
-		r.SNonce.Text(16),
-		r.BD.Text(16),
-		r.Q0.Text(16),
-		r.KV.Text(16),
+		SID:    r.SID.Text(16),
+		SNonce: r.SNonce.Text(16),
+		BD:     r.BD.Text(16),
+		Q0:     r.Q0.Text(16),
+		Y:      r.Y.Text(16),
+	}
+	if r.ProofC != nil && r.ProofS != nil {
+		body.ProofC = r.ProofC.Text(16)
+		body.ProofS = r.ProofS.Text(16)
 	}
 
 	return json.NewEncoder(w).Encode(body)
@@ -142,54 +436,74 @@ func UnmarshalExpKResponse(r io.Reader) (ExpKResponse, error) {
 		SNonce string `json:"sNonce"`
 		BD     string `json:"bd"`
 		Q0     string `json:"q0"`
-		KV     string `json:"kv"`
+		Y      string `json:"y"`
+		ProofC string `json:"proofC"`
+		ProofS string `json:"proofS"`
 	}
 
 	if err := json.NewDecoder(r).Decode(&body); err != nil {
 		return ExpKResponse{}, err
 	}
 
-	sID := new(big.Int)
-	sID.SetString(body.SID, 16)
-
-	sNonce := new(big.Int)
-	sNonce.SetString(body.SNonce, 16)
-
-	bd := new(big.Int)
-	bd.SetString(body.BD, 16)
-
-	q0 := new(big.Int)
-	q0.SetString(body.Q0, 16)
-
-	kv := new(big.Int)
-	kv.SetString(body.KV, 16)
+	var ints [5]*big.Int
+	for i, s := range []string{body.SID, body.SNonce, body.BD, body.Q0, body.Y} {
+		x, ok := new(big.Int).SetString(s, 16)
+		if !ok {
+			return ExpKResponse{}, ErrUnexpectedType
+		}
+		ints[i] = x
+	}
+	sID, sNonce, bd, q0, y := ints[0], ints[1], ints[2], ints[3], ints[4]
+
+	var proofC, proofS *big.Int
+	if body.ProofC != "" || body.ProofS != "" {
+		var okC, okS bool
+		proofC, okC = new(big.Int).SetString(body.ProofC, 16)
+		proofS, okS = new(big.Int).SetString(body.ProofS, 16)
+		if !okC || !okS {
+			return ExpKResponse{}, ErrUnexpectedType
+		}
+	}
 
 	return ExpKResponse{
 		SID:    sID,
 		SNonce: sNonce,
 		BD:     bd,
 		Q0:     q0,
-		KV:     kv,
+		Y:      y,
+		ProofC: proofC,
+		ProofS: proofS,
 	}, nil
 }
 
-// ExpKResponse ...
+// ExpKResponse contains bd = b**k and the DLEQ proof (ProofC, ProofS) of RFC 9497,
+// which shows that bd has been computed with the key of the published public key,
+// and the ephemeral key Y = G**y of the service. It contains no long-term secret,
+// the session key follows from crypto.SessionKey.
 type ExpKResponse struct {
 	SID    *big.Int
 	SNonce *big.Int
 	BD     *big.Int
 	Q0     *big.Int
-	KV     *big.Int
+	Y      *big.Int
+	ProofC *big.Int
+	ProofS *big.Int
 }
 
 // MarshalChallengeRequest ...
 func MarshalChallengeRequest(r ChallengeRequest) (io.Reader, error) {
 	body := struct {
-		G string
-		Q string
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
+		G         string `json:"g"`
+		Q         string `json:"q"`
 	}{
-		G: r.G.Text(16),
-		Q: r.Q.Text(16),
+		MAC:       hex.EncodeToString(r.MAC),
+		Counter:   r.Counter,
+		Timestamp: UnixMilli(r.Timestamp),
+		G:         r.G.Text(16),
+		Q:         r.Q.Text(16),
 	}
 	buf, err := json.Marshal(body)
 	if err != nil {
@@ -201,30 +515,53 @@ func MarshalChallengeRequest(r ChallengeRequest) (io.Reader, error) {
 // UnmarshalChallengeRequest ...
 func UnmarshalChallengeRequest(r io.Reader) (ChallengeRequest, error) {
 	var body struct {
-		G string `json:"g"`
-		Q string `json:"q"`
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
+		G         string `json:"g"`
+		Q         string `json:"q"`
 	}
 
-	if err := json.NewDecoder(r).Decode(&body); err != nil {
-		return ChallengeRequest{}, err
+	if err := decodeRequest(r, &body); err != nil {
+		return ChallengeRequest{}, errors.Wrap(err, "UnmarshalChallengeRequest")
+	}
+
+	mac, err := parseMAC(body.MAC)
+	if err != nil {
+		return ChallengeRequest{}, errors.Wrap(err, "UnmarshalChallengeRequest")
 	}
 
-	g := new(big.Int)
-	g.SetString(body.G, 16)
+	g, err := parseInt("g", body.G)
+	if err != nil {
+		return ChallengeRequest{}, errors.Wrap(err, "UnmarshalChallengeRequest")
+	}
 
-	q := new(big.Int)
-	q.SetString(body.Q, 16)
+	q, err := parseInt("q", body.Q)
+	if err != nil {
+		return ChallengeRequest{}, errors.Wrap(err, "UnmarshalChallengeRequest")
+	}
 
-	return ChallengeRequest{
-		G: g,
-		Q: q,
-	}, nil
+	req := ChallengeRequest{
+		MAC:       mac,
+		Counter:   body.Counter,
+		Timestamp: FromUnixMilli(body.Timestamp),
+		G:         g,
+		Q:         q,
+	}
+	if err := req.Validate(); err != nil {
+		return ChallengeRequest{}, errors.Wrap(err, "UnmarshalChallengeRequest")
+	}
+	return req, nil
 }
 
-// ChallengeRequest ...
+// ChallengeRequest completes the login, its MAC proves that the client derived the session key SKi.
+// The service answers every session with exactly one challenge.
 type ChallengeRequest struct {
-	G *big.Int
-	Q *big.Int
+	MAC       []byte
+	Counter   uint64
+	Timestamp time.Time
+	G         *big.Int
+	Q         *big.Int
 }
 
 // MarshalChallengeResponse ...
@@ -249,8 +586,10 @@ func UnmarshalChallengeResponse(r io.Reader) (ChallengeResponse, error) {
 		return ChallengeResponse{}, err
 	}
 
-	rv := new(big.Int)
-	rv.SetString(body.R, 16)
+	rv, ok := new(big.Int).SetString(body.R, 16)
+	if !ok {
+		return ChallengeResponse{}, ErrUnexpectedType
+	}
 
 	return ChallengeResponse{
 		R: rv,
@@ -266,9 +605,13 @@ type ChallengeResponse struct {
 func MarshalMetadataRequest(r MetadataRequest) (io.Reader, error) {
 
 	body := struct {
-		MAC string `json:"mac"`
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
 	}{
 		hex.EncodeToString(r.MAC),
+		r.Counter,
+		UnixMilli(r.Timestamp),
 	}
 
 	buf, err := json.Marshal(body)
@@ -281,26 +624,38 @@ func MarshalMetadataRequest(r MetadataRequest) (io.Reader, error) {
 // UnmarshalMetadataRequest ...
 func UnmarshalMetadataRequest(r io.Reader) (MetadataRequest, error) {
 	var body struct {
-		MAC string `json:"mac"`
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
 	}
 
-	if err := json.NewDecoder(r).Decode(&body); err != nil {
-		return MetadataRequest{}, err
+	if err := decodeRequest(r, &body); err != nil {
+		return MetadataRequest{}, errors.Wrap(err, "UnmarshalMetadataRequest")
 	}
 
-	mac, err := hex.DecodeString(body.MAC)
+	mac, err := parseMAC(body.MAC)
 	if err != nil {
-		return MetadataRequest{}, err
+		return MetadataRequest{}, errors.Wrap(err, "UnmarshalMetadataRequest")
 	}
 
-	return MetadataRequest{
-		MAC: mac,
-	}, nil
+	req := MetadataRequest{
+		MAC:       mac,
+		Counter:   body.Counter,
+		Timestamp: FromUnixMilli(body.Timestamp),
+	}
+	if err := req.Validate(); err != nil {
+		return MetadataRequest{}, errors.Wrap(err, "UnmarshalMetadataRequest")
+	}
+	return req, nil
 }
 
-// MetadataRequest ...
+// MetadataRequest lists the domains of the user.
+// Like all authenticated requests it carries the MAC of its Canonical encoding,
+// a counter increasing with every request of the session and the time it was sent.
 type MetadataRequest struct {
-	MAC []byte
+	MAC       []byte
+	Counter   uint64
+	Timestamp time.Time
 }
 
 // MarshalMetadataResponse ...
@@ -337,11 +692,17 @@ type MetadataResponse struct {
 // MarshalAddRequest ...
 func MarshalAddRequest(r AddRequest) (io.Reader, error) {
 	body := struct {
-		MAC    string `json:"mac"`
-		Domain string `json:"domain"`
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
+		Domain    string `json:"domain"`
+		Metadata  string `json:"metadata,omitempty"`
 	}{
 		hex.EncodeToString(r.MAC),
+		r.Counter,
+		UnixMilli(r.Timestamp),
 		r.Domain,
+		r.Metadata,
 	}
 
 	buf, err := json.Marshal(body)
@@ -354,43 +715,62 @@ func MarshalAddRequest(r AddRequest) (io.Reader, error) {
 // UnmarshalAddRequest ...
 func UnmarshalAddRequest(r io.Reader) (AddRequest, error) {
 	var body struct {
-		MAC    string `json:"mac"`
-		Domain string `json:"domain"`
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
+		Domain    string `json:"domain"`
+		Metadata  string `json:"metadata"`
 	}
 
-	if err := json.NewDecoder(r).Decode(&body); err != nil {
-		return AddRequest{}, err
+	if err := decodeRequest(r, &body); err != nil {
+		return AddRequest{}, errors.Wrap(err, "UnmarshalAddRequest")
 	}
 
-	mac, err := hex.DecodeString(body.MAC)
+	mac, err := parseMAC(body.MAC)
 	if err != nil {
-		return AddRequest{}, err
+		return AddRequest{}, errors.Wrap(err, "UnmarshalAddRequest")
 	}
 
-	return AddRequest{
-		MAC:    mac,
-		Domain: body.Domain,
-	}, nil
+	req := AddRequest{
+		MAC:       mac,
+		Counter:   body.Counter,
+		Timestamp: FromUnixMilli(body.Timestamp),
+		Domain:    body.Domain,
+		Metadata:  body.Metadata,
+	}
+	if err := req.Validate(); err != nil {
+		return AddRequest{}, errors.Wrap(err, "UnmarshalAddRequest")
+	}
+	return req, nil
 }
 
-// AddRequest ...
+// AddRequest adds a domain together with its metadata, which is opaque to the service.
 type AddRequest struct {
-	MAC    []byte
-	Domain string
+	MAC       []byte
+	Counter   uint64
+	Timestamp time.Time
+	Domain    string
+	Metadata  string
 }
 
 // MarshalGetRequest ...
 func MarshalGetRequest(r GetRequest) (io.Reader, error) {
 	body := struct {
-		MAC    string `json:"mac"`
-		Domain string `json:"domain"`
-		BMK    string `json:"bmk"`
-		Q      string `json:"q"`
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
+		Domain    string `json:"domain"`
+		BMK       string `json:"bmk"`
+		Q         string `json:"q"`
+		Previous  bool   `json:"previous,omitempty"`
 	}{
 		hex.EncodeToString(r.MAC),
+		r.Counter,
+		UnixMilli(r.Timestamp),
 		r.Domain,
 		r.BMK.Text(16),
 		r.Q.Text(16),
+		r.Previous,
 	}
 
 	buf, err := json.Marshal(body)
@@ -403,58 +783,417 @@ func MarshalGetRequest(r GetRequest) (io.Reader, error) {
 // UnmarshalGetRequest ...
 func UnmarshalGetRequest(r io.Reader) (GetRequest, error) {
 	var body struct {
-		MAC    string `json:"mac"`
-		Domain string `json:"domain"`
-		BMK    string `json:"bmk"`
-		Q      string `json:"q"`
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
+		Domain    string `json:"domain"`
+		BMK       string `json:"bmk"`
+		Q         string `json:"q"`
+		Previous  bool   `json:"previous"`
 	}
 
-	if err := json.NewDecoder(r).Decode(&body); err != nil {
-		return GetRequest{}, err
+	if err := decodeRequest(r, &body); err != nil {
+		return GetRequest{}, errors.Wrap(err, "UnmarshalGetRequest")
 	}
 
-	bmk := new(big.Int)
-	_, ok := bmk.SetString(body.BMK, 16)
-	if !ok {
-		return GetRequest{}, ErrUnexpectedType
+	bmk, err := parseInt("bmk", body.BMK)
+	if err != nil {
+		return GetRequest{}, errors.Wrap(err, "UnmarshalGetRequest")
 	}
 
-	q := new(big.Int)
-	_, ok = q.SetString(body.Q, 16)
-	if !ok {
-		return GetRequest{}, ErrUnexpectedType
+	q, err := parseInt("q", body.Q)
+	if err != nil {
+		return GetRequest{}, errors.Wrap(err, "UnmarshalGetRequest")
 	}
 
-	mac, err := hex.DecodeString(body.MAC)
+	mac, err := parseMAC(body.MAC)
 	if err != nil {
-		return GetRequest{}, err
+		return GetRequest{}, errors.Wrap(err, "UnmarshalGetRequest")
 	}
 
-	return GetRequest{
-		MAC:    mac,
-		Domain: body.Domain,
-		BMK:    bmk,
-		Q:      q,
-	}, nil
+	req := GetRequest{
+		MAC:       mac,
+		Counter:   body.Counter,
+		Timestamp: FromUnixMilli(body.Timestamp),
+		Domain:    body.Domain,
+		BMK:       bmk,
+		Q:         q,
+		Previous:  body.Previous,
+	}
+	if err := req.Validate(); err != nil {
+		return GetRequest{}, errors.Wrap(err, "UnmarshalGetRequest")
+	}
+	return req, nil
 }
 
-// GetRequest ...
+// GetRequest evaluates the vault of a domain, or its version before the last rotation if Previous is set.
 type GetRequest struct {
-	MAC    []byte
-	Domain string
-	BMK    *big.Int
-	Q      *big.Int
+	MAC       []byte
+	Counter   uint64
+	Timestamp time.Time
+	Domain    string
+	BMK       *big.Int
+	Q         *big.Int
+	Previous  bool
+}
+
+// MarshalRotateRequest ...
+func MarshalRotateRequest(r RotateRequest) (io.Reader, error) {
+	body := struct {
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
+		Domain    string `json:"domain"`
+	}{
+		hex.EncodeToString(r.MAC),
+		r.Counter,
+		UnixMilli(r.Timestamp),
+		r.Domain,
+	}
+
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(buf), nil
+}
+
+// UnmarshalRotateRequest ...
+func UnmarshalRotateRequest(r io.Reader) (RotateRequest, error) {
+	var body struct {
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
+		Domain    string `json:"domain"`
+	}
+
+	if err := decodeRequest(r, &body); err != nil {
+		return RotateRequest{}, errors.Wrap(err, "UnmarshalRotateRequest")
+	}
+
+	mac, err := parseMAC(body.MAC)
+	if err != nil {
+		return RotateRequest{}, errors.Wrap(err, "UnmarshalRotateRequest")
+	}
+
+	req := RotateRequest{
+		MAC:       mac,
+		Counter:   body.Counter,
+		Timestamp: FromUnixMilli(body.Timestamp),
+		Domain:    body.Domain,
+	}
+	if err := req.Validate(); err != nil {
+		return RotateRequest{}, errors.Wrap(err, "UnmarshalRotateRequest")
+	}
+	return req, nil
+}
+
+// RotateRequest replaces the key material of a domain.
+type RotateRequest struct {
+	MAC       []byte
+	Counter   uint64
+	Timestamp time.Time
+	Domain    string
+}
+
+// MarshalRotateResponse ...
+func MarshalRotateResponse(w io.Writer, r RotateResponse) error {
+	body := struct {
+		Version       int    `json:"version"`
+		PreviousUntil string `json:"previousUntil"`
+	}{
+		r.Version,
+		r.PreviousUntil.UTC().Format(time.RFC3339Nano),
+	}
+
+	return json.NewEncoder(w).Encode(body)
+}
+
+// UnmarshalRotateResponse ...
+func UnmarshalRotateResponse(r io.Reader) (RotateResponse, error) {
+	var body struct {
+		Version       int    `json:"version"`
+		PreviousUntil string `json:"previousUntil"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return RotateResponse{}, err
+	}
+
+	previousUntil, err := time.Parse(time.RFC3339Nano, body.PreviousUntil)
+	if err != nil {
+		return RotateResponse{}, ErrUnexpectedType
+	}
+
+	return RotateResponse{
+		Version:       body.Version,
+		PreviousUntil: previousUntil,
+	}, nil
+}
+
+// RotateResponse contains the new version of the domain and until when the previous version is retrievable.
+type RotateResponse struct {
+	Version       int
+	PreviousUntil time.Time
+}
+
+// MarshalDeleteRequest ...
+func MarshalDeleteRequest(r DeleteRequest) (io.Reader, error) {
+	body := struct {
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
+		Domain    string `json:"domain"`
+	}{
+		hex.EncodeToString(r.MAC),
+		r.Counter,
+		UnixMilli(r.Timestamp),
+		r.Domain,
+	}
+
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(buf), nil
+}
+
+// UnmarshalDeleteRequest ...
+func UnmarshalDeleteRequest(r io.Reader) (DeleteRequest, error) {
+	var body struct {
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
+		Domain    string `json:"domain"`
+	}
+
+	if err := decodeRequest(r, &body); err != nil {
+		return DeleteRequest{}, errors.Wrap(err, "UnmarshalDeleteRequest")
+	}
+
+	mac, err := parseMAC(body.MAC)
+	if err != nil {
+		return DeleteRequest{}, errors.Wrap(err, "UnmarshalDeleteRequest")
+	}
+
+	req := DeleteRequest{
+		MAC:       mac,
+		Counter:   body.Counter,
+		Timestamp: FromUnixMilli(body.Timestamp),
+		Domain:    body.Domain,
+	}
+	if err := req.Validate(); err != nil {
+		return DeleteRequest{}, errors.Wrap(err, "UnmarshalDeleteRequest")
+	}
+	return req, nil
+}
+
+// DeleteRequest removes a domain and its key material, including the previous version of a rotation.
+type DeleteRequest struct {
+	MAC       []byte
+	Counter   uint64
+	Timestamp time.Time
+	Domain    string
+}
+
+// MarshalSessionsRequest ...
+func MarshalSessionsRequest(r SessionsRequest) (io.Reader, error) {
+	body := struct {
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
+	}{
+		hex.EncodeToString(r.MAC),
+		r.Counter,
+		UnixMilli(r.Timestamp),
+	}
+
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(buf), nil
+}
+
+// UnmarshalSessionsRequest ...
+func UnmarshalSessionsRequest(r io.Reader) (SessionsRequest, error) {
+	var body struct {
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
+	}
+
+	if err := decodeRequest(r, &body); err != nil {
+		return SessionsRequest{}, errors.Wrap(err, "UnmarshalSessionsRequest")
+	}
+
+	mac, err := parseMAC(body.MAC)
+	if err != nil {
+		return SessionsRequest{}, errors.Wrap(err, "UnmarshalSessionsRequest")
+	}
+
+	req := SessionsRequest{
+		MAC:       mac,
+		Counter:   body.Counter,
+		Timestamp: FromUnixMilli(body.Timestamp),
+	}
+	if err := req.Validate(); err != nil {
+		return SessionsRequest{}, errors.Wrap(err, "UnmarshalSessionsRequest")
+	}
+	return req, nil
+}
+
+// SessionsRequest lists the active sessions of the user.
+type SessionsRequest struct {
+	MAC       []byte
+	Counter   uint64
+	Timestamp time.Time
+}
+
+// MarshalSessionsResponse ...
+func MarshalSessionsResponse(w io.Writer, r SessionsResponse) error {
+	type session struct {
+		ID       string `json:"id"`
+		Created  string `json:"created"`
+		LastSeen string `json:"lastSeen"`
+		Current  bool   `json:"current,omitempty"`
+	}
+	body := struct {
+		Sessions []session `json:"sessions"`
+	}{
+		[]session{},
+	}
+	for _, s := range r.Sessions {
+		body.Sessions = append(body.Sessions, session{
+			ID:       s.ID,
+			Created:  s.Created.UTC().Format(time.RFC3339Nano),
+			LastSeen: s.LastSeen.UTC().Format(time.RFC3339Nano),
+			Current:  s.Current,
+		})
+	}
+
+	return json.NewEncoder(w).Encode(body)
+}
+
+// UnmarshalSessionsResponse ...
+func UnmarshalSessionsResponse(r io.Reader) (SessionsResponse, error) {
+	var body struct {
+		Sessions []struct {
+			ID       string `json:"id"`
+			Created  string `json:"created"`
+			LastSeen string `json:"lastSeen"`
+			Current  bool   `json:"current"`
+		} `json:"sessions"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&body); err != nil {
+		return SessionsResponse{}, err
+	}
+
+	var resp SessionsResponse
+	for _, s := range body.Sessions {
+		created, err := time.Parse(time.RFC3339Nano, s.Created)
+		if err != nil {
+			return SessionsResponse{}, ErrUnexpectedType
+		}
+		lastSeen, err := time.Parse(time.RFC3339Nano, s.LastSeen)
+		if err != nil {
+			return SessionsResponse{}, ErrUnexpectedType
+		}
+		resp.Sessions = append(resp.Sessions, SessionInfo{ID: s.ID, Created: created, LastSeen: lastSeen, Current: s.Current})
+	}
+	return resp, nil
+}
+
+// SessionsResponse contains the active sessions of the user ordered by creation.
+type SessionsResponse struct {
+	Sessions []SessionInfo
+}
+
+// SessionInfo describes a session without its keys, Current marks the session of the request.
+type SessionInfo struct {
+	ID       string
+	Created  time.Time
+	LastSeen time.Time
+	Current  bool
+}
+
+// MarshalRevokeSessionsRequest ...
+func MarshalRevokeSessionsRequest(r RevokeSessionsRequest) (io.Reader, error) {
+	body := struct {
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
+		ID        string `json:"id,omitempty"`
+		All       bool   `json:"all,omitempty"`
+	}{
+		hex.EncodeToString(r.MAC),
+		r.Counter,
+		UnixMilli(r.Timestamp),
+		r.ID,
+		r.All,
+	}
+
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(buf), nil
+}
+
+// UnmarshalRevokeSessionsRequest ...
+func UnmarshalRevokeSessionsRequest(r io.Reader) (RevokeSessionsRequest, error) {
+	var body struct {
+		MAC       string `json:"mac"`
+		Counter   uint64 `json:"counter"`
+		Timestamp int64  `json:"timestamp"`
+		ID        string `json:"id"`
+		All       bool   `json:"all"`
+	}
+
+	if err := decodeRequest(r, &body); err != nil {
+		return RevokeSessionsRequest{}, errors.Wrap(err, "UnmarshalRevokeSessionsRequest")
+	}
+
+	mac, err := parseMAC(body.MAC)
+	if err != nil {
+		return RevokeSessionsRequest{}, errors.Wrap(err, "UnmarshalRevokeSessionsRequest")
+	}
+
+	req := RevokeSessionsRequest{
+		MAC:       mac,
+		Counter:   body.Counter,
+		Timestamp: FromUnixMilli(body.Timestamp),
+		ID:        body.ID,
+		All:       body.All,
+	}
+	if err := req.Validate(); err != nil {
+		return RevokeSessionsRequest{}, errors.Wrap(err, "UnmarshalRevokeSessionsRequest")
+	}
+	return req, nil
+}
+
+// RevokeSessionsRequest ends the session with ID or, if All is set, all sessions of the user
+// including the session of the request.
+type RevokeSessionsRequest struct {
+	MAC       []byte
+	Counter   uint64
+	Timestamp time.Time
+	ID        string
+	All       bool
 }
 
 // MarshalGetResponse ...
 func MarshalGetResponse(w io.Writer, r GetResponse) error {
 
 	body := struct {
-		Bj string `json:"bj"`
-		Qj string `json:"qj"`
+		Bj       string `json:"bj"`
+		Qj       string `json:"qj"`
+		Metadata string `json:"metadata,omitempty"`
 	}{
 		r.Bj.Text(16),
 		r.Qj.Text(16),
+		r.Metadata,
 	}
 
 	return json.NewEncoder(w).Encode(body)
@@ -463,8 +1202,9 @@ func MarshalGetResponse(w io.Writer, r GetResponse) error {
 // UnmarshalGetResponse ...
 func UnmarshalGetResponse(r io.Reader) (GetResponse, error) {
 	var body struct {
-		Bj string `json:"bj"`
-		Qj string `json:"qj"`
+		Bj       string `json:"bj"`
+		Qj       string `json:"qj"`
+		Metadata string `json:"metadata,omitempty"`
 	}
 
 	if err := json.NewDecoder(r).Decode(&body); err != nil {
@@ -484,47 +1224,70 @@ func UnmarshalGetResponse(r io.Reader) (GetResponse, error) {
 	}
 
 	return GetResponse{
-		Bj: bj,
-		Qj: qj,
+		Bj:       bj,
+		Qj:       qj,
+		Metadata: body.Metadata,
 	}, nil
 }
 
-// GetResponse ...
+// GetResponse contains the evaluation of the vault and the metadata stored with the domain.
 type GetResponse struct {
-	Bj *big.Int
-	Qj *big.Int
+	Bj       *big.Int
+	Qj       *big.Int
+	Metadata string
 }
 
-// MarshalError ...
+// MarshalError writes err with the HTTP status code of its Code.
+// Errors which are not caused by an *Error are written as CodeInternal without revealing their message.
 func MarshalError(w http.ResponseWriter, err error) error {
+	e, ok := errors.Cause(err).(*Error)
+	if !ok {
+		e = NewError(CodeInternal, "internal error")
+	}
+
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	switch err {
-	default:
-		w.WriteHeader(http.StatusInternalServerError)
+	if e.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
 	}
+	w.WriteHeader(e.StatusCode())
 
 	body := struct {
-		Err string `json:"error"`
+		Err        string `json:"error"`
+		Code       Code   `json:"code"`
+		RetryAfter int64  `json:"retryAfterMs,omitempty"`
 	}{
-		Err: err.Error(),
+		Err:        e.Message,
+		Code:       e.Code,
+		RetryAfter: int64(e.RetryAfter / time.Millisecond),
 	}
 
 	return json.NewEncoder(w).Encode(body)
 }
 
-// UnmarshalIfError ...
+// UnmarshalIfError returns an *Error if the response has no 2xx status code.
+// Responses without code, e.g. of proxies, get a code according to their status code.
 func UnmarshalIfError(r *http.Response) error {
 
 	if r.StatusCode >= 300 {
 		var body struct {
-			Err string `json:"error"`
+			Err        string `json:"error"`
+			Code       Code   `json:"code"`
+			RetryAfter int64  `json:"retryAfterMs"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			return err
+			body.Err = http.StatusText(r.StatusCode)
 		}
 		defer r.Body.Close()
 
-		return errors.New(body.Err)
+		e := NewError(body.Code, body.Err)
+		if e.Code == "" {
+			e.Code = codeOf(r.StatusCode)
+		}
+		e.RetryAfter = time.Duration(body.RetryAfter) * time.Millisecond
+		if s, err := strconv.Atoi(r.Header.Get("Retry-After")); err == nil && e.RetryAfter == 0 {
+			e.RetryAfter = time.Duration(s) * time.Second
+		}
+		return e
 	}
 
 	return nil
@@ -4,14 +4,21 @@ import (
 	"bufio"
 	"bytes"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 func TestUnmarshalRegisterRequest(t *testing.T) {
 	t.Run("should un/marshal", func(t *testing.T) {
 		want := RegisterRequest{
-			CID: big.NewInt(1),
+			CID:      big.NewInt(1),
+			Verifier: big.NewInt(2),
 		}
 
 		r, err := MarshalRegisterRequest(want)
@@ -31,6 +38,111 @@ func TestUnmarshalRegisterRequest(t *testing.T) {
 	})
 }
 
+func TestUnmarshalEnrollRequest(t *testing.T) {
+	t.Run("should un/marshal", func(t *testing.T) {
+		want := EnrollRequest{
+			CID:      big.NewInt(1),
+			Code:     "c0de",
+			Verifier: big.NewInt(2),
+			E:        big.NewInt(3),
+			ProofC:   big.NewInt(4),
+			ProofS:   big.NewInt(5),
+		}
+
+		r, err := MarshalEnrollRequest(want)
+		if err != nil {
+			t.Errorf("MarshalEnrollRequest() error = %v", err)
+			return
+		}
+
+		got, err := UnmarshalEnrollRequest(r)
+		if err != nil {
+			t.Errorf("UnmarshalEnrollRequest() error = %v", err)
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("EnrollRequest = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestUnmarshalRegisterExpKRequest(t *testing.T) {
+	t.Run("should un/marshal", func(t *testing.T) {
+		want := RegisterExpKRequest{
+			B: big.NewInt(2),
+			Q: big.NewInt(3),
+		}
+
+		r, err := MarshalRegisterExpKRequest(want)
+		if err != nil {
+			t.Errorf("MarshalRegisterExpKRequest() error = %v", err)
+			return
+		}
+
+		got, err := UnmarshalRegisterExpKRequest(r)
+		if err != nil {
+			t.Errorf("UnmarshalRegisterExpKRequest() error = %v", err)
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("RegisterExpKRequest = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestUnmarshalRegisterExpKResponse(t *testing.T) {
+	t.Run("should un/marshal", func(t *testing.T) {
+		want := RegisterExpKResponse{
+			BD:     big.NewInt(3),
+			Q0:     big.NewInt(4),
+			ProofC: big.NewInt(6),
+			ProofS: big.NewInt(7),
+		}
+		var buf bytes.Buffer
+		err := MarshalRegisterExpKResponse(&buf, want)
+		if err != nil {
+			t.Errorf("MarshalRegisterExpKResponse() error = %v", err)
+			return
+		}
+
+		got, err := UnmarshalRegisterExpKResponse(&buf)
+		if err != nil {
+			t.Errorf("UnmarshalRegisterExpKResponse() error = %v", err)
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("RegisterExpKResponse = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestUnmarshalGroupResponse(t *testing.T) {
+	t.Run("should un/marshal", func(t *testing.T) {
+		want := GroupResponse{
+			Name: "ffdhe2048",
+			Q:    big.NewInt(11),
+			PK:   big.NewInt(4),
+		}
+		var buf bytes.Buffer
+		w := bufio.NewWriter(&buf)
+		err := MarshalGroupResponse(w, want)
+		if err != nil {
+			t.Errorf("MarshalGroupResponse() error = %v", err)
+			return
+		}
+		w.Flush()
+
+		got, err := UnmarshalGroupResponse(bufio.NewReader(&buf))
+		if err != nil {
+			t.Errorf("UnmarshalGroupResponse() error = %v", err)
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GroupResponse = %v, want %v", got, want)
+		}
+	})
+}
+
 func TestUnmarshalExpKRequest(t *testing.T) {
 	t.Run("marshal and unmarshal", func(t *testing.T) {
 		want := ExpKRequest{
@@ -38,6 +150,7 @@ func TestUnmarshalExpKRequest(t *testing.T) {
 			CNonce: big.NewInt(2),
 			B:      big.NewInt(3),
 			Q:      big.NewInt(4),
+			X:      big.NewInt(5),
 		}
 
 		r, err := MarshalExpKRequest(want)
@@ -64,7 +177,7 @@ func TestUnmarshalExpKResponse(t *testing.T) {
 			SNonce: big.NewInt(2),
 			BD:     big.NewInt(3),
 			Q0:     big.NewInt(4),
-			KV:     big.NewInt(5),
+			Y:      big.NewInt(5),
 		}
 		var buf bytes.Buffer
 		w := bufio.NewWriter(&buf)
@@ -88,11 +201,50 @@ func TestUnmarshalExpKResponse(t *testing.T) {
 	})
 }
 
+func TestUnmarshalExpKResponse_Proof(t *testing.T) {
+	t.Run("should un/marshal proof", func(t *testing.T) {
+		want := ExpKResponse{
+			SID:    big.NewInt(1),
+			SNonce: big.NewInt(2),
+			BD:     big.NewInt(3),
+			Q0:     big.NewInt(4),
+			Y:      big.NewInt(5),
+			ProofC: big.NewInt(6),
+			ProofS: big.NewInt(7),
+		}
+		var buf bytes.Buffer
+		err := MarshalExpKResponse(&buf, want)
+		if err != nil {
+			t.Errorf("MarshalExpKResponse() error = %v", err)
+			return
+		}
+
+		got, err := UnmarshalExpKResponse(&buf)
+		if err != nil {
+			t.Errorf("UnmarshalExpKResponse() error = %v", err)
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ExpKResponse = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("should return ErrUnexpectedType for an incomplete proof", func(t *testing.T) {
+		_, err := UnmarshalExpKResponse(strings.NewReader(`{"sID":"1","sNonce":"2","bd":"3","q0":"4","y":"5","proofC":"6"}`))
+		if err != ErrUnexpectedType {
+			t.Errorf("UnmarshalExpKResponse() error = %v, wantErr %v", err, ErrUnexpectedType)
+		}
+	})
+}
+
 func TestUnmarshalChallengeRequest(t *testing.T) {
 	t.Run("should un/marshal", func(t *testing.T) {
 		want := ChallengeRequest{
-			G: big.NewInt(1),
-			Q: big.NewInt(2),
+			MAC:       []byte("mac"),
+			Counter:   1,
+			Timestamp: FromUnixMilli(1571234567890),
+			G:         big.NewInt(2),
+			Q:         big.NewInt(3),
 		}
 
 		r, err := MarshalChallengeRequest(want)
@@ -142,7 +294,9 @@ func TestUnmarshalChallengeResponse(t *testing.T) {
 func TestUnmarshalMetadataRequest(t *testing.T) {
 	t.Run("should un/marshal", func(t *testing.T) {
 		want := MetadataRequest{
-			MAC: []byte("mac"),
+			MAC:       []byte("mac"),
+			Counter:   1,
+			Timestamp: FromUnixMilli(1571234567890),
 		}
 
 		r, err := MarshalMetadataRequest(want)
@@ -217,8 +371,11 @@ func TestUnmarshalMetadataResponse(t *testing.T) {
 func TestUnmarshalAddRequest(t *testing.T) {
 	t.Run("should un/marshal", func(t *testing.T) {
 		want := AddRequest{
-			MAC:    []byte("mac"),
-			Domain: "domain",
+			MAC:       []byte("mac"),
+			Counter:   2,
+			Timestamp: FromUnixMilli(1571234567890),
+			Domain:    "domain",
+			Metadata:  `{"policy":{"length":16}}`,
 		}
 
 		r, err := MarshalAddRequest(want)
@@ -241,10 +398,13 @@ func TestUnmarshalAddRequest(t *testing.T) {
 func TestUnmarshalGetRequest(t *testing.T) {
 	t.Run("should un/marshal", func(t *testing.T) {
 		want := GetRequest{
-			MAC:    []byte("mac"),
-			Domain: "domain",
-			BMK:    big.NewInt(2),
-			Q:      big.NewInt(3),
+			MAC:       []byte("mac"),
+			Counter:   3,
+			Timestamp: FromUnixMilli(1571234567890),
+			Domain:    "domain",
+			BMK:       big.NewInt(2),
+			Q:         big.NewInt(3),
+			Previous:  true,
 		}
 
 		r, err := MarshalGetRequest(want)
@@ -264,12 +424,167 @@ func TestUnmarshalGetRequest(t *testing.T) {
 	})
 }
 
+func TestUnmarshalRotateRequest(t *testing.T) {
+	t.Run("should un/marshal", func(t *testing.T) {
+		want := RotateRequest{
+			MAC:       []byte("mac"),
+			Counter:   4,
+			Timestamp: FromUnixMilli(1571234567890),
+			Domain:    "domain",
+		}
+
+		r, err := MarshalRotateRequest(want)
+		if err != nil {
+			t.Errorf("MarshalRotateRequest() error = %v", err)
+			return
+		}
+
+		got, err := UnmarshalRotateRequest(r)
+		if err != nil {
+			t.Errorf("UnmarshalRotateRequest() error = %v", err)
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("RotateRequest = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestUnmarshalDeleteRequest(t *testing.T) {
+	t.Run("should un/marshal", func(t *testing.T) {
+		want := DeleteRequest{
+			MAC:       []byte("mac"),
+			Counter:   5,
+			Timestamp: FromUnixMilli(1571234567890),
+			Domain:    "domain",
+		}
+
+		r, err := MarshalDeleteRequest(want)
+		if err != nil {
+			t.Errorf("MarshalDeleteRequest() error = %v", err)
+			return
+		}
+
+		got, err := UnmarshalDeleteRequest(r)
+		if err != nil {
+			t.Errorf("UnmarshalDeleteRequest() error = %v", err)
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("DeleteRequest = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestUnmarshalSessionsRequest(t *testing.T) {
+	t.Run("should un/marshal", func(t *testing.T) {
+		want := SessionsRequest{
+			MAC:       []byte("mac"),
+			Counter:   5,
+			Timestamp: FromUnixMilli(1571234567890),
+		}
+
+		r, err := MarshalSessionsRequest(want)
+		if err != nil {
+			t.Errorf("MarshalSessionsRequest() error = %v", err)
+			return
+		}
+
+		got, err := UnmarshalSessionsRequest(r)
+		if err != nil {
+			t.Errorf("UnmarshalSessionsRequest() error = %v", err)
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("SessionsRequest = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestUnmarshalSessionsResponse(t *testing.T) {
+	t.Run("should un/marshal", func(t *testing.T) {
+		created := time.Date(2019, 1, 2, 3, 4, 5, 6, time.UTC)
+		want := SessionsResponse{Sessions: []SessionInfo{
+			{ID: "a", Created: created, LastSeen: created.Add(time.Minute), Current: true},
+			{ID: "b", Created: created, LastSeen: created},
+		}}
+		var buf bytes.Buffer
+		err := MarshalSessionsResponse(&buf, want)
+		if err != nil {
+			t.Errorf("MarshalSessionsResponse() error = %v", err)
+			return
+		}
+
+		got, err := UnmarshalSessionsResponse(&buf)
+		if err != nil {
+			t.Errorf("UnmarshalSessionsResponse() error = %v", err)
+			return
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("SessionsResponse = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestUnmarshalRevokeSessionsRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		want RevokeSessionsRequest
+	}{
+		{"should un/marshal a session", RevokeSessionsRequest{MAC: []byte("mac"), Counter: 5, Timestamp: FromUnixMilli(1571234567890), ID: "a"}},
+		{"should un/marshal all sessions", RevokeSessionsRequest{MAC: []byte("mac"), Counter: 5, Timestamp: FromUnixMilli(1571234567890), All: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := MarshalRevokeSessionsRequest(tt.want)
+			if err != nil {
+				t.Errorf("MarshalRevokeSessionsRequest() error = %v", err)
+				return
+			}
+
+			got, err := UnmarshalRevokeSessionsRequest(r)
+			if err != nil {
+				t.Errorf("UnmarshalRevokeSessionsRequest() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RevokeSessionsRequest = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalRotateResponse(t *testing.T) {
+	t.Run("should un/marshal", func(t *testing.T) {
+		want := RotateResponse{
+			Version:       2,
+			PreviousUntil: time.Date(2019, 1, 2, 3, 4, 5, 6, time.UTC),
+		}
+		var buf bytes.Buffer
+		err := MarshalRotateResponse(&buf, want)
+		if err != nil {
+			t.Errorf("MarshalRotateResponse() error = %v", err)
+			return
+		}
+
+		got, err := UnmarshalRotateResponse(&buf)
+		if err != nil {
+			t.Errorf("UnmarshalRotateResponse() error = %v", err)
+			return
+		}
+		if got.Version != want.Version || !got.PreviousUntil.Equal(want.PreviousUntil) {
+			t.Errorf("RotateResponse = %v, want %v", got, want)
+		}
+	})
+}
+
 func TestUnmarshalGetResponse(t *testing.T) {
 
 	t.Run("should un/marshal", func(t *testing.T) {
 		want := GetResponse{
-			Bj: big.NewInt(2),
-			Qj: big.NewInt(3),
+			Bj:       big.NewInt(2),
+			Qj:       big.NewInt(3),
+			Metadata: `{"policy":{"length":16}}`,
 		}
 		var buf bytes.Buffer
 		w := bufio.NewWriter(&buf)
@@ -292,3 +607,74 @@ func TestUnmarshalGetResponse(t *testing.T) {
 		}
 	})
 }
+
+func TestMarshalError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		want       *Error
+	}{
+		{
+			"should map code to status",
+			errors.Wrap(NewError(CodeDomainNotFound, "domain not found"), "context"),
+			http.StatusNotFound,
+			NewError(CodeDomainNotFound, "domain not found"),
+		},
+		{
+			"should keep retry hint",
+			&Error{Code: CodeUnavailable, Message: "unavailable", RetryAfter: 1500 * time.Millisecond},
+			http.StatusServiceUnavailable,
+			&Error{Code: CodeUnavailable, Message: "unavailable", RetryAfter: 1500 * time.Millisecond},
+		},
+		{
+			"should hide other errors as internal",
+			errors.New("secret details"),
+			http.StatusInternalServerError,
+			NewError(CodeInternal, "internal error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			err := MarshalError(w, tt.err)
+			if err != nil {
+				t.Fatalf("MarshalError() error = %v", err)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("MarshalError() status = %v, want %v", w.Code, tt.wantStatus)
+			}
+
+			got := UnmarshalIfError(w.Result())
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnmarshalIfError() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalIfError(t *testing.T) {
+	t.Run("should return nil for success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		w.WriteHeader(http.StatusOK)
+		if err := UnmarshalIfError(w.Result()); err != nil {
+			t.Errorf("UnmarshalIfError() error = %v", err)
+		}
+	})
+
+	t.Run("should derive code and retry hint of responses without code", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.WriteString("<html>down for maintenance</html>")
+
+		err := UnmarshalIfError(w.Result())
+		e, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("UnmarshalIfError() = %#v, want *Error", err)
+		}
+		if e.Code != CodeUnavailable || e.RetryAfter != 3*time.Second {
+			t.Errorf("UnmarshalIfError() = %#v", e)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package contract
+
+import (
+	"net/http"
+	"time"
+)
+
+// Code identifies an error of the Online SPHINX protocol.
+// Codes are part of the contract, never change or reuse them.
+type Code string
+
+// Error codes returned by the service.
+const (
+	// CodeInternal is an unexpected failure of the service.
+	CodeInternal Code = "internal"
+	// CodeUnavailable is a temporary failure, the request can be retried later.
+	CodeUnavailable Code = "unavailable"
+	// CodeBadRequest is a malformed request.
+	CodeBadRequest Code = "bad_request"
+	// CodeLoginRequired is a request without valid session.
+	CodeLoginRequired Code = "login_required"
+	// CodeMACMismatch is a request whose MAC does not verify with the session key.
+	CodeMACMismatch Code = "mac_mismatch"
+	// CodeUserNotFound is a request of a client ID which is not registered.
+	CodeUserNotFound Code = "user_not_found"
+	// CodeUserAlreadyExists is the registration of a client ID registered before.
+	CodeUserAlreadyExists Code = "user_already_exists"
+	// CodeDomainNotFound is a request for a domain the user never added.
+	CodeDomainNotFound Code = "domain_not_found"
+	// CodeDomainAlreadyExists is the addition of a domain the user added before.
+	CodeDomainAlreadyExists Code = "domain_already_exists"
+	// CodeNoPreviousVersion is a request for the previous version of a domain outside of the grace period of a rotation.
+	CodeNoPreviousVersion Code = "no_previous_version"
+	// CodeGroupMismatch is a request for another group than the one of the service.
+	CodeGroupMismatch Code = "group_mismatch"
+	// CodeInvalidElement is a request with a value which is not an element of the group.
+	CodeInvalidElement Code = "invalid_element"
+	// CodeMetadataTooLong is the addition of a domain with too much metadata.
+	CodeMetadataTooLong Code = "metadata_too_long"
+	// CodeRequestTooLarge is a request body exceeding MaxRequestSize.
+	CodeRequestTooLarge Code = "request_too_large"
+	// CodeReplayedRequest is an authenticated request whose counter was used before or whose timestamp is stale.
+	CodeReplayedRequest Code = "replayed_request"
+	// CodeLoginOutOfOrder is a login step or operation the login of the session has not reached or already passed.
+	CodeLoginOutOfOrder Code = "login_out_of_order"
+	// CodeLoginTimeout is a challenge after the login window of the session has closed.
+	CodeLoginTimeout Code = "login_timeout"
+	// CodeEnrollmentInvalid is an enrollment with a wrong, used or expired enrollment code.
+	CodeEnrollmentInvalid Code = "enrollment_invalid"
+	// CodeTooManyAttempts is a login of a client or from a source which has to back off, see Error.RetryAfter.
+	CodeTooManyAttempts Code = "too_many_attempts"
+)
+
+// statusCodes maps every code to its HTTP status code.
+var statusCodes = map[Code]int{
+	CodeInternal:            http.StatusInternalServerError,
+	CodeUnavailable:         http.StatusServiceUnavailable,
+	CodeBadRequest:          http.StatusBadRequest,
+	CodeLoginRequired:       http.StatusUnauthorized,
+	CodeMACMismatch:         http.StatusUnauthorized,
+	CodeUserNotFound:        http.StatusNotFound,
+	CodeUserAlreadyExists:   http.StatusConflict,
+	CodeDomainNotFound:      http.StatusNotFound,
+	CodeDomainAlreadyExists: http.StatusConflict,
+	CodeNoPreviousVersion:   http.StatusNotFound,
+	CodeGroupMismatch:       http.StatusBadRequest,
+	CodeInvalidElement:      http.StatusBadRequest,
+	CodeMetadataTooLong:     http.StatusBadRequest,
+	CodeRequestTooLarge:     http.StatusRequestEntityTooLarge,
+	CodeReplayedRequest:     http.StatusUnauthorized,
+	CodeLoginOutOfOrder:     http.StatusConflict,
+	CodeLoginTimeout:        http.StatusUnauthorized,
+	CodeEnrollmentInvalid:   http.StatusForbidden,
+	CodeTooManyAttempts:     http.StatusTooManyRequests,
+}
+
+// Error is the structured error returned by the service.
+type Error struct {
+	Code    Code
+	Message string
+	// RetryAfter hints when the request may succeed if retried, zero if retrying does not help.
+	RetryAfter time.Duration
+}
+
+// NewError returns an error with code and message.
+func NewError(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Error returns the message.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// StatusCode returns the HTTP status code of the error, 500 for unknown codes.
+func (e *Error) StatusCode() int {
+	if status, ok := statusCodes[e.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// codeOf guesses the code of responses without one, e.g. of proxies.
+func codeOf(status int) Code {
+	switch {
+	case status == http.StatusUnauthorized:
+		return CodeLoginRequired
+	case status == http.StatusServiceUnavailable || status == http.StatusBadGateway || status == http.StatusGatewayTimeout:
+		return CodeUnavailable
+	case status >= 400 && status < 500:
+		return CodeBadRequest
+	}
+	return CodeInternal
+}
@@ -0,0 +1,47 @@
+package contract
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Run("should carry the request ID in the context", func(t *testing.T) {
+		ctx := WithRequestID(context.Background(), "request-1")
+
+		if got := RequestID(ctx); got != "request-1" {
+			t.Errorf("RequestID() = %v, want %v", got, "request-1")
+		}
+		if got := RequestID(context.Background()); got != "" {
+			t.Errorf("RequestID() = %v, want none", got)
+		}
+	})
+
+	t.Run("should generate valid request IDs", func(t *testing.T) {
+		if id := NewRequestID(); !ValidRequestID(id) || id == NewRequestID() {
+			t.Errorf("NewRequestID() = %v, want a valid random ID", id)
+		}
+	})
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{"should accept letters, digits and separators", "Req-1_a.b", true},
+		{"should reject empty IDs", "", false},
+		{"should reject spaces", "request 1", false},
+		{"should reject line breaks", "request\nlevel=error", false},
+		{"should reject long IDs", strings.Repeat("a", 65), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidRequestID(tt.id); got != tt.want {
+				t.Errorf("ValidRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Limits of the requests accepted by the Unmarshal functions.
+const (
+	// MaxRequestSize is the maximum size of a request body in bytes,
+	// large enough for metadata of the service limit in escaped form.
+	MaxRequestSize = 32 << 10
+	// MaxIntegerBits is the maximum bit length of integers, twice the largest vetted group.
+	MaxIntegerBits = 8192
+	// MaxDomainLength is the maximum length of a domain in bytes.
+	MaxDomainLength = 255
+	// MaxMACLength is the maximum length of a MAC in bytes, enough for SHA-512.
+	MaxMACLength = 64
+	// MaxSessionIDLength is the maximum length of a session ID in bytes.
+	MaxSessionIDLength = 64
+	// MaxEnrollmentCodeLength is the maximum length of an enrollment code in bytes.
+	MaxEnrollmentCodeLength = 64
+)
+
+var (
+	// ErrInvalidRequest is returned when a request is malformed or contains invalid values.
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrRequestTooLarge is returned when a request body exceeds MaxRequestSize.
+	ErrRequestTooLarge = errors.New("request too large")
+)
+
+// decodeRequest decodes exactly one JSON object of at most MaxRequestSize bytes into v
+// and rejects unknown fields.
+func decodeRequest(r io.Reader, v interface{}) error {
+	buf, err := ioutil.ReadAll(io.LimitReader(r, MaxRequestSize+1))
+	if err != nil {
+		return errors.Wrap(err, "failed to read request")
+	}
+	if len(buf) > MaxRequestSize {
+		return errors.Wrapf(ErrRequestTooLarge, "more than %d bytes", MaxRequestSize)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return errors.Wrap(ErrInvalidRequest, err.Error())
+	}
+	if dec.More() {
+		return errors.Wrap(ErrInvalidRequest, "trailing data after request")
+	}
+	return nil
+}
+
+// parseInt parses the required hex encoded integer field of a request.
+func parseInt(field, s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.Wrapf(ErrInvalidRequest, "%s: missing", field)
+	}
+	if len(s) > MaxIntegerBits/4 {
+		return nil, errors.Wrapf(ErrInvalidRequest, "%s: exceeds %d bits", field, MaxIntegerBits)
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return nil, errors.Wrapf(ErrInvalidRequest, "%s: not hex", field)
+		}
+	}
+	x, _ := new(big.Int).SetString(s, 16)
+	return x, nil
+}
+
+// parseMAC parses the required hex encoded MAC field of a request.
+func parseMAC(s string) ([]byte, error) {
+	if s == "" {
+		return nil, errors.Wrap(ErrInvalidRequest, "mac: missing")
+	}
+	mac, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidRequest, "mac: not hex")
+	}
+	return mac, nil
+}
+
+// validateInt rejects missing, negative and oversized integers.
+func validateInt(field string, x *big.Int) error {
+	if x == nil {
+		return errors.Wrapf(ErrInvalidRequest, "%s: missing", field)
+	}
+	if x.Sign() < 0 {
+		return errors.Wrapf(ErrInvalidRequest, "%s: negative", field)
+	}
+	if x.BitLen() > MaxIntegerBits {
+		return errors.Wrapf(ErrInvalidRequest, "%s: exceeds %d bits", field, MaxIntegerBits)
+	}
+	return nil
+}
+
+// validatePositive rejects integers which are zero, e.g. an ID or a group order.
+func validatePositive(field string, x *big.Int) error {
+	if err := validateInt(field, x); err != nil {
+		return err
+	}
+	if x.Sign() == 0 {
+		return errors.Wrapf(ErrInvalidRequest, "%s: zero", field)
+	}
+	return nil
+}
+
+// validateElement rejects zero and one, which are never valid elements.
+// Whether the value is an element of the group of the service is checked by the service.
+func validateElement(field string, x *big.Int) error {
+	if err := validateInt(field, x); err != nil {
+		return err
+	}
+	if x.Cmp(big.NewInt(1)) <= 0 {
+		return errors.Wrapf(ErrInvalidRequest, "%s: not a group element", field)
+	}
+	return nil
+}
+
+// validateMAC rejects missing and oversized MACs.
+func validateMAC(mac []byte) error {
+	if len(mac) == 0 {
+		return errors.Wrap(ErrInvalidRequest, "mac: missing")
+	}
+	if len(mac) > MaxMACLength {
+		return errors.Wrapf(ErrInvalidRequest, "mac: exceeds %d bytes", MaxMACLength)
+	}
+	return nil
+}
+
+// validateAuth rejects authenticated requests without MAC, counter or timestamp.
+func validateAuth(mac []byte, counter uint64, timestamp time.Time) error {
+	if err := validateMAC(mac); err != nil {
+		return err
+	}
+	if counter == 0 {
+		return errors.Wrap(ErrInvalidRequest, "counter: missing")
+	}
+	if UnixMilli(timestamp) <= 0 {
+		return errors.Wrap(ErrInvalidRequest, "timestamp: missing")
+	}
+	return nil
+}
+
+// validateDomain rejects empty, over-long and non printable domains.
+func validateDomain(domain string) error {
+	if domain == "" {
+		return errors.Wrap(ErrInvalidRequest, "domain: missing")
+	}
+	if len(domain) > MaxDomainLength {
+		return errors.Wrapf(ErrInvalidRequest, "domain: exceeds %d bytes", MaxDomainLength)
+	}
+	if !utf8.ValidString(domain) {
+		return errors.Wrap(ErrInvalidRequest, "domain: not UTF-8")
+	}
+	for _, c := range domain {
+		if !unicode.IsPrint(c) {
+			return errors.Wrap(ErrInvalidRequest, "domain: not printable")
+		}
+	}
+	return nil
+}
+
+// validateSessionID rejects empty, over-long and session IDs which are not unpadded base64url.
+func validateSessionID(id string) error {
+	if id == "" {
+		return errors.Wrap(ErrInvalidRequest, "id: missing")
+	}
+	if len(id) > MaxSessionIDLength {
+		return errors.Wrapf(ErrInvalidRequest, "id: exceeds %d bytes", MaxSessionIDLength)
+	}
+	for _, c := range id {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '-' || c == '_') {
+			return errors.Wrap(ErrInvalidRequest, "id: not base64url")
+		}
+	}
+	return nil
+}
+
+// validateEnrollmentCode rejects empty, over-long and enrollment codes which are not hex.
+func validateEnrollmentCode(code string) error {
+	if code == "" {
+		return errors.Wrap(ErrInvalidRequest, "code: missing")
+	}
+	if len(code) > MaxEnrollmentCodeLength {
+		return errors.Wrapf(ErrInvalidRequest, "code: exceeds %d bytes", MaxEnrollmentCodeLength)
+	}
+	if _, err := hex.DecodeString(code); err != nil {
+		return errors.Wrap(ErrInvalidRequest, "code: not hex")
+	}
+	return nil
+}
+
+// Validate returns ErrInvalidRequest unless cID is a positive integer and the verifier a candidate group element.
+func (r RegisterRequest) Validate() error {
+	if err := validatePositive("cID", r.CID); err != nil {
+		return err
+	}
+	return validateElement("verifier", r.Verifier)
+}
+
+// Validate returns ErrInvalidRequest unless cID, the code and the proof are present and verifier and e are candidate group elements.
+func (r EnrollRequest) Validate() error {
+	if err := validatePositive("cID", r.CID); err != nil {
+		return err
+	}
+	if err := validateEnrollmentCode(r.Code); err != nil {
+		return err
+	}
+	if err := validateElement("verifier", r.Verifier); err != nil {
+		return err
+	}
+	if err := validateElement("e", r.E); err != nil {
+		return err
+	}
+	if err := validateInt("proofC", r.ProofC); err != nil {
+		return err
+	}
+	return validateInt("proofS", r.ProofS)
+}
+
+// Validate returns ErrInvalidRequest unless b is a candidate group element and q is present.
+func (r RegisterExpKRequest) Validate() error {
+	if err := validateElement("b", r.B); err != nil {
+		return err
+	}
+	return validatePositive("q", r.Q)
+}
+
+// Validate returns ErrInvalidRequest unless all values are present and b and x are candidate group elements.
+func (r ExpKRequest) Validate() error {
+	if err := validatePositive("cID", r.CID); err != nil {
+		return err
+	}
+	if err := validateInt("cNonce", r.CNonce); err != nil {
+		return err
+	}
+	if err := validateElement("b", r.B); err != nil {
+		return err
+	}
+	if err := validateElement("x", r.X); err != nil {
+		return err
+	}
+	return validatePositive("q", r.Q)
+}
+
+// Validate returns ErrInvalidRequest unless MAC, counter and timestamp are present,
+// g is a candidate group element and q is present.
+func (r ChallengeRequest) Validate() error {
+	if err := validateAuth(r.MAC, r.Counter, r.Timestamp); err != nil {
+		return err
+	}
+	if err := validateElement("g", r.G); err != nil {
+		return err
+	}
+	return validatePositive("q", r.Q)
+}
+
+// Validate returns ErrInvalidRequest unless MAC, counter and timestamp are present.
+func (r MetadataRequest) Validate() error {
+	return validateAuth(r.MAC, r.Counter, r.Timestamp)
+}
+
+// Validate returns ErrInvalidRequest unless MAC, counter, timestamp and domain are valid.
+func (r AddRequest) Validate() error {
+	if err := validateAuth(r.MAC, r.Counter, r.Timestamp); err != nil {
+		return err
+	}
+	return validateDomain(r.Domain)
+}
+
+// Validate returns ErrInvalidRequest unless MAC, counter, timestamp and domain are valid,
+// bmk is a candidate group element and q is present.
+func (r GetRequest) Validate() error {
+	if err := validateAuth(r.MAC, r.Counter, r.Timestamp); err != nil {
+		return err
+	}
+	if err := validateDomain(r.Domain); err != nil {
+		return err
+	}
+	if err := validateElement("bmk", r.BMK); err != nil {
+		return err
+	}
+	return validatePositive("q", r.Q)
+}
+
+// Validate returns ErrInvalidRequest unless MAC, counter, timestamp and domain are valid.
+func (r RotateRequest) Validate() error {
+	if err := validateAuth(r.MAC, r.Counter, r.Timestamp); err != nil {
+		return err
+	}
+	return validateDomain(r.Domain)
+}
+
+// Validate returns ErrInvalidRequest unless MAC, counter, timestamp and domain are valid.
+func (r DeleteRequest) Validate() error {
+	if err := validateAuth(r.MAC, r.Counter, r.Timestamp); err != nil {
+		return err
+	}
+	return validateDomain(r.Domain)
+}
+
+// Validate returns ErrInvalidRequest unless MAC, counter and timestamp are present.
+func (r SessionsRequest) Validate() error {
+	return validateAuth(r.MAC, r.Counter, r.Timestamp)
+}
+
+// Validate returns ErrInvalidRequest unless MAC, counter and timestamp are present
+// and either a valid session ID or all sessions are requested.
+func (r RevokeSessionsRequest) Validate() error {
+	if err := validateAuth(r.MAC, r.Counter, r.Timestamp); err != nil {
+		return err
+	}
+	if r.All {
+		if r.ID != "" {
+			return errors.Wrap(ErrInvalidRequest, "id: set with all")
+		}
+		return nil
+	}
+	return validateSessionID(r.ID)
+}
@@ -0,0 +1,100 @@
+package contract
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestUnmarshalRequest_Validation(t *testing.T) {
+	unmarshalExpK := func(body string) error {
+		_, err := UnmarshalExpKRequest(strings.NewReader(body))
+		return err
+	}
+	unmarshalGet := func(body string) error {
+		_, err := UnmarshalGetRequest(strings.NewReader(body))
+		return err
+	}
+	tests := []struct {
+		name      string
+		unmarshal func(string) error
+		body      string
+		wantErr   error
+	}{
+		{"should accept valid ExpKRequest", unmarshalExpK, `{"cID":"1","cNonce":"0","b":"2","q":"3","x":"5"}`, nil},
+		{"should reject empty body", unmarshalExpK, ``, ErrInvalidRequest},
+		{"should reject missing field", unmarshalExpK, `{"cID":"1","cNonce":"0","q":"3","x":"5"}`, ErrInvalidRequest},
+		{"should reject missing ephemeral key", unmarshalExpK, `{"cID":"1","cNonce":"0","b":"2","q":"3"}`, ErrInvalidRequest},
+		{"should reject unknown field", unmarshalExpK, `{"cID":"1","cNonce":"0","b":"2","q":"3","kv":"4"}`, ErrInvalidRequest},
+		{"should reject trailing data", unmarshalExpK, `{"cID":"1","cNonce":"0","b":"2","q":"3","x":"5"}{}`, ErrInvalidRequest},
+		{"should reject non-hex", unmarshalExpK, `{"cID":"1","cNonce":"0","b":"xyz","q":"3","x":"5"}`, ErrInvalidRequest},
+		{"should reject signed value", unmarshalExpK, `{"cID":"1","cNonce":"0","b":"-2","q":"3","x":"5"}`, ErrInvalidRequest},
+		{"should reject zero element", unmarshalExpK, `{"cID":"1","cNonce":"0","b":"0","q":"3","x":"5"}`, ErrInvalidRequest},
+		{"should reject one as element", unmarshalExpK, `{"cID":"1","cNonce":"0","b":"1","q":"3","x":"5"}`, ErrInvalidRequest},
+		{"should reject zero cID", unmarshalExpK, `{"cID":"0","cNonce":"0","b":"2","q":"3","x":"5"}`, ErrInvalidRequest},
+		{"should reject oversized integer", unmarshalExpK, `{"cID":"1","cNonce":"0","b":"` + strings.Repeat("f", MaxIntegerBits/4+1) + `","q":"3","x":"5"}`, ErrInvalidRequest},
+		{"should reject oversized body", unmarshalExpK, `{"cID":"1","cNonce":"` + strings.Repeat("0", MaxRequestSize) + `","b":"2","q":"3","x":"5"}`, ErrRequestTooLarge},
+		{"should accept valid GetRequest", unmarshalGet, `{"mac":"aa","counter":1,"timestamp":1571234567890,"domain":"example.com","bmk":"2","q":"3"}`, nil},
+		{"should reject missing domain", unmarshalGet, `{"mac":"aa","counter":1,"timestamp":1571234567890,"bmk":"2","q":"3"}`, ErrInvalidRequest},
+		{"should reject over-long domain", unmarshalGet, `{"mac":"aa","counter":1,"timestamp":1571234567890,"domain":"` + strings.Repeat("a", MaxDomainLength+1) + `","bmk":"2","q":"3"}`, ErrInvalidRequest},
+		{"should reject non printable domain", unmarshalGet, `{"mac":"aa","counter":1,"timestamp":1571234567890,"domain":"a\nb","bmk":"2","q":"3"}`, ErrInvalidRequest},
+		{"should reject missing MAC", unmarshalGet, `{"counter":1,"timestamp":1571234567890,"domain":"example.com","bmk":"2","q":"3"}`, ErrInvalidRequest},
+		{"should reject oversized MAC", unmarshalGet, `{"mac":"` + strings.Repeat("aa", MaxMACLength+1) + `","counter":1,"timestamp":1571234567890,"domain":"example.com","bmk":"2","q":"3"}`, ErrInvalidRequest},
+		{"should reject missing counter", unmarshalGet, `{"mac":"aa","timestamp":1571234567890,"domain":"example.com","bmk":"2","q":"3"}`, ErrInvalidRequest},
+		{"should reject missing timestamp", unmarshalGet, `{"mac":"aa","counter":1,"domain":"example.com","bmk":"2","q":"3"}`, ErrInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.unmarshal(tt.body)
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     interface{ Validate() error }
+		wantErr error
+	}{
+		{"should accept ExpKRequest with zero nonce", ExpKRequest{CID: big.NewInt(1), CNonce: big.NewInt(0), B: big.NewInt(2), Q: big.NewInt(3), X: big.NewInt(5)}, nil},
+		{"should reject ExpKRequest without values", ExpKRequest{}, ErrInvalidRequest},
+		{"should reject ExpKRequest with one as ephemeral key", ExpKRequest{CID: big.NewInt(1), CNonce: big.NewInt(0), B: big.NewInt(2), Q: big.NewInt(3), X: big.NewInt(1)}, ErrInvalidRequest},
+		{"should accept RegisterRequest", RegisterRequest{CID: big.NewInt(1), Verifier: big.NewInt(2)}, nil},
+		{"should reject RegisterRequest without verifier", RegisterRequest{CID: big.NewInt(1)}, ErrInvalidRequest},
+		{"should accept EnrollRequest", EnrollRequest{CID: big.NewInt(1), Code: "c0de", Verifier: big.NewInt(2), E: big.NewInt(3), ProofC: big.NewInt(4), ProofS: big.NewInt(5)}, nil},
+		{"should reject EnrollRequest without proof", EnrollRequest{CID: big.NewInt(1), Code: "c0de", Verifier: big.NewInt(2), E: big.NewInt(3)}, ErrInvalidRequest},
+		{"should reject EnrollRequest without code", EnrollRequest{CID: big.NewInt(1), Verifier: big.NewInt(2), E: big.NewInt(3), ProofC: big.NewInt(4), ProofS: big.NewInt(5)}, ErrInvalidRequest},
+		{"should reject EnrollRequest with a code which is not hex", EnrollRequest{CID: big.NewInt(1), Code: "code", Verifier: big.NewInt(2), E: big.NewInt(3), ProofC: big.NewInt(4), ProofS: big.NewInt(5)}, ErrInvalidRequest},
+		{"should accept RegisterExpKRequest", RegisterExpKRequest{B: big.NewInt(2), Q: big.NewInt(3)}, nil},
+		{"should reject RegisterExpKRequest without b", RegisterExpKRequest{Q: big.NewInt(3)}, ErrInvalidRequest},
+		{"should reject negative element", ChallengeRequest{MAC: []byte("mac"), Counter: 1, Timestamp: time.Now(), G: big.NewInt(-2), Q: big.NewInt(3)}, ErrInvalidRequest},
+		{"should reject oversized element", ChallengeRequest{MAC: []byte("mac"), Counter: 1, Timestamp: time.Now(), G: new(big.Int).Lsh(big.NewInt(1), MaxIntegerBits), Q: big.NewInt(3)}, ErrInvalidRequest},
+		{"should accept ChallengeRequest", ChallengeRequest{MAC: []byte("mac"), Counter: 1, Timestamp: time.Now(), G: big.NewInt(2), Q: big.NewInt(3)}, nil},
+		{"should reject ChallengeRequest without MAC", ChallengeRequest{Counter: 1, Timestamp: time.Now(), G: big.NewInt(2), Q: big.NewInt(3)}, ErrInvalidRequest},
+		{"should reject AddRequest without MAC", AddRequest{Domain: "example.com"}, ErrInvalidRequest},
+		{"should accept RotateRequest", RotateRequest{MAC: []byte("mac"), Counter: 1, Timestamp: time.Now(), Domain: "example.com"}, nil},
+		{"should reject RotateRequest without counter", RotateRequest{MAC: []byte("mac"), Timestamp: time.Now(), Domain: "example.com"}, ErrInvalidRequest},
+		{"should accept DeleteRequest", DeleteRequest{MAC: []byte("mac"), Counter: 1, Timestamp: time.Now(), Domain: "example.com"}, nil},
+		{"should reject DeleteRequest without domain", DeleteRequest{MAC: []byte("mac"), Counter: 1, Timestamp: time.Now()}, ErrInvalidRequest},
+		{"should accept SessionsRequest", SessionsRequest{MAC: []byte("mac"), Counter: 1, Timestamp: time.Now()}, nil},
+		{"should accept RevokeSessionsRequest of a session", RevokeSessionsRequest{MAC: []byte("mac"), Counter: 1, Timestamp: time.Now(), ID: "aB3-_x"}, nil},
+		{"should accept RevokeSessionsRequest of all sessions", RevokeSessionsRequest{MAC: []byte("mac"), Counter: 1, Timestamp: time.Now(), All: true}, nil},
+		{"should reject RevokeSessionsRequest without session", RevokeSessionsRequest{MAC: []byte("mac"), Counter: 1, Timestamp: time.Now()}, ErrInvalidRequest},
+		{"should reject RevokeSessionsRequest of a session and all", RevokeSessionsRequest{MAC: []byte("mac"), Counter: 1, Timestamp: time.Now(), ID: "a", All: true}, ErrInvalidRequest},
+		{"should reject RevokeSessionsRequest with invalid session ID", RevokeSessionsRequest{MAC: []byte("mac"), Counter: 1, Timestamp: time.Now(), ID: "a/b"}, ErrInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"hash"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// The login of Online SPHINX is an authenticated key exchange bound to the password.
+// The client derives its authentication key ak from the master key mk, which requires
+// the OPRF output of the password and the device secret, and registers only the
+// verifier AK = G**ak. At login client and service exchange the ephemeral keys
+// X = G**x and Y = G**y and derive the session key from X**y = Y**x and AK**y = Y**ak,
+// so that only a client knowing password and device secret and only the service
+// knowing the verifier end up with the same session key.
+
+const (
+	authKeyDST    = "online-sphinx auth key v1"
+	sessionDST    = "online-sphinx session key v1"
+	possessionDST = "online-sphinx possession v1-"
+)
+
+// AuthKey derives the authentication key ak of a client from its master key mk.
+func AuthKey(g Group, mk *big.Int) *big.Int {
+	q := g.Order()
+	l := (q.BitLen() + 128 + 7) / 8
+	return hashToField(sha256.New, g.Encode(mk), []byte(authKeyDST), q, 1, l)[0]
+}
+
+// Verifier returns the verifier AK = G**ak of the authentication key ak, which the service stores.
+func Verifier(g Group, ak *big.Int) *big.Int {
+	return g.Exp(g.Generator(), ak)
+}
+
+// PossessionProof proves that a client knows the authentication key ak of the verifier AK = G**ak.
+// It is the DLEQ proof of RFC 9497 that E = H(cID)**ak, so that it is bound to the client ID cID
+// and can not be replayed for another one.
+type PossessionProof struct {
+	E *big.Int
+	Proof
+}
+
+// ProvePossession returns the proof that the client with cID knows the authentication key ak.
+func ProvePossession(g Group, ak, cID *big.Int) (PossessionProof, error) {
+	o := possessionOPRF(g)
+	h := o.HashToGroup(cID.Bytes())
+	e := g.Exp(h, ak)
+	p, err := o.Prove(ak, []*big.Int{h}, []*big.Int{e})
+	if err != nil {
+		return PossessionProof{}, errors.Wrap(err, "ProvePossession")
+	}
+	return PossessionProof{E: e, Proof: p}, nil
+}
+
+// VerifyPossession returns ErrProofInvalid unless p proves that the client with cID
+// knows the authentication key of verifier.
+func VerifyPossession(g Group, verifier, cID *big.Int, p PossessionProof) error {
+	o := possessionOPRF(g)
+	return o.Verify(verifier, []*big.Int{o.HashToGroup(cID.Bytes())}, []*big.Int{p.E}, p.Proof)
+}
+
+// possessionOPRF returns an OPRF of g whose context separates proofs of possession from evaluations.
+func possessionOPRF(g Group) OPRF {
+	return OPRF{group: g, context: []byte(possessionDST + g.Name())}
+}
+
+// Transcript contains the public values of a login, the session key is bound to all of them.
+type Transcript struct {
+	CID    *big.Int
+	SID    *big.Int
+	CNonce *big.Int
+	SNonce *big.Int
+	B      *big.Int
+	BD     *big.Int
+	X      *big.Int
+	Y      *big.Int
+}
+
+func (t Transcript) encode(g Group) []byte {
+	return lengthPrefixed(
+		t.CID.Bytes(), t.SID.Bytes(), t.CNonce.Bytes(), t.SNonce.Bytes(),
+		g.Encode(t.B), g.Encode(t.BD), g.Encode(t.X), g.Encode(t.Y),
+	)
+}
+
+// SessionKey derives the session key SKi of a login from ee = X**y = Y**x
+// and se = AK**y = Y**ak, bound to the transcript t.
+func SessionKey(h func() hash.Hash, g Group, ee, se *big.Int, t Transcript) *big.Int {
+	key := append(g.Encode(ee), g.Encode(se)...)
+	return new(big.Int).SetBytes(HmacData(h, key, []byte(sessionDST), t.encode(g)))
+}
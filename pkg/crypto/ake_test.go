@@ -0,0 +1,93 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"testing"
+)
+
+func TestSessionKey(t *testing.T) {
+	g, err := GroupByName(P256)
+	if err != nil {
+		t.Fatalf("GroupByName() error = %v", err)
+	}
+
+	mk, _ := g.RandomElement()
+	verifier := Verifier(g, AuthKey(g, mk))
+	x, _ := g.RandomScalar()
+	y, _ := g.RandomScalar()
+	tr := Transcript{
+		CID: big.NewInt(1), SID: big.NewInt(2), CNonce: big.NewInt(3), SNonce: big.NewInt(4),
+		B: g.Generator(), BD: g.Generator(), X: g.Exp(g.Generator(), x), Y: g.Exp(g.Generator(), y),
+	}
+	service := SessionKey(sha256.New, g, g.Exp(tr.X, y), g.Exp(verifier, y), tr)
+
+	t.Run("should derive the session key of the service with the master key", func(t *testing.T) {
+		// when
+		client := SessionKey(sha256.New, g, g.Exp(tr.Y, x), g.Exp(tr.Y, AuthKey(g, mk)), tr)
+
+		// then
+		if client.Cmp(service) != 0 {
+			t.Errorf("SessionKey() = %v, want %v", client, service)
+		}
+	})
+
+	t.Run("should derive another session key with another master key", func(t *testing.T) {
+		other, _ := g.RandomElement()
+
+		client := SessionKey(sha256.New, g, g.Exp(tr.Y, x), g.Exp(tr.Y, AuthKey(g, other)), tr)
+
+		if client.Cmp(service) == 0 {
+			t.Errorf("SessionKey() = %v, want another key", client)
+		}
+	})
+
+	t.Run("should bind the session key to the transcript", func(t *testing.T) {
+		other := tr
+		other.SNonce = big.NewInt(5)
+
+		client := SessionKey(sha256.New, g, g.Exp(tr.Y, x), g.Exp(tr.Y, AuthKey(g, mk)), other)
+
+		if client.Cmp(service) == 0 {
+			t.Errorf("SessionKey() = %v, want another key", client)
+		}
+	})
+}
+
+func TestVerifyPossession(t *testing.T) {
+	g, err := GroupByName(P256)
+	if err != nil {
+		t.Fatalf("GroupByName() error = %v", err)
+	}
+
+	mk, _ := g.RandomElement()
+	ak := AuthKey(g, mk)
+	verifier := Verifier(g, ak)
+	cID := big.NewInt(1)
+	proof, err := ProvePossession(g, ak, cID)
+	if err != nil {
+		t.Fatalf("ProvePossession() error = %v", err)
+	}
+	other, _ := g.RandomScalar()
+
+	tests := []struct {
+		name     string
+		verifier *big.Int
+		cID      *big.Int
+		proof    PossessionProof
+		wantErr  bool
+	}{
+		{"should verify the proof of the authentication key", verifier, cID, proof, false},
+		{"should reject the proof for another verifier", Verifier(g, other), cID, proof, true},
+		{"should reject the proof for another client ID", verifier, big.NewInt(2), proof, true},
+		{"should reject a proof without E", verifier, cID, PossessionProof{Proof: proof.Proof}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyPossession(g, tt.verifier, tt.cID, tt.proof)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyPossession() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
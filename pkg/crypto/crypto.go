@@ -4,6 +4,8 @@ import (
 	"crypto/hmac"
 	"hash"
 	"math/big"
+
+	"github.com/pkg/errors"
 )
 
 var one = big.NewInt(1)
@@ -19,8 +21,33 @@ func ExpInGroup(g, k, q *big.Int) *big.Int {
 	return r.Exp(g, k, p)
 }
 
-// HashInGroup takes an arbitrary string transform into a group element
-func HashInGroup(password string, newHash func() hash.Hash, q *big.Int) *big.Int {
+// HashInGroup maps msg into the subgroup of quadratic residues of the safe prime p = 2q + 1.
+// msg is expanded with expand_message_xmd of RFC 9380 and the domain separation tag dst
+// to 128 bits more than p, reduced into [2, p-2] and squared, so the result is never the identity
+// and its discrete logarithm is unknown.
+func HashInGroup(msg, dst []byte, newHash func() hash.Hash, q *big.Int) (*big.Int, error) {
+	p := new(big.Int).Add(new(big.Int).Mul(two, q), one)
+	l := (p.BitLen() + 128 + 7) / 8
+
+	uniform, err := ExpandMessageXMD(newHash, msg, dst, l)
+	if err != nil {
+		return nil, errors.Wrap(err, "HashInGroup: failed to expand message")
+	}
+
+	// u in [2, p-2], u**2 = 1 only for u = 1 or u = p-1
+	u := new(big.Int).SetBytes(uniform)
+	u.Mod(u, new(big.Int).Sub(p, big.NewInt(3)))
+	u.Add(u, two)
+
+	return ExpInGroup(u, two, q), nil
+}
+
+// LegacyHashInGroup is the original Online SPHINX mapping of passwords into the group.
+// It squares the password bytes followed by the digest of the empty input, which is
+// neither domain separated nor one-way.
+//
+// Deprecated: only used to derive the passwords of users registered with HashVersionLegacy, use HashInGroup.
+func LegacyHashInGroup(password string, newHash func() hash.Hash, q *big.Int) *big.Int {
 	p := new(big.Int)
 	p.SetBytes(newHash().Sum([]byte(password)))
 
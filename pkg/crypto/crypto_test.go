@@ -38,7 +38,7 @@ func TestCrypto_ExpInGroup(t *testing.T) {
 	}
 }
 
-func TestCrypto_HashInGroup(t *testing.T) {
+func TestCrypto_LegacyHashInGroup(t *testing.T) {
 	type args struct {
 		password string
 		newHash  func() hash.Hash
@@ -61,13 +61,64 @@ func TestCrypto_HashInGroup(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := HashInGroup(tt.args.password, tt.args.newHash, tt.args.q); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("HashInGroup() = %v, want %v", got, tt.want)
+			if got := LegacyHashInGroup(tt.args.password, tt.args.newHash, tt.args.q); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LegacyHashInGroup() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func TestCrypto_HashInGroup(t *testing.T) {
+	g, err := GroupByName("modp2048")
+	if err != nil {
+		t.Fatalf("GroupByName() error = %v", err)
+	}
+	q := g.Order()
+
+	hashInGroup := func(msg, dst string) *big.Int {
+		e, err := HashInGroup([]byte(msg), []byte(dst), sha256.New, q)
+		if err != nil {
+			t.Fatalf("HashInGroup() error = %v", err)
+		}
+		return e
+	}
+
+	t.Run("should map deterministically into the group", func(t *testing.T) {
+		e := hashInGroup("password", "dst")
+		if !g.IsElement(e) {
+			t.Errorf("HashInGroup() = %v is not an element", e)
+		}
+		if e.Cmp(hashInGroup("password", "dst")) != 0 {
+			t.Errorf("HashInGroup() is not deterministic")
+		}
+	})
+
+	t.Run("should separate domains", func(t *testing.T) {
+		if hashInGroup("password", "dst").Cmp(hashInGroup("password", "other")) == 0 {
+			t.Errorf("HashInGroup() does not depend on dst")
+		}
+	})
+
+	t.Run("should not square the password", func(t *testing.T) {
+		if hashInGroup("password", "dst").Cmp(LegacyHashInGroup("password", sha256.New, q)) == 0 {
+			t.Errorf("HashInGroup() = LegacyHashInGroup()")
+		}
+	})
+
+	t.Run("should map into small groups", func(t *testing.T) {
+		small := big.NewInt(11)
+		for _, msg := range []string{"a", "b", "c", "d", "e", "f"} {
+			e, err := HashInGroup([]byte(msg), []byte("dst"), sha256.New, small)
+			if err != nil {
+				t.Fatalf("HashInGroup() error = %v", err)
+			}
+			if !(ModPGroup{name: "test", q: small}).IsElement(e) {
+				t.Errorf("HashInGroup(%s) = %v is not an element", msg, e)
+			}
+		}
+	})
+}
+
 func TestHmacData(t *testing.T) {
 	type args struct {
 		h    func() hash.Hash
@@ -0,0 +1,203 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"math/big"
+)
+
+// P256 is the name of the NIST P-256 group.
+const P256 = "p256"
+
+// CurveGroup is a prime order elliptic curve group y^2 = x^3 - 3x + b with cofactor 1.
+// Points are encoded in compressed SEC 1 form, the identity is not a valid element.
+type CurveGroup struct {
+	name  string
+	curve elliptic.Curve
+	z     *big.Int // non square constant Z of the simplified SWU map, RFC 9380 section 8
+}
+
+// newP256 returns the NIST P-256 group with the P256_XMD:SHA-256_SSWU_RO_ hash to curve suite.
+func newP256() CurveGroup {
+	p := elliptic.P256().Params().P
+	return CurveGroup{
+		name:  P256,
+		curve: elliptic.P256(),
+		z:     new(big.Int).Sub(p, big.NewInt(10)),
+	}
+}
+
+// Name of the group.
+func (g CurveGroup) Name() string {
+	return g.name
+}
+
+// Order returns the order n of the base point.
+func (g CurveGroup) Order() *big.Int {
+	return g.curve.Params().N
+}
+
+// Bits returns the bit length of the field.
+func (g CurveGroup) Bits() int {
+	return g.curve.Params().BitSize
+}
+
+// Generator returns the base point G.
+func (g CurveGroup) Generator() *big.Int {
+	return g.encode(g.curve.Params().Gx, g.curve.Params().Gy)
+}
+
+// Encode returns the compressed SEC 1 encoding of x.
+func (g CurveGroup) Encode(x *big.Int) []byte {
+	return fixedBytes(x, 1+(g.curve.Params().BitSize+7)/8)
+}
+
+// IsElement reports whether x is the compressed encoding of a point on the curve.
+func (g CurveGroup) IsElement(x *big.Int) bool {
+	_, _, ok := g.decode(x)
+	return ok
+}
+
+// Exp returns the scalar multiplication k*x.
+func (g CurveGroup) Exp(x, k *big.Int) *big.Int {
+	px, py, ok := g.decode(x)
+	if !ok {
+		return new(big.Int)
+	}
+	s := new(big.Int).Mod(k, g.Order())
+	return g.encode(g.curve.ScalarMult(px, py, s.Bytes()))
+}
+
+// Mul returns the point addition x+y.
+func (g CurveGroup) Mul(x, y *big.Int) *big.Int {
+	px, py, ok := g.decode(x)
+	if !ok {
+		return new(big.Int)
+	}
+	qx, qy, ok := g.decode(y)
+	if !ok {
+		return new(big.Int)
+	}
+	return g.encode(g.curve.Add(px, py, qx, qy))
+}
+
+// HashToGroup implements hash_to_curve of RFC 9380 with the random oracle suite of the curve.
+func (g CurveGroup) HashToGroup(msg, dst []byte) *big.Int {
+	u := hashToField(sha256.New, msg, dst, g.curve.Params().P, 2, 48)
+	x0, y0 := g.mapToCurve(u[0])
+	x1, y1 := g.mapToCurve(u[1])
+	return g.encode(g.curve.Add(x0, y0, x1, y1))
+}
+
+// RandomElement returns r*G for a random scalar r.
+func (g CurveGroup) RandomElement() (*big.Int, error) {
+	r, err := g.RandomScalar()
+	if err != nil {
+		return nil, err
+	}
+	return g.encode(g.curve.ScalarBaseMult(r.Bytes())), nil
+}
+
+// RandomScalar returns a uniformly random scalar in [1, n).
+func (g CurveGroup) RandomScalar() (*big.Int, error) {
+	return randomScalar(g.Order())
+}
+
+// encode returns the compressed SEC 1 encoding of (x, y) or zero for the identity.
+func (g CurveGroup) encode(x, y *big.Int) *big.Int {
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return new(big.Int)
+	}
+	buf := append([]byte{byte(2 + y.Bit(0))}, fixedBytes(x, (g.curve.Params().BitSize+7)/8)...)
+	return new(big.Int).SetBytes(buf)
+}
+
+// decode decompresses e and reports whether it is a point on the curve.
+func (g CurveGroup) decode(e *big.Int) (x, y *big.Int, ok bool) {
+	if e == nil || e.Sign() <= 0 {
+		return nil, nil, false
+	}
+	params := g.curve.Params()
+	size := (params.BitSize + 7) / 8
+	buf := e.Bytes()
+	if len(buf) != 1+size || (buf[0] != 2 && buf[0] != 3) {
+		return nil, nil, false
+	}
+
+	x = new(big.Int).SetBytes(buf[1:])
+	if x.Cmp(params.P) >= 0 {
+		return nil, nil, false
+	}
+
+	y = sqrt(g.rhs(x), params.P)
+	if y == nil {
+		return nil, nil, false
+	}
+	if y.Bit(0) != uint(buf[0]&1) {
+		y.Sub(params.P, y)
+	}
+	if !g.curve.IsOnCurve(x, y) {
+		return nil, nil, false
+	}
+	return x, y, true
+}
+
+// rhs returns x^3 - 3x + b mod p.
+func (g CurveGroup) rhs(x *big.Int) *big.Int {
+	params := g.curve.Params()
+	r := new(big.Int).Exp(x, big.NewInt(3), params.P)
+	x3 := new(big.Int).Lsh(x, 1)
+	x3.Add(x3, x)
+	r.Sub(r, x3)
+	r.Add(r, params.B)
+	return r.Mod(r, params.P)
+}
+
+// mapToCurve is the simplified SWU map of RFC 9380 section 6.6.2 for A = -3.
+func (g CurveGroup) mapToCurve(u *big.Int) (x, y *big.Int) {
+	p := g.curve.Params().P
+	a := new(big.Int).Sub(p, big.NewInt(3))
+	b := g.curve.Params().B
+	mod := func(n *big.Int) *big.Int { return n.Mod(n, p) }
+	mul := func(x, y *big.Int) *big.Int { return mod(new(big.Int).Mul(x, y)) }
+
+	// tv1 = inv0(Z^2 * u^4 + Z * u^2)
+	zu2 := mul(g.z, mul(u, u))
+	tv1 := mod(new(big.Int).Add(mul(zu2, zu2), zu2))
+	if tv1.Sign() != 0 {
+		tv1.ModInverse(tv1, p)
+	}
+
+	// x1 = (-B / A) * (1 + tv1) or B / (Z * A) if tv1 = 0
+	var x1 *big.Int
+	if tv1.Sign() == 0 {
+		x1 = mul(b, new(big.Int).ModInverse(mul(g.z, a), p))
+	} else {
+		nb := mod(new(big.Int).Neg(b))
+		x1 = mul(mul(nb, new(big.Int).ModInverse(a, p)), new(big.Int).Add(tv1, one))
+	}
+
+	x = x1
+	y = sqrt(g.rhs(x1), p)
+	if y == nil {
+		// x2 = Z * u^2 * x1
+		x = mul(zu2, x1)
+		y = sqrt(g.rhs(x), p)
+	}
+
+	if u.Bit(0) != y.Bit(0) {
+		y.Sub(p, y).Mod(y, p)
+	}
+	return x, y
+}
+
+// sqrt returns a square root of a mod p for p = 3 mod 4 or nil if a is not a square.
+func sqrt(a, p *big.Int) *big.Int {
+	e := new(big.Int).Add(p, one)
+	e.Rsh(e, 2)
+	r := new(big.Int).Exp(a, e, p)
+	if new(big.Int).Exp(r, two, p).Cmp(new(big.Int).Mod(a, p)) != 0 {
+		return nil
+	}
+	return r
+}
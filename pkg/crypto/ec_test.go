@@ -0,0 +1,124 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func hexInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(s, "0x"), 16)
+	if !ok {
+		panic("invalid hex " + s)
+	}
+	return n
+}
+
+// test vectors of RFC 9380 appendix J.1.1
+func TestCurveGroup_HashToGroup(t *testing.T) {
+	g := newP256()
+	dst := []byte("QUUX-V01-CS02-with-P256_XMD:SHA-256_SSWU_RO_")
+	tests := []struct {
+		msg    string
+		x, y   string
+		u0, u1 string
+	}{
+		{"",
+			"0x2c15230b26dbc6fc9a37051158c95b79656e17a1a920b11394ca91c44247d3e4", "0x8a7a74985cc5c776cdfe4b1f19884970453912e9d31528c060be9ab5c43e8415",
+			"0xad5342c66a6dd0ff080df1da0ea1c04b96e0330dd89406465eeba11582515009", "0x8c0f1d43204bd6f6ea70ae8013070a1518b43873bcd850aafa0a9e220e2eea5a"},
+		{"abc",
+			"0x0bb8b87485551aa43ed54f009230450b492fead5f1cc91658775dac4a3388a0f", "0x5c41b3d0731a27a7b14bc0bf0ccded2d8751f83493404c84a88e71ffd424212e",
+			"0xafe47f2ea2b10465cc26ac403194dfb68b7f5ee865cda61e9f3e07a537220af1", "0x379a27833b0bfe6f7bdca08e1e83c760bf9a338ab335542704edcd69ce9e46e0"},
+		{"abcdef0123456789",
+			"0x65038ac8f2b1def042a5df0b33b1f4eca6bff7cb0f9c6c1526811864e544ed80", "0xcad44d40a656e7aff4002a8de287abc8ae0482b5ae825822bb870d6df9b56ca3",
+			"0x0fad9d125a9477d55cf9357105b0eb3a5c4259809bf87180aa01d651f53d312c", "0xb68597377392cd3419d8fcc7d7660948c8403b19ea78bbca4b133c9d2196c0fb"},
+		{"q128_" + strings.Repeat("q", 128),
+			"0x4be61ee205094282ba8a2042bcb48d88dfbb609301c49aa8b078533dc65a0b5d", "0x98f8df449a072c4721d241a3b1236d3caccba603f916ca680f4539d2bfb3c29e",
+			"0x3bbc30446f39a7befad080f4d5f32ed116b9534626993d2cc5033f6f8d805919", "0x76bb02db019ca9d3c1e02f0c17f8baf617bbdae5c393a81d9ce11e3be1bf1d33"},
+		{"a512_" + strings.Repeat("a", 512),
+			"0x457ae2981f70ca85d8e24c308b14db22f3e3862c5ea0f652ca38b5e49cd64bc5", "0xecb9f0eadc9aeed232dabc53235368c1394c78de05dd96893eefa62b0f4757dc",
+			"0x4ebc95a6e839b1ae3c63b847798e85cb3c12d3817ec6ebc10af6ee51adb29fec", "0x4e21af88e22ea80156aff790750121035b3eefaa96b425a8716e0d20b4e269ee"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("should hash %.16q to the curve", tt.msg), func(t *testing.T) {
+			u := hashToField(sha256.New, []byte(tt.msg), dst, elliptic.P256().Params().P, 2, 48)
+			if u[0].Cmp(hexInt(tt.u0)) != 0 || u[1].Cmp(hexInt(tt.u1)) != 0 {
+				t.Errorf("hashToField() = %x, want [%s %s]", u, tt.u0, tt.u1)
+			}
+
+			got := g.HashToGroup([]byte(tt.msg), dst)
+			x, y, ok := g.decode(got)
+			if !ok {
+				t.Fatalf("HashToGroup() = %x is not an element", got)
+			}
+			if x.Cmp(hexInt(tt.x)) != 0 || y.Cmp(hexInt(tt.y)) != 0 {
+				t.Errorf("HashToGroup() = (%x, %x), want (%s, %s)", x, y, tt.x, tt.y)
+			}
+		})
+	}
+}
+
+func TestCurveGroup_IsElement(t *testing.T) {
+	g := newP256()
+	params := elliptic.P256().Params()
+	base := g.encode(params.Gx, params.Gy)
+	withPrefix := func(prefix byte, x *big.Int) *big.Int {
+		buf := make([]byte, 33)
+		buf[0] = prefix
+		xb := x.Bytes()
+		copy(buf[33-len(xb):], xb)
+		return new(big.Int).SetBytes(buf)
+	}
+
+	tests := []struct {
+		name string
+		x    *big.Int
+		want bool
+	}{
+		{"should accept base point", base, true},
+		{"should accept negated base point", withPrefix(2+byte(1-params.Gy.Bit(0)), params.Gx), true},
+		{"should reject identity", big.NewInt(0), false},
+		{"should reject nil", nil, false},
+		{"should reject uncompressed prefix", withPrefix(4, params.Gx), false},
+		{"should reject x = p", withPrefix(2, params.P), false},
+		{"should reject x without point", withPrefix(2, big.NewInt(1)), false},
+		{"should reject short encoding", new(big.Int).Rsh(base, 8), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.IsElement(tt.x); got != tt.want {
+				t.Errorf("IsElement(%x) = %v, want %v", tt.x, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCurveGroup_Exp(t *testing.T) {
+	g := newP256()
+	x, err := g.RandomElement()
+	if err != nil {
+		t.Fatalf("RandomElement() error = %v", err)
+	}
+
+	t.Run("should multiply by adding", func(t *testing.T) {
+		if got, want := g.Exp(x, big.NewInt(3)), g.Mul(g.Mul(x, x), x); got.Cmp(want) != 0 {
+			t.Errorf("Exp() = %x, want %x", got, want)
+		}
+	})
+
+	t.Run("should return the identity for x**q", func(t *testing.T) {
+		if got := g.Exp(x, g.Order()); g.IsElement(got) {
+			t.Errorf("Exp() = %x, want identity", got)
+		}
+	})
+
+	t.Run("should return the identity for x * x**(q-1)", func(t *testing.T) {
+		inv := g.Exp(x, new(big.Int).Sub(g.Order(), one))
+		if got := g.Mul(x, inv); g.IsElement(got) {
+			t.Errorf("Mul() = %x, want identity", got)
+		}
+	})
+}
@@ -0,0 +1,354 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrUnknownGroup is returned when a group name is not one of the vetted groups.
+	ErrUnknownGroup = errors.New("unknown group")
+	// ErrInvalidGroup is returned when group parameters do not describe a safe prime group.
+	ErrInvalidGroup = errors.New("invalid group")
+	// ErrUnknownHashVersion is returned for a hash to group version this version does not support.
+	ErrUnknownHashVersion = errors.New("unknown hash version")
+)
+
+// Versions of the mapping of passwords into safe prime groups. Changing the mapping
+// changes every derived password, therefore clients keep the version of each user.
+const (
+	// HashVersionLegacy maps with LegacyHashInGroup and ignores the domain separation tag.
+	HashVersionLegacy = 0
+	// HashVersionXMD maps with HashInGroup based on expand_message_xmd.
+	HashVersionXMD = 1
+	// HashVersionCurrent is the version of newly registered users.
+	HashVersionCurrent = HashVersionXMD
+)
+
+// Group is a prime order group all Online SPHINX operations happen in.
+// Elements are represented as *big.Int to fit the contract, points on elliptic curves
+// by their compressed SEC 1 encoding. Exp and Mul expect elements, validate untrusted
+// values with IsElement first.
+type Group interface {
+	// Name identifies the group between client and service.
+	Name() string
+	// Order returns the prime order q of the group.
+	Order() *big.Int
+	// Bits returns the bit length of the underlying field, i.e. of p.
+	Bits() int
+	// Generator returns a fixed generator of the group.
+	Generator() *big.Int
+	// Encode returns the fixed length encoding of element x used in transcripts.
+	Encode(x *big.Int) []byte
+	// IsElement reports whether x encodes a group element other than the identity.
+	IsElement(x *big.Int) bool
+	// Exp returns x**k, which is the scalar multiplication k*x on elliptic curves.
+	Exp(x, k *big.Int) *big.Int
+	// Mul returns x*y, which is the point addition x+y on elliptic curves.
+	Mul(x, y *big.Int) *big.Int
+	// HashToGroup deterministically maps msg to a group element, dst separates domains.
+	HashToGroup(msg, dst []byte) *big.Int
+	// RandomElement returns a uniformly random group element other than the identity.
+	RandomElement() (*big.Int, error)
+	// RandomScalar returns a uniformly random scalar in [1, q).
+	RandomScalar() (*big.Int, error)
+}
+
+// Blind returns x**r for a fresh random scalar r, together with r to unblind it later.
+func Blind(g Group, x *big.Int) (b, r *big.Int, err error) {
+	r, err = g.RandomScalar()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Blind: failed to generate blinding scalar")
+	}
+	return g.Exp(x, r), r, nil
+}
+
+// Unblind returns x**(1/r), i.e. removes the blinding scalar r from x.
+func Unblind(g Group, x, r *big.Int) *big.Int {
+	return g.Exp(x, new(big.Int).ModInverse(r, g.Order()))
+}
+
+// ModPGroup is the prime order q subgroup of quadratic residues in Z_p^*,
+// where p = 2q + 1 is a safe prime.
+type ModPGroup struct {
+	name   string
+	q      *big.Int
+	legacy bool // hash to group with HashVersionLegacy
+}
+
+// Name of the group.
+func (g ModPGroup) Name() string {
+	return g.name
+}
+
+// Order returns q.
+func (g ModPGroup) Order() *big.Int {
+	return g.q
+}
+
+// P returns the safe prime modulus 2q + 1.
+func (g ModPGroup) P() *big.Int {
+	p := new(big.Int).Mul(two, g.q)
+	return p.Add(p, one)
+}
+
+// Bits returns the bit length of p.
+func (g ModPGroup) Bits() int {
+	return g.P().BitLen()
+}
+
+// Generator returns 4 = 2**2, which generates the quadratic residues of a safe prime.
+func (g ModPGroup) Generator() *big.Int {
+	return big.NewInt(4)
+}
+
+// Encode returns x as big endian byte string of the length of p.
+func (g ModPGroup) Encode(x *big.Int) []byte {
+	return fixedBytes(x, (g.Bits()+7)/8)
+}
+
+// IsElement reports whether x is a group element other than the identity,
+// i.e. 1 < x < p-1 and x**q mod p = 1. Elements of small order are rejected.
+func (g ModPGroup) IsElement(x *big.Int) bool {
+	if x == nil || g.q == nil {
+		return false
+	}
+	p := g.P()
+	pm1 := new(big.Int).Sub(p, one)
+	if x.Cmp(one) <= 0 || x.Cmp(pm1) >= 0 {
+		return false
+	}
+	return new(big.Int).Exp(x, g.q, p).Cmp(one) == 0
+}
+
+// Exp returns x**k mod p.
+func (g ModPGroup) Exp(x, k *big.Int) *big.Int {
+	return ExpInGroup(x, k, g.q)
+}
+
+// Mul returns x*y mod p.
+func (g ModPGroup) Mul(x, y *big.Int) *big.Int {
+	r := new(big.Int).Mul(x, y)
+	return r.Mod(r, g.P())
+}
+
+// HashToGroup maps msg with HashInGroup and SHA-256, or with LegacyHashInGroup
+// ignoring dst for groups of HashVersionLegacy. It returns zero, which is not
+// an element, if p is too long to be expanded by SHA-256.
+func (g ModPGroup) HashToGroup(msg, dst []byte) *big.Int {
+	if g.legacy {
+		return LegacyHashInGroup(string(msg), sha256.New, g.q)
+	}
+	e, err := HashInGroup(msg, dst, sha256.New, g.q)
+	if err != nil {
+		return new(big.Int)
+	}
+	return e
+}
+
+// RandomElement returns a uniformly random group element other than the identity.
+func (g ModPGroup) RandomElement() (*big.Int, error) {
+	pm3 := new(big.Int).Sub(g.P(), big.NewInt(3))
+	for {
+		r, err := rand.Int(rand.Reader, pm3)
+		if err != nil {
+			return nil, errors.Wrap(err, "RandomElement: failed to generate random int")
+		}
+		// r + 2 in [2, p-2] squared is a quadratic residue
+		e := ExpInGroup(r.Add(r, two), two, g.q)
+		if g.IsElement(e) {
+			return e, nil
+		}
+	}
+}
+
+// RandomScalar returns a uniformly random exponent in [1, q).
+func (g ModPGroup) RandomScalar() (*big.Int, error) {
+	return randomScalar(g.q)
+}
+
+// fixedBytes returns the big endian encoding of x left padded to size bytes.
+func fixedBytes(x *big.Int, size int) []byte {
+	buf := make([]byte, size)
+	xb := x.Bytes()
+	if len(xb) > size {
+		return xb
+	}
+	copy(buf[size-len(xb):], xb)
+	return buf
+}
+
+func randomScalar(q *big.Int) (*big.Int, error) {
+	qm1 := new(big.Int).Sub(q, one)
+	r, err := rand.Int(rand.Reader, qm1)
+	if err != nil {
+		return nil, errors.Wrap(err, "RandomScalar: failed to generate random int")
+	}
+	return r.Add(r, one), nil
+}
+
+// WithHashVersion returns g hashing to group with the given version.
+// Elliptic curve groups always hash to curve as specified by RFC 9380 and ignore the version.
+func WithHashVersion(g Group, version int) (Group, error) {
+	if version != HashVersionLegacy && version != HashVersionXMD {
+		return nil, errors.Wrapf(ErrUnknownHashVersion, "WithHashVersion: %d", version)
+	}
+	if m, ok := g.(ModPGroup); ok {
+		m.legacy = version == HashVersionLegacy
+		return m, nil
+	}
+	return g, nil
+}
+
+// GroupByName returns one of the vetted groups, either P-256 or
+// one of the RFC 3526 or RFC 7919 groups.
+func GroupByName(name string) (Group, error) {
+	if name == P256 {
+		return newP256(), nil
+	}
+	h, ok := groups[name]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownGroup, "GroupByName: %s", name)
+	}
+	p, _ := new(big.Int).SetString(h, 16)
+	return ModPGroup{name: name, q: p.Rsh(p, 1)}, nil
+}
+
+// GroupNames returns the names of all vetted groups.
+func GroupNames() []string {
+	names := make([]string, 0, len(groups)+1)
+	names = append(names, P256)
+	for n := range groups {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewGroup returns the group given by q, which is either a vetted group with the same q
+// or a custom group which is checked to be a safe prime group.
+func NewGroup(name string, q *big.Int) (Group, error) {
+	if known, err := GroupByName(name); err == nil {
+		if q == nil || known.Order().Cmp(q) != 0 {
+			return nil, errors.Wrapf(ErrInvalidGroup, "NewGroup: q does not match %s", name)
+		}
+		return known, nil
+	}
+
+	g := ModPGroup{name: name, q: q}
+	if q == nil || q.Cmp(big.NewInt(5)) < 0 || !q.ProbablyPrime(20) || !g.P().ProbablyPrime(20) {
+		return nil, errors.Wrapf(ErrInvalidGroup, "NewGroup: %s is not a safe prime group", name)
+	}
+	return g, nil
+}
+
+// GenerateGroup generates a custom safe prime group where p has the given bit length.
+// It is slow for large bit lengths, use GroupByName instead.
+func GenerateGroup(name string, bits int) (Group, error) {
+	if bits < 4 {
+		return nil, errors.Wrapf(ErrInvalidGroup, "GenerateGroup: %d bits too short", bits)
+	}
+	for {
+		q, err := rand.Prime(rand.Reader, bits-1)
+		if err != nil {
+			return nil, errors.Wrap(err, "GenerateGroup: failed to generate prime")
+		}
+		g := ModPGroup{name: name, q: q}
+		if q.Cmp(big.NewInt(5)) >= 0 && g.P().ProbablyPrime(20) {
+			return g, nil
+		}
+	}
+}
+
+// groups contains the safe prime p of all vetted groups in hex.
+var groups = map[string]string{
+	// RFC 3526 1536-bit MODP group
+	"modp1536": "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804F1746C08CA237327FFFFFFFFFFFFFFFF",
+	// RFC 3526 2048-bit MODP group
+	"modp2048": "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+		"3995497CEA956AE515D2261898FA051015728E5A8AACAA68FFFFFFFFFFFFFFFF",
+	// RFC 3526 3072-bit MODP group
+	"modp3072": "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+		"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+		"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+		"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+		"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+		"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF",
+	// RFC 3526 4096-bit MODP group
+	"modp4096": "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+		"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+		"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+		"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+		"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+		"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A92108011A723C12A787E6D7" +
+		"88719A10BDBA5B2699C327186AF4E23C1A946834B6150BDA2583E9CA2AD44CE8" +
+		"DBBBC2DB04DE8EF92E8EFC141FBECAA6287C59474E6BC05D99B2964FA090C3A2" +
+		"233BA186515BE7ED1F612970CEE2D7AFB81BDD762170481CD0069127D5B05AA9" +
+		"93B4EA988D8FDDC186FFB7DC90A6C08F4DF435C934063199FFFFFFFFFFFFFFFF",
+	// RFC 7919 ffdhe2048 group
+	"ffdhe2048": "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B423861285C97FFFFFFFFFFFFFFFF",
+	// RFC 7919 ffdhe3072 group
+	"ffdhe3072": "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B4238611FCFDCDE355B3B6519035B" +
+		"BC34F4DEF99C023861B46FC9D6E6C9077AD91D2691F7F7EE598CB0FAC186D91C" +
+		"AEFE130985139270B4130C93BC437944F4FD4452E2D74DD364F2E21E71F54BFF" +
+		"5CAE82AB9C9DF69EE86D2BC522363A0DABC521979B0DEADA1DBF9A42D5C4484E" +
+		"0ABCD06BFA53DDEF3C1B20EE3FD59D7C25E41D2B66C62E37FFFFFFFFFFFFFFFF",
+	// RFC 7919 ffdhe4096 group
+	"ffdhe4096": "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B4238611FCFDCDE355B3B6519035B" +
+		"BC34F4DEF99C023861B46FC9D6E6C9077AD91D2691F7F7EE598CB0FAC186D91C" +
+		"AEFE130985139270B4130C93BC437944F4FD4452E2D74DD364F2E21E71F54BFF" +
+		"5CAE82AB9C9DF69EE86D2BC522363A0DABC521979B0DEADA1DBF9A42D5C4484E" +
+		"0ABCD06BFA53DDEF3C1B20EE3FD59D7C25E41D2B669E1EF16E6F52C3164DF4FB" +
+		"7930E9E4E58857B6AC7D5F42D69F6D187763CF1D5503400487F55BA57E31CC7A" +
+		"7135C886EFB4318AED6A1E012D9E6832A907600A918130C46DC778F971AD0038" +
+		"092999A333CB8B7A1A1DB93D7140003C2A4ECEA9F98D0ACC0A8291CDCEC97DCF" +
+		"8EC9B55A7F88A46B4DB5A851F44182E1C68A007E5E655F6AFFFFFFFFFFFFFFFF",
+}
@@ -0,0 +1,221 @@
+package crypto
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestGroupByName(t *testing.T) {
+	for _, name := range GroupNames() {
+		t.Run("should be a prime order group "+name, func(t *testing.T) {
+			g, err := GroupByName(name)
+			if err != nil {
+				t.Fatalf("GroupByName() error = %v", err)
+			}
+			if g.Name() != name || !g.Order().ProbablyPrime(10) {
+				t.Errorf("GroupByName() %s is not a prime order group", name)
+			}
+			if m, ok := g.(ModPGroup); ok && !m.P().ProbablyPrime(10) {
+				t.Errorf("GroupByName() %s is not a safe prime group", name)
+			}
+		})
+	}
+
+	t.Run("should return ErrUnknownGroup", func(t *testing.T) {
+		_, err := GroupByName("modp8")
+		if errors.Cause(err) != ErrUnknownGroup {
+			t.Errorf("GroupByName() error = %v, wantErr %v", err, ErrUnknownGroup)
+		}
+	})
+}
+
+func TestGroup_IsElement(t *testing.T) {
+	g := ModPGroup{name: "test", q: big.NewInt(11)}
+	tests := []struct {
+		name string
+		x    *big.Int
+		want bool
+	}{
+		{"should accept quadratic residue 4", big.NewInt(4), true},
+		{"should accept quadratic residue 2", big.NewInt(2), true},
+		{"should reject non residue 5", big.NewInt(5), false},
+		{"should reject identity", big.NewInt(1), false},
+		{"should reject zero", big.NewInt(0), false},
+		{"should reject p-1 of order 2", big.NewInt(22), false},
+		{"should reject p", big.NewInt(23), false},
+		{"should reject residue greater than p", big.NewInt(27), false},
+		{"should reject nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.IsElement(tt.x); got != tt.want {
+				t.Errorf("IsElement(%v) = %v, want %v", tt.x, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroup_RandomElement(t *testing.T) {
+	g := ModPGroup{name: "test", q: big.NewInt(11)}
+	for i := 0; i < 100; i++ {
+		e, err := g.RandomElement()
+		if err != nil {
+			t.Fatalf("RandomElement() error = %v", err)
+		}
+		if !g.IsElement(e) {
+			t.Errorf("RandomElement() = %v is not an element", e)
+		}
+	}
+}
+
+func TestWithHashVersion(t *testing.T) {
+	g, err := GroupByName("modp1536")
+	if err != nil {
+		t.Fatalf("GroupByName() error = %v", err)
+	}
+	msg, dst := []byte("password"), []byte("dst")
+
+	t.Run("should hash new groups with HashInGroup", func(t *testing.T) {
+		want, _ := HashInGroup(msg, dst, sha256.New, g.Order())
+		if got := g.HashToGroup(msg, dst); got.Cmp(want) != 0 {
+			t.Errorf("HashToGroup() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("should hash legacy groups with LegacyHashInGroup", func(t *testing.T) {
+		legacy, err := WithHashVersion(g, HashVersionLegacy)
+		if err != nil {
+			t.Fatalf("WithHashVersion() error = %v", err)
+		}
+		want := LegacyHashInGroup(string(msg), sha256.New, g.Order())
+		if got := legacy.HashToGroup(msg, dst); got.Cmp(want) != 0 {
+			t.Errorf("HashToGroup() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("should keep hash to curve", func(t *testing.T) {
+		p256, _ := GroupByName(P256)
+		legacy, err := WithHashVersion(p256, HashVersionLegacy)
+		if err != nil {
+			t.Fatalf("WithHashVersion() error = %v", err)
+		}
+		if legacy.HashToGroup(msg, dst).Cmp(p256.HashToGroup(msg, dst)) != 0 {
+			t.Errorf("HashToGroup() changed for %s", P256)
+		}
+	})
+
+	t.Run("should return ErrUnknownHashVersion", func(t *testing.T) {
+		if _, err := WithHashVersion(g, 42); errors.Cause(err) != ErrUnknownHashVersion {
+			t.Errorf("WithHashVersion() error = %v, wantErr %v", err, ErrUnknownHashVersion)
+		}
+	})
+}
+
+func TestNewGroup(t *testing.T) {
+	modp2048, err := GroupByName("modp2048")
+	if err != nil {
+		t.Fatalf("GroupByName() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		group   string
+		q       *big.Int
+		wantErr bool
+	}{
+		{"should accept vetted group", "modp2048", modp2048.Order(), false},
+		{"should accept p256", P256, elliptic.P256().Params().N, false},
+		{"should reject p256 with other q", P256, big.NewInt(11), true},
+		{"should reject vetted group name with other q", "modp2048", big.NewInt(11), true},
+		{"should accept custom safe prime group", "custom", big.NewInt(11), false},
+		{"should reject prime q with composite p", "custom", big.NewInt(13), true},
+		{"should reject composite q", "custom", big.NewInt(15), true},
+		{"should reject tiny group", "custom", big.NewInt(2), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewGroup(tt.group, tt.q)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewGroup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateGroup(t *testing.T) {
+	g, err := GenerateGroup("custom", 32)
+	if err != nil {
+		t.Fatalf("GenerateGroup() error = %v", err)
+	}
+	if g.Bits() != 32 {
+		t.Errorf("GenerateGroup() p has %d bits, want 32", g.Bits())
+	}
+	if _, err := NewGroup(g.Name(), g.Order()); err != nil {
+		t.Errorf("NewGroup() error = %v", err)
+	}
+}
+
+func TestBlind(t *testing.T) {
+	for _, name := range []string{"modp1536", P256} {
+		t.Run("should unblind to the unblinded value in "+name, func(t *testing.T) {
+			g, err := GroupByName(name)
+			if err != nil {
+				t.Fatalf("GroupByName() error = %v", err)
+			}
+			x := g.HashToGroup([]byte("password"), []byte("test"))
+			k, err := g.RandomScalar()
+			if err != nil {
+				t.Fatalf("RandomScalar() error = %v", err)
+			}
+
+			b, r, err := Blind(g, x)
+			if err != nil {
+				t.Fatalf("Blind() error = %v", err)
+			}
+			if b.Cmp(x) == 0 {
+				t.Errorf("Blind() did not change x")
+			}
+
+			got := Unblind(g, g.Exp(b, k), r)
+			if want := g.Exp(x, k); got.Cmp(want) != 0 {
+				t.Errorf("Unblind() = %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func BenchmarkGroup_Exp_FFDHE2048(b *testing.B) {
+	benchmarkGroupExp(b, "ffdhe2048")
+}
+
+func BenchmarkGroup_Exp_FFDHE3072(b *testing.B) {
+	benchmarkGroupExp(b, "ffdhe3072")
+}
+
+func BenchmarkGroup_Exp_P256(b *testing.B) {
+	benchmarkGroupExp(b, P256)
+}
+
+func benchmarkGroupExp(b *testing.B, name string) {
+	g, err := GroupByName(name)
+	if err != nil {
+		b.Fatalf("GroupByName() error = %v", err)
+	}
+	x, err := g.RandomElement()
+	if err != nil {
+		b.Fatalf("RandomElement() error = %v", err)
+	}
+	k, err := g.RandomScalar()
+	if err != nil {
+		b.Fatalf("RandomScalar() error = %v", err)
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		g.Exp(x, k)
+	}
+}
@@ -0,0 +1,188 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// Modes of RFC 9497.
+const (
+	// ModeOPRF is the base mode without proof.
+	ModeOPRF byte = 0x00
+	// ModeVOPRF is the verifiable mode, where the server proves the evaluation with its public key.
+	ModeVOPRF byte = 0x01
+)
+
+// ErrProofInvalid is returned when a DLEQ proof does not verify against the public key.
+var ErrProofInvalid = errors.New("invalid proof")
+
+// OPRF implements the OPRF and VOPRF protocols of RFC 9497 with SHA-256.
+// On P-256 it is the P256-SHA256 ciphersuite, on safe prime groups the same
+// construction is used with the group name as identifier.
+type OPRF struct {
+	group   Group
+	context []byte // contextString of RFC 9497 section 3.1
+}
+
+// Proof is a DLEQ proof of RFC 9497 section 2.2.
+type Proof struct {
+	C *big.Int
+	S *big.Int
+}
+
+// NewOPRF returns the OPRF of group g in the given mode.
+func NewOPRF(g Group, mode byte) OPRF {
+	id := g.Name() + "-SHA256"
+	if g.Name() == P256 {
+		id = "P256-SHA256"
+	}
+	return OPRF{
+		group:   g,
+		context: append([]byte("OPRFV1-"), append([]byte{mode, '-'}, id...)...),
+	}
+}
+
+// HashToGroup maps input to an element with the domain separation tag of the OPRF.
+func (o OPRF) HashToGroup(input []byte) *big.Int {
+	return o.group.HashToGroup(input, o.dst("HashToGroup-"))
+}
+
+// HashToScalar maps input to a scalar with the domain separation tag of the OPRF.
+func (o OPRF) HashToScalar(input []byte) *big.Int {
+	q := o.group.Order()
+	l := (q.BitLen() + 128 + 7) / 8
+	return hashToField(sha256.New, input, o.dst("HashToScalar-"), q, 1, l)[0]
+}
+
+// PublicKey returns the public key sk*G of the secret key sk.
+func (o OPRF) PublicKey(sk *big.Int) *big.Int {
+	return o.group.Exp(o.group.Generator(), sk)
+}
+
+// Blind hashes input into the group and blinds it with a fresh random scalar.
+func (o OPRF) Blind(input []byte) (blinded, blind *big.Int, err error) {
+	blind, err = o.group.RandomScalar()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Blind: failed to generate blinding scalar")
+	}
+	return o.group.Exp(o.HashToGroup(input), blind), blind, nil
+}
+
+// BlindEvaluate evaluates the blinded element with secret key sk.
+func (o OPRF) BlindEvaluate(sk, blinded *big.Int) *big.Int {
+	return o.group.Exp(blinded, sk)
+}
+
+// Unblind removes the blinding scalar from the evaluated element.
+func (o OPRF) Unblind(evaluated, blind *big.Int) *big.Int {
+	return Unblind(o.group, evaluated, blind)
+}
+
+// Finalize unblinds the evaluated element and hashes it together with input into the OPRF output.
+func (o OPRF) Finalize(input []byte, blind, evaluated *big.Int) []byte {
+	h := sha256.New()
+	h.Write(lengthPrefixed(input, o.group.Encode(o.Unblind(evaluated, blind))))
+	h.Write([]byte("Finalize"))
+	return h.Sum(nil)
+}
+
+// Prove generates a DLEQ proof that all evaluated elements are blinded elements
+// evaluated with the secret key sk of the public key sk*G.
+func (o OPRF) Prove(sk *big.Int, blinded, evaluated []*big.Int) (Proof, error) {
+	r, err := o.group.RandomScalar()
+	if err != nil {
+		return Proof{}, errors.Wrap(err, "Prove: failed to generate random scalar")
+	}
+	return o.prove(sk, blinded, evaluated, r), nil
+}
+
+func (o OPRF) prove(sk *big.Int, blinded, evaluated []*big.Int, r *big.Int) Proof {
+	g := o.group
+	pk := o.PublicKey(sk)
+	m, _ := o.composites(pk, blinded, evaluated)
+	z := g.Exp(m, sk)
+
+	c := o.challenge(pk, m, z, g.Exp(g.Generator(), r), g.Exp(m, r))
+
+	s := new(big.Int).Mul(c, sk)
+	s.Sub(r, s)
+	s.Mod(s, g.Order())
+	return Proof{C: c, S: s}
+}
+
+// Verify returns ErrProofInvalid unless the proof shows that all evaluated elements
+// are blinded elements evaluated with the secret key of pk.
+func (o OPRF) Verify(pk *big.Int, blinded, evaluated []*big.Int, p Proof) error {
+	g := o.group
+	if p.C == nil || p.S == nil || len(blinded) == 0 || len(blinded) != len(evaluated) {
+		return ErrProofInvalid
+	}
+	if !g.IsElement(pk) {
+		return errors.Wrap(ErrProofInvalid, "public key is not a group element")
+	}
+	for i := range blinded {
+		if !g.IsElement(blinded[i]) || !g.IsElement(evaluated[i]) {
+			return errors.Wrap(ErrProofInvalid, "element is not a group element")
+		}
+	}
+
+	m, z := o.composites(pk, blinded, evaluated)
+	t2 := g.Mul(g.Exp(g.Generator(), p.S), g.Exp(pk, p.C))
+	t3 := g.Mul(g.Exp(m, p.S), g.Exp(z, p.C))
+	if !g.IsElement(t2) || !g.IsElement(t3) {
+		return ErrProofInvalid
+	}
+
+	if o.challenge(pk, m, z, t2, t3).Cmp(p.C) != 0 {
+		return ErrProofInvalid
+	}
+	return nil
+}
+
+// composites returns the random linear combinations M of blinded and Z of evaluated elements,
+// ComputeComposites of RFC 9497 section 2.2.1.
+func (o OPRF) composites(pk *big.Int, blinded, evaluated []*big.Int) (m, z *big.Int) {
+	g := o.group
+	h := sha256.New()
+	h.Write(lengthPrefixed(g.Encode(pk), o.dst("Seed-")))
+	seed := h.Sum(nil)
+
+	for i := range blinded {
+		transcript := lengthPrefixed(seed)
+		transcript = append(transcript, byte(i>>8), byte(i))
+		transcript = append(transcript, lengthPrefixed(g.Encode(blinded[i]), g.Encode(evaluated[i]))...)
+		transcript = append(transcript, "Composite"...)
+		d := o.HashToScalar(transcript)
+
+		mi, zi := g.Exp(blinded[i], d), g.Exp(evaluated[i], d)
+		if m == nil {
+			m, z = mi, zi
+			continue
+		}
+		m, z = g.Mul(m, mi), g.Mul(z, zi)
+	}
+	return m, z
+}
+
+// challenge returns the Fiat-Shamir challenge of the DLEQ proof.
+func (o OPRF) challenge(pk, m, z, t2, t3 *big.Int) *big.Int {
+	g := o.group
+	transcript := lengthPrefixed(g.Encode(pk), g.Encode(m), g.Encode(z), g.Encode(t2), g.Encode(t3))
+	return o.HashToScalar(append(transcript, "Challenge"...))
+}
+
+func (o OPRF) dst(prefix string) []byte {
+	return append([]byte(prefix), o.context...)
+}
+
+// lengthPrefixed concatenates all values, each prefixed with its length as two bytes.
+func lengthPrefixed(values ...[]byte) []byte {
+	var buf []byte
+	for _, v := range values {
+		buf = append(buf, byte(len(v)>>8), byte(len(v)))
+		buf = append(buf, v...)
+	}
+	return buf
+}
@@ -0,0 +1,187 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func hexInts(s string) []*big.Int {
+	var ns []*big.Int
+	for _, h := range strings.Split(s, ",") {
+		ns = append(ns, hexInt(h))
+	}
+	return ns
+}
+
+// test vectors of RFC 9497 appendix A.3.1
+func TestOPRF_P256(t *testing.T) {
+	g, err := GroupByName(P256)
+	if err != nil {
+		t.Fatalf("GroupByName() error = %v", err)
+	}
+	blind := "3338fa65ec36e0290022b48eb562889d89dbfa691d1cde91517fa222ed7ad364"
+
+	tests := []struct {
+		name      string
+		mode      byte
+		sk        string
+		pk        string
+		input     string
+		blinded   string
+		evaluated string
+		output    string
+		proof     string
+		r         string
+	}{
+		{
+			name:      "OPRF vector 1",
+			mode:      ModeOPRF,
+			sk:        "159749d750713afe245d2d39ccfaae8381c53ce92d098a9375ee70739c7ac0bf",
+			input:     "00",
+			blinded:   "03723a1e5c09b8b9c18d1dcbca29e8007e95f14f4732d9346d490ffc195110368d",
+			evaluated: "030de02ffec47a1fd53efcdd1c6faf5bdc270912b8749e783c7ca75bb412958832",
+			output:    "a0b34de5fa4c5b6da07e72af73cc507cceeb48981b97b7285fc375345fe495dd",
+		},
+		{
+			name:      "OPRF vector 2",
+			mode:      ModeOPRF,
+			sk:        "159749d750713afe245d2d39ccfaae8381c53ce92d098a9375ee70739c7ac0bf",
+			input:     "5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a",
+			blinded:   "03cc1df781f1c2240a64d1c297b3f3d16262ef5d4cf102734882675c26231b0838",
+			evaluated: "03a0395fe3828f2476ffcd1f4fe540e5a8489322d398be3c4e5a869db7fcb7c52c",
+			output:    "c748ca6dd327f0ce85f4ae3a8cd6d4d5390bbb804c9e12dcf94f853fece3dcce",
+		},
+		{
+			name:      "VOPRF vector 1",
+			mode:      ModeVOPRF,
+			sk:        "ca5d94c8807817669a51b196c34c1b7f8442fde4334a7121ae4736364312fca6",
+			pk:        "03e17e70604bcabe198882c0a1f27a92441e774224ed9c702e51dd17038b102462",
+			input:     "00",
+			blinded:   "02dd05901038bb31a6fae01828fd8d0e49e35a486b5c5d4b4994013648c01277da",
+			evaluated: "0209f33cab60cf8fe69239b0afbcfcd261af4c1c5632624f2e9ba29b90ae83e4a2",
+			output:    "0412e8f78b02c415ab3a288e228978376f99927767ff37c5718d420010a645a1",
+			proof:     "e7c2b3c5c954c035949f1f74e6bce2ed539a3be267d1481e9ddb178533df4c2664f69d065c604a4fd953e100b856ad83804eb3845189babfa5a702090d6fc5fa",
+			r:         "f9db001266677f62c095021db018cd8cbb55941d4073698ce45c405d1348b7b1",
+		},
+		{
+			name:      "VOPRF vector 2",
+			mode:      ModeVOPRF,
+			sk:        "ca5d94c8807817669a51b196c34c1b7f8442fde4334a7121ae4736364312fca6",
+			pk:        "03e17e70604bcabe198882c0a1f27a92441e774224ed9c702e51dd17038b102462",
+			input:     "5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a5a",
+			blinded:   "03cd0f033e791c4d79dfa9c6ed750f2ac009ec46cd4195ca6fd3800d1e9b887dbd",
+			evaluated: "030d2985865c693bf7af47ba4d3a3813176576383d19aff003ef7b0784a0d83cf1",
+			output:    "771e10dcd6bcd3664e23b8f2a710cfaaa8357747c4a8cbba03133967b5c24f18",
+			proof:     "2787d729c57e3d9512d3aa9e8708ad226bc48e0f1750b0767aaff73482c44b8d2873d74ec88aebd3504961acea16790a05c542d9fbff4fe269a77510db00abab",
+			r:         "f9db001266677f62c095021db018cd8cbb55941d4073698ce45c405d1348b7b1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := NewOPRF(g, tt.mode)
+			sk := hexInt(tt.sk)
+
+			blinded := g.Exp(o.HashToGroup(unhex(tt.input)), hexInt(blind))
+			if blinded.Cmp(hexInt(tt.blinded)) != 0 {
+				t.Errorf("Blind() = %x, want %s", blinded, tt.blinded)
+			}
+
+			evaluated := o.BlindEvaluate(sk, blinded)
+			if evaluated.Cmp(hexInt(tt.evaluated)) != 0 {
+				t.Errorf("BlindEvaluate() = %x, want %s", evaluated, tt.evaluated)
+			}
+
+			if output := o.Finalize(unhex(tt.input), hexInt(blind), evaluated); !bytes.Equal(output, unhex(tt.output)) {
+				t.Errorf("Finalize() = %x, want %s", output, tt.output)
+			}
+
+			if tt.mode != ModeVOPRF {
+				return
+			}
+			pk := o.PublicKey(sk)
+			if pk.Cmp(hexInt(tt.pk)) != 0 {
+				t.Errorf("PublicKey() = %x, want %s", pk, tt.pk)
+			}
+
+			p := o.prove(sk, []*big.Int{blinded}, []*big.Int{evaluated}, hexInt(tt.r))
+			want := Proof{C: new(big.Int).SetBytes(unhex(tt.proof)[:32]), S: new(big.Int).SetBytes(unhex(tt.proof)[32:])}
+			if p.C.Cmp(want.C) != 0 || p.S.Cmp(want.S) != 0 {
+				t.Errorf("Prove() = %x %x, want %s", p.C, p.S, tt.proof)
+			}
+			if err := o.Verify(pk, []*big.Int{blinded}, []*big.Int{evaluated}, want); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+		})
+	}
+
+	t.Run("VOPRF batch vector", func(t *testing.T) {
+		o := NewOPRF(g, ModeVOPRF)
+		sk := hexInt("ca5d94c8807817669a51b196c34c1b7f8442fde4334a7121ae4736364312fca6")
+		blinded := hexInts("02dd05901038bb31a6fae01828fd8d0e49e35a486b5c5d4b4994013648c01277da,03462e9ae64cae5b83ba98a6b360d942266389ac369b923eb3d557213b1922f8ab")
+		evaluated := hexInts("0209f33cab60cf8fe69239b0afbcfcd261af4c1c5632624f2e9ba29b90ae83e4a2,02bb24f4d838414aef052a8f044a6771230ca69c0a5677540fff738dd31bb69771")
+		proof := unhex("bdcc351707d02a72ce49511c7db990566d29d6153ad6f8982fad2b435d6ce4d60da1e6b3fa740811bde34dd4fe0aa1b5fe6600d0440c9ddee95ea7fad7a60cf2")
+
+		p := o.prove(sk, blinded, evaluated, hexInt("350e8040f828bf6ceca27405420cdf3d63cb3aef005f40ba51943c8026877963"))
+		if !bytes.Equal(append(fixedBytes(p.C, 32), fixedBytes(p.S, 32)...), proof) {
+			t.Errorf("Prove() = %x %x, want %x", p.C, p.S, proof)
+		}
+		if err := o.Verify(o.PublicKey(sk), blinded, evaluated, p); err != nil {
+			t.Errorf("Verify() error = %v", err)
+		}
+	})
+}
+
+func TestOPRF_Verify(t *testing.T) {
+	for _, name := range []string{"modp1536", P256} {
+		g, err := GroupByName(name)
+		if err != nil {
+			t.Fatalf("GroupByName() error = %v", err)
+		}
+		o := NewOPRF(g, ModeVOPRF)
+		sk, _ := g.RandomScalar()
+		other, _ := g.RandomScalar()
+		blinded, _, err := o.Blind([]byte("password"))
+		if err != nil {
+			t.Fatalf("Blind() error = %v", err)
+		}
+		evaluated := o.BlindEvaluate(sk, blinded)
+		p, err := o.Prove(sk, []*big.Int{blinded}, []*big.Int{evaluated})
+		if err != nil {
+			t.Fatalf("Prove() error = %v", err)
+		}
+
+		t.Run("should verify proof in "+name, func(t *testing.T) {
+			if err := o.Verify(o.PublicKey(sk), []*big.Int{blinded}, []*big.Int{evaluated}, p); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+		})
+
+		t.Run("should reject proof for another public key in "+name, func(t *testing.T) {
+			err := o.Verify(o.PublicKey(other), []*big.Int{blinded}, []*big.Int{evaluated}, p)
+			if errors.Cause(err) != ErrProofInvalid {
+				t.Errorf("Verify() error = %v, wantErr %v", err, ErrProofInvalid)
+			}
+		})
+
+		t.Run("should reject evaluation with another key in "+name, func(t *testing.T) {
+			forged := o.BlindEvaluate(other, blinded)
+			err := o.Verify(o.PublicKey(sk), []*big.Int{blinded}, []*big.Int{forged}, p)
+			if errors.Cause(err) != ErrProofInvalid {
+				t.Errorf("Verify() error = %v, wantErr %v", err, ErrProofInvalid)
+			}
+		})
+	}
+}
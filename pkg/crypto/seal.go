@@ -0,0 +1,84 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrSealInvalid is returned when sealed data could not be opened,
+// because of a wrong passphrase or modified data.
+var ErrSealInvalid = errors.New("sealed data invalid or wrong passphrase")
+
+const (
+	sealVersion  = 1
+	sealSaltSize = 16
+	// scrypt parameters recommended for interactive logins in 2017
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// Seal encrypts and authenticates plaintext with a key derived from passphrase
+// using scrypt and AES-256-GCM. The result contains version, salt and nonce.
+func Seal(passphrase, plaintext []byte) ([]byte, error) {
+	salt := make([]byte, sealSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, errors.Wrap(err, "Seal: failed to generate salt")
+	}
+
+	aead, err := newSealAEAD(passphrase, salt)
+	if err != nil {
+		return nil, errors.Wrap(err, "Seal: failed to create cipher")
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err, "Seal: failed to generate nonce")
+	}
+
+	header := append([]byte{sealVersion}, salt...)
+	header = append(header, nonce...)
+	return append(header, aead.Seal(nil, nonce, plaintext, header)...), nil
+}
+
+// Open decrypts data sealed by Seal with the same passphrase.
+func Open(passphrase, sealed []byte) ([]byte, error) {
+	if len(sealed) < 1+sealSaltSize || sealed[0] != sealVersion {
+		return nil, ErrSealInvalid
+	}
+	salt := sealed[1 : 1+sealSaltSize]
+
+	aead, err := newSealAEAD(passphrase, salt)
+	if err != nil {
+		return nil, errors.Wrap(err, "Open: failed to create cipher")
+	}
+
+	n := 1 + sealSaltSize + aead.NonceSize()
+	if len(sealed) < n+aead.Overhead() {
+		return nil, ErrSealInvalid
+	}
+
+	plaintext, err := aead.Open(nil, sealed[1+sealSaltSize:n], sealed[n:], sealed[:n])
+	if err != nil {
+		return nil, ErrSealInvalid
+	}
+	return plaintext, nil
+}
+
+func newSealAEAD(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,53 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSeal(t *testing.T) {
+	t.Run("should open sealed data with the same passphrase", func(t *testing.T) {
+		want := []byte("secret key material")
+
+		sealed, err := Seal([]byte("passphrase"), want)
+		if err != nil {
+			t.Fatalf("Seal() error = %v", err)
+		}
+		if bytes.Contains(sealed, want) {
+			t.Errorf("Seal() contains plaintext")
+		}
+
+		got, err := Open([]byte("passphrase"), sealed)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Open() = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("should not open sealed data with a wrong passphrase", func(t *testing.T) {
+		sealed, err := Seal([]byte("passphrase"), []byte("secret"))
+		if err != nil {
+			t.Fatalf("Seal() error = %v", err)
+		}
+
+		_, err = Open([]byte("wrong-passphrase"), sealed)
+		if err != ErrSealInvalid {
+			t.Errorf("Open() error = %v, wantErr %v", err, ErrSealInvalid)
+		}
+	})
+
+	t.Run("should not open modified sealed data", func(t *testing.T) {
+		sealed, err := Seal([]byte("passphrase"), []byte("secret"))
+		if err != nil {
+			t.Fatalf("Seal() error = %v", err)
+		}
+		sealed[len(sealed)-1] ^= 1
+
+		_, err = Open([]byte("passphrase"), sealed)
+		if err != ErrSealInvalid {
+			t.Errorf("Open() error = %v, wantErr %v", err, ErrSealInvalid)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package crypto
+
+import (
+	"hash"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// ExpandMessageXMD implements expand_message_xmd of RFC 9380 section 5.3.1.
+// It returns n uniformly random bytes derived from msg and the domain separation tag dst,
+// a dst longer than 255 bytes is hashed as described in section 5.3.3.
+func ExpandMessageXMD(newHash func() hash.Hash, msg, dst []byte, n int) ([]byte, error) {
+	h := newHash()
+	b := h.Size()
+	ell := (n + b - 1) / b
+	if ell > 255 || n > 65535 {
+		return nil, errors.Errorf("ExpandMessageXMD: %d bytes too long", n)
+	}
+	if len(dst) > 255 {
+		h.Write([]byte("H2C-OVERSIZE-DST-"))
+		h.Write(dst)
+		dst = h.Sum(nil)
+		h.Reset()
+	}
+	dstPrime := append(append([]byte{}, dst...), byte(len(dst)))
+
+	h.Write(make([]byte, h.BlockSize()))
+	h.Write(msg)
+	h.Write([]byte{byte(n >> 8), byte(n), 0})
+	h.Write(dstPrime)
+	b0 := h.Sum(nil)
+
+	h.Reset()
+	h.Write(b0)
+	h.Write([]byte{1})
+	h.Write(dstPrime)
+	bi := h.Sum(nil)
+
+	uniform := append(make([]byte, 0, ell*b), bi...)
+	for i := 2; i <= ell; i++ {
+		h.Reset()
+		for j := range bi {
+			bi[j] ^= b0[j]
+		}
+		h.Write(bi)
+		h.Write([]byte{byte(i)})
+		h.Write(dstPrime)
+		bi = h.Sum(nil)
+		uniform = append(uniform, bi...)
+	}
+	return uniform[:n], nil
+}
+
+// hashToField implements hash_to_field of RFC 9380 section 5.2 for fields of prime order p,
+// l is the number of bytes per element which has to provide k bits of security above log2(p).
+func hashToField(newHash func() hash.Hash, msg, dst []byte, p *big.Int, count, l int) []*big.Int {
+	uniform, err := ExpandMessageXMD(newHash, msg, dst, count*l)
+	if err != nil {
+		// count and l are constants of the hash to curve suite
+		panic(err)
+	}
+	u := make([]*big.Int, count)
+	for i := range u {
+		u[i] = new(big.Int).SetBytes(uniform[i*l : (i+1)*l])
+		u[i].Mod(u[i], p)
+	}
+	return u
+}
@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// test vectors of RFC 9380 appendix K.1
+func TestExpandMessageXMD(t *testing.T) {
+	dst := "QUUX-V01-CS02-with-expander-SHA256-128"
+	longDST := dst + "-long-DST-"
+	longDST += strings.Repeat("1", 256-len(longDST))
+
+	tests := []struct {
+		name string
+		dst  string
+		msg  string
+		n    int
+		want string
+	}{
+		{"should expand empty msg to 32 bytes", dst, "", 32, "68a985b87eb6b46952128911f2a4412bbc302a9d759667f87f7a21d803f07235"},
+		{"should expand abc to 32 bytes", dst, "abc", 32, "d8ccab23b5985ccea865c6c97b6e5b8350e794e603b4b97902f53a8a0d605615"},
+		{"should expand abcdef0123456789 to 32 bytes", dst, "abcdef0123456789", 32, "eff31487c770a893cfb36f912fbfcbff40d5661771ca4b2cb4eafe524333f5c1"},
+		{"should expand abc to 128 bytes", dst, "abc", 128, "abba86a6129e366fc877aab32fc4ffc70120d8996c88aee2fe4b32d6c7b6437a647e6c3163d40b76a73cf6a5674ef1d890f95b664ee0afa5359a5c4e07985635bbecbac65d747d3d2da7ec2b8221b17b0ca9dc8a1ac1c07ea6a1e60583e2cb00058e77b7b72a298425cd1b941ad4ec65e8afc50303a22c0f99b0509b4c895f40"},
+		{"should hash an oversized dst", longDST, "abc", 32, "52dbf4f36cf560fca57dedec2ad924ee9c266341d8f3d6afe5171733b16bbb12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandMessageXMD(sha256.New, []byte(tt.msg), []byte(tt.dst), tt.n)
+			if err != nil {
+				t.Fatalf("ExpandMessageXMD() error = %v", err)
+			}
+			want, _ := hex.DecodeString(tt.want)
+			if !bytes.Equal(got, want) {
+				t.Errorf("ExpandMessageXMD() = %x, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("should reject more than 255 blocks", func(t *testing.T) {
+		_, err := ExpandMessageXMD(sha256.New, nil, []byte(dst), 256*32)
+		if err == nil {
+			t.Errorf("ExpandMessageXMD() error = %v, wantErr true", err)
+		}
+	})
+}
@@ -0,0 +1,5 @@
+// Package pb contains the protobuf messages and gRPC stubs of the Online SPHINX API,
+// generated from sphinx.proto.
+package pb
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:. sphinx.proto
@@ -0,0 +1,13 @@
+package pb
+
+// Metadata keys of the OnlineSphinx service.
+const (
+	// SessionKey is the response header of ExpK and the request metadata of all later calls carrying the session.
+	SessionKey = "online-sphinx-session"
+	// CodeKey is the trailer carrying the contract.Code of an error.
+	CodeKey = "online-sphinx-code"
+	// RetryAfterKey is the trailer carrying the retry hint of an error in milliseconds.
+	RetryAfterKey = "online-sphinx-retry-after-ms"
+	// RequestIDKey is the request metadata and response header carrying the request ID, see contract.RequestIDHeader.
+	RequestIDKey = "x-request-id"
+)
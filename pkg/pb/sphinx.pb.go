@@ -0,0 +1,2165 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: sphinx.proto
+
+package pb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type GroupRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GroupRequest) Reset()         { *m = GroupRequest{} }
+func (m *GroupRequest) String() string { return proto.CompactTextString(m) }
+func (*GroupRequest) ProtoMessage()    {}
+func (*GroupRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{0}
+}
+
+func (m *GroupRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GroupRequest.Unmarshal(m, b)
+}
+func (m *GroupRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GroupRequest.Marshal(b, m, deterministic)
+}
+func (m *GroupRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GroupRequest.Merge(m, src)
+}
+func (m *GroupRequest) XXX_Size() int {
+	return xxx_messageInfo_GroupRequest.Size(m)
+}
+func (m *GroupRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GroupRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GroupRequest proto.InternalMessageInfo
+
+type GroupResponse struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Q                    []byte   `protobuf:"bytes,2,opt,name=q,proto3" json:"q,omitempty"`
+	Pk                   []byte   `protobuf:"bytes,3,opt,name=pk,proto3" json:"pk,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GroupResponse) Reset()         { *m = GroupResponse{} }
+func (m *GroupResponse) String() string { return proto.CompactTextString(m) }
+func (*GroupResponse) ProtoMessage()    {}
+func (*GroupResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{1}
+}
+
+func (m *GroupResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GroupResponse.Unmarshal(m, b)
+}
+func (m *GroupResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GroupResponse.Marshal(b, m, deterministic)
+}
+func (m *GroupResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GroupResponse.Merge(m, src)
+}
+func (m *GroupResponse) XXX_Size() int {
+	return xxx_messageInfo_GroupResponse.Size(m)
+}
+func (m *GroupResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GroupResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GroupResponse proto.InternalMessageInfo
+
+func (m *GroupResponse) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *GroupResponse) GetQ() []byte {
+	if m != nil {
+		return m.Q
+	}
+	return nil
+}
+
+func (m *GroupResponse) GetPk() []byte {
+	if m != nil {
+		return m.Pk
+	}
+	return nil
+}
+
+type RegisterExpKRequest struct {
+	B                    []byte   `protobuf:"bytes,1,opt,name=b,proto3" json:"b,omitempty"`
+	Q                    []byte   `protobuf:"bytes,2,opt,name=q,proto3" json:"q,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RegisterExpKRequest) Reset()         { *m = RegisterExpKRequest{} }
+func (m *RegisterExpKRequest) String() string { return proto.CompactTextString(m) }
+func (*RegisterExpKRequest) ProtoMessage()    {}
+func (*RegisterExpKRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{2}
+}
+
+func (m *RegisterExpKRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterExpKRequest.Unmarshal(m, b)
+}
+func (m *RegisterExpKRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterExpKRequest.Marshal(b, m, deterministic)
+}
+func (m *RegisterExpKRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterExpKRequest.Merge(m, src)
+}
+func (m *RegisterExpKRequest) XXX_Size() int {
+	return xxx_messageInfo_RegisterExpKRequest.Size(m)
+}
+func (m *RegisterExpKRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterExpKRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegisterExpKRequest proto.InternalMessageInfo
+
+func (m *RegisterExpKRequest) GetB() []byte {
+	if m != nil {
+		return m.B
+	}
+	return nil
+}
+
+func (m *RegisterExpKRequest) GetQ() []byte {
+	if m != nil {
+		return m.Q
+	}
+	return nil
+}
+
+type RegisterExpKResponse struct {
+	Bd                   []byte   `protobuf:"bytes,1,opt,name=bd,proto3" json:"bd,omitempty"`
+	Q0                   []byte   `protobuf:"bytes,2,opt,name=q0,proto3" json:"q0,omitempty"`
+	ProofC               []byte   `protobuf:"bytes,3,opt,name=proof_c,json=proofC,proto3" json:"proof_c,omitempty"`
+	ProofS               []byte   `protobuf:"bytes,4,opt,name=proof_s,json=proofS,proto3" json:"proof_s,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RegisterExpKResponse) Reset()         { *m = RegisterExpKResponse{} }
+func (m *RegisterExpKResponse) String() string { return proto.CompactTextString(m) }
+func (*RegisterExpKResponse) ProtoMessage()    {}
+func (*RegisterExpKResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{3}
+}
+
+func (m *RegisterExpKResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterExpKResponse.Unmarshal(m, b)
+}
+func (m *RegisterExpKResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterExpKResponse.Marshal(b, m, deterministic)
+}
+func (m *RegisterExpKResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterExpKResponse.Merge(m, src)
+}
+func (m *RegisterExpKResponse) XXX_Size() int {
+	return xxx_messageInfo_RegisterExpKResponse.Size(m)
+}
+func (m *RegisterExpKResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterExpKResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegisterExpKResponse proto.InternalMessageInfo
+
+func (m *RegisterExpKResponse) GetBd() []byte {
+	if m != nil {
+		return m.Bd
+	}
+	return nil
+}
+
+func (m *RegisterExpKResponse) GetQ0() []byte {
+	if m != nil {
+		return m.Q0
+	}
+	return nil
+}
+
+func (m *RegisterExpKResponse) GetProofC() []byte {
+	if m != nil {
+		return m.ProofC
+	}
+	return nil
+}
+
+func (m *RegisterExpKResponse) GetProofS() []byte {
+	if m != nil {
+		return m.ProofS
+	}
+	return nil
+}
+
+type RegisterRequest struct {
+	CId                  []byte   `protobuf:"bytes,1,opt,name=c_id,json=cId,proto3" json:"c_id,omitempty"`
+	Verifier             []byte   `protobuf:"bytes,2,opt,name=verifier,proto3" json:"verifier,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RegisterRequest) Reset()         { *m = RegisterRequest{} }
+func (m *RegisterRequest) String() string { return proto.CompactTextString(m) }
+func (*RegisterRequest) ProtoMessage()    {}
+func (*RegisterRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{4}
+}
+
+func (m *RegisterRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RegisterRequest.Unmarshal(m, b)
+}
+func (m *RegisterRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RegisterRequest.Marshal(b, m, deterministic)
+}
+func (m *RegisterRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RegisterRequest.Merge(m, src)
+}
+func (m *RegisterRequest) XXX_Size() int {
+	return xxx_messageInfo_RegisterRequest.Size(m)
+}
+func (m *RegisterRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RegisterRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RegisterRequest proto.InternalMessageInfo
+
+func (m *RegisterRequest) GetCId() []byte {
+	if m != nil {
+		return m.CId
+	}
+	return nil
+}
+
+func (m *RegisterRequest) GetVerifier() []byte {
+	if m != nil {
+		return m.Verifier
+	}
+	return nil
+}
+
+// EnrollRequest sets the verifier of a user, who authenticates with the one-time code
+// issued by the operator, e = H(c_id)**ak and the DLEQ proof (proof_c, proof_s) prove the possession of ak.
+type EnrollRequest struct {
+	CId                  []byte   `protobuf:"bytes,1,opt,name=c_id,json=cId,proto3" json:"c_id,omitempty"`
+	Verifier             []byte   `protobuf:"bytes,2,opt,name=verifier,proto3" json:"verifier,omitempty"`
+	E                    []byte   `protobuf:"bytes,3,opt,name=e,proto3" json:"e,omitempty"`
+	ProofC               []byte   `protobuf:"bytes,4,opt,name=proof_c,json=proofC,proto3" json:"proof_c,omitempty"`
+	ProofS               []byte   `protobuf:"bytes,5,opt,name=proof_s,json=proofS,proto3" json:"proof_s,omitempty"`
+	Code                 string   `protobuf:"bytes,6,opt,name=code,proto3" json:"code,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EnrollRequest) Reset()         { *m = EnrollRequest{} }
+func (m *EnrollRequest) String() string { return proto.CompactTextString(m) }
+func (*EnrollRequest) ProtoMessage()    {}
+func (*EnrollRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{5}
+}
+
+func (m *EnrollRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EnrollRequest.Unmarshal(m, b)
+}
+func (m *EnrollRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EnrollRequest.Marshal(b, m, deterministic)
+}
+func (m *EnrollRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EnrollRequest.Merge(m, src)
+}
+func (m *EnrollRequest) XXX_Size() int {
+	return xxx_messageInfo_EnrollRequest.Size(m)
+}
+func (m *EnrollRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_EnrollRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EnrollRequest proto.InternalMessageInfo
+
+func (m *EnrollRequest) GetCId() []byte {
+	if m != nil {
+		return m.CId
+	}
+	return nil
+}
+
+func (m *EnrollRequest) GetVerifier() []byte {
+	if m != nil {
+		return m.Verifier
+	}
+	return nil
+}
+
+func (m *EnrollRequest) GetE() []byte {
+	if m != nil {
+		return m.E
+	}
+	return nil
+}
+
+func (m *EnrollRequest) GetProofC() []byte {
+	if m != nil {
+		return m.ProofC
+	}
+	return nil
+}
+
+func (m *EnrollRequest) GetProofS() []byte {
+	if m != nil {
+		return m.ProofS
+	}
+	return nil
+}
+
+func (m *EnrollRequest) GetCode() string {
+	if m != nil {
+		return m.Code
+	}
+	return ""
+}
+
+type EnrollResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EnrollResponse) Reset()         { *m = EnrollResponse{} }
+func (m *EnrollResponse) String() string { return proto.CompactTextString(m) }
+func (*EnrollResponse) ProtoMessage()    {}
+func (*EnrollResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{6}
+}
+
+func (m *EnrollResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EnrollResponse.Unmarshal(m, b)
+}
+func (m *EnrollResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EnrollResponse.Marshal(b, m, deterministic)
+}
+func (m *EnrollResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EnrollResponse.Merge(m, src)
+}
+func (m *EnrollResponse) XXX_Size() int {
+	return xxx_messageInfo_EnrollResponse.Size(m)
+}
+func (m *EnrollResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_EnrollResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EnrollResponse proto.InternalMessageInfo
+
+type ExpKRequest struct {
+	CId                  []byte   `protobuf:"bytes,1,opt,name=c_id,json=cId,proto3" json:"c_id,omitempty"`
+	CNonce               []byte   `protobuf:"bytes,2,opt,name=c_nonce,json=cNonce,proto3" json:"c_nonce,omitempty"`
+	B                    []byte   `protobuf:"bytes,3,opt,name=b,proto3" json:"b,omitempty"`
+	Q                    []byte   `protobuf:"bytes,4,opt,name=q,proto3" json:"q,omitempty"`
+	X                    []byte   `protobuf:"bytes,5,opt,name=x,proto3" json:"x,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ExpKRequest) Reset()         { *m = ExpKRequest{} }
+func (m *ExpKRequest) String() string { return proto.CompactTextString(m) }
+func (*ExpKRequest) ProtoMessage()    {}
+func (*ExpKRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{7}
+}
+
+func (m *ExpKRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExpKRequest.Unmarshal(m, b)
+}
+func (m *ExpKRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExpKRequest.Marshal(b, m, deterministic)
+}
+func (m *ExpKRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExpKRequest.Merge(m, src)
+}
+func (m *ExpKRequest) XXX_Size() int {
+	return xxx_messageInfo_ExpKRequest.Size(m)
+}
+func (m *ExpKRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExpKRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ExpKRequest proto.InternalMessageInfo
+
+func (m *ExpKRequest) GetCId() []byte {
+	if m != nil {
+		return m.CId
+	}
+	return nil
+}
+
+func (m *ExpKRequest) GetCNonce() []byte {
+	if m != nil {
+		return m.CNonce
+	}
+	return nil
+}
+
+func (m *ExpKRequest) GetB() []byte {
+	if m != nil {
+		return m.B
+	}
+	return nil
+}
+
+func (m *ExpKRequest) GetQ() []byte {
+	if m != nil {
+		return m.Q
+	}
+	return nil
+}
+
+func (m *ExpKRequest) GetX() []byte {
+	if m != nil {
+		return m.X
+	}
+	return nil
+}
+
+type ExpKResponse struct {
+	SId                  []byte   `protobuf:"bytes,1,opt,name=s_id,json=sId,proto3" json:"s_id,omitempty"`
+	SNonce               []byte   `protobuf:"bytes,2,opt,name=s_nonce,json=sNonce,proto3" json:"s_nonce,omitempty"`
+	Bd                   []byte   `protobuf:"bytes,3,opt,name=bd,proto3" json:"bd,omitempty"`
+	Q0                   []byte   `protobuf:"bytes,4,opt,name=q0,proto3" json:"q0,omitempty"`
+	ProofC               []byte   `protobuf:"bytes,6,opt,name=proof_c,json=proofC,proto3" json:"proof_c,omitempty"`
+	ProofS               []byte   `protobuf:"bytes,7,opt,name=proof_s,json=proofS,proto3" json:"proof_s,omitempty"`
+	Y                    []byte   `protobuf:"bytes,8,opt,name=y,proto3" json:"y,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ExpKResponse) Reset()         { *m = ExpKResponse{} }
+func (m *ExpKResponse) String() string { return proto.CompactTextString(m) }
+func (*ExpKResponse) ProtoMessage()    {}
+func (*ExpKResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{8}
+}
+
+func (m *ExpKResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ExpKResponse.Unmarshal(m, b)
+}
+func (m *ExpKResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ExpKResponse.Marshal(b, m, deterministic)
+}
+func (m *ExpKResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ExpKResponse.Merge(m, src)
+}
+func (m *ExpKResponse) XXX_Size() int {
+	return xxx_messageInfo_ExpKResponse.Size(m)
+}
+func (m *ExpKResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ExpKResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ExpKResponse proto.InternalMessageInfo
+
+func (m *ExpKResponse) GetSId() []byte {
+	if m != nil {
+		return m.SId
+	}
+	return nil
+}
+
+func (m *ExpKResponse) GetSNonce() []byte {
+	if m != nil {
+		return m.SNonce
+	}
+	return nil
+}
+
+func (m *ExpKResponse) GetBd() []byte {
+	if m != nil {
+		return m.Bd
+	}
+	return nil
+}
+
+func (m *ExpKResponse) GetQ0() []byte {
+	if m != nil {
+		return m.Q0
+	}
+	return nil
+}
+
+func (m *ExpKResponse) GetProofC() []byte {
+	if m != nil {
+		return m.ProofC
+	}
+	return nil
+}
+
+func (m *ExpKResponse) GetProofS() []byte {
+	if m != nil {
+		return m.ProofS
+	}
+	return nil
+}
+
+func (m *ExpKResponse) GetY() []byte {
+	if m != nil {
+		return m.Y
+	}
+	return nil
+}
+
+type ChallengeRequest struct {
+	G                    []byte   `protobuf:"bytes,1,opt,name=g,proto3" json:"g,omitempty"`
+	Q                    []byte   `protobuf:"bytes,2,opt,name=q,proto3" json:"q,omitempty"`
+	Mac                  []byte   `protobuf:"bytes,3,opt,name=mac,proto3" json:"mac,omitempty"`
+	Counter              uint64   `protobuf:"varint,4,opt,name=counter,proto3" json:"counter,omitempty"`
+	Timestamp            int64    `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ChallengeRequest) Reset()         { *m = ChallengeRequest{} }
+func (m *ChallengeRequest) String() string { return proto.CompactTextString(m) }
+func (*ChallengeRequest) ProtoMessage()    {}
+func (*ChallengeRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{9}
+}
+
+func (m *ChallengeRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ChallengeRequest.Unmarshal(m, b)
+}
+func (m *ChallengeRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ChallengeRequest.Marshal(b, m, deterministic)
+}
+func (m *ChallengeRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ChallengeRequest.Merge(m, src)
+}
+func (m *ChallengeRequest) XXX_Size() int {
+	return xxx_messageInfo_ChallengeRequest.Size(m)
+}
+func (m *ChallengeRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ChallengeRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ChallengeRequest proto.InternalMessageInfo
+
+func (m *ChallengeRequest) GetG() []byte {
+	if m != nil {
+		return m.G
+	}
+	return nil
+}
+
+func (m *ChallengeRequest) GetQ() []byte {
+	if m != nil {
+		return m.Q
+	}
+	return nil
+}
+
+func (m *ChallengeRequest) GetMac() []byte {
+	if m != nil {
+		return m.Mac
+	}
+	return nil
+}
+
+func (m *ChallengeRequest) GetCounter() uint64 {
+	if m != nil {
+		return m.Counter
+	}
+	return 0
+}
+
+func (m *ChallengeRequest) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+type ChallengeResponse struct {
+	R                    []byte   `protobuf:"bytes,1,opt,name=r,proto3" json:"r,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ChallengeResponse) Reset()         { *m = ChallengeResponse{} }
+func (m *ChallengeResponse) String() string { return proto.CompactTextString(m) }
+func (*ChallengeResponse) ProtoMessage()    {}
+func (*ChallengeResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{10}
+}
+
+func (m *ChallengeResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ChallengeResponse.Unmarshal(m, b)
+}
+func (m *ChallengeResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ChallengeResponse.Marshal(b, m, deterministic)
+}
+func (m *ChallengeResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ChallengeResponse.Merge(m, src)
+}
+func (m *ChallengeResponse) XXX_Size() int {
+	return xxx_messageInfo_ChallengeResponse.Size(m)
+}
+func (m *ChallengeResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ChallengeResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ChallengeResponse proto.InternalMessageInfo
+
+func (m *ChallengeResponse) GetR() []byte {
+	if m != nil {
+		return m.R
+	}
+	return nil
+}
+
+type LogoutRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LogoutRequest) Reset()         { *m = LogoutRequest{} }
+func (m *LogoutRequest) String() string { return proto.CompactTextString(m) }
+func (*LogoutRequest) ProtoMessage()    {}
+func (*LogoutRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{11}
+}
+
+func (m *LogoutRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LogoutRequest.Unmarshal(m, b)
+}
+func (m *LogoutRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LogoutRequest.Marshal(b, m, deterministic)
+}
+func (m *LogoutRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LogoutRequest.Merge(m, src)
+}
+func (m *LogoutRequest) XXX_Size() int {
+	return xxx_messageInfo_LogoutRequest.Size(m)
+}
+func (m *LogoutRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LogoutRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LogoutRequest proto.InternalMessageInfo
+
+type LogoutResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LogoutResponse) Reset()         { *m = LogoutResponse{} }
+func (m *LogoutResponse) String() string { return proto.CompactTextString(m) }
+func (*LogoutResponse) ProtoMessage()    {}
+func (*LogoutResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{12}
+}
+
+func (m *LogoutResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LogoutResponse.Unmarshal(m, b)
+}
+func (m *LogoutResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LogoutResponse.Marshal(b, m, deterministic)
+}
+func (m *LogoutResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LogoutResponse.Merge(m, src)
+}
+func (m *LogoutResponse) XXX_Size() int {
+	return xxx_messageInfo_LogoutResponse.Size(m)
+}
+func (m *LogoutResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_LogoutResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LogoutResponse proto.InternalMessageInfo
+
+type MetadataRequest struct {
+	Mac                  []byte   `protobuf:"bytes,1,opt,name=mac,proto3" json:"mac,omitempty"`
+	Counter              uint64   `protobuf:"varint,2,opt,name=counter,proto3" json:"counter,omitempty"`
+	Timestamp            int64    `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MetadataRequest) Reset()         { *m = MetadataRequest{} }
+func (m *MetadataRequest) String() string { return proto.CompactTextString(m) }
+func (*MetadataRequest) ProtoMessage()    {}
+func (*MetadataRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{13}
+}
+
+func (m *MetadataRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MetadataRequest.Unmarshal(m, b)
+}
+func (m *MetadataRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MetadataRequest.Marshal(b, m, deterministic)
+}
+func (m *MetadataRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MetadataRequest.Merge(m, src)
+}
+func (m *MetadataRequest) XXX_Size() int {
+	return xxx_messageInfo_MetadataRequest.Size(m)
+}
+func (m *MetadataRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_MetadataRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MetadataRequest proto.InternalMessageInfo
+
+func (m *MetadataRequest) GetMac() []byte {
+	if m != nil {
+		return m.Mac
+	}
+	return nil
+}
+
+func (m *MetadataRequest) GetCounter() uint64 {
+	if m != nil {
+		return m.Counter
+	}
+	return 0
+}
+
+func (m *MetadataRequest) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+type MetadataResponse struct {
+	Domains              []string `protobuf:"bytes,1,rep,name=domains,proto3" json:"domains,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MetadataResponse) Reset()         { *m = MetadataResponse{} }
+func (m *MetadataResponse) String() string { return proto.CompactTextString(m) }
+func (*MetadataResponse) ProtoMessage()    {}
+func (*MetadataResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{14}
+}
+
+func (m *MetadataResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MetadataResponse.Unmarshal(m, b)
+}
+func (m *MetadataResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MetadataResponse.Marshal(b, m, deterministic)
+}
+func (m *MetadataResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MetadataResponse.Merge(m, src)
+}
+func (m *MetadataResponse) XXX_Size() int {
+	return xxx_messageInfo_MetadataResponse.Size(m)
+}
+func (m *MetadataResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_MetadataResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MetadataResponse proto.InternalMessageInfo
+
+func (m *MetadataResponse) GetDomains() []string {
+	if m != nil {
+		return m.Domains
+	}
+	return nil
+}
+
+type AddRequest struct {
+	Mac                  []byte   `protobuf:"bytes,1,opt,name=mac,proto3" json:"mac,omitempty"`
+	Domain               string   `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"`
+	Metadata             string   `protobuf:"bytes,3,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	Counter              uint64   `protobuf:"varint,4,opt,name=counter,proto3" json:"counter,omitempty"`
+	Timestamp            int64    `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddRequest) Reset()         { *m = AddRequest{} }
+func (m *AddRequest) String() string { return proto.CompactTextString(m) }
+func (*AddRequest) ProtoMessage()    {}
+func (*AddRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{15}
+}
+
+func (m *AddRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddRequest.Unmarshal(m, b)
+}
+func (m *AddRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddRequest.Marshal(b, m, deterministic)
+}
+func (m *AddRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddRequest.Merge(m, src)
+}
+func (m *AddRequest) XXX_Size() int {
+	return xxx_messageInfo_AddRequest.Size(m)
+}
+func (m *AddRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddRequest proto.InternalMessageInfo
+
+func (m *AddRequest) GetMac() []byte {
+	if m != nil {
+		return m.Mac
+	}
+	return nil
+}
+
+func (m *AddRequest) GetDomain() string {
+	if m != nil {
+		return m.Domain
+	}
+	return ""
+}
+
+func (m *AddRequest) GetMetadata() string {
+	if m != nil {
+		return m.Metadata
+	}
+	return ""
+}
+
+func (m *AddRequest) GetCounter() uint64 {
+	if m != nil {
+		return m.Counter
+	}
+	return 0
+}
+
+func (m *AddRequest) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+type AddResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddResponse) Reset()         { *m = AddResponse{} }
+func (m *AddResponse) String() string { return proto.CompactTextString(m) }
+func (*AddResponse) ProtoMessage()    {}
+func (*AddResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{16}
+}
+
+func (m *AddResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddResponse.Unmarshal(m, b)
+}
+func (m *AddResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddResponse.Marshal(b, m, deterministic)
+}
+func (m *AddResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddResponse.Merge(m, src)
+}
+func (m *AddResponse) XXX_Size() int {
+	return xxx_messageInfo_AddResponse.Size(m)
+}
+func (m *AddResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddResponse proto.InternalMessageInfo
+
+type GetRequest struct {
+	Mac                  []byte   `protobuf:"bytes,1,opt,name=mac,proto3" json:"mac,omitempty"`
+	Domain               string   `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"`
+	Bmk                  []byte   `protobuf:"bytes,3,opt,name=bmk,proto3" json:"bmk,omitempty"`
+	Q                    []byte   `protobuf:"bytes,4,opt,name=q,proto3" json:"q,omitempty"`
+	Previous             bool     `protobuf:"varint,5,opt,name=previous,proto3" json:"previous,omitempty"`
+	Counter              uint64   `protobuf:"varint,6,opt,name=counter,proto3" json:"counter,omitempty"`
+	Timestamp            int64    `protobuf:"varint,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetRequest) Reset()         { *m = GetRequest{} }
+func (m *GetRequest) String() string { return proto.CompactTextString(m) }
+func (*GetRequest) ProtoMessage()    {}
+func (*GetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{17}
+}
+
+func (m *GetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetRequest.Unmarshal(m, b)
+}
+func (m *GetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetRequest.Marshal(b, m, deterministic)
+}
+func (m *GetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetRequest.Merge(m, src)
+}
+func (m *GetRequest) XXX_Size() int {
+	return xxx_messageInfo_GetRequest.Size(m)
+}
+func (m *GetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetRequest proto.InternalMessageInfo
+
+func (m *GetRequest) GetMac() []byte {
+	if m != nil {
+		return m.Mac
+	}
+	return nil
+}
+
+func (m *GetRequest) GetDomain() string {
+	if m != nil {
+		return m.Domain
+	}
+	return ""
+}
+
+func (m *GetRequest) GetBmk() []byte {
+	if m != nil {
+		return m.Bmk
+	}
+	return nil
+}
+
+func (m *GetRequest) GetQ() []byte {
+	if m != nil {
+		return m.Q
+	}
+	return nil
+}
+
+func (m *GetRequest) GetPrevious() bool {
+	if m != nil {
+		return m.Previous
+	}
+	return false
+}
+
+func (m *GetRequest) GetCounter() uint64 {
+	if m != nil {
+		return m.Counter
+	}
+	return 0
+}
+
+func (m *GetRequest) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+type GetResponse struct {
+	Bj                   []byte   `protobuf:"bytes,1,opt,name=bj,proto3" json:"bj,omitempty"`
+	Qj                   []byte   `protobuf:"bytes,2,opt,name=qj,proto3" json:"qj,omitempty"`
+	Metadata             string   `protobuf:"bytes,3,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetResponse) Reset()         { *m = GetResponse{} }
+func (m *GetResponse) String() string { return proto.CompactTextString(m) }
+func (*GetResponse) ProtoMessage()    {}
+func (*GetResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{18}
+}
+
+func (m *GetResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetResponse.Unmarshal(m, b)
+}
+func (m *GetResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetResponse.Marshal(b, m, deterministic)
+}
+func (m *GetResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetResponse.Merge(m, src)
+}
+func (m *GetResponse) XXX_Size() int {
+	return xxx_messageInfo_GetResponse.Size(m)
+}
+func (m *GetResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetResponse proto.InternalMessageInfo
+
+func (m *GetResponse) GetBj() []byte {
+	if m != nil {
+		return m.Bj
+	}
+	return nil
+}
+
+func (m *GetResponse) GetQj() []byte {
+	if m != nil {
+		return m.Qj
+	}
+	return nil
+}
+
+func (m *GetResponse) GetMetadata() string {
+	if m != nil {
+		return m.Metadata
+	}
+	return ""
+}
+
+type RotateRequest struct {
+	Mac                  []byte   `protobuf:"bytes,1,opt,name=mac,proto3" json:"mac,omitempty"`
+	Domain               string   `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"`
+	Counter              uint64   `protobuf:"varint,3,opt,name=counter,proto3" json:"counter,omitempty"`
+	Timestamp            int64    `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RotateRequest) Reset()         { *m = RotateRequest{} }
+func (m *RotateRequest) String() string { return proto.CompactTextString(m) }
+func (*RotateRequest) ProtoMessage()    {}
+func (*RotateRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{19}
+}
+
+func (m *RotateRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RotateRequest.Unmarshal(m, b)
+}
+func (m *RotateRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RotateRequest.Marshal(b, m, deterministic)
+}
+func (m *RotateRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RotateRequest.Merge(m, src)
+}
+func (m *RotateRequest) XXX_Size() int {
+	return xxx_messageInfo_RotateRequest.Size(m)
+}
+func (m *RotateRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RotateRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RotateRequest proto.InternalMessageInfo
+
+func (m *RotateRequest) GetMac() []byte {
+	if m != nil {
+		return m.Mac
+	}
+	return nil
+}
+
+func (m *RotateRequest) GetDomain() string {
+	if m != nil {
+		return m.Domain
+	}
+	return ""
+}
+
+func (m *RotateRequest) GetCounter() uint64 {
+	if m != nil {
+		return m.Counter
+	}
+	return 0
+}
+
+func (m *RotateRequest) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+type RotateResponse struct {
+	Version int64 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	// previous_until is the end of the grace period in unix nanoseconds.
+	PreviousUntil        int64    `protobuf:"varint,2,opt,name=previous_until,json=previousUntil,proto3" json:"previous_until,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RotateResponse) Reset()         { *m = RotateResponse{} }
+func (m *RotateResponse) String() string { return proto.CompactTextString(m) }
+func (*RotateResponse) ProtoMessage()    {}
+func (*RotateResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{20}
+}
+
+func (m *RotateResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RotateResponse.Unmarshal(m, b)
+}
+func (m *RotateResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RotateResponse.Marshal(b, m, deterministic)
+}
+func (m *RotateResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RotateResponse.Merge(m, src)
+}
+func (m *RotateResponse) XXX_Size() int {
+	return xxx_messageInfo_RotateResponse.Size(m)
+}
+func (m *RotateResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RotateResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RotateResponse proto.InternalMessageInfo
+
+func (m *RotateResponse) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *RotateResponse) GetPreviousUntil() int64 {
+	if m != nil {
+		return m.PreviousUntil
+	}
+	return 0
+}
+
+type DeleteRequest struct {
+	Mac                  []byte   `protobuf:"bytes,1,opt,name=mac,proto3" json:"mac,omitempty"`
+	Domain               string   `protobuf:"bytes,2,opt,name=domain,proto3" json:"domain,omitempty"`
+	Counter              uint64   `protobuf:"varint,3,opt,name=counter,proto3" json:"counter,omitempty"`
+	Timestamp            int64    `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteRequest) Reset()         { *m = DeleteRequest{} }
+func (m *DeleteRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteRequest) ProtoMessage()    {}
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{21}
+}
+
+func (m *DeleteRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteRequest.Unmarshal(m, b)
+}
+func (m *DeleteRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteRequest.Merge(m, src)
+}
+func (m *DeleteRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteRequest.Size(m)
+}
+func (m *DeleteRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteRequest proto.InternalMessageInfo
+
+func (m *DeleteRequest) GetMac() []byte {
+	if m != nil {
+		return m.Mac
+	}
+	return nil
+}
+
+func (m *DeleteRequest) GetDomain() string {
+	if m != nil {
+		return m.Domain
+	}
+	return ""
+}
+
+func (m *DeleteRequest) GetCounter() uint64 {
+	if m != nil {
+		return m.Counter
+	}
+	return 0
+}
+
+func (m *DeleteRequest) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+type DeleteResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteResponse) Reset()         { *m = DeleteResponse{} }
+func (m *DeleteResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteResponse) ProtoMessage()    {}
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{22}
+}
+
+func (m *DeleteResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteResponse.Unmarshal(m, b)
+}
+func (m *DeleteResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteResponse.Marshal(b, m, deterministic)
+}
+func (m *DeleteResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteResponse.Merge(m, src)
+}
+func (m *DeleteResponse) XXX_Size() int {
+	return xxx_messageInfo_DeleteResponse.Size(m)
+}
+func (m *DeleteResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteResponse proto.InternalMessageInfo
+
+type SessionsRequest struct {
+	Mac                  []byte   `protobuf:"bytes,1,opt,name=mac,proto3" json:"mac,omitempty"`
+	Counter              uint64   `protobuf:"varint,2,opt,name=counter,proto3" json:"counter,omitempty"`
+	Timestamp            int64    `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SessionsRequest) Reset()         { *m = SessionsRequest{} }
+func (m *SessionsRequest) String() string { return proto.CompactTextString(m) }
+func (*SessionsRequest) ProtoMessage()    {}
+func (*SessionsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{23}
+}
+
+func (m *SessionsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SessionsRequest.Unmarshal(m, b)
+}
+func (m *SessionsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SessionsRequest.Marshal(b, m, deterministic)
+}
+func (m *SessionsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SessionsRequest.Merge(m, src)
+}
+func (m *SessionsRequest) XXX_Size() int {
+	return xxx_messageInfo_SessionsRequest.Size(m)
+}
+func (m *SessionsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SessionsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SessionsRequest proto.InternalMessageInfo
+
+func (m *SessionsRequest) GetMac() []byte {
+	if m != nil {
+		return m.Mac
+	}
+	return nil
+}
+
+func (m *SessionsRequest) GetCounter() uint64 {
+	if m != nil {
+		return m.Counter
+	}
+	return 0
+}
+
+func (m *SessionsRequest) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+type SessionInfo struct {
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// created and last_seen are unix nanoseconds.
+	Created              int64    `protobuf:"varint,2,opt,name=created,proto3" json:"created,omitempty"`
+	LastSeen             int64    `protobuf:"varint,3,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	Current              bool     `protobuf:"varint,4,opt,name=current,proto3" json:"current,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SessionInfo) Reset()         { *m = SessionInfo{} }
+func (m *SessionInfo) String() string { return proto.CompactTextString(m) }
+func (*SessionInfo) ProtoMessage()    {}
+func (*SessionInfo) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{24}
+}
+
+func (m *SessionInfo) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SessionInfo.Unmarshal(m, b)
+}
+func (m *SessionInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SessionInfo.Marshal(b, m, deterministic)
+}
+func (m *SessionInfo) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SessionInfo.Merge(m, src)
+}
+func (m *SessionInfo) XXX_Size() int {
+	return xxx_messageInfo_SessionInfo.Size(m)
+}
+func (m *SessionInfo) XXX_DiscardUnknown() {
+	xxx_messageInfo_SessionInfo.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SessionInfo proto.InternalMessageInfo
+
+func (m *SessionInfo) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *SessionInfo) GetCreated() int64 {
+	if m != nil {
+		return m.Created
+	}
+	return 0
+}
+
+func (m *SessionInfo) GetLastSeen() int64 {
+	if m != nil {
+		return m.LastSeen
+	}
+	return 0
+}
+
+func (m *SessionInfo) GetCurrent() bool {
+	if m != nil {
+		return m.Current
+	}
+	return false
+}
+
+type SessionsResponse struct {
+	Sessions             []*SessionInfo `protobuf:"bytes,1,rep,name=sessions,proto3" json:"sessions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *SessionsResponse) Reset()         { *m = SessionsResponse{} }
+func (m *SessionsResponse) String() string { return proto.CompactTextString(m) }
+func (*SessionsResponse) ProtoMessage()    {}
+func (*SessionsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{25}
+}
+
+func (m *SessionsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SessionsResponse.Unmarshal(m, b)
+}
+func (m *SessionsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SessionsResponse.Marshal(b, m, deterministic)
+}
+func (m *SessionsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SessionsResponse.Merge(m, src)
+}
+func (m *SessionsResponse) XXX_Size() int {
+	return xxx_messageInfo_SessionsResponse.Size(m)
+}
+func (m *SessionsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SessionsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SessionsResponse proto.InternalMessageInfo
+
+func (m *SessionsResponse) GetSessions() []*SessionInfo {
+	if m != nil {
+		return m.Sessions
+	}
+	return nil
+}
+
+type RevokeSessionsRequest struct {
+	Mac                  []byte   `protobuf:"bytes,1,opt,name=mac,proto3" json:"mac,omitempty"`
+	Id                   string   `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	All                  bool     `protobuf:"varint,3,opt,name=all,proto3" json:"all,omitempty"`
+	Counter              uint64   `protobuf:"varint,4,opt,name=counter,proto3" json:"counter,omitempty"`
+	Timestamp            int64    `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeSessionsRequest) Reset()         { *m = RevokeSessionsRequest{} }
+func (m *RevokeSessionsRequest) String() string { return proto.CompactTextString(m) }
+func (*RevokeSessionsRequest) ProtoMessage()    {}
+func (*RevokeSessionsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{26}
+}
+
+func (m *RevokeSessionsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeSessionsRequest.Unmarshal(m, b)
+}
+func (m *RevokeSessionsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeSessionsRequest.Marshal(b, m, deterministic)
+}
+func (m *RevokeSessionsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeSessionsRequest.Merge(m, src)
+}
+func (m *RevokeSessionsRequest) XXX_Size() int {
+	return xxx_messageInfo_RevokeSessionsRequest.Size(m)
+}
+func (m *RevokeSessionsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeSessionsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeSessionsRequest proto.InternalMessageInfo
+
+func (m *RevokeSessionsRequest) GetMac() []byte {
+	if m != nil {
+		return m.Mac
+	}
+	return nil
+}
+
+func (m *RevokeSessionsRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *RevokeSessionsRequest) GetAll() bool {
+	if m != nil {
+		return m.All
+	}
+	return false
+}
+
+func (m *RevokeSessionsRequest) GetCounter() uint64 {
+	if m != nil {
+		return m.Counter
+	}
+	return 0
+}
+
+func (m *RevokeSessionsRequest) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
+type RevokeSessionsResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeSessionsResponse) Reset()         { *m = RevokeSessionsResponse{} }
+func (m *RevokeSessionsResponse) String() string { return proto.CompactTextString(m) }
+func (*RevokeSessionsResponse) ProtoMessage()    {}
+func (*RevokeSessionsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5312d6cc3ef085c5, []int{27}
+}
+
+func (m *RevokeSessionsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeSessionsResponse.Unmarshal(m, b)
+}
+func (m *RevokeSessionsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeSessionsResponse.Marshal(b, m, deterministic)
+}
+func (m *RevokeSessionsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeSessionsResponse.Merge(m, src)
+}
+func (m *RevokeSessionsResponse) XXX_Size() int {
+	return xxx_messageInfo_RevokeSessionsResponse.Size(m)
+}
+func (m *RevokeSessionsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeSessionsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeSessionsResponse proto.InternalMessageInfo
+
+func init() {
+	proto.RegisterType((*GroupRequest)(nil), "onlinesphinx.v1.GroupRequest")
+	proto.RegisterType((*GroupResponse)(nil), "onlinesphinx.v1.GroupResponse")
+	proto.RegisterType((*RegisterExpKRequest)(nil), "onlinesphinx.v1.RegisterExpKRequest")
+	proto.RegisterType((*RegisterExpKResponse)(nil), "onlinesphinx.v1.RegisterExpKResponse")
+	proto.RegisterType((*RegisterRequest)(nil), "onlinesphinx.v1.RegisterRequest")
+	proto.RegisterType((*EnrollRequest)(nil), "onlinesphinx.v1.EnrollRequest")
+	proto.RegisterType((*EnrollResponse)(nil), "onlinesphinx.v1.EnrollResponse")
+	proto.RegisterType((*ExpKRequest)(nil), "onlinesphinx.v1.ExpKRequest")
+	proto.RegisterType((*ExpKResponse)(nil), "onlinesphinx.v1.ExpKResponse")
+	proto.RegisterType((*ChallengeRequest)(nil), "onlinesphinx.v1.ChallengeRequest")
+	proto.RegisterType((*ChallengeResponse)(nil), "onlinesphinx.v1.ChallengeResponse")
+	proto.RegisterType((*LogoutRequest)(nil), "onlinesphinx.v1.LogoutRequest")
+	proto.RegisterType((*LogoutResponse)(nil), "onlinesphinx.v1.LogoutResponse")
+	proto.RegisterType((*MetadataRequest)(nil), "onlinesphinx.v1.MetadataRequest")
+	proto.RegisterType((*MetadataResponse)(nil), "onlinesphinx.v1.MetadataResponse")
+	proto.RegisterType((*AddRequest)(nil), "onlinesphinx.v1.AddRequest")
+	proto.RegisterType((*AddResponse)(nil), "onlinesphinx.v1.AddResponse")
+	proto.RegisterType((*GetRequest)(nil), "onlinesphinx.v1.GetRequest")
+	proto.RegisterType((*GetResponse)(nil), "onlinesphinx.v1.GetResponse")
+	proto.RegisterType((*RotateRequest)(nil), "onlinesphinx.v1.RotateRequest")
+	proto.RegisterType((*RotateResponse)(nil), "onlinesphinx.v1.RotateResponse")
+	proto.RegisterType((*DeleteRequest)(nil), "onlinesphinx.v1.DeleteRequest")
+	proto.RegisterType((*DeleteResponse)(nil), "onlinesphinx.v1.DeleteResponse")
+	proto.RegisterType((*SessionsRequest)(nil), "onlinesphinx.v1.SessionsRequest")
+	proto.RegisterType((*SessionInfo)(nil), "onlinesphinx.v1.SessionInfo")
+	proto.RegisterType((*SessionsResponse)(nil), "onlinesphinx.v1.SessionsResponse")
+	proto.RegisterType((*RevokeSessionsRequest)(nil), "onlinesphinx.v1.RevokeSessionsRequest")
+	proto.RegisterType((*RevokeSessionsResponse)(nil), "onlinesphinx.v1.RevokeSessionsResponse")
+}
+
+func init() { proto.RegisterFile("sphinx.proto", fileDescriptor_5312d6cc3ef085c5) }
+
+var fileDescriptor_5312d6cc3ef085c5 = []byte{
+	// 1066 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x57, 0x4d, 0x73, 0xe3, 0x44,
+	0x13, 0x2e, 0x7d, 0xc4, 0x96, 0x3b, 0xb2, 0xa3, 0xe8, 0x7d, 0xd9, 0x55, 0x69, 0xb3, 0x24, 0x51,
+	0xb1, 0x90, 0x03, 0x9b, 0x64, 0xc3, 0x85, 0x2a, 0x2e, 0x1b, 0xc2, 0x92, 0x0a, 0x04, 0xb6, 0x98,
+	0x14, 0x17, 0xf6, 0xe0, 0x92, 0xa5, 0x89, 0xa3, 0x58, 0xd6, 0xc8, 0x1a, 0xd9, 0xe5, 0x3d, 0x73,
+	0xe3, 0xc8, 0x6f, 0xe0, 0xc4, 0x9f, 0xe1, 0x2f, 0x51, 0x1a, 0xcd, 0x58, 0x1f, 0x96, 0x1c, 0x36,
+	0x45, 0x71, 0x53, 0x4f, 0xf7, 0x74, 0x3f, 0x4f, 0x77, 0xcf, 0xf4, 0x08, 0x74, 0x1a, 0xdf, 0x05,
+	0xd1, 0xf2, 0x38, 0x4e, 0x48, 0x4a, 0xcc, 0x1d, 0x12, 0x85, 0x41, 0x84, 0xf9, 0xda, 0xe2, 0x95,
+	0x33, 0x00, 0xfd, 0x32, 0x21, 0xf3, 0x18, 0xe1, 0xd9, 0x1c, 0xd3, 0xd4, 0x39, 0x87, 0x3e, 0x97,
+	0x69, 0x4c, 0x22, 0x8a, 0x4d, 0x13, 0xd4, 0xc8, 0x9d, 0x62, 0x4b, 0x3a, 0x90, 0x8e, 0x7a, 0x88,
+	0x7d, 0x9b, 0x3a, 0x48, 0x33, 0x4b, 0x3e, 0x90, 0x8e, 0x74, 0x24, 0xcd, 0xcc, 0x01, 0xc8, 0xf1,
+	0xc4, 0x52, 0x98, 0x28, 0xc7, 0x13, 0xe7, 0x15, 0xfc, 0x0f, 0xe1, 0x71, 0x40, 0x53, 0x9c, 0xbc,
+	0x59, 0xc6, 0xdf, 0x73, 0xcf, 0xd9, 0xa6, 0x11, 0xf3, 0xa2, 0x23, 0x69, 0x54, 0x75, 0xe1, 0xdc,
+	0xc1, 0xff, 0xab, 0x5b, 0x78, 0xf0, 0x01, 0xc8, 0x23, 0x9f, 0x6f, 0x92, 0x47, 0x7e, 0x26, 0xcf,
+	0x4e, 0xf9, 0x36, 0x79, 0x76, 0x6a, 0x3e, 0x85, 0x6e, 0x9c, 0x10, 0x72, 0x3b, 0xf4, 0x78, 0xfc,
+	0x0e, 0x13, 0x2f, 0x0a, 0x05, 0xb5, 0xd4, 0x92, 0xe2, 0xc6, 0x79, 0x0d, 0x3b, 0x22, 0x92, 0x00,
+	0xb6, 0x0b, 0xaa, 0x37, 0x0c, 0x44, 0x18, 0xc5, 0xbb, 0xf2, 0x4d, 0x1b, 0xb4, 0x05, 0x4e, 0x82,
+	0xdb, 0x00, 0x27, 0x3c, 0xda, 0x4a, 0x76, 0x7e, 0x97, 0xa0, 0xff, 0x26, 0x4a, 0x48, 0x18, 0x3e,
+	0xce, 0x41, 0x46, 0x1d, 0x73, 0xb8, 0x12, 0x2e, 0x53, 0x50, 0xdb, 0x28, 0x6c, 0x95, 0x29, 0x64,
+	0x15, 0xf1, 0x88, 0x8f, 0xad, 0x4e, 0x5e, 0x91, 0xec, 0xdb, 0x31, 0x60, 0x20, 0x30, 0xe5, 0xa9,
+	0x73, 0x3c, 0xd8, 0x2e, 0x67, 0xbf, 0x01, 0xe3, 0x53, 0xe8, 0x7a, 0xc3, 0x88, 0x44, 0x1e, 0xe6,
+	0x10, 0x3b, 0xde, 0x8f, 0x99, 0x94, 0x57, 0x4a, 0xa9, 0x54, 0x4a, 0x15, 0xc5, 0xd6, 0x41, 0x5a,
+	0x72, 0x3c, 0xd2, 0xd2, 0xf9, 0x43, 0x02, 0xbd, 0x52, 0xb0, 0x5d, 0x50, 0x69, 0x29, 0x0c, 0xcd,
+	0xc3, 0xd0, 0x6a, 0x18, 0x9a, 0x87, 0xc9, 0x8b, 0xab, 0xd4, 0x8a, 0xab, 0x36, 0x15, 0xb7, 0xd3,
+	0x96, 0x99, 0x6e, 0x25, 0x33, 0x3a, 0x48, 0xef, 0x2d, 0x2d, 0x07, 0xf7, 0xfe, 0x3b, 0x55, 0xdb,
+	0x32, 0x3a, 0x48, 0x9e, 0x2c, 0x9c, 0x25, 0x18, 0x17, 0x77, 0x6e, 0x18, 0xe2, 0x68, 0x8c, 0x4b,
+	0xed, 0x38, 0x16, 0xed, 0x38, 0xae, 0x75, 0xb4, 0x01, 0xca, 0xd4, 0x15, 0x2d, 0x95, 0x7d, 0x9a,
+	0x16, 0x74, 0x3d, 0x32, 0x8f, 0x52, 0x9c, 0x30, 0x80, 0x2a, 0x12, 0xa2, 0xb9, 0x07, 0xbd, 0x34,
+	0x98, 0x62, 0x9a, 0xba, 0xd3, 0x98, 0x25, 0x46, 0x41, 0xc5, 0x82, 0x73, 0x08, 0xbb, 0xa5, 0xc8,
+	0x3c, 0x49, 0x3a, 0x48, 0x89, 0x08, 0x9d, 0x38, 0x3b, 0xd0, 0xbf, 0x26, 0x63, 0x32, 0x4f, 0xc5,
+	0x11, 0x34, 0x60, 0x20, 0x16, 0x78, 0x2d, 0xdf, 0xc1, 0xce, 0x0f, 0x38, 0x75, 0x7d, 0x37, 0x75,
+	0x05, 0x7c, 0x0e, 0x51, 0x6a, 0x84, 0x28, 0x6f, 0x80, 0xa8, 0xd4, 0x21, 0x7e, 0x0e, 0x46, 0xe1,
+	0x9c, 0x23, 0xb4, 0xa0, 0xeb, 0x93, 0xa9, 0x1b, 0x44, 0xd4, 0x92, 0x0e, 0x94, 0xa3, 0x1e, 0x12,
+	0xa2, 0xf3, 0x9b, 0x04, 0x70, 0xee, 0xfb, 0xed, 0x30, 0x9e, 0x40, 0x27, 0xb7, 0x65, 0x28, 0x7a,
+	0x88, 0x4b, 0xd9, 0x89, 0x98, 0xf2, 0x30, 0x0c, 0x43, 0x0f, 0xad, 0xe4, 0x47, 0x67, 0xb7, 0x0f,
+	0xdb, 0x0c, 0x0b, 0x4f, 0xd3, 0x9f, 0x12, 0xc0, 0x25, 0x4e, 0x3f, 0x1c, 0x9b, 0x01, 0xca, 0x68,
+	0x2a, 0xae, 0xb0, 0xec, 0xb3, 0xd6, 0xf4, 0x36, 0x68, 0x71, 0x82, 0x17, 0x01, 0x99, 0xe7, 0x67,
+	0x51, 0x43, 0x2b, 0xb9, 0x8c, 0xbd, 0xb3, 0x01, 0x7b, 0xb7, 0x8e, 0xfd, 0x0a, 0xb6, 0x19, 0xd6,
+	0xd2, 0x4d, 0x77, 0xbf, 0xba, 0xe9, 0xee, 0xd9, 0x61, 0xb8, 0x5f, 0xdd, 0x74, 0xf7, 0x9b, 0xd2,
+	0xe7, 0xcc, 0xa0, 0x8f, 0x48, 0xea, 0xa6, 0xf8, 0xc3, 0x99, 0x97, 0xd0, 0x2b, 0x1b, 0xd0, 0xab,
+	0x75, 0xf4, 0x3f, 0xc1, 0x40, 0x84, 0x2c, 0x5a, 0x66, 0x81, 0x13, 0x1a, 0x90, 0x88, 0xc5, 0x55,
+	0x90, 0x10, 0xcd, 0x17, 0x30, 0x10, 0xd9, 0x1a, 0xce, 0xa3, 0x34, 0x08, 0x19, 0x06, 0x05, 0xf5,
+	0xc5, 0xea, 0xcf, 0xd9, 0x62, 0xc6, 0xe2, 0x1b, 0x1c, 0xe2, 0xff, 0x92, 0x85, 0x01, 0x03, 0x11,
+	0xb2, 0x38, 0x69, 0x37, 0x98, 0x66, 0xb0, 0xe9, 0xbf, 0x7f, 0xd2, 0x22, 0xd8, 0xe6, 0xce, 0xaf,
+	0xa2, 0x5b, 0x92, 0x95, 0x98, 0xdf, 0x94, 0x3d, 0x24, 0x07, 0x3e, 0x73, 0x9b, 0x60, 0x37, 0xc5,
+	0x3e, 0x4f, 0x90, 0x10, 0xcd, 0x67, 0xd0, 0x0b, 0x5d, 0x9a, 0x0e, 0x29, 0xc6, 0x11, 0x77, 0xab,
+	0x65, 0x0b, 0x37, 0x18, 0xe7, 0xe4, 0xe7, 0x49, 0x82, 0xa3, 0x94, 0x11, 0xd4, 0x90, 0x10, 0x9d,
+	0x6b, 0x30, 0x0a, 0x32, 0xbc, 0x4c, 0x5f, 0x82, 0x46, 0xf9, 0x1a, 0x3b, 0xda, 0xdb, 0x67, 0x7b,
+	0xc7, 0xb5, 0x37, 0xc1, 0x71, 0x09, 0x24, 0x5a, 0x59, 0x3b, 0xbf, 0x4a, 0xf0, 0x11, 0xc2, 0x0b,
+	0x32, 0xc1, 0x0f, 0x67, 0x28, 0xa7, 0x26, 0xaf, 0xa8, 0x19, 0xa0, 0xb8, 0x61, 0xc8, 0xa0, 0x6b,
+	0x28, 0xfb, 0x7c, 0xf4, 0x91, 0xb7, 0xe0, 0x49, 0x1d, 0x44, 0xce, 0xec, 0xec, 0x2f, 0x0d, 0xf4,
+	0xb7, 0x8c, 0xc9, 0x0d, 0x63, 0x62, 0x7e, 0x0b, 0x5b, 0xec, 0x29, 0x63, 0x3e, 0x5f, 0x63, 0x58,
+	0x7e, 0xf2, 0xd8, 0x1f, 0xb7, 0xa9, 0x79, 0xca, 0xde, 0x81, 0x5e, 0x7e, 0x9c, 0x98, 0x9f, 0xac,
+	0xd9, 0x37, 0x3c, 0x77, 0xec, 0x17, 0x0f, 0x58, 0x71, 0xe7, 0xd7, 0xa0, 0x89, 0x75, 0xf3, 0xa0,
+	0x75, 0xcb, 0x3f, 0x85, 0x7a, 0x05, 0x9d, 0xfc, 0x19, 0x60, 0xae, 0x5b, 0x56, 0xde, 0x2c, 0xf6,
+	0x7e, 0xab, 0x9e, 0xbb, 0xba, 0x00, 0x95, 0xb1, 0x5d, 0x6f, 0x8f, 0x32, 0xcb, 0xe7, 0x2d, 0x5a,
+	0xee, 0x04, 0x41, 0x6f, 0x35, 0xfe, 0xcc, 0xc3, 0x35, 0xdb, 0xfa, 0x50, 0xb6, 0x9d, 0x4d, 0x26,
+	0x05, 0xc7, 0x7c, 0x3c, 0x36, 0x70, 0xac, 0x0c, 0x52, 0x7b, 0xbf, 0x55, 0xcf, 0x5d, 0xbd, 0x05,
+	0x4d, 0xb4, 0x51, 0x43, 0xf2, 0x6b, 0x6d, 0x6e, 0x1f, 0x6e, 0xb0, 0xe0, 0x0e, 0x5d, 0x18, 0x54,
+	0xbb, 0xd3, 0xfc, 0xb4, 0xa1, 0xa6, 0x0d, 0x67, 0xc8, 0xfe, 0xec, 0x41, 0xbb, 0x02, 0xb3, 0x18,
+	0xd7, 0x0d, 0x98, 0x6b, 0xcf, 0x04, 0xfb, 0x70, 0x83, 0x05, 0x77, 0xf8, 0x1a, 0x94, 0x73, 0xdf,
+	0x37, 0x9f, 0xad, 0x59, 0x16, 0x63, 0xde, 0xde, 0x6b, 0x56, 0x16, 0x1e, 0x2e, 0x71, 0xda, 0xe0,
+	0xa1, 0x18, 0xc6, 0xf6, 0x5e, 0xb3, 0xb2, 0xa8, 0x69, 0x3e, 0x4e, 0x1a, 0x6a, 0x5a, 0x19, 0x6d,
+	0xf6, 0x7e, 0xab, 0xbe, 0x70, 0x95, 0xdf, 0xe9, 0x0d, 0xae, 0x2a, 0xf3, 0xc5, 0xde, 0x6f, 0xd5,
+	0xe7, 0xae, 0xbe, 0x3e, 0xfb, 0xe5, 0x74, 0x1c, 0xa4, 0x77, 0xf3, 0xd1, 0xb1, 0x47, 0xa6, 0x27,
+	0xd7, 0xe7, 0xa9, 0x1b, 0xb9, 0x94, 0x92, 0xc5, 0xc9, 0x98, 0xbc, 0xcc, 0xb7, 0xbe, 0xcc, 0xf7,
+	0x9e, 0xc4, 0x93, 0xf1, 0x49, 0x3c, 0xfa, 0x2a, 0x1e, 0x8d, 0x3a, 0xec, 0x3f, 0xeb, 0x8b, 0xbf,
+	0x07, 0x00, 0x5e, 0x6c, 0x26, 0x7a, 0x77, 0x0d, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// OnlineSphinxClient is the client API for OnlineSphinx service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type OnlineSphinxClient interface {
+	Group(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*GroupResponse, error)
+	RegisterExpK(ctx context.Context, in *RegisterExpKRequest, opts ...grpc.CallOption) (*RegisterExpKResponse, error)
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*GroupResponse, error)
+	Enroll(ctx context.Context, in *EnrollRequest, opts ...grpc.CallOption) (*EnrollResponse, error)
+	ExpK(ctx context.Context, in *ExpKRequest, opts ...grpc.CallOption) (*ExpKResponse, error)
+	Challenge(ctx context.Context, in *ChallengeRequest, opts ...grpc.CallOption) (*ChallengeResponse, error)
+	Logout(ctx context.Context, in *LogoutRequest, opts ...grpc.CallOption) (*LogoutResponse, error)
+	Sessions(ctx context.Context, in *SessionsRequest, opts ...grpc.CallOption) (*SessionsResponse, error)
+	RevokeSessions(ctx context.Context, in *RevokeSessionsRequest, opts ...grpc.CallOption) (*RevokeSessionsResponse, error)
+	Metadata(ctx context.Context, in *MetadataRequest, opts ...grpc.CallOption) (*MetadataResponse, error)
+	Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*AddResponse, error)
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	Rotate(ctx context.Context, in *RotateRequest, opts ...grpc.CallOption) (*RotateResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+}
+
+type onlineSphinxClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewOnlineSphinxClient(cc grpc.ClientConnInterface) OnlineSphinxClient {
+	return &onlineSphinxClient{cc}
+}
+
+func (c *onlineSphinxClient) Group(ctx context.Context, in *GroupRequest, opts ...grpc.CallOption) (*GroupResponse, error) {
+	out := new(GroupResponse)
+	err := c.cc.Invoke(ctx, "/onlinesphinx.v1.OnlineSphinx/Group", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *onlineSphinxClient) RegisterExpK(ctx context.Context, in *RegisterExpKRequest, opts ...grpc.CallOption) (*RegisterExpKResponse, error) {
+	out := new(RegisterExpKResponse)
+	err := c.cc.Invoke(ctx, "/onlinesphinx.v1.OnlineSphinx/RegisterExpK", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *onlineSphinxClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*GroupResponse, error) {
+	out := new(GroupResponse)
+	err := c.cc.Invoke(ctx, "/onlinesphinx.v1.OnlineSphinx/Register", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *onlineSphinxClient) Enroll(ctx context.Context, in *EnrollRequest, opts ...grpc.CallOption) (*EnrollResponse, error) {
+	out := new(EnrollResponse)
+	err := c.cc.Invoke(ctx, "/onlinesphinx.v1.OnlineSphinx/Enroll", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *onlineSphinxClient) ExpK(ctx context.Context, in *ExpKRequest, opts ...grpc.CallOption) (*ExpKResponse, error) {
+	out := new(ExpKResponse)
+	err := c.cc.Invoke(ctx, "/onlinesphinx.v1.OnlineSphinx/ExpK", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *onlineSphinxClient) Challenge(ctx context.Context, in *ChallengeRequest, opts ...grpc.CallOption) (*ChallengeResponse, error) {
+	out := new(ChallengeResponse)
+	err := c.cc.Invoke(ctx, "/onlinesphinx.v1.OnlineSphinx/Challenge", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *onlineSphinxClient) Logout(ctx context.Context, in *LogoutRequest, opts ...grpc.CallOption) (*LogoutResponse, error) {
+	out := new(LogoutResponse)
+	err := c.cc.Invoke(ctx, "/onlinesphinx.v1.OnlineSphinx/Logout", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *onlineSphinxClient) Sessions(ctx context.Context, in *SessionsRequest, opts ...grpc.CallOption) (*SessionsResponse, error) {
+	out := new(SessionsResponse)
+	err := c.cc.Invoke(ctx, "/onlinesphinx.v1.OnlineSphinx/Sessions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *onlineSphinxClient) RevokeSessions(ctx context.Context, in *RevokeSessionsRequest, opts ...grpc.CallOption) (*RevokeSessionsResponse, error) {
+	out := new(RevokeSessionsResponse)
+	err := c.cc.Invoke(ctx, "/onlinesphinx.v1.OnlineSphinx/RevokeSessions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *onlineSphinxClient) Metadata(ctx context.Context, in *MetadataRequest, opts ...grpc.CallOption) (*MetadataResponse, error) {
+	out := new(MetadataResponse)
+	err := c.cc.Invoke(ctx, "/onlinesphinx.v1.OnlineSphinx/Metadata", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *onlineSphinxClient) Add(ctx context.Context, in *AddRequest, opts ...grpc.CallOption) (*AddResponse, error) {
+	out := new(AddResponse)
+	err := c.cc.Invoke(ctx, "/onlinesphinx.v1.OnlineSphinx/Add", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *onlineSphinxClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, "/onlinesphinx.v1.OnlineSphinx/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *onlineSphinxClient) Rotate(ctx context.Context, in *RotateRequest, opts ...grpc.CallOption) (*RotateResponse, error) {
+	out := new(RotateResponse)
+	err := c.cc.Invoke(ctx, "/onlinesphinx.v1.OnlineSphinx/Rotate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *onlineSphinxClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/onlinesphinx.v1.OnlineSphinx/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// OnlineSphinxServer is the server API for OnlineSphinx service.
+type OnlineSphinxServer interface {
+	Group(context.Context, *GroupRequest) (*GroupResponse, error)
+	RegisterExpK(context.Context, *RegisterExpKRequest) (*RegisterExpKResponse, error)
+	Register(context.Context, *RegisterRequest) (*GroupResponse, error)
+	Enroll(context.Context, *EnrollRequest) (*EnrollResponse, error)
+	ExpK(context.Context, *ExpKRequest) (*ExpKResponse, error)
+	Challenge(context.Context, *ChallengeRequest) (*ChallengeResponse, error)
+	Logout(context.Context, *LogoutRequest) (*LogoutResponse, error)
+	Sessions(context.Context, *SessionsRequest) (*SessionsResponse, error)
+	RevokeSessions(context.Context, *RevokeSessionsRequest) (*RevokeSessionsResponse, error)
+	Metadata(context.Context, *MetadataRequest) (*MetadataResponse, error)
+	Add(context.Context, *AddRequest) (*AddResponse, error)
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Rotate(context.Context, *RotateRequest) (*RotateResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+}
+
+// UnimplementedOnlineSphinxServer can be embedded to have forward compatible implementations.
+type UnimplementedOnlineSphinxServer struct {
+}
+
+func (*UnimplementedOnlineSphinxServer) Group(ctx context.Context, req *GroupRequest) (*GroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Group not implemented")
+}
+func (*UnimplementedOnlineSphinxServer) RegisterExpK(ctx context.Context, req *RegisterExpKRequest) (*RegisterExpKResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterExpK not implemented")
+}
+func (*UnimplementedOnlineSphinxServer) Register(ctx context.Context, req *RegisterRequest) (*GroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (*UnimplementedOnlineSphinxServer) Enroll(ctx context.Context, req *EnrollRequest) (*EnrollResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Enroll not implemented")
+}
+func (*UnimplementedOnlineSphinxServer) ExpK(ctx context.Context, req *ExpKRequest) (*ExpKResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExpK not implemented")
+}
+func (*UnimplementedOnlineSphinxServer) Challenge(ctx context.Context, req *ChallengeRequest) (*ChallengeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Challenge not implemented")
+}
+func (*UnimplementedOnlineSphinxServer) Logout(ctx context.Context, req *LogoutRequest) (*LogoutResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Logout not implemented")
+}
+func (*UnimplementedOnlineSphinxServer) Sessions(ctx context.Context, req *SessionsRequest) (*SessionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sessions not implemented")
+}
+func (*UnimplementedOnlineSphinxServer) RevokeSessions(ctx context.Context, req *RevokeSessionsRequest) (*RevokeSessionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeSessions not implemented")
+}
+func (*UnimplementedOnlineSphinxServer) Metadata(ctx context.Context, req *MetadataRequest) (*MetadataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Metadata not implemented")
+}
+func (*UnimplementedOnlineSphinxServer) Add(ctx context.Context, req *AddRequest) (*AddResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Add not implemented")
+}
+func (*UnimplementedOnlineSphinxServer) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (*UnimplementedOnlineSphinxServer) Rotate(ctx context.Context, req *RotateRequest) (*RotateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rotate not implemented")
+}
+func (*UnimplementedOnlineSphinxServer) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+
+func RegisterOnlineSphinxServer(s *grpc.Server, srv OnlineSphinxServer) {
+	s.RegisterService(&_OnlineSphinx_serviceDesc, srv)
+}
+
+func _OnlineSphinx_Group_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OnlineSphinxServer).Group(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/onlinesphinx.v1.OnlineSphinx/Group",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OnlineSphinxServer).Group(ctx, req.(*GroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OnlineSphinx_RegisterExpK_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterExpKRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OnlineSphinxServer).RegisterExpK(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/onlinesphinx.v1.OnlineSphinx/RegisterExpK",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OnlineSphinxServer).RegisterExpK(ctx, req.(*RegisterExpKRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OnlineSphinx_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OnlineSphinxServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/onlinesphinx.v1.OnlineSphinx/Register",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OnlineSphinxServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OnlineSphinx_Enroll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnrollRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OnlineSphinxServer).Enroll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/onlinesphinx.v1.OnlineSphinx/Enroll",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OnlineSphinxServer).Enroll(ctx, req.(*EnrollRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OnlineSphinx_ExpK_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExpKRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OnlineSphinxServer).ExpK(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/onlinesphinx.v1.OnlineSphinx/ExpK",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OnlineSphinxServer).ExpK(ctx, req.(*ExpKRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OnlineSphinx_Challenge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChallengeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OnlineSphinxServer).Challenge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/onlinesphinx.v1.OnlineSphinx/Challenge",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OnlineSphinxServer).Challenge(ctx, req.(*ChallengeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OnlineSphinx_Logout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogoutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OnlineSphinxServer).Logout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/onlinesphinx.v1.OnlineSphinx/Logout",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OnlineSphinxServer).Logout(ctx, req.(*LogoutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OnlineSphinx_Sessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OnlineSphinxServer).Sessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/onlinesphinx.v1.OnlineSphinx/Sessions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OnlineSphinxServer).Sessions(ctx, req.(*SessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OnlineSphinx_RevokeSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OnlineSphinxServer).RevokeSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/onlinesphinx.v1.OnlineSphinx/RevokeSessions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OnlineSphinxServer).RevokeSessions(ctx, req.(*RevokeSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OnlineSphinx_Metadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MetadataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OnlineSphinxServer).Metadata(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/onlinesphinx.v1.OnlineSphinx/Metadata",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OnlineSphinxServer).Metadata(ctx, req.(*MetadataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OnlineSphinx_Add_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OnlineSphinxServer).Add(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/onlinesphinx.v1.OnlineSphinx/Add",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OnlineSphinxServer).Add(ctx, req.(*AddRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OnlineSphinx_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OnlineSphinxServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/onlinesphinx.v1.OnlineSphinx/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OnlineSphinxServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OnlineSphinx_Rotate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OnlineSphinxServer).Rotate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/onlinesphinx.v1.OnlineSphinx/Rotate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OnlineSphinxServer).Rotate(ctx, req.(*RotateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _OnlineSphinx_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(OnlineSphinxServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/onlinesphinx.v1.OnlineSphinx/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(OnlineSphinxServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _OnlineSphinx_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "onlinesphinx.v1.OnlineSphinx",
+	HandlerType: (*OnlineSphinxServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Group",
+			Handler:    _OnlineSphinx_Group_Handler,
+		},
+		{
+			MethodName: "RegisterExpK",
+			Handler:    _OnlineSphinx_RegisterExpK_Handler,
+		},
+		{
+			MethodName: "Register",
+			Handler:    _OnlineSphinx_Register_Handler,
+		},
+		{
+			MethodName: "Enroll",
+			Handler:    _OnlineSphinx_Enroll_Handler,
+		},
+		{
+			MethodName: "ExpK",
+			Handler:    _OnlineSphinx_ExpK_Handler,
+		},
+		{
+			MethodName: "Challenge",
+			Handler:    _OnlineSphinx_Challenge_Handler,
+		},
+		{
+			MethodName: "Logout",
+			Handler:    _OnlineSphinx_Logout_Handler,
+		},
+		{
+			MethodName: "Sessions",
+			Handler:    _OnlineSphinx_Sessions_Handler,
+		},
+		{
+			MethodName: "RevokeSessions",
+			Handler:    _OnlineSphinx_RevokeSessions_Handler,
+		},
+		{
+			MethodName: "Metadata",
+			Handler:    _OnlineSphinx_Metadata_Handler,
+		},
+		{
+			MethodName: "Add",
+			Handler:    _OnlineSphinx_Add_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _OnlineSphinx_Get_Handler,
+		},
+		{
+			MethodName: "Rotate",
+			Handler:    _OnlineSphinx_Rotate_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _OnlineSphinx_Delete_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sphinx.proto",
+}
@@ -0,0 +1,205 @@
+// Package pinentry reads passwords by a pinentry program, e.g. pinentry-curses or pinentry-mac,
+// which speaks the Assuan protocol of GnuPG on its standard input and output.
+package pinentry
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCancelled is returned when the user cancels the dialog of the pinentry program.
+var ErrCancelled = errors.New("pinentry cancelled")
+
+// Options are the texts of the dialog.
+type Options struct {
+	Title       string
+	Description string
+	Prompt      string
+	// Repeat asks for the password twice, e.g. for new passwords, and lets pinentry compare them.
+	Repeat string
+	// Error is shown e.g. after a wrong password.
+	Error string
+
+	// TTYName and TTYType are the terminal of curses pinentries, by default the terminal
+	// of GPG_TTY or of the standard input and TERM, like gpg-agent passes them.
+	TTYName string
+	TTYType string
+}
+
+// GetPin runs program and returns the password entered by the user.
+func GetPin(program string, opts Options) (string, error) {
+	if opts.TTYName == "" {
+		opts.TTYName = ttyName()
+	}
+	if opts.TTYType == "" {
+		opts.TTYType = os.Getenv("TERM")
+	}
+
+	cmd := exec.Command(program)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", errors.Wrap(err, "GetPin: failed to create stdin pipe")
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", errors.Wrap(err, "GetPin: failed to create stdout pipe")
+	}
+	if err := cmd.Start(); err != nil {
+		return "", errors.Wrapf(err, "GetPin: failed to start %s", program)
+	}
+	defer cmd.Wait()
+	defer stdin.Close()
+
+	pin, err := getPin(stdout, stdin, opts)
+	if err != nil {
+		return "", errors.Wrapf(err, "GetPin: %s", program)
+	}
+	return pin, nil
+}
+
+// getPin talks Assuan to a pinentry, writing commands to w and reading responses from r.
+func getPin(r io.Reader, w io.Writer, opts Options) (string, error) {
+	conn := &assuan{r: bufio.NewReader(r), w: w}
+	if _, err := conn.response(); err != nil {
+		return "", errors.Wrap(err, "failed to connect")
+	}
+
+	options := []struct{ name, value string }{
+		{"ttyname", opts.TTYName},
+		{"ttytype", opts.TTYType},
+	}
+	for _, o := range options {
+		if o.value == "" {
+			continue
+		}
+		if _, err := conn.call("OPTION " + o.name + "=" + escape(o.value)); err != nil {
+			return "", errors.Wrapf(err, "failed to set option %s", o.name)
+		}
+	}
+
+	commands := []struct{ name, arg string }{
+		{"SETTITLE", opts.Title},
+		{"SETDESC", opts.Description},
+		{"SETPROMPT", opts.Prompt},
+		{"SETREPEAT", opts.Repeat},
+		{"SETERROR", opts.Error},
+	}
+	for _, c := range commands {
+		if c.arg == "" {
+			continue
+		}
+		if _, err := conn.call(c.name + " " + escape(c.arg)); err != nil {
+			return "", errors.Wrapf(err, "failed to %s", c.name)
+		}
+	}
+
+	pin, err := conn.call("GETPIN")
+	if err != nil {
+		return "", err
+	}
+	conn.call("BYE")
+	return pin, nil
+}
+
+// ttyName returns GPG_TTY or the terminal of the standard input, empty if there is none.
+func ttyName() string {
+	if tty := os.Getenv("GPG_TTY"); tty != "" {
+		return tty
+	}
+	cmd := exec.Command("tty")
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// assuan is the client side of an Assuan connection.
+type assuan struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+// call sends command and returns the data of the response.
+func (a *assuan) call(command string) (string, error) {
+	if _, err := io.WriteString(a.w, command+"\n"); err != nil {
+		return "", errors.Wrap(err, "failed to send command")
+	}
+	return a.response()
+}
+
+// response reads the lines of a response up to OK or ERR and returns the data lines.
+func (a *assuan) response() (string, error) {
+	var data strings.Builder
+	for {
+		line, err := a.r.ReadString('\n')
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read response")
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "OK" || strings.HasPrefix(line, "OK "):
+			return data.String(), nil
+		case strings.HasPrefix(line, "ERR "):
+			return "", responseError(line[len("ERR "):])
+		case strings.HasPrefix(line, "D "):
+			data.WriteString(unescape(line[len("D "):]))
+		}
+		// status lines "S" and comments "#" are ignored
+	}
+}
+
+// errCodeCancelled is the GnuPG error code of a cancelled dialog without error source.
+const errCodeCancelled = 99
+
+// responseError returns the error of an ERR response, i.e. its code and description.
+func responseError(response string) error {
+	fields := strings.SplitN(response, " ", 2)
+	code, err := strconv.ParseUint(fields[0], 10, 32)
+	if err == nil && code&0xffff == errCodeCancelled {
+		return ErrCancelled
+	}
+	if len(fields) == 2 {
+		return errors.Errorf("pinentry error %s: %s", fields[0], fields[1])
+	}
+	return errors.Errorf("pinentry error %s", fields[0])
+}
+
+// escape percent-escapes the characters which must not appear in Assuan lines.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '%', '\r', '\n':
+			fmt.Fprintf(&b, "%%%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// unescape decodes the percent-escaped data of a D line.
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
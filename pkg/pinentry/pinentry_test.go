@@ -0,0 +1,104 @@
+package pinentry
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestGetPin(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         Options
+		responses    string
+		wantCommands string
+		want         string
+		wantErr      error
+	}{
+		{
+			name:         "should return the pin",
+			opts:         Options{Prompt: "Password:"},
+			responses:    "OK Pleased to meet you\nOK\nS PASSWORD_FROM_CACHE\nD pass\nD word\nOK\nOK closing connection\n",
+			wantCommands: "SETPROMPT Password:\nGETPIN\nBYE\n",
+			want:         "password",
+		},
+		{
+			name:         "should escape commands and unescape data",
+			opts:         Options{Description: "100%\nsure"},
+			responses:    "OK\nOK\nD %25%0A\nOK\nOK\n",
+			wantCommands: "SETDESC 100%25%0Asure\nGETPIN\nBYE\n",
+			want:         "%\n",
+		},
+		{
+			name:         "should set the terminal before asking",
+			opts:         Options{Prompt: "Password:", TTYName: "/dev/pts/1", TTYType: "xterm"},
+			responses:    "OK\nOK\nOK\nOK\nD password\nOK\nOK\n",
+			wantCommands: "OPTION ttyname=/dev/pts/1\nOPTION ttytype=xterm\nSETPROMPT Password:\nGETPIN\nBYE\n",
+			want:         "password",
+		},
+		{
+			name:         "should return ErrCancelled",
+			responses:    "OK\nERR 83886179 Operation cancelled <Pinentry>\n",
+			wantCommands: "GETPIN\n",
+			wantErr:      ErrCancelled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			var commands bytes.Buffer
+
+			// when
+			got, err := getPin(strings.NewReader(tt.responses), &commands, tt.opts)
+
+			// then
+			if errors.Cause(err) != tt.wantErr {
+				t.Fatalf("getPin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("getPin() = %q, want %q", got, tt.want)
+			}
+			if commands.String() != tt.wantCommands {
+				t.Errorf("getPin() commands = %q, want %q", commands.String(), tt.wantCommands)
+			}
+		})
+	}
+
+	t.Run("should return errors of options", func(t *testing.T) {
+		_, err := getPin(strings.NewReader("OK\nERR 83886254 No such device\n"), &bytes.Buffer{}, Options{TTYName: "/dev/pts/1"})
+		if err == nil || !strings.Contains(err.Error(), "ttyname") {
+			t.Errorf("getPin() error = %v, want error of option ttyname", err)
+		}
+	})
+
+	t.Run("should return other errors", func(t *testing.T) {
+		_, err := getPin(strings.NewReader("OK\nERR 83886254 No such device\n"), &bytes.Buffer{}, Options{})
+		if err == nil || errors.Cause(err) == ErrCancelled {
+			t.Errorf("getPin() error = %v, want pinentry error", err)
+		}
+	})
+}
+
+func TestTTYName(t *testing.T) {
+	// given
+	old, ok := os.LookupEnv("GPG_TTY")
+	os.Setenv("GPG_TTY", "/dev/pts/7")
+	defer func() {
+		if ok {
+			os.Setenv("GPG_TTY", old)
+		} else {
+			os.Unsetenv("GPG_TTY")
+		}
+	}()
+
+	// when
+	got := ttyName()
+
+	// then
+	if got != "/dev/pts/7" {
+		t.Errorf("ttyName() = %q, want GPG_TTY %q", got, "/dev/pts/7")
+	}
+}
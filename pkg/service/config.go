@@ -3,28 +3,53 @@ package service
 import (
 	"hash"
 	"math/big"
+	"time"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/crypto"
+	"github.com/pkg/errors"
 )
 
 // Configuration contains cryptographical key material needed for an Online SPHINX service.
 type Configuration struct {
-	sID  *big.Int         // service ID
-	k    *big.Int         // service key k
-	q0   *big.Int         // common ElGammal component Q_0
-	hash func() hash.Hash // hash function
-	bits *big.Int         // bits used in crypthographic directives
-	max  *big.Int
+	sID   *big.Int         // service ID
+	k     *big.Int         // service key k
+	q0    *big.Int         // common ElGammal component Q_0
+	hash  func() hash.Hash // hash function
+	group crypto.Group     // group all exponentiations happen in
+	oprf  crypto.OPRF      // verifiable OPRF of RFC 9497 evaluated with k
+	pk    *big.Int         // public key k*G of the OPRF
+	grace time.Duration    // the previous version of a rotated domain stays retrievable
 }
 
-// NewConfiguration initialize and returns a Configuration
-func NewConfiguration(sID, k, q0, bits *big.Int, hash func() hash.Hash) Configuration {
-	max := new(big.Int)
-	max.Exp(big.NewInt(2), bits, nil)
-	return Configuration{
-		sID:  sID,
-		k:    k,
-		q0:   q0,
-		bits: bits,
-		hash: hash,
-		max:  max,
+// DefaultGracePeriod is how long the previous version of a rotated domain stays retrievable.
+const DefaultGracePeriod = 7 * 24 * time.Hour
+
+// NewConfiguration initialize and returns a Configuration.
+// Returns an error if k is not an exponent in [1, q) or q0 is not a group element.
+func NewConfiguration(sID, k, q0 *big.Int, group crypto.Group, hash func() hash.Hash) (Configuration, error) {
+	if k.Sign() <= 0 || k.Cmp(group.Order()) >= 0 {
+		return Configuration{}, errors.Errorf("NewConfiguration: k is not within [1, q) of group %s", group.Name())
+	}
+	if !group.IsElement(q0) {
+		return Configuration{}, errors.Errorf("NewConfiguration: q0 is not an element of group %s", group.Name())
 	}
+
+	oprf := crypto.NewOPRF(group, crypto.ModeVOPRF)
+	return Configuration{
+		sID:   sID,
+		k:     k,
+		q0:    q0,
+		hash:  hash,
+		group: group,
+		oprf:  oprf,
+		pk:    oprf.PublicKey(k),
+		grace: DefaultGracePeriod,
+	}, nil
+}
+
+// WithGracePeriod returns a copy of c keeping the previous version of rotated domains
+// retrievable for d, zero disables the retrieval of previous versions.
+func (c Configuration) WithGracePeriod(d time.Duration) Configuration {
+	c.grace = d
+	return c
 }
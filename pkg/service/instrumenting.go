@@ -1,10 +1,13 @@
 package service
 
 import (
+	"context"
 	"math/big"
 	"time"
 
 	"github.com/go-kit/kit/metrics"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/crypto"
 )
 
 // NewInstrumentingMiddleware returns an instance of the instrumented middleware.
@@ -24,69 +27,107 @@ type instrumentingService struct {
 	Service
 }
 
-func (s *instrumentingService) Register(cID *big.Int) (err error) {
+func (s *instrumentingService) RegisterExpK(ctx context.Context, b, q *big.Int) (bd, q0 *big.Int, proof crypto.Proof, err error) {
+
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "RegisterExpK").Add(1)
+		s.requestLatency.With("method", "RegisterExpK").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.Service.RegisterExpK(ctx, b, q)
+}
+
+func (s *instrumentingService) Register(ctx context.Context, cID, verifier *big.Int) (err error) {
 
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "Register").Add(1)
 		s.requestLatency.With("method", "Register").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.Service.Register(cID)
+	return s.Service.Register(ctx, cID, verifier)
 }
 
-func (s *instrumentingService) ExpK(cID, cNonce, b, q *big.Int) (ski, sID, sNonce, bd, q0, kv *big.Int, err error) {
+func (s *instrumentingService) Enroll(ctx context.Context, cID, verifier *big.Int, code string, proof crypto.PossessionProof) (err error) {
+
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "Enroll").Add(1)
+		s.requestLatency.With("method", "Enroll").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.Service.Enroll(ctx, cID, verifier, code, proof)
+}
+
+func (s *instrumentingService) ExpK(ctx context.Context, cID, cNonce, b, q, x *big.Int) (ski, sID, sNonce, bd, q0, y *big.Int, proof crypto.Proof, err error) {
 
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "ExpK").Add(1)
 		s.requestLatency.With("method", "ExpK").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.Service.ExpK(cID, cNonce, b, q)
+	return s.Service.ExpK(ctx, cID, cNonce, b, q, x)
 }
-func (s *instrumentingService) Challenge(ski, g, q *big.Int) (r *big.Int, err error) {
+func (s *instrumentingService) Challenge(ctx context.Context, ski, g, q *big.Int) (r *big.Int, err error) {
 
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "Challenge").Add(1)
 		s.requestLatency.With("method", "Challenge").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.Service.Challenge(ski, g, q)
+	return s.Service.Challenge(ctx, ski, g, q)
 }
 
-func (s *instrumentingService) VerifyMAC(mac []byte, ski *big.Int, data ...[]byte) (err error) {
+func (s *instrumentingService) VerifyMAC(ctx context.Context, mac []byte, ski *big.Int, data ...[]byte) (err error) {
 
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "VerifyMAC").Add(1)
 		s.requestLatency.With("method", "VerifyMAC").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.Service.VerifyMAC(mac, ski, data...)
+	return s.Service.VerifyMAC(ctx, mac, ski, data...)
 }
 
-func (s *instrumentingService) GetMetadata(cID *big.Int) (domains []string, err error) {
+func (s *instrumentingService) GetMetadata(ctx context.Context, cID *big.Int) (domains []string, err error) {
 
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "GetMetadata").Add(1)
 		s.requestLatency.With("method", "GetMetadata").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.Service.GetMetadata(cID)
+	return s.Service.GetMetadata(ctx, cID)
 }
-func (s *instrumentingService) Add(cID *big.Int, domain string) (err error) {
+func (s *instrumentingService) Add(ctx context.Context, cID *big.Int, domain, metadata string) (err error) {
 
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "Add").Add(1)
 		s.requestLatency.With("method", "Add").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.Service.Add(cID, domain)
+	return s.Service.Add(ctx, cID, domain, metadata)
 }
-func (s *instrumentingService) Get(cID *big.Int, domain string, bmk *big.Int, q *big.Int) (bj, qj *big.Int, err error) {
+func (s *instrumentingService) Get(ctx context.Context, cID *big.Int, domain string, bmk *big.Int, q *big.Int, previous bool) (bj, qj *big.Int, metadata string, err error) {
 
 	defer func(begin time.Time) {
 		s.requestCount.With("method", "Get").Add(1)
 		s.requestLatency.With("method", "Get").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return s.Service.Get(cID, domain, bmk, q)
+	return s.Service.Get(ctx, cID, domain, bmk, q, previous)
+}
+func (s *instrumentingService) Rotate(ctx context.Context, cID *big.Int, domain string) (version int, previousUntil time.Time, err error) {
+
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "Rotate").Add(1)
+		s.requestLatency.With("method", "Rotate").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.Service.Rotate(ctx, cID, domain)
+}
+func (s *instrumentingService) Delete(ctx context.Context, cID *big.Int, domain string) (err error) {
+
+	defer func(begin time.Time) {
+		s.requestCount.With("method", "Delete").Add(1)
+		s.requestLatency.With("method", "Delete").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return s.Service.Delete(ctx, cID, domain)
 }
@@ -1,143 +1,148 @@
 package service
 
 import (
-	"fmt"
+	"context"
 	"math/big"
 	"time"
 
 	"github.com/go-kit/kit/log"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
+	"github.com/LAtanassov/go-online-sphinx/pkg/crypto"
 )
 
-// NewLoggingMiddleware returns a new instance of a logging middleware.
-func NewLoggingMiddleware(logger log.Logger) Middleware {
+// NewLoggingMiddleware returns a new instance of a logging middleware,
+// which logs according to the redaction policy r.
+func NewLoggingMiddleware(logger log.Logger, r *Redactor) Middleware {
 	return func(next Service) Service {
-		return &loggingService{logger, next}
+		return &loggingService{logger, r, next}
 	}
 }
 
 type loggingService struct {
-	logger log.Logger
+	logger   log.Logger
+	redactor *Redactor
 	Service
 }
 
-func (s *loggingService) Register(cID *big.Int) (err error) {
-
-	defer func(begin time.Time) {
-		s.logger.Log(
-			"method", "Register",
-			"cID", cID.Text(16),
+// log logs keyvals of the request of ctx failing with err, if enabled by the redaction policy.
+func (s *loggingService) log(ctx context.Context, err error, begin time.Time, keyvals ...interface{}) {
+	if !s.redactor.Enabled(err) {
+		return
+	}
+	if id := contract.RequestID(ctx); id != "" {
+		keyvals = append(keyvals, "request", id)
+	}
+	if err != nil {
+		keyvals = append(keyvals, "err", s.redactor.Error(err))
+	}
+	s.logger.Log(append(keyvals, "took", time.Since(begin))...)
+}
 
-			"err", fmt.Sprintf("%+v", err),
+// public appends the public values to keyvals at debug level.
+func (s *loggingService) public(keyvals []interface{}, values ...interface{}) []interface{} {
+	if !s.redactor.Debug() {
+		return keyvals
+	}
+	for i := 1; i < len(values); i += 2 {
+		if n, ok := values[i].(*big.Int); ok {
+			values[i] = s.redactor.Public(n)
+		}
+	}
+	return append(keyvals, values...)
+}
 
-			"took", time.Since(begin),
-		)
+func (s *loggingService) RegisterExpK(ctx context.Context, b, q *big.Int) (bd, q0 *big.Int, proof crypto.Proof, err error) {
+	defer func(begin time.Time) {
+		s.log(ctx, err, begin, s.public([]interface{}{"method", "RegisterExpK"}, "b", b)...)
 	}(time.Now())
 
-	return s.Service.Register(cID)
+	return s.Service.RegisterExpK(ctx, b, q)
 }
 
-func (s *loggingService) ExpK(cID, cNonce, b, q *big.Int) (ski, sID, sNonce, bd, q0, kv *big.Int, err error) {
+func (s *loggingService) Register(ctx context.Context, cID, verifier *big.Int) (err error) {
 	defer func(begin time.Time) {
-		s.logger.Log(
-			"method", "ExpK",
-			"cID", cID.Text(16),
-			"cNonce", cNonce.Text(16),
-			"b", b.Text(16),
-			"q", q.Text(16),
-
-			"ski", ski.Text(16),
-			"sID", sID.Text(16),
-			"sNonce", sNonce.Text(16),
-			"bd", bd.Text(16),
-			"q0", q0.Text(16),
-			"kv", kv.Text(16),
-			"err", fmt.Sprintf("%+v", err),
-
-			"took", time.Since(begin),
-		)
+		s.log(ctx, err, begin, s.public([]interface{}{"method", "Register", "cID", s.redactor.Int(cID)}, "verifier", verifier)...)
 	}(time.Now())
 
-	return s.Service.ExpK(cID, cNonce, b, q)
+	return s.Service.Register(ctx, cID, verifier)
 }
-func (s *loggingService) Challenge(ski, g, q *big.Int) (r *big.Int, err error) {
+
+func (s *loggingService) Enroll(ctx context.Context, cID, verifier *big.Int, code string, proof crypto.PossessionProof) (err error) {
 	defer func(begin time.Time) {
-		s.logger.Log(
-			"method", "Challenge",
-			"ski", ski.Text(16),
-			"g", g.Text(16),
-			"q", q.Text(16),
+		s.log(ctx, err, begin, s.public([]interface{}{"method", "Enroll", "cID", s.redactor.Int(cID)}, "verifier", verifier)...)
+	}(time.Now())
 
-			"r", r.Text(16),
-			"err", fmt.Sprintf("%+v", err),
+	return s.Service.Enroll(ctx, cID, verifier, code, proof)
+}
 
-			"took", time.Since(begin),
-		)
+func (s *loggingService) ExpK(ctx context.Context, cID, cNonce, b, q, x *big.Int) (ski, sID, sNonce, bd, q0, y *big.Int, proof crypto.Proof, err error) {
+	defer func(begin time.Time) {
+		keyvals := []interface{}{"method", "ExpK", "cID", s.redactor.Int(cID), "sID", s.redactor.Int(sID)}
+		s.log(ctx, err, begin, s.public(keyvals, "b", b, "x", x, "y", y)...)
 	}(time.Now())
 
-	return s.Service.Challenge(ski, g, q)
+	return s.Service.ExpK(ctx, cID, cNonce, b, q, x)
 }
 
-func (s *loggingService) VerifyMAC(mac []byte, ski *big.Int, data ...[]byte) (err error) {
+func (s *loggingService) Challenge(ctx context.Context, ski, g, q *big.Int) (r *big.Int, err error) {
 	defer func(begin time.Time) {
-		s.logger.Log(
-			"method", "VerifyMAC",
-			"mac", mac,
-			"ski", ski.Text(16),
+		s.log(ctx, err, begin, s.public([]interface{}{"method", "Challenge"}, "g", g)...)
+	}(time.Now())
 
-			"err", fmt.Sprintf("%+v", err),
+	return s.Service.Challenge(ctx, ski, g, q)
+}
 
-			"took", time.Since(begin),
-		)
+func (s *loggingService) VerifyMAC(ctx context.Context, mac []byte, ski *big.Int, data ...[]byte) (err error) {
+	defer func(begin time.Time) {
+		s.log(ctx, err, begin, "method", "VerifyMAC")
 	}(time.Now())
 
-	return s.Service.VerifyMAC(mac, ski, data...)
+	return s.Service.VerifyMAC(ctx, mac, ski, data...)
 }
 
-func (s *loggingService) GetMetadata(cID *big.Int) (domains []string, err error) {
+func (s *loggingService) GetMetadata(ctx context.Context, cID *big.Int) (domains []string, err error) {
 	defer func(begin time.Time) {
-		s.logger.Log(
-			"method", "GetMetadata",
-			"cID", cID.Text(16),
+		s.log(ctx, err, begin, "method", "GetMetadata", "cID", s.redactor.Int(cID), "domains", len(domains))
+	}(time.Now())
 
-			"err", fmt.Sprintf("%+v", err),
+	return s.Service.GetMetadata(ctx, cID)
+}
 
-			"took", time.Since(begin),
-		)
+func (s *loggingService) Add(ctx context.Context, cID *big.Int, domain, metadata string) (err error) {
+	defer func(begin time.Time) {
+		s.log(ctx, err, begin, "method", "Add", "cID", s.redactor.Int(cID), "domain", s.redactor.ID(domain), "metadata", len(metadata))
 	}(time.Now())
 
-	return s.Service.GetMetadata(cID)
+	return s.Service.Add(ctx, cID, domain, metadata)
 }
-func (s *loggingService) Add(cID *big.Int, domain string) (err error) {
+
+func (s *loggingService) Get(ctx context.Context, cID *big.Int, domain string, bmk *big.Int, q *big.Int, previous bool) (bj, qj *big.Int, metadata string, err error) {
 	defer func(begin time.Time) {
-		s.logger.Log(
-			"method", "Add",
-			"cID", cID.Text(16),
-			"domain", domain,
+		s.log(ctx, err, begin, "method", "Get", "cID", s.redactor.Int(cID), "domain", s.redactor.ID(domain), "previous", previous)
+	}(time.Now())
 
-			"err", fmt.Sprintf("%+v", err),
+	return s.Service.Get(ctx, cID, domain, bmk, q, previous)
+}
 
-			"took", time.Since(begin),
+func (s *loggingService) Rotate(ctx context.Context, cID *big.Int, domain string) (version int, previousUntil time.Time, err error) {
+	defer func(begin time.Time) {
+		s.log(ctx, err, begin,
+			"method", "Rotate",
+			"cID", s.redactor.Int(cID),
+			"domain", s.redactor.ID(domain),
+			"version", version,
+			"previousUntil", previousUntil,
 		)
 	}(time.Now())
 
-	return s.Service.Add(cID, domain)
+	return s.Service.Rotate(ctx, cID, domain)
 }
-func (s *loggingService) Get(cID *big.Int, domain string, bmk *big.Int, q *big.Int) (bj, qj *big.Int, err error) {
+
+func (s *loggingService) Delete(ctx context.Context, cID *big.Int, domain string) (err error) {
 	defer func(begin time.Time) {
-		s.logger.Log(
-			"method", "Get",
-			"cID", cID.Text(16),
-			"domain", domain,
-			"bmk", bmk.Text(16),
-			"q", q.Text(16),
-
-			"bj", bj.Text(16),
-			"qj", qj.Text(16),
-			"err", fmt.Sprintf("%+v", err),
-
-			"took", time.Since(begin),
-		)
+		s.log(ctx, err, begin, "method", "Delete", "cID", s.redactor.Int(cID), "domain", s.redactor.ID(domain))
 	}(time.Now())
 
-	return s.Service.Get(cID, domain, bmk, q)
+	return s.Service.Delete(ctx, cID, domain)
 }
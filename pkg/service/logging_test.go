@@ -0,0 +1,157 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/client"
+	"github.com/LAtanassov/go-online-sphinx/pkg/crypto"
+)
+
+// secretService records the secrets passing the service.
+type secretService struct {
+	ints  []*big.Int
+	bytes [][]byte
+	Service
+}
+
+func (s *secretService) record(ints ...*big.Int) {
+	s.ints = append(s.ints, ints...)
+}
+
+func (s *secretService) RegisterExpK(ctx context.Context, b, q *big.Int) (bd, q0 *big.Int, proof crypto.Proof, err error) {
+	bd, q0, proof, err = s.Service.RegisterExpK(ctx, b, q)
+	s.record(bd, q0)
+	return bd, q0, proof, err
+}
+
+func (s *secretService) Register(ctx context.Context, cID, verifier *big.Int) error {
+	s.record(cID)
+	return s.Service.Register(ctx, cID, verifier)
+}
+
+func (s *secretService) ExpK(ctx context.Context, cID, cNonce, b, q, x *big.Int) (ski, sID, sNonce, bd, q0, y *big.Int, proof crypto.Proof, err error) {
+	ski, sID, sNonce, bd, q0, y, proof, err = s.Service.ExpK(ctx, cID, cNonce, b, q, x)
+	s.record(cID, ski, sID, bd, q0)
+	return ski, sID, sNonce, bd, q0, y, proof, err
+}
+
+func (s *secretService) Challenge(ctx context.Context, ski, g, q *big.Int) (*big.Int, error) {
+	r, err := s.Service.Challenge(ctx, ski, g, q)
+	s.record(ski, r)
+	return r, err
+}
+
+func (s *secretService) VerifyMAC(ctx context.Context, mac []byte, ski *big.Int, data ...[]byte) error {
+	s.bytes = append(s.bytes, mac)
+	return s.Service.VerifyMAC(ctx, mac, ski, data...)
+}
+
+func (s *secretService) Get(ctx context.Context, cID *big.Int, domain string, bmk *big.Int, q *big.Int, previous bool) (bj, qj *big.Int, metadata string, err error) {
+	bj, qj, metadata, err = s.Service.Get(ctx, cID, domain, bmk, q, previous)
+	s.record(bmk, bj, qj)
+	return bj, qj, metadata, err
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	t.Run("should not log secrets of a full login", func(t *testing.T) {
+		// given
+		g, err := crypto.GroupByName("p256")
+		if err != nil {
+			t.Fatalf("GroupByName() error = %v", err)
+		}
+		k, _ := g.RandomScalar()
+		q0, _ := g.RandomElement()
+		cfg, err := NewConfiguration(big.NewInt(1), k, q0, g, sha256.New)
+		if err != nil {
+			t.Fatalf("NewConfiguration() error = %v", err)
+		}
+
+		var buf bytes.Buffer
+		logger := log.NewLogfmtLogger(log.NewSyncWriter(&buf))
+		redactor := NewRedactor(LogDebug, nil)
+
+		secrets := &secretService{Service: New(NewUserRepository(), NewVaultRepository(), cfg)}
+		svc := NewLoggingMiddleware(logger, redactor)(secrets)
+		ts := httptest.NewServer(newTestMux(NewHTTPTransport(svc, newTestSessionManager(t), logger).WithRedactor(redactor)))
+		defer ts.Close()
+
+		ccfg, err := client.NewConfiguration(ts.URL, 256, sha256.New)
+		if err != nil {
+			t.Fatalf("client.NewConfiguration() error = %v", err)
+		}
+		jar, _ := cookiejar.New(nil)
+		clt := client.New(&http.Client{Jar: jar}, ccfg, client.NewInMemoryUserRepository())
+
+		// when
+		if err := clt.Register(context.Background(), "alice", "correct horse"); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+		if err := clt.Login(context.Background(), "alice", "wrong horse"); err == nil {
+			t.Fatalf("Login() with wrong password succeeded")
+		}
+		if err := clt.Login(context.Background(), "alice", "correct horse"); err != nil {
+			t.Fatalf("Login() error = %v", err)
+		}
+		if err := clt.Add(context.Background(), "secret.example.com"); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		pwd, err := clt.Get(context.Background(), "secret.example.com")
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if _, err := clt.Get(context.Background(), "unknown.example.com"); err == nil {
+			t.Fatalf("Get() of unknown domain succeeded")
+		}
+		if err := clt.Logout(context.Background()); err != nil {
+			t.Fatalf("Logout() error = %v", err)
+		}
+
+		// then
+		out := buf.String()
+		if !strings.Contains(out, "method=Get") || !strings.Contains(out, "handler=get") {
+			t.Fatalf("log output misses requests:\n%s", out)
+		}
+		forbidden := []string{"alice", "correct horse", "wrong horse", "secret.example.com", "unknown.example.com", pwd, k.Text(16), k.Text(10), q0.Text(16), q0.Text(10)}
+		for _, n := range secrets.ints {
+			if n != nil && n.BitLen() > 64 {
+				forbidden = append(forbidden, n.Text(16), n.Text(10), base64.StdEncoding.EncodeToString(n.Bytes()))
+			}
+		}
+		for _, b := range secrets.bytes {
+			forbidden = append(forbidden, hex.EncodeToString(b), base64.StdEncoding.EncodeToString(b))
+		}
+		for _, s := range forbidden {
+			if strings.Contains(out, s) {
+				t.Errorf("log output contains secret %s:\n%s", s, out)
+			}
+		}
+	})
+}
+
+// newTestMux returns the routes of ossvc handled by t.
+func newTestMux(t *HTTPTransport) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/v1/group", t.MakeGroupHandler())
+	mux.Handle("/v1/register/expk", t.MakeRegisterExpKHandler())
+	mux.Handle("/v1/register", t.MakeRegisterHandler())
+	mux.Handle("/v1/login/expk", t.MakeExpKHandler())
+	mux.Handle("/v1/login/challenge", t.MakeChallengeHandler())
+	mux.Handle("/v1/logout", t.MakeLogoutHandler())
+	mux.Handle("/v1/metadata", t.MakeMetadataHandler())
+	mux.Handle("/v1/add", t.MakeAddHandler())
+	mux.Handle("/v1/get", t.MakeGetHandler())
+	mux.Handle("/v1/rotate", t.MakeRotateHandler())
+	return mux
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// LogLevel is the verbosity of the logs of the service.
+type LogLevel int
+
+const (
+	// LogError logs failed requests only.
+	LogError LogLevel = iota
+	// LogInfo logs all requests.
+	LogInfo
+	// LogDebug logs all requests and the truncated public values of the protocol.
+	LogDebug
+)
+
+// ParseLogLevel returns the LogLevel named error, info or debug.
+func ParseLogLevel(name string) (LogLevel, error) {
+	switch name {
+	case "error":
+		return LogError, nil
+	case "info":
+		return LogInfo, nil
+	case "debug":
+		return LogDebug, nil
+	}
+	return LogError, errors.Errorf("ParseLogLevel: unknown log level %s", name)
+}
+
+// Redactor is the redaction policy of the logs of the service:
+// * secrets like session keys, MACs, OPRF evaluations and metadata are never logged,
+// * identifiers like client IDs, session IDs and domains are logged as keyed hashes,
+// so that the logs of an identifier can be correlated without revealing it,
+// * errors are logged by their cause, whose messages contain neither.
+type Redactor struct {
+	level LogLevel
+	key   []byte
+}
+
+// NewRedactor returns a Redactor logging with level and hashing identifiers with key.
+// Without key a random key is used, so that hashes only correlate within one process.
+func NewRedactor(level LogLevel, key []byte) *Redactor {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &Redactor{level: level, key: key}
+}
+
+// Enabled returns whether requests failing with err are logged.
+func (r *Redactor) Enabled(err error) bool {
+	return err != nil || r.level >= LogInfo
+}
+
+// Debug returns whether public values are logged.
+func (r *Redactor) Debug() bool {
+	return r.level >= LogDebug
+}
+
+// ID returns the keyed hash of the identifier id.
+func (r *Redactor) ID(id string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// Int returns the keyed hash of the identifier n, e.g. a client ID.
+func (r *Redactor) Int(n *big.Int) string {
+	if n == nil {
+		return ""
+	}
+	return r.ID(n.Text(16))
+}
+
+// Public returns the first 8 hex digits of the public value n.
+func (r *Redactor) Public(n *big.Int) string {
+	if n == nil {
+		return ""
+	}
+	s := n.Text(16)
+	if len(s) > 8 {
+		return s[:8] + "..."
+	}
+	return s
+}
+
+// Error returns the message of the cause of err, nil errors are empty.
+func (r *Redactor) Error(err error) string {
+	if err == nil {
+		return ""
+	}
+	return errors.Cause(err).Error()
+}
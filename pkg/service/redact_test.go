@@ -0,0 +1,83 @@
+package service
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		want    LogLevel
+		wantErr bool
+	}{
+		{"should parse error", "error", LogError, false},
+		{"should parse info", "info", LogInfo, false},
+		{"should parse debug", "debug", LogDebug, false},
+		{"should reject unknown level", "trace", LogError, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLogLevel(tt.level)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseLogLevel() = %v, %v, want %v, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	key := []byte("key")
+
+	t.Run("should hash identifiers stable per key", func(t *testing.T) {
+		// given
+		r := NewRedactor(LogInfo, key)
+
+		// when
+		got := r.Int(big.NewInt(42))
+
+		// then
+		if got != NewRedactor(LogInfo, key).Int(big.NewInt(42)) {
+			t.Errorf("Int() = %v, not stable", got)
+		}
+		if got == NewRedactor(LogInfo, []byte("other key")).Int(big.NewInt(42)) {
+			t.Errorf("Int() = %v, same for other key", got)
+		}
+		if got == big.NewInt(42).Text(16) || len(got) != 16 {
+			t.Errorf("Int() = %v, want 16 hex digits of the hash", got)
+		}
+	})
+
+	t.Run("should truncate public values", func(t *testing.T) {
+		r := NewRedactor(LogDebug, key)
+		n, _ := new(big.Int).SetString("123456789abcdef", 16)
+
+		if got := r.Public(n); got != "12345678..." {
+			t.Errorf("Public() = %v, want %v", got, "12345678...")
+		}
+		if got := r.Public(big.NewInt(255)); got != "ff" {
+			t.Errorf("Public() = %v, want %v", got, "ff")
+		}
+	})
+
+	t.Run("should log errors by their cause only", func(t *testing.T) {
+		r := NewRedactor(LogError, key)
+		err := errors.Wrapf(ErrDomainNotFound, "Get: domain secret.example.com of cID %v", big.NewInt(42))
+
+		if got := r.Error(err); got != ErrDomainNotFound.Error() {
+			t.Errorf("Error() = %v, want %v", got, ErrDomainNotFound.Error())
+		}
+	})
+
+	t.Run("should log successful requests from level info", func(t *testing.T) {
+		if NewRedactor(LogError, key).Enabled(nil) || !NewRedactor(LogError, key).Enabled(ErrMacMismatch) {
+			t.Errorf("Enabled() of LogError should only log errors")
+		}
+		if !NewRedactor(LogInfo, key).Enabled(nil) || NewRedactor(LogInfo, key).Debug() {
+			t.Errorf("Enabled() of LogInfo should log all requests without public values")
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MaxRequestAge is the maximum difference between the timestamp of an authenticated request and the time of the service.
+const MaxRequestAge = 5 * time.Minute
+
+// ErrReplayedRequest is returned for authenticated requests with a counter that was already used
+// within the session or with a timestamp older or newer than MaxRequestAge.
+var ErrReplayedRequest = errors.New("replayed request")
+
+// checkReplay returns ErrReplayedRequest unless timestamp is within MaxRequestAge of now and counter
+// is higher than the last counter accepted in the session with id. The counter is compared and recorded
+// in one update of store, so that a request is accepted only once by all instances sharing the store.
+func checkReplay(ctx context.Context, store SessionStore, id string, counter uint64, timestamp, now time.Time) error {
+	if age := now.Sub(timestamp); age > MaxRequestAge || age < -MaxRequestAge {
+		return errors.Wrapf(ErrReplayedRequest, "checkReplay: timestamp %v outside of window %v", timestamp, MaxRequestAge)
+	}
+
+	return store.Update(ctx, id, func(s Session) (Session, error) {
+		if counter <= s.Counter {
+			return s, errors.Wrapf(ErrReplayedRequest, "checkReplay: counter %d not greater than %d", counter, s.Counter)
+		}
+		s.Counter = counter
+		return s, nil
+	})
+}
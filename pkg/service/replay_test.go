@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestSessionManager_checkReplay(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		session   string
+		counter   uint64
+		timestamp time.Time
+		wantErr   error
+	}{
+		{"should accept the first request", "a", 1, now, nil},
+		{"should accept a higher counter", "a", 5, now, nil},
+		{"should reject a replayed counter", "a", 5, now, ErrReplayedRequest},
+		{"should reject a lower counter", "a", 3, now, ErrReplayedRequest},
+		{"should track sessions separately", "b", 1, now, nil},
+		{"should reject a stale timestamp", "a", 6, now.Add(-MaxRequestAge - time.Second), ErrReplayedRequest},
+		{"should reject a future timestamp", "a", 7, now.Add(MaxRequestAge + time.Second), ErrReplayedRequest},
+		{"should accept a timestamp within the window", "a", 8, now.Add(-MaxRequestAge), nil},
+		{"should reject an unknown session", "c", 1, now, ErrSessionNotFound},
+	}
+
+	// given
+	store := NewSessionStore()
+	for _, id := range []string{"a", "b"} {
+		store.Set(ctx, Session{ID: id, CID: big.NewInt(1), Created: now, LastSeen: now})
+	}
+	newManager := func() *SessionManager {
+		sm, err := NewSessionManager(store, SessionConfig{Keys: [][]byte{GenerateSessionKey()}})
+		if err != nil {
+			t.Fatalf("NewSessionManager() error = %v", err)
+		}
+		sm.now = func() time.Time { return now }
+		return sm
+	}
+	sm := newManager()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// when
+			err := sm.checkReplay(ctx, Session{ID: tt.session}, tt.counter, tt.timestamp)
+			// then
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("checkReplay() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("should reject a counter accepted by another instance sharing the store", func(t *testing.T) {
+		// when
+		err := newManager().checkReplay(ctx, Session{ID: "a"}, 8, now)
+		// then
+		if errors.Cause(err) != ErrReplayedRequest {
+			t.Errorf("checkReplay() error = %v, wantErr %v", err, ErrReplayedRequest)
+		}
+	})
+}
@@ -1,13 +1,20 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"math/big"
+	"sort"
 	"sync"
+	"time"
 )
 
-// ErrUserNotFound is returned when an user with a given cID does not exists
-var ErrUserNotFound = errors.New("user repo: user not found")
+var (
+	// ErrUserNotFound is returned when an user with a given cID does not exists
+	ErrUserNotFound = errors.New("user repo: user not found")
+	// ErrUserAlreadyExists is returned when an user with a given cID is added twice
+	ErrUserAlreadyExists = errors.New("user repo: user already exists")
+)
 
 // NewUserRepository creates and returns an inmemory user repository.
 func NewUserRepository() *InMemoryUserRepository {
@@ -27,29 +34,80 @@ type InMemoryUserRepository struct {
 }
 
 // User is an entity and contains all user related informated to implement server-side Online SPHINX.
+// verifier is the public key AK = G**ak of the authentication key of the client,
+// users registered before it was introduced have none.
+// enrollment is the SHA-256 hash of the enrollment code issued by the operator, valid until enrollUntil.
 type User struct {
-	cID    *big.Int
-	kv     *big.Int
-	vaults map[string]Vault
+	cID         *big.Int
+	verifier    *big.Int
+	enrollment  []byte
+	enrollUntil time.Time
 }
 
 // Vault is an entity and contains cryptographic material necessary to retrieve the vault password
+// and the metadata the client stored with the domain.
+// Every rotation increments version and keeps the keys before as previous until previousUntil.
 type Vault struct {
-	k  *big.Int
-	qj *big.Int
+	k             *big.Int
+	qj            *big.Int
+	metadata      string
+	version       int
+	previous      *Vault
+	previousUntil time.Time
 }
 
 // Set new or overrides existing user to user repository
-func (r *InMemoryUserRepository) Set(u User) error {
+func (r *InMemoryUserRepository) Set(ctx context.Context, u User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.users[u.cID.Text(16)] = u
+	return nil
+}
+
+// Add an user if there is none with its cID yet, otherwise returns ErrUserAlreadyExists.
+func (r *InMemoryUserRepository) Add(ctx context.Context, u User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
+	if _, ok := r.users[u.cID.Text(16)]; ok {
+		return ErrUserAlreadyExists
+	}
 	r.users[u.cID.Text(16)] = u
 	return nil
 }
 
+// Update replaces the user with cID by the result of update, otherwise returns ErrUserNotFound.
+func (r *InMemoryUserRepository) Update(ctx context.Context, cID *big.Int, update func(User) (User, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	u, ok := r.users[cID.Text(16)]
+	if !ok {
+		return ErrUserNotFound
+	}
+	u, err := update(u)
+	if err != nil {
+		return err
+	}
+	r.users[cID.Text(16)] = u
+	return nil
+}
+
 // Get an existing user
-func (r *InMemoryUserRepository) Get(cID *big.Int) (User, error) {
+func (r *InMemoryUserRepository) Get(ctx context.Context, cID *big.Int) (User, error) {
+	if err := ctx.Err(); err != nil {
+		return User{}, err
+	}
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -59,3 +117,273 @@ func (r *InMemoryUserRepository) Get(cID *big.Int) (User, error) {
 	}
 	return u, nil
 }
+
+// NewVaultRepository creates and returns an inmemory vault repository.
+func NewVaultRepository() *InMemoryVaultRepository {
+	return &InMemoryVaultRepository{
+		mutex:  sync.Mutex{},
+		vaults: make(map[string]map[string]Vault),
+	}
+}
+
+// InMemoryVaultRepository provides a vault repository keyed by cID and domain.
+type InMemoryVaultRepository struct {
+	mutex  sync.Mutex
+	vaults map[string]map[string]Vault
+}
+
+// Add a vault for domain d if the user has none yet, otherwise returns ErrDomainAlreadyExists.
+func (r *InMemoryVaultRepository) Add(ctx context.Context, cID *big.Int, d string, v Vault) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	vaults, ok := r.vaults[cID.Text(16)]
+	if !ok {
+		vaults = make(map[string]Vault)
+		r.vaults[cID.Text(16)] = vaults
+	}
+
+	if _, ok := vaults[d]; ok {
+		return ErrDomainAlreadyExists
+	}
+	vaults[d] = v
+	return nil
+}
+
+// Update replaces the vault of domain d by the result of update, otherwise returns ErrDomainNotFound.
+func (r *InMemoryVaultRepository) Update(ctx context.Context, cID *big.Int, d string, update func(Vault) (Vault, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.vaults[cID.Text(16)][d]
+	if !ok {
+		return ErrDomainNotFound
+	}
+
+	v, err := update(v)
+	if err != nil {
+		return err
+	}
+	r.vaults[cID.Text(16)][d] = v
+	return nil
+}
+
+// Get the vault of domain d
+func (r *InMemoryVaultRepository) Get(ctx context.Context, cID *big.Int, d string) (Vault, error) {
+	if err := ctx.Err(); err != nil {
+		return Vault{}, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	v, ok := r.vaults[cID.Text(16)][d]
+	if !ok {
+		return Vault{}, ErrDomainNotFound
+	}
+	return v, nil
+}
+
+// GetDomains returns all domains of an user in ascending order
+func (r *InMemoryVaultRepository) GetDomains(ctx context.Context, cID *big.Int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	vaults := r.vaults[cID.Text(16)]
+	domains := make([]string, 0, len(vaults))
+	for d := range vaults {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	return domains, nil
+}
+
+// Delete the vault of domain d, otherwise returns ErrDomainNotFound.
+func (r *InMemoryVaultRepository) Delete(ctx context.Context, cID *big.Int, d string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.vaults[cID.Text(16)][d]; !ok {
+		return ErrDomainNotFound
+	}
+	delete(r.vaults[cID.Text(16)], d)
+	return nil
+}
+
+// NewSessionStore creates and returns an inmemory session store,
+// sessions are lost on restart and not shared between instances.
+func NewSessionStore() *InMemorySessionStore {
+	return &InMemorySessionStore{
+		mutex:    sync.Mutex{},
+		sessions: make(map[string]Session),
+	}
+}
+
+// InMemorySessionStore provides a session store keyed by session ID.
+type InMemorySessionStore struct {
+	mutex    sync.Mutex
+	sessions map[string]Session
+}
+
+// Set new or overrides existing session
+func (r *InMemorySessionStore) Set(ctx context.Context, s Session) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.sessions[s.ID] = s
+	return nil
+}
+
+// Update replaces an existing session by the result of update
+func (r *InMemorySessionStore) Update(ctx context.Context, id string, update func(Session) (Session, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	s, ok := r.sessions[id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	s, err := update(s)
+	if err != nil {
+		return err
+	}
+	r.sessions[id] = s
+	return nil
+}
+
+// Get an existing session
+func (r *InMemorySessionStore) Get(ctx context.Context, id string) (Session, error) {
+	if err := ctx.Err(); err != nil {
+		return Session{}, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	s, ok := r.sessions[id]
+	if !ok {
+		return Session{}, ErrSessionNotFound
+	}
+	return s, nil
+}
+
+// Delete a session, unknown sessions are ignored
+func (r *InMemorySessionStore) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.sessions, id)
+	return nil
+}
+
+// List returns all sessions of an user ordered by creation
+func (r *InMemorySessionStore) List(ctx context.Context, cID *big.Int) ([]Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var sessions []Session
+	for _, s := range r.sessions {
+		if s.CID.Cmp(cID) == 0 {
+			sessions = append(sessions, s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Created.Before(sessions[j].Created) })
+	return sessions, nil
+}
+
+// Prune deletes all sessions for which ended returns true
+func (r *InMemorySessionStore) Prune(ctx context.Context, ended func(Session) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, s := range r.sessions {
+		if ended(s) {
+			delete(r.sessions, id)
+		}
+	}
+	return nil
+}
+
+// NewThrottleStore creates and returns an inmemory throttle store,
+// attempts are lost on restart and not shared between instances.
+func NewThrottleStore() *InMemoryThrottleStore {
+	return &InMemoryThrottleStore{
+		mutex:    sync.Mutex{},
+		attempts: make(map[string]Attempts),
+	}
+}
+
+// InMemoryThrottleStore provides a throttle store keyed by client ID or source.
+type InMemoryThrottleStore struct {
+	mutex    sync.Mutex
+	attempts map[string]Attempts
+}
+
+// Update replaces the attempts of key by the result of update
+func (r *InMemoryThrottleStore) Update(ctx context.Context, key string, update func(Attempts) (Attempts, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	a, err := update(r.attempts[key])
+	if err != nil {
+		return err
+	}
+	r.attempts[key] = a
+	return nil
+}
+
+// Delete the attempts of key, unknown keys are ignored
+func (r *InMemoryThrottleStore) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
+
+// Prune deletes all attempts whose last attempt was before t
+func (r *InMemoryThrottleStore) Prune(ctx context.Context, t time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, a := range r.attempts {
+		if a.Last.Before(t) {
+			delete(r.attempts, key)
+		}
+	}
+	return nil
+}
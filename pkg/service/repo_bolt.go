@@ -0,0 +1,768 @@
+package service
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// ErrUnsupportedSchema is returned when a store was written by a newer ossvc version
+	ErrUnsupportedSchema = errors.New("user repo: unsupported schema version")
+	// ErrUnsupportedRecord is returned when a stored record has an unknown version
+	ErrUnsupportedRecord = errors.New("user repo: unsupported record version")
+)
+
+var (
+	metaBucket     = []byte("meta")
+	usersBucket    = []byte("users")
+	vaultsBucket   = []byte("vaults")
+	sessionsBucket = []byte("sessions")
+	throttleBucket = []byte("throttle")
+	schemaKey      = []byte("schema")
+)
+
+// userRecordVersion is the version of user records written by BoltUserRepository.
+const userRecordVersion = 3
+
+// migrations upgrade the store schema, migrations[i] upgrades version i to i+1.
+// Append new migrations, never change existing ones.
+var migrations = []func(tx *bolt.Tx) error{
+	// 0 -> 1: initial schema
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(usersBucket)
+		return err
+	},
+	// 1 -> 2: vaults moved out of user records into their own bucket
+	migrateVaults,
+	// 2 -> 3: server-side sessions
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		return err
+	},
+	// 3 -> 4: users authenticate with a verifier instead of kv
+	migrateVerifiers,
+	// 4 -> 5: login attempts of clients and sources
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(throttleBucket)
+		return err
+	},
+}
+
+// OpenBolt opens or creates the bbolt database at path
+// and migrates it to the latest schema version.
+// Every write is an atomic and fsynced transaction, so that a crash never leaves
+// a partially written record behind.
+func OpenBolt(path string) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, errors.Wrapf(err, "OpenBolt: failed to open %s", path)
+	}
+
+	err = db.Update(migrate)
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "OpenBolt: failed to migrate %s", path)
+	}
+
+	return db, nil
+}
+
+// updateTx runs fn in a read-write transaction of db unless ctx is done,
+// also after waiting for the transaction of another writer.
+func updateTx(ctx context.Context, db *bolt.DB, fn func(tx *bolt.Tx) error) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// viewTx runs fn in a read-only transaction of db unless ctx is done.
+func viewTx(ctx context.Context, db *bolt.DB, fn func(tx *bolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return db.View(fn)
+}
+
+// BoltUserRepository provides a durable user repository backed by bbolt.
+type BoltUserRepository struct {
+	db *bolt.DB
+}
+
+// NewBoltUserRepository returns an user repository stored in a database opened by OpenBolt.
+func NewBoltUserRepository(db *bolt.DB) *BoltUserRepository {
+	return &BoltUserRepository{db: db}
+}
+
+// Set new or overrides existing user to user repository
+func (r *BoltUserRepository) Set(ctx context.Context, u User) error {
+	buf, err := encodeUser(u)
+	if err != nil {
+		return errors.Wrapf(err, "Set: failed to encode user with cID=%v", u.cID)
+	}
+
+	return updateTx(ctx, r.db, func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).Put(u.cID.Bytes(), buf)
+	})
+}
+
+// Add an user if there is none with its cID yet, otherwise returns ErrUserAlreadyExists.
+func (r *BoltUserRepository) Add(ctx context.Context, u User) error {
+	buf, err := encodeUser(u)
+	if err != nil {
+		return errors.Wrapf(err, "Add: failed to encode user with cID=%v", u.cID)
+	}
+
+	return updateTx(ctx, r.db, func(tx *bolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+		if b.Get(u.cID.Bytes()) != nil {
+			return ErrUserAlreadyExists
+		}
+		return b.Put(u.cID.Bytes(), buf)
+	})
+}
+
+// Update replaces the user with cID by the result of update, otherwise returns ErrUserNotFound.
+func (r *BoltUserRepository) Update(ctx context.Context, cID *big.Int, update func(User) (User, error)) error {
+	return updateTx(ctx, r.db, func(tx *bolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+		buf := b.Get(cID.Bytes())
+		if buf == nil {
+			return ErrUserNotFound
+		}
+		u, err := decodeUser(buf)
+		if err != nil {
+			return err
+		}
+
+		u, err = update(u)
+		if err != nil {
+			return err
+		}
+		buf, err = encodeUser(u)
+		if err != nil {
+			return errors.Wrapf(err, "Update: failed to encode user with cID=%v", cID)
+		}
+		return b.Put(cID.Bytes(), buf)
+	})
+}
+
+// Get an existing user
+func (r *BoltUserRepository) Get(ctx context.Context, cID *big.Int) (User, error) {
+	var u User
+	err := viewTx(ctx, r.db, func(tx *bolt.Tx) error {
+		buf := tx.Bucket(usersBucket).Get(cID.Bytes())
+		if buf == nil {
+			return ErrUserNotFound
+		}
+
+		var err error
+		u, err = decodeUser(buf)
+		return err
+	})
+	return u, err
+}
+
+// BoltVaultRepository provides a durable vault repository backed by bbolt.
+// Vaults are stored in one bucket per user keyed by domain.
+type BoltVaultRepository struct {
+	db *bolt.DB
+}
+
+// NewBoltVaultRepository returns a vault repository stored in a database opened by OpenBolt.
+func NewBoltVaultRepository(db *bolt.DB) *BoltVaultRepository {
+	return &BoltVaultRepository{db: db}
+}
+
+// Add a vault for domain d if the user has none yet, otherwise returns ErrDomainAlreadyExists.
+func (r *BoltVaultRepository) Add(ctx context.Context, cID *big.Int, d string, v Vault) error {
+	buf, err := json.Marshal(encodeVault(v))
+	if err != nil {
+		return errors.Wrapf(err, "Add: failed to encode vault of user with cID=%v", cID)
+	}
+
+	return updateTx(ctx, r.db, func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(vaultsBucket).CreateBucketIfNotExists(cID.Bytes())
+		if err != nil {
+			return err
+		}
+		if b.Get([]byte(d)) != nil {
+			return ErrDomainAlreadyExists
+		}
+		return b.Put([]byte(d), buf)
+	})
+}
+
+// Update replaces the vault of domain d by the result of update, otherwise returns ErrDomainNotFound.
+func (r *BoltVaultRepository) Update(ctx context.Context, cID *big.Int, d string, update func(Vault) (Vault, error)) error {
+	return updateTx(ctx, r.db, func(tx *bolt.Tx) error {
+		b := tx.Bucket(vaultsBucket).Bucket(cID.Bytes())
+		if b == nil {
+			return ErrDomainNotFound
+		}
+		buf := b.Get([]byte(d))
+		if buf == nil {
+			return ErrDomainNotFound
+		}
+
+		var rec vaultRecord
+		if err := json.Unmarshal(buf, &rec); err != nil {
+			return errors.Wrap(err, "failed to unmarshal vault record")
+		}
+		v, err := decodeVault(rec)
+		if err != nil {
+			return err
+		}
+
+		v, err = update(v)
+		if err != nil {
+			return err
+		}
+		buf, err = json.Marshal(encodeVault(v))
+		if err != nil {
+			return errors.Wrapf(err, "Update: failed to encode vault of user with cID=%v", cID)
+		}
+		return b.Put([]byte(d), buf)
+	})
+}
+
+// Get the vault of domain d
+func (r *BoltVaultRepository) Get(ctx context.Context, cID *big.Int, d string) (Vault, error) {
+	var v Vault
+	err := viewTx(ctx, r.db, func(tx *bolt.Tx) error {
+		b := tx.Bucket(vaultsBucket).Bucket(cID.Bytes())
+		if b == nil {
+			return ErrDomainNotFound
+		}
+		buf := b.Get([]byte(d))
+		if buf == nil {
+			return ErrDomainNotFound
+		}
+
+		var rec vaultRecord
+		if err := json.Unmarshal(buf, &rec); err != nil {
+			return errors.Wrap(err, "failed to unmarshal vault record")
+		}
+
+		var err error
+		v, err = decodeVault(rec)
+		return err
+	})
+	return v, err
+}
+
+// GetDomains returns all domains of an user in ascending order
+func (r *BoltVaultRepository) GetDomains(ctx context.Context, cID *big.Int) ([]string, error) {
+	domains := []string{}
+	err := viewTx(ctx, r.db, func(tx *bolt.Tx) error {
+		b := tx.Bucket(vaultsBucket).Bucket(cID.Bytes())
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, _ []byte) error {
+			domains = append(domains, string(k))
+			return nil
+		})
+	})
+	return domains, err
+}
+
+// Delete the vault of domain d, otherwise returns ErrDomainNotFound.
+func (r *BoltVaultRepository) Delete(ctx context.Context, cID *big.Int, d string) error {
+	return updateTx(ctx, r.db, func(tx *bolt.Tx) error {
+		b := tx.Bucket(vaultsBucket).Bucket(cID.Bytes())
+		if b == nil || b.Get([]byte(d)) == nil {
+			return ErrDomainNotFound
+		}
+		return b.Delete([]byte(d))
+	})
+}
+
+// BoltSessionStore provides a durable session store backed by bbolt,
+// which survives restarts of the service. bbolt is opened by one process only,
+// so replicas of the service can not share it, see RedisSessionStore.
+type BoltSessionStore struct {
+	db *bolt.DB
+}
+
+// NewBoltSessionStore returns a session store stored in a database opened by OpenBolt.
+func NewBoltSessionStore(db *bolt.DB) *BoltSessionStore {
+	return &BoltSessionStore{db: db}
+}
+
+// Set new or overrides existing session
+func (r *BoltSessionStore) Set(ctx context.Context, s Session) error {
+	buf, err := json.Marshal(encodeSession(s))
+	if err != nil {
+		return errors.Wrap(err, "Set: failed to encode session")
+	}
+
+	return updateTx(ctx, r.db, func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(s.ID), buf)
+	})
+}
+
+// Update replaces an existing session by the result of update
+func (r *BoltSessionStore) Update(ctx context.Context, id string, update func(Session) (Session, error)) error {
+	return updateTx(ctx, r.db, func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		buf := b.Get([]byte(id))
+		if buf == nil {
+			return ErrSessionNotFound
+		}
+
+		s, err := decodeSession(buf)
+		if err != nil {
+			return err
+		}
+		s, err = update(s)
+		if err != nil {
+			return err
+		}
+		buf, err = json.Marshal(encodeSession(s))
+		if err != nil {
+			return errors.Wrap(err, "Update: failed to encode session")
+		}
+		return b.Put([]byte(id), buf)
+	})
+}
+
+// Get an existing session
+func (r *BoltSessionStore) Get(ctx context.Context, id string) (Session, error) {
+	var s Session
+	err := viewTx(ctx, r.db, func(tx *bolt.Tx) error {
+		buf := tx.Bucket(sessionsBucket).Get([]byte(id))
+		if buf == nil {
+			return ErrSessionNotFound
+		}
+
+		var err error
+		s, err = decodeSession(buf)
+		return err
+	})
+	return s, err
+}
+
+// Delete a session, unknown sessions are ignored
+func (r *BoltSessionStore) Delete(ctx context.Context, id string) error {
+	return updateTx(ctx, r.db, func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(id))
+	})
+}
+
+// List returns all sessions of an user ordered by creation
+func (r *BoltSessionStore) List(ctx context.Context, cID *big.Int) ([]Session, error) {
+	var sessions []Session
+	err := viewTx(ctx, r.db, func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEach(func(_, buf []byte) error {
+			s, err := decodeSession(buf)
+			if err != nil {
+				return err
+			}
+			if s.CID.Cmp(cID) == 0 {
+				sessions = append(sessions, s)
+			}
+			return nil
+		})
+	})
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Created.Before(sessions[j].Created) })
+	return sessions, err
+}
+
+// Prune deletes all sessions for which ended returns true
+func (r *BoltSessionStore) Prune(ctx context.Context, ended func(Session) bool) error {
+	return updateTx(ctx, r.db, func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		var ids [][]byte
+		err := b.ForEach(func(id, buf []byte) error {
+			s, err := decodeSession(buf)
+			if err != nil {
+				return err
+			}
+			if ended(s) {
+				ids = append(ids, id)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := b.Delete(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// BoltThrottleStore provides a durable throttle store backed by bbolt,
+// so that restarts of the service do not reset login attempts. Replicas of the service
+// can not share it, so each would throttle on its own, see RedisThrottleStore.
+type BoltThrottleStore struct {
+	db *bolt.DB
+}
+
+// NewBoltThrottleStore returns a throttle store stored in a database opened by OpenBolt.
+func NewBoltThrottleStore(db *bolt.DB) *BoltThrottleStore {
+	return &BoltThrottleStore{db: db}
+}
+
+// Update replaces the attempts of key by the result of update
+func (r *BoltThrottleStore) Update(ctx context.Context, key string, update func(Attempts) (Attempts, error)) error {
+	return updateTx(ctx, r.db, func(tx *bolt.Tx) error {
+		b := tx.Bucket(throttleBucket)
+
+		var a Attempts
+		if buf := b.Get([]byte(key)); buf != nil {
+			var err error
+			if a, err = decodeAttempts(buf); err != nil {
+				return err
+			}
+		}
+
+		a, err := update(a)
+		if err != nil {
+			return err
+		}
+		buf, err := json.Marshal(encodeAttempts(a))
+		if err != nil {
+			return errors.Wrapf(err, "Update: failed to encode attempts of %s", key)
+		}
+		return b.Put([]byte(key), buf)
+	})
+}
+
+// Delete the attempts of key, unknown keys are ignored
+func (r *BoltThrottleStore) Delete(ctx context.Context, key string) error {
+	return updateTx(ctx, r.db, func(tx *bolt.Tx) error {
+		return tx.Bucket(throttleBucket).Delete([]byte(key))
+	})
+}
+
+// Prune deletes all attempts whose last attempt was before t
+func (r *BoltThrottleStore) Prune(ctx context.Context, t time.Time) error {
+	return updateTx(ctx, r.db, func(tx *bolt.Tx) error {
+		b := tx.Bucket(throttleBucket)
+		var keys [][]byte
+		err := b.ForEach(func(key, buf []byte) error {
+			a, err := decodeAttempts(buf)
+			if err != nil {
+				return err
+			}
+			if a.Last.Before(t) {
+				keys = append(keys, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// migrate applies all pending migrations within the given transaction.
+func migrate(tx *bolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return err
+	}
+
+	var version uint64
+	if v := meta.Get(schemaKey); v != nil {
+		version = binary.BigEndian.Uint64(v)
+	}
+	if version > uint64(len(migrations)) {
+		return errors.Wrapf(ErrUnsupportedSchema, "schema version %d", version)
+	}
+
+	for ; version < uint64(len(migrations)); version++ {
+		if err := migrations[version](tx); err != nil {
+			return errors.Wrapf(err, "migration to schema version %d failed", version+1)
+		}
+	}
+
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, version)
+	return meta.Put(schemaKey, v)
+}
+
+// migrateVaults moves the vaults of version 1 user records into the vaults bucket.
+func migrateVaults(tx *bolt.Tx) error {
+	vaults, err := tx.CreateBucketIfNotExists(vaultsBucket)
+	if err != nil {
+		return err
+	}
+
+	users := tx.Bucket(usersBucket)
+	updates := make(map[string][]byte)
+	err = users.ForEach(func(k, buf []byte) error {
+		var rec struct {
+			userRecord
+			KV     string                 `json:"kv"`
+			Vaults map[string]vaultRecord `json:"vaults"`
+		}
+		if err := json.Unmarshal(buf, &rec); err != nil {
+			return errors.Wrap(err, "failed to unmarshal user record")
+		}
+		if rec.Version != 1 {
+			return errors.Wrapf(ErrUnsupportedRecord, "user record version %d", rec.Version)
+		}
+
+		b, err := vaults.CreateBucketIfNotExists(k)
+		if err != nil {
+			return err
+		}
+		for d, v := range rec.Vaults {
+			vbuf, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(d), vbuf); err != nil {
+				return err
+			}
+		}
+
+		rec.userRecord.Version = 2
+		ubuf, err := json.Marshal(struct {
+			userRecord
+			KV string `json:"kv"`
+		}{rec.userRecord, rec.KV})
+		if err != nil {
+			return err
+		}
+		updates[string(k)] = ubuf
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for k, buf := range updates {
+		if err := users.Put([]byte(k), buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateVerifiers drops kv of version 2 user records, which only authenticated the login
+// before the verifier. It was sent to everyone knowing the cID and can not authenticate the user anymore.
+// These users keep their cID and vaults but have no verifier until they enroll one with a code
+// the operator issued out of band, see OnlineSphinx.IssueEnrollment.
+func migrateVerifiers(tx *bolt.Tx) error {
+	users := tx.Bucket(usersBucket)
+	updates := make(map[string][]byte)
+	err := users.ForEach(func(k, buf []byte) error {
+		var rec userRecord
+		if err := json.Unmarshal(buf, &rec); err != nil {
+			return errors.Wrap(err, "failed to unmarshal user record")
+		}
+		if rec.Version != 2 {
+			return errors.Wrapf(ErrUnsupportedRecord, "user record version %d", rec.Version)
+		}
+
+		ubuf, err := json.Marshal(userRecord{Version: 3, CID: rec.CID})
+		if err != nil {
+			return err
+		}
+		updates[string(k)] = ubuf
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for k, buf := range updates {
+		if err := users.Put([]byte(k), buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type userRecord struct {
+	Version     int    `json:"version"`
+	CID         string `json:"cID"`
+	Verifier    string `json:"verifier,omitempty"`
+	Enrollment  string `json:"enrollment,omitempty"`
+	EnrollUntil int64  `json:"enrollUntil,omitempty"` // unix time in nanoseconds
+}
+
+type vaultRecord struct {
+	K             string       `json:"k"`
+	QJ            string       `json:"qj"`
+	Metadata      string       `json:"metadata,omitempty"`
+	Version       int          `json:"version,omitempty"`
+	Previous      *vaultRecord `json:"previous,omitempty"`
+	PreviousUntil int64        `json:"previousUntil,omitempty"` // unix time in nanoseconds
+}
+
+type sessionRecord struct {
+	ID       string `json:"id"`
+	CID      string `json:"cID"`
+	SID      string `json:"sID"`
+	SKi      string `json:"ski"`
+	State    string `json:"state"`
+	Created  int64  `json:"created"`  // unix time in nanoseconds
+	LastSeen int64  `json:"lastSeen"` // unix time in nanoseconds
+	Counter  uint64 `json:"counter,omitempty"`
+}
+
+type attemptsRecord struct {
+	Failures int   `json:"failures"`
+	Last     int64 `json:"last"` // unix time in nanoseconds
+	Next     int64 `json:"next"` // unix time in nanoseconds
+}
+
+func encodeAttempts(a Attempts) attemptsRecord {
+	return attemptsRecord{Failures: a.Failures, Last: a.Last.UnixNano(), Next: a.Next.UnixNano()}
+}
+
+func decodeAttempts(buf []byte) (Attempts, error) {
+	var rec attemptsRecord
+	if err := json.Unmarshal(buf, &rec); err != nil {
+		return Attempts{}, errors.Wrap(err, "failed to unmarshal attempts record")
+	}
+	return Attempts{Failures: rec.Failures, Last: time.Unix(0, rec.Last), Next: time.Unix(0, rec.Next)}, nil
+}
+
+func encodeSession(s Session) sessionRecord {
+	return sessionRecord{
+		ID:       s.ID,
+		CID:      encodeInt(s.CID),
+		SID:      encodeInt(s.SID),
+		SKi:      encodeInt(s.SKi),
+		State:    string(s.State),
+		Created:  s.Created.UnixNano(),
+		LastSeen: s.LastSeen.UnixNano(),
+		Counter:  s.Counter,
+	}
+}
+
+func decodeSession(buf []byte) (Session, error) {
+	var rec sessionRecord
+	if err := json.Unmarshal(buf, &rec); err != nil {
+		return Session{}, errors.Wrap(err, "failed to unmarshal session record")
+	}
+
+	s := Session{ID: rec.ID, State: SessionState(rec.State), Created: time.Unix(0, rec.Created), LastSeen: time.Unix(0, rec.LastSeen), Counter: rec.Counter}
+	var err error
+	if s.CID, err = decodeInt(rec.CID); err != nil {
+		return Session{}, err
+	}
+	if s.SID, err = decodeInt(rec.SID); err != nil {
+		return Session{}, err
+	}
+	if s.SKi, err = decodeInt(rec.SKi); err != nil {
+		return Session{}, err
+	}
+	return s, nil
+}
+
+func encodeUser(u User) ([]byte, error) {
+	rec := userRecord{
+		Version:  userRecordVersion,
+		CID:      encodeInt(u.cID),
+		Verifier: encodeInt(u.verifier),
+	}
+	if u.enrollment != nil {
+		rec.Enrollment = hex.EncodeToString(u.enrollment)
+		rec.EnrollUntil = u.enrollUntil.UnixNano()
+	}
+	return json.Marshal(rec)
+}
+
+func encodeVault(v Vault) vaultRecord {
+	rec := vaultRecord{K: encodeInt(v.k), QJ: encodeInt(v.qj), Metadata: v.metadata, Version: v.version}
+	if v.previous != nil {
+		prev := encodeVault(*v.previous)
+		rec.Previous = &prev
+		rec.PreviousUntil = v.previousUntil.UnixNano()
+	}
+	return rec
+}
+
+func decodeVault(rec vaultRecord) (Vault, error) {
+	var v Vault
+	var err error
+	if v.k, err = decodeInt(rec.K); err != nil {
+		return Vault{}, err
+	}
+	if v.qj, err = decodeInt(rec.QJ); err != nil {
+		return Vault{}, err
+	}
+	v.metadata = rec.Metadata
+	v.version = rec.Version
+	if rec.Previous != nil {
+		prev, err := decodeVault(*rec.Previous)
+		if err != nil {
+			return Vault{}, err
+		}
+		v.previous = &prev
+		v.previousUntil = time.Unix(0, rec.PreviousUntil)
+	}
+	return v, nil
+}
+
+func decodeUser(buf []byte) (User, error) {
+	var rec userRecord
+	if err := json.Unmarshal(buf, &rec); err != nil {
+		return User{}, errors.Wrap(err, "failed to unmarshal user record")
+	}
+	if rec.Version != userRecordVersion {
+		return User{}, errors.Wrapf(ErrUnsupportedRecord, "user record version %d", rec.Version)
+	}
+
+	var u User
+	var err error
+	if u.cID, err = decodeInt(rec.CID); err != nil {
+		return User{}, err
+	}
+	if u.verifier, err = decodeInt(rec.Verifier); err != nil {
+		return User{}, err
+	}
+	if rec.Enrollment != "" {
+		if u.enrollment, err = hex.DecodeString(rec.Enrollment); err != nil {
+			return User{}, errors.Wrap(err, "failed to decode enrollment")
+		}
+		u.enrollUntil = time.Unix(0, rec.EnrollUntil)
+	}
+	return u, nil
+}
+
+// encodeInt returns n as hex string, nil is encoded as empty string.
+func encodeInt(n *big.Int) string {
+	if n == nil {
+		return ""
+	}
+	return n.Text(16)
+}
+
+// decodeInt parses a hex string created by encodeInt.
+func decodeInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		return nil, errors.Errorf("invalid hex integer %q", s)
+	}
+	return n, nil
+}
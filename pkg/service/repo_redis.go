@@ -0,0 +1,496 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+)
+
+// Keys of the records in Redis, integers are hex encoded like in the bbolt records.
+const (
+	redisUserPrefix     = "ossvc:user:"
+	redisVaultsPrefix   = "ossvc:vaults:"
+	redisSessionPrefix  = "ossvc:session:"
+	redisSessionsPrefix = "ossvc:sessions:"
+	redisSessionsKey    = "ossvc:sessions"
+	redisThrottlePrefix = "ossvc:throttle:"
+	redisThrottleKey    = "ossvc:throttle"
+)
+
+// redisMaxRetries bounds the retries of a transaction whose watched keys another writer changed.
+const redisMaxRetries = 16
+
+// OpenRedis connects to the Redis server at url e.g. redis://:password@localhost:6379/0.
+// Unlike bbolt, Redis is shared by all replicas of the service.
+func OpenRedis(url string) (*redis.Client, error) {
+	opt, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "OpenRedis: invalid url")
+	}
+
+	c := redis.NewClient(opt)
+	if err := c.Ping().Err(); err != nil {
+		c.Close()
+		return nil, errors.Wrapf(err, "OpenRedis: failed to connect to %s", opt.Addr)
+	}
+	return c, nil
+}
+
+// watchTx runs fn in a transaction of c watching keys unless ctx is done.
+// fn is retried if another writer changed the keys before the transaction committed.
+func watchTx(ctx context.Context, c *redis.Client, fn func(tx *redis.Tx) error, keys ...string) error {
+	for i := 0; i < redisMaxRetries; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := c.WithContext(ctx).Watch(fn, keys...)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return errors.Wrapf(redis.TxFailedErr, "%d conflicting writers", redisMaxRetries)
+}
+
+// RedisUserRepository provides an user repository backed by Redis.
+type RedisUserRepository struct {
+	client *redis.Client
+}
+
+// NewRedisUserRepository returns an user repository stored in a Redis server connected by OpenRedis.
+func NewRedisUserRepository(c *redis.Client) *RedisUserRepository {
+	return &RedisUserRepository{client: c}
+}
+
+// Set new or overrides existing user to user repository
+func (r *RedisUserRepository) Set(ctx context.Context, u User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	buf, err := encodeUser(u)
+	if err != nil {
+		return errors.Wrapf(err, "Set: failed to encode user with cID=%v", u.cID)
+	}
+	return r.client.WithContext(ctx).Set(redisUserPrefix+u.cID.Text(16), buf, 0).Err()
+}
+
+// Add an user if there is none with its cID yet, otherwise returns ErrUserAlreadyExists.
+func (r *RedisUserRepository) Add(ctx context.Context, u User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	buf, err := encodeUser(u)
+	if err != nil {
+		return errors.Wrapf(err, "Add: failed to encode user with cID=%v", u.cID)
+	}
+
+	ok, err := r.client.WithContext(ctx).SetNX(redisUserPrefix+u.cID.Text(16), buf, 0).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrUserAlreadyExists
+	}
+	return nil
+}
+
+// Update replaces the user with cID by the result of update, otherwise returns ErrUserNotFound.
+func (r *RedisUserRepository) Update(ctx context.Context, cID *big.Int, update func(User) (User, error)) error {
+	key := redisUserPrefix + cID.Text(16)
+	return watchTx(ctx, r.client, func(tx *redis.Tx) error {
+		buf, err := tx.Get(key).Bytes()
+		if err == redis.Nil {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		u, err := decodeUser(buf)
+		if err != nil {
+			return err
+		}
+
+		u, err = update(u)
+		if err != nil {
+			return err
+		}
+		buf, err = encodeUser(u)
+		if err != nil {
+			return errors.Wrapf(err, "Update: failed to encode user with cID=%v", cID)
+		}
+		_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+			return pipe.Set(key, buf, 0).Err()
+		})
+		return err
+	}, key)
+}
+
+// Get an existing user
+func (r *RedisUserRepository) Get(ctx context.Context, cID *big.Int) (User, error) {
+	if err := ctx.Err(); err != nil {
+		return User{}, err
+	}
+	buf, err := r.client.WithContext(ctx).Get(redisUserPrefix + cID.Text(16)).Bytes()
+	if err == redis.Nil {
+		return User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, err
+	}
+	return decodeUser(buf)
+}
+
+// RedisVaultRepository provides a vault repository backed by Redis.
+// Vaults are stored in one hash per user keyed by domain.
+type RedisVaultRepository struct {
+	client *redis.Client
+}
+
+// NewRedisVaultRepository returns a vault repository stored in a Redis server connected by OpenRedis.
+func NewRedisVaultRepository(c *redis.Client) *RedisVaultRepository {
+	return &RedisVaultRepository{client: c}
+}
+
+// Add a vault for domain d if the user has none yet, otherwise returns ErrDomainAlreadyExists.
+func (r *RedisVaultRepository) Add(ctx context.Context, cID *big.Int, d string, v Vault) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	buf, err := json.Marshal(encodeVault(v))
+	if err != nil {
+		return errors.Wrapf(err, "Add: failed to encode vault of user with cID=%v", cID)
+	}
+
+	ok, err := r.client.WithContext(ctx).HSetNX(redisVaultsPrefix+cID.Text(16), d, buf).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDomainAlreadyExists
+	}
+	return nil
+}
+
+// Update replaces the vault of domain d by the result of update, otherwise returns ErrDomainNotFound.
+func (r *RedisVaultRepository) Update(ctx context.Context, cID *big.Int, d string, update func(Vault) (Vault, error)) error {
+	key := redisVaultsPrefix + cID.Text(16)
+	return watchTx(ctx, r.client, func(tx *redis.Tx) error {
+		v, err := getVault(tx.HGet(key, d))
+		if err != nil {
+			return err
+		}
+
+		v, err = update(v)
+		if err != nil {
+			return err
+		}
+		buf, err := json.Marshal(encodeVault(v))
+		if err != nil {
+			return errors.Wrapf(err, "Update: failed to encode vault of user with cID=%v", cID)
+		}
+		_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+			return pipe.HSet(key, d, buf).Err()
+		})
+		return err
+	}, key)
+}
+
+// Get the vault of domain d
+func (r *RedisVaultRepository) Get(ctx context.Context, cID *big.Int, d string) (Vault, error) {
+	if err := ctx.Err(); err != nil {
+		return Vault{}, err
+	}
+	return getVault(r.client.WithContext(ctx).HGet(redisVaultsPrefix+cID.Text(16), d))
+}
+
+// GetDomains returns all domains of an user in ascending order
+func (r *RedisVaultRepository) GetDomains(ctx context.Context, cID *big.Int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	domains, err := r.client.WithContext(ctx).HKeys(redisVaultsPrefix + cID.Text(16)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if domains == nil {
+		domains = []string{}
+	}
+	sort.Strings(domains)
+	return domains, nil
+}
+
+// Delete the vault of domain d, otherwise returns ErrDomainNotFound.
+func (r *RedisVaultRepository) Delete(ctx context.Context, cID *big.Int, d string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	n, err := r.client.WithContext(ctx).HDel(redisVaultsPrefix+cID.Text(16), d).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDomainNotFound
+	}
+	return nil
+}
+
+// getVault decodes the vault read by cmd, a missing vault returns ErrDomainNotFound.
+func getVault(cmd *redis.StringCmd) (Vault, error) {
+	buf, err := cmd.Bytes()
+	if err == redis.Nil {
+		return Vault{}, ErrDomainNotFound
+	}
+	if err != nil {
+		return Vault{}, err
+	}
+
+	var rec vaultRecord
+	if err := json.Unmarshal(buf, &rec); err != nil {
+		return Vault{}, errors.Wrap(err, "failed to unmarshal vault record")
+	}
+	return decodeVault(rec)
+}
+
+// RedisSessionStore provides a session store backed by Redis, shared by all replicas of the service.
+// Each session is stored under its ID and indexed in a set of all sessions and a set per user.
+type RedisSessionStore struct {
+	client *redis.Client
+}
+
+// NewRedisSessionStore returns a session store stored in a Redis server connected by OpenRedis.
+func NewRedisSessionStore(c *redis.Client) *RedisSessionStore {
+	return &RedisSessionStore{client: c}
+}
+
+// Set new or overrides existing session
+func (r *RedisSessionStore) Set(ctx context.Context, s Session) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	buf, err := json.Marshal(encodeSession(s))
+	if err != nil {
+		return errors.Wrap(err, "Set: failed to encode session")
+	}
+
+	_, err = r.client.WithContext(ctx).TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Set(redisSessionPrefix+s.ID, buf, 0)
+		pipe.SAdd(redisSessionsPrefix+s.CID.Text(16), s.ID)
+		return pipe.SAdd(redisSessionsKey, s.ID).Err()
+	})
+	return err
+}
+
+// Update replaces an existing session by the result of update
+func (r *RedisSessionStore) Update(ctx context.Context, id string, update func(Session) (Session, error)) error {
+	key := redisSessionPrefix + id
+	return watchTx(ctx, r.client, func(tx *redis.Tx) error {
+		s, err := getSession(tx.Get(key))
+		if err != nil {
+			return err
+		}
+
+		s, err = update(s)
+		if err != nil {
+			return err
+		}
+		buf, err := json.Marshal(encodeSession(s))
+		if err != nil {
+			return errors.Wrap(err, "Update: failed to encode session")
+		}
+		_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+			return pipe.Set(key, buf, 0).Err()
+		})
+		return err
+	}, key)
+}
+
+// Get an existing session
+func (r *RedisSessionStore) Get(ctx context.Context, id string) (Session, error) {
+	if err := ctx.Err(); err != nil {
+		return Session{}, err
+	}
+	return getSession(r.client.WithContext(ctx).Get(redisSessionPrefix + id))
+}
+
+// Delete a session, unknown sessions are ignored
+func (r *RedisSessionStore) Delete(ctx context.Context, id string) error {
+	return r.delete(ctx, id, func(Session) bool { return true })
+}
+
+// List returns all sessions of an user ordered by creation
+func (r *RedisSessionStore) List(ctx context.Context, cID *big.Int) ([]Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c := r.client.WithContext(ctx)
+	ids, err := c.SMembers(redisSessionsPrefix + cID.Text(16)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []Session
+	for _, id := range ids {
+		s, err := getSession(c.Get(redisSessionPrefix + id))
+		if errors.Cause(err) == ErrSessionNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Created.Before(sessions[j].Created) })
+	return sessions, nil
+}
+
+// Prune deletes all sessions for which ended returns true
+func (r *RedisSessionStore) Prune(ctx context.Context, ended func(Session) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ids, err := r.client.WithContext(ctx).SMembers(redisSessionsKey).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := r.delete(ctx, id, ended); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delete removes the session with id and its index entries if ended returns true for it.
+// The index entries of a missing session are removed as well.
+func (r *RedisSessionStore) delete(ctx context.Context, id string, ended func(Session) bool) error {
+	key := redisSessionPrefix + id
+	return watchTx(ctx, r.client, func(tx *redis.Tx) error {
+		s, err := getSession(tx.Get(key))
+		if errors.Cause(err) == ErrSessionNotFound {
+			return tx.SRem(redisSessionsKey, id).Err()
+		}
+		if err != nil {
+			return err
+		}
+		if !ended(s) {
+			return nil
+		}
+
+		_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+			pipe.Del(key)
+			pipe.SRem(redisSessionsPrefix+s.CID.Text(16), id)
+			return pipe.SRem(redisSessionsKey, id).Err()
+		})
+		return err
+	}, key)
+}
+
+// getSession decodes the session read by cmd, a missing session returns ErrSessionNotFound.
+func getSession(cmd *redis.StringCmd) (Session, error) {
+	buf, err := cmd.Bytes()
+	if err == redis.Nil {
+		return Session{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	return decodeSession(buf)
+}
+
+// RedisThrottleStore provides a throttle store backed by Redis, so that all replicas
+// of the service count the same attempts. Keys are indexed in a set for Prune.
+type RedisThrottleStore struct {
+	client *redis.Client
+}
+
+// NewRedisThrottleStore returns a throttle store stored in a Redis server connected by OpenRedis.
+func NewRedisThrottleStore(c *redis.Client) *RedisThrottleStore {
+	return &RedisThrottleStore{client: c}
+}
+
+// Update replaces the attempts of key by the result of update
+func (r *RedisThrottleStore) Update(ctx context.Context, key string, update func(Attempts) (Attempts, error)) error {
+	rkey := redisThrottlePrefix + key
+	return watchTx(ctx, r.client, func(tx *redis.Tx) error {
+		a, err := getAttempts(tx.Get(rkey))
+		if err != nil {
+			return err
+		}
+
+		a, err = update(a)
+		if err != nil {
+			return err
+		}
+		buf, err := json.Marshal(encodeAttempts(a))
+		if err != nil {
+			return errors.Wrapf(err, "Update: failed to encode attempts of %s", key)
+		}
+		_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+			pipe.Set(rkey, buf, 0)
+			return pipe.SAdd(redisThrottleKey, key).Err()
+		})
+		return err
+	}, rkey)
+}
+
+// Delete the attempts of key, unknown keys are ignored
+func (r *RedisThrottleStore) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err := r.client.WithContext(ctx).TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Del(redisThrottlePrefix + key)
+		return pipe.SRem(redisThrottleKey, key).Err()
+	})
+	return err
+}
+
+// Prune deletes all attempts whose last attempt was before t
+func (r *RedisThrottleStore) Prune(ctx context.Context, t time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	keys, err := r.client.WithContext(ctx).SMembers(redisThrottleKey).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		rkey := redisThrottlePrefix + key
+		err := watchTx(ctx, r.client, func(tx *redis.Tx) error {
+			a, err := getAttempts(tx.Get(rkey))
+			if err != nil {
+				return err
+			}
+			if !a.Last.Before(t) {
+				return nil
+			}
+			_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+				pipe.Del(rkey)
+				return pipe.SRem(redisThrottleKey, key).Err()
+			})
+			return err
+		}, rkey)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getAttempts decodes the attempts read by cmd, missing attempts are zero.
+func getAttempts(cmd *redis.StringCmd) (Attempts, error) {
+	buf, err := cmd.Bytes()
+	if err == redis.Nil {
+		return Attempts{}, nil
+	}
+	if err != nil {
+		return Attempts{}, err
+	}
+	return decodeAttempts(buf)
+}
@@ -1,26 +1,182 @@
 package service
 
 import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
 	"math/big"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
 )
 
-func TestUserRepository_Add(t *testing.T) {
+func TestInMemoryUserRepository(t *testing.T) {
+	testUserRepository(t, func(t *testing.T) (UserRepository, func()) {
+		return NewUserRepository(), func() {}
+	})
+}
+
+func TestBoltUserRepository(t *testing.T) {
+	testUserRepository(t, func(t *testing.T) (UserRepository, func()) {
+		db, cleanup := openTempBolt(t)
+		return NewBoltUserRepository(db), cleanup
+	})
+
+	t.Run("should get user after reopening the repository", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		fn := filepath.Join(dir, "ossvc.db")
+		wantUser := User{cID: big.NewInt(1), verifier: big.NewInt(2)}
+
+		db, err := OpenBolt(fn)
+		if err != nil {
+			t.Fatalf("OpenBolt() error = %v", err)
+		}
+		err = NewBoltUserRepository(db).Set(context.Background(), wantUser)
+		if err != nil {
+			t.Errorf("BoltUserRepository.Set() error = %v", err)
+		}
+		db.Close()
+
+		db, err = OpenBolt(fn)
+		if err != nil {
+			t.Fatalf("OpenBolt() error = %v", err)
+		}
+		defer db.Close()
+		gotUser, err := NewBoltUserRepository(db).Get(context.Background(), wantUser.cID)
+		if err != nil {
+			t.Errorf("BoltUserRepository.Get() error = %v", err)
+		}
+		if !reflect.DeepEqual(wantUser, gotUser) {
+			t.Errorf("BoltUserRepository.Get() wantUser = %v but gotUser = %v", wantUser, gotUser)
+		}
+	})
+
+	t.Run("should refuse to open a store with a newer schema", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		fn := filepath.Join(dir, "ossvc.db")
+
+		db, err := bolt.Open(fn, 0600, nil)
+		if err != nil {
+			t.Fatalf("bolt.Open() error = %v", err)
+		}
+		err = db.Update(func(tx *bolt.Tx) error {
+			b, err := tx.CreateBucket(metaBucket)
+			if err != nil {
+				return err
+			}
+			v := make([]byte, 8)
+			binary.BigEndian.PutUint64(v, uint64(len(migrations)+1))
+			return b.Put(schemaKey, v)
+		})
+		if err != nil {
+			t.Fatalf("db.Update() error = %v", err)
+		}
+		db.Close()
+
+		_, err = OpenBolt(fn)
+		if errors.Cause(err) != ErrUnsupportedSchema {
+			t.Errorf("OpenBolt() error = %v wantError = %v", err, ErrUnsupportedSchema)
+		}
+	})
+}
+
+func TestBoltUserRepository_Migrate(t *testing.T) {
+	t.Run("should migrate vaults out of version 1 user records and drop kv", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		fn := filepath.Join(dir, "ossvc.db")
+
+		db, err := bolt.Open(fn, 0600, nil)
+		if err != nil {
+			t.Fatalf("bolt.Open() error = %v", err)
+		}
+		err = db.Update(func(tx *bolt.Tx) error {
+			meta, err := tx.CreateBucket(metaBucket)
+			if err != nil {
+				return err
+			}
+			v := make([]byte, 8)
+			binary.BigEndian.PutUint64(v, 1)
+			if err := meta.Put(schemaKey, v); err != nil {
+				return err
+			}
+			users, err := tx.CreateBucket(usersBucket)
+			if err != nil {
+				return err
+			}
+			return users.Put(big.NewInt(1).Bytes(), []byte(`{"version":1,"cID":"1","kv":"2","vaults":{"domain":{"k":"3","qj":"4"}}}`))
+		})
+		if err != nil {
+			t.Fatalf("db.Update() error = %v", err)
+		}
+		db.Close()
+
+		db, err = OpenBolt(fn)
+		if err != nil {
+			t.Fatalf("OpenBolt() error = %v", err)
+		}
+		defer db.Close()
+
+		gotUser, err := NewBoltUserRepository(db).Get(context.Background(), big.NewInt(1))
+		if err != nil {
+			t.Errorf("BoltUserRepository.Get() error = %v", err)
+		}
+		wantUser := User{cID: big.NewInt(1)}
+		if !reflect.DeepEqual(wantUser, gotUser) {
+			t.Errorf("BoltUserRepository.Get() wantUser = %v but gotUser = %v", wantUser, gotUser)
+		}
 
+		gotVault, err := NewBoltVaultRepository(db).Get(context.Background(), big.NewInt(1), "domain")
+		if err != nil {
+			t.Errorf("BoltVaultRepository.Get() error = %v", err)
+		}
+		wantVault := Vault{k: big.NewInt(3), qj: big.NewInt(4)}
+		if !reflect.DeepEqual(wantVault, gotVault) {
+			t.Errorf("BoltVaultRepository.Get() wantVault = %v but gotVault = %v", wantVault, gotVault)
+		}
+	})
+}
+
+func TestRedisUserRepository(t *testing.T) {
+	testUserRepository(t, func(t *testing.T) (UserRepository, func()) {
+		c, cleanup := openTempRedis(t)
+		return NewRedisUserRepository(c), cleanup
+	})
+}
+
+// testUserRepository is the conformance test suite every UserRepository has to pass.
+func testUserRepository(t *testing.T, newRepo func(t *testing.T) (UserRepository, func())) {
+	testUserRepositoryAdd(t, newRepo)
+	testUserRepositorySet(t, newRepo)
+	testUserRepositoryUpdate(t, newRepo)
+}
+
+func testUserRepositoryAdd(t *testing.T, newRepo func(t *testing.T) (UserRepository, func())) {
 	cID := big.NewInt(1)
 
 	t.Run("should add new user and get the same", func(t *testing.T) {
 		// given
-		r := NewUserRepository()
+		r, cleanup := newRepo(t)
+		defer cleanup()
 		wantUser := User{cID: cID}
 		// when
-		err := r.Set(wantUser)
+		err := r.Set(context.Background(), wantUser)
 		if err != nil {
 			t.Errorf("UserRepository.Add() error = %v", err)
 		}
 		// then expect
-		gotUser, err := r.Get(cID)
+		gotUser, err := r.Get(context.Background(), cID)
 		if err != nil {
 			t.Errorf("UserRepository.Add() error = %v", err)
 		}
@@ -30,22 +186,92 @@ func TestUserRepository_Add(t *testing.T) {
 		}
 	})
 
+	t.Run("should add user with verifier and get the same", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+		wantUser := User{
+			cID:      cID,
+			verifier: big.NewInt(2),
+		}
+
+		err := r.Set(context.Background(), wantUser)
+		if err != nil {
+			t.Errorf("UserRepository.Set() error = %v", err)
+		}
+
+		gotUser, err := r.Get(context.Background(), cID)
+		if err != nil {
+			t.Errorf("UserRepository.Get() error = %v", err)
+		}
+		if !reflect.DeepEqual(wantUser, gotUser) {
+			t.Errorf("UserRepository.Get() wantUser = %v but gotUser = %v", wantUser, gotUser)
+		}
+	})
+
+	t.Run("should add user with enrollment and get the same", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+		wantUser := User{
+			cID:         cID,
+			enrollment:  []byte{1, 2, 3},
+			enrollUntil: time.Unix(0, 1571227200000000000),
+		}
+
+		err := r.Set(context.Background(), wantUser)
+		if err != nil {
+			t.Errorf("UserRepository.Set() error = %v", err)
+		}
+
+		gotUser, err := r.Get(context.Background(), cID)
+		if err != nil {
+			t.Errorf("UserRepository.Get() error = %v", err)
+		}
+		if !reflect.DeepEqual(wantUser, gotUser) {
+			t.Errorf("UserRepository.Get() wantUser = %v but gotUser = %v", wantUser, gotUser)
+		}
+	})
+
+	t.Run("should not add an existing user", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+		oldUser := User{cID: cID, verifier: big.NewInt(1)}
+
+		err := r.Add(context.Background(), oldUser)
+		if err != nil {
+			t.Fatalf("UserRepository.Add() error = %v", err)
+		}
+		err = r.Add(context.Background(), User{cID: cID, verifier: big.NewInt(2)})
+		if errors.Cause(err) != ErrUserAlreadyExists {
+			t.Errorf("UserRepository.Add() error = %v wantError = %v", err, ErrUserAlreadyExists)
+		}
+
+		gotUser, _ := r.Get(context.Background(), cID)
+		if !reflect.DeepEqual(oldUser, gotUser) {
+			t.Errorf("Get() wantUser = %v, gotUser %v", oldUser, gotUser)
+		}
+	})
+}
+
+func testUserRepositorySet(t *testing.T, newRepo func(t *testing.T) (UserRepository, func())) {
+	cID := big.NewInt(1)
+
 	t.Run("should override existing user and get the new one", func(t *testing.T) {
-		oldUser := User{cID: cID, kv: big.NewInt(1)}
-		newUser := User{cID: cID, kv: big.NewInt(1)}
-		r := NewUserRepository()
+		oldUser := User{cID: cID, verifier: big.NewInt(1)}
+		newUser := User{cID: cID, verifier: big.NewInt(2)}
+		r, cleanup := newRepo(t)
+		defer cleanup()
 		// given
-		err := r.Set(oldUser)
+		err := r.Set(context.Background(), oldUser)
 		if err != nil {
 			t.Errorf("UserRepository.Set() error = %v", err)
 		}
 		// when
-		err = r.Set(newUser)
+		err = r.Set(context.Background(), newUser)
 		if err != nil {
 			t.Errorf("UserRepository.Set() error = %v", err)
 		}
 
-		gotUser, err := r.Get(cID)
+		gotUser, err := r.Get(context.Background(), cID)
 		if err != nil {
 			t.Errorf("UserRepository.Add() error = %v", err)
 		}
@@ -54,12 +280,706 @@ func TestUserRepository_Add(t *testing.T) {
 		}
 	})
 
+	t.Run("should set users concurrently", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+		n := 10
+
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if err := r.Set(context.Background(), User{cID: big.NewInt(int64(i + 1))}); err != nil {
+					t.Errorf("UserRepository.Set() error = %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < n; i++ {
+			if _, err := r.Get(context.Background(), big.NewInt(int64(i+1))); err != nil {
+				t.Errorf("UserRepository.Get() error = %v", err)
+			}
+		}
+	})
+}
+
+func testUserRepositoryUpdate(t *testing.T, newRepo func(t *testing.T) (UserRepository, func())) {
+	cID := big.NewInt(1)
+
+	t.Run("should update an user and abort an update returning an error", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+		r.Add(context.Background(), User{cID: cID})
+
+		err := r.Update(context.Background(), cID, func(u User) (User, error) {
+			u.verifier = big.NewInt(2)
+			return u, nil
+		})
+		if err != nil {
+			t.Fatalf("UserRepository.Update() error = %v", err)
+		}
+		ErrTest := errors.New("unit test")
+		err = r.Update(context.Background(), cID, func(u User) (User, error) {
+			u.verifier = big.NewInt(3)
+			return u, ErrTest
+		})
+		if errors.Cause(err) != ErrTest {
+			t.Errorf("UserRepository.Update() error = %v wantError = %v", err, ErrTest)
+		}
+
+		want := User{cID: cID, verifier: big.NewInt(2)}
+		gotUser, _ := r.Get(context.Background(), cID)
+		if !reflect.DeepEqual(want, gotUser) {
+			t.Errorf("Get() wantUser = %v, gotUser %v", want, gotUser)
+		}
+	})
+
+	t.Run("should not update a missing user", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+
+		err := r.Update(context.Background(), cID, func(u User) (User, error) { return u, nil })
+		if errors.Cause(err) != ErrUserNotFound {
+			t.Errorf("UserRepository.Update() error = %v wantError = %v", err, ErrUserNotFound)
+		}
+	})
+
 	t.Run("should return ErrUserNotFound if user does not exist", func(t *testing.T) {
-		r := NewUserRepository()
+		r, cleanup := newRepo(t)
+		defer cleanup()
 
-		_, err := r.Get(cID)
+		_, err := r.Get(context.Background(), cID)
 		if err != ErrUserNotFound {
 			t.Errorf("UserRepository.Get() error = %v wantError = %v", err, ErrUserNotFound)
 		}
 	})
+
+	t.Run("should return the error of a done context", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := r.Set(ctx, User{cID: cID}); err != context.Canceled {
+			t.Errorf("UserRepository.Set() error = %v wantError = %v", err, context.Canceled)
+		}
+		if err := r.Add(ctx, User{cID: cID}); err != context.Canceled {
+			t.Errorf("UserRepository.Add() error = %v wantError = %v", err, context.Canceled)
+		}
+		if err := r.Update(ctx, cID, func(u User) (User, error) { return u, nil }); err != context.Canceled {
+			t.Errorf("UserRepository.Update() error = %v wantError = %v", err, context.Canceled)
+		}
+		if _, err := r.Get(ctx, cID); err != context.Canceled {
+			t.Errorf("UserRepository.Get() error = %v wantError = %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestInMemoryVaultRepository(t *testing.T) {
+	testVaultRepository(t, func(t *testing.T) (VaultRepository, func()) {
+		return NewVaultRepository(), func() {}
+	})
+}
+
+func TestBoltVaultRepository(t *testing.T) {
+	testVaultRepository(t, func(t *testing.T) (VaultRepository, func()) {
+		db, cleanup := openTempBolt(t)
+		return NewBoltVaultRepository(db), cleanup
+	})
+}
+
+func TestRedisVaultRepository(t *testing.T) {
+	testVaultRepository(t, func(t *testing.T) (VaultRepository, func()) {
+		c, cleanup := openTempRedis(t)
+		return NewRedisVaultRepository(c), cleanup
+	})
+}
+
+// testVaultRepository is the conformance test suite every VaultRepository has to pass.
+func testVaultRepository(t *testing.T, newRepo func(t *testing.T) (VaultRepository, func())) {
+	testVaultRepositoryAdd(t, newRepo)
+	testVaultRepositoryUpdate(t, newRepo)
+	testVaultRepositoryConcurrency(t, newRepo)
+}
+
+func testVaultRepositoryAdd(t *testing.T, newRepo func(t *testing.T) (VaultRepository, func())) {
+	cID := big.NewInt(1)
+	vault := Vault{k: big.NewInt(2), qj: big.NewInt(3), metadata: `{"policy":{"length":16}}`}
+
+	t.Run("should add new vault and get the same", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+
+		err := r.Add(context.Background(), cID, "domain", vault)
+		if err != nil {
+			t.Errorf("VaultRepository.Add() error = %v", err)
+		}
+
+		got, err := r.Get(context.Background(), cID, "domain")
+		if err != nil {
+			t.Errorf("VaultRepository.Get() error = %v", err)
+		}
+		if !reflect.DeepEqual(vault, got) {
+			t.Errorf("VaultRepository.Get() want = %v but got = %v", vault, got)
+		}
+	})
+
+	t.Run("should not override an existing vault", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+
+		err := r.Add(context.Background(), cID, "domain", vault)
+		if err != nil {
+			t.Errorf("VaultRepository.Add() error = %v", err)
+		}
+		err = r.Add(context.Background(), cID, "domain", Vault{k: big.NewInt(4), qj: big.NewInt(5)})
+		if err != ErrDomainAlreadyExists {
+			t.Errorf("VaultRepository.Add() error = %v wantError = %v", err, ErrDomainAlreadyExists)
+		}
+
+		got, err := r.Get(context.Background(), cID, "domain")
+		if err != nil {
+			t.Errorf("VaultRepository.Get() error = %v", err)
+		}
+		if !reflect.DeepEqual(vault, got) {
+			t.Errorf("VaultRepository.Get() want = %v but got = %v", vault, got)
+		}
+	})
+
+	t.Run("should return all domains in ascending order", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+
+		domains, err := r.GetDomains(context.Background(), cID)
+		if err != nil {
+			t.Errorf("VaultRepository.GetDomains() error = %v", err)
+		}
+		if len(domains) != 0 {
+			t.Errorf("VaultRepository.GetDomains() expect no domains but got %v", domains)
+		}
+
+		for _, d := range []string{"b.com", "a.com"} {
+			if err := r.Add(context.Background(), cID, d, vault); err != nil {
+				t.Errorf("VaultRepository.Add() error = %v", err)
+			}
+		}
+
+		domains, err = r.GetDomains(context.Background(), cID)
+		if err != nil {
+			t.Errorf("VaultRepository.GetDomains() error = %v", err)
+		}
+		want := []string{"a.com", "b.com"}
+		if !reflect.DeepEqual(want, domains) {
+			t.Errorf("VaultRepository.GetDomains() want = %v but got = %v", want, domains)
+		}
+	})
+}
+
+func testVaultRepositoryUpdate(t *testing.T, newRepo func(t *testing.T) (VaultRepository, func())) {
+	cID := big.NewInt(1)
+	vault := Vault{k: big.NewInt(2), qj: big.NewInt(3), metadata: `{"policy":{"length":16}}`}
+
+	t.Run("should update an existing vault and keep its previous version", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+
+		err := r.Add(context.Background(), cID, "domain", vault)
+		if err != nil {
+			t.Errorf("VaultRepository.Add() error = %v", err)
+		}
+
+		want := Vault{
+			k:             big.NewInt(4),
+			qj:            big.NewInt(5),
+			metadata:      vault.metadata,
+			version:       1,
+			previous:      &Vault{k: vault.k, qj: vault.qj},
+			previousUntil: time.Unix(0, 42),
+		}
+		err = r.Update(context.Background(), cID, "domain", func(v Vault) (Vault, error) {
+			if !reflect.DeepEqual(vault, v) {
+				t.Errorf("VaultRepository.Update() want = %v but got = %v", vault, v)
+			}
+			return want, nil
+		})
+		if err != nil {
+			t.Errorf("VaultRepository.Update() error = %v", err)
+		}
+
+		got, err := r.Get(context.Background(), cID, "domain")
+		if err != nil {
+			t.Errorf("VaultRepository.Get() error = %v", err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("VaultRepository.Get() want = %v but got = %v", want, got)
+		}
+	})
+
+	t.Run("should not update a missing vault", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+
+		err := r.Update(context.Background(), cID, "domain", func(v Vault) (Vault, error) { return v, nil })
+		if err != ErrDomainNotFound {
+			t.Errorf("VaultRepository.Update() error = %v wantError = %v", err, ErrDomainNotFound)
+		}
+	})
+
+	t.Run("should delete a vault and allow to add it again", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+		if err := r.Add(context.Background(), cID, "domain", vault); err != nil {
+			t.Fatalf("VaultRepository.Add() error = %v", err)
+		}
+
+		err := r.Delete(context.Background(), cID, "domain")
+
+		if err != nil {
+			t.Errorf("VaultRepository.Delete() error = %v", err)
+		}
+		if _, err := r.Get(context.Background(), cID, "domain"); err != ErrDomainNotFound {
+			t.Errorf("VaultRepository.Get() error = %v wantError = %v", err, ErrDomainNotFound)
+		}
+		if err := r.Add(context.Background(), cID, "domain", vault); err != nil {
+			t.Errorf("VaultRepository.Add() error = %v", err)
+		}
+	})
+
+	t.Run("should not delete a missing vault", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+
+		err := r.Delete(context.Background(), cID, "domain")
+		if err != ErrDomainNotFound {
+			t.Errorf("VaultRepository.Delete() error = %v wantError = %v", err, ErrDomainNotFound)
+		}
+	})
+
+	t.Run("should keep vaults of different users apart", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+
+		err := r.Add(context.Background(), cID, "domain", vault)
+		if err != nil {
+			t.Errorf("VaultRepository.Add() error = %v", err)
+		}
+
+		_, err = r.Get(context.Background(), big.NewInt(2), "domain")
+		if err != ErrDomainNotFound {
+			t.Errorf("VaultRepository.Get() error = %v wantError = %v", err, ErrDomainNotFound)
+		}
+	})
+}
+
+func testVaultRepositoryConcurrency(t *testing.T, newRepo func(t *testing.T) (VaultRepository, func())) {
+	cID := big.NewInt(1)
+	vault := Vault{k: big.NewInt(2), qj: big.NewInt(3), metadata: `{"policy":{"length":16}}`}
+
+	t.Run("should add vaults of different domains concurrently", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+		n := 10
+
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if err := r.Add(context.Background(), cID, fmt.Sprintf("domain-%d", i), vault); err != nil {
+					t.Errorf("VaultRepository.Add() error = %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		domains, err := r.GetDomains(context.Background(), cID)
+		if err != nil {
+			t.Errorf("VaultRepository.GetDomains() error = %v", err)
+		}
+		if len(domains) != n {
+			t.Errorf("VaultRepository.GetDomains() want %v domains but got %v", n, domains)
+		}
+	})
+
+	t.Run("should add the same domain only once concurrently", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+		n := 10
+
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		var added int
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := r.Add(context.Background(), cID, "domain", vault)
+				switch err {
+				case nil:
+					mutex.Lock()
+					added++
+					mutex.Unlock()
+				case ErrDomainAlreadyExists:
+				default:
+					t.Errorf("VaultRepository.Add() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if added != 1 {
+			t.Errorf("VaultRepository.Add() succeeded %v times, want once", added)
+		}
+	})
+
+	t.Run("should return the error of a done context", func(t *testing.T) {
+		r, cleanup := newRepo(t)
+		defer cleanup()
+		ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+		defer cancel()
+
+		if err := r.Add(ctx, cID, "domain", vault); err != context.DeadlineExceeded {
+			t.Errorf("VaultRepository.Add() error = %v wantError = %v", err, context.DeadlineExceeded)
+		}
+		if _, err := r.Get(ctx, cID, "domain"); err != context.DeadlineExceeded {
+			t.Errorf("VaultRepository.Get() error = %v wantError = %v", err, context.DeadlineExceeded)
+		}
+		if _, err := r.GetDomains(ctx, cID); err != context.DeadlineExceeded {
+			t.Errorf("VaultRepository.GetDomains() error = %v wantError = %v", err, context.DeadlineExceeded)
+		}
+	})
+}
+
+func TestInMemorySessionStore(t *testing.T) {
+	testSessionStore(t, func(t *testing.T) (SessionStore, func()) {
+		return NewSessionStore(), func() {}
+	})
+}
+
+func TestBoltSessionStore(t *testing.T) {
+	testSessionStore(t, func(t *testing.T) (SessionStore, func()) {
+		db, cleanup := openTempBolt(t)
+		return NewBoltSessionStore(db), cleanup
+	})
+}
+
+func TestRedisSessionStore(t *testing.T) {
+	testSessionStore(t, func(t *testing.T) (SessionStore, func()) {
+		c, cleanup := openTempRedis(t)
+		return NewRedisSessionStore(c), cleanup
+	})
+}
+
+// testSessionStore is the conformance test suite every SessionStore has to pass.
+func testSessionStore(t *testing.T, newStore func(t *testing.T) (SessionStore, func())) {
+	testSessionStoreUpdate(t, newStore)
+	testSessionStoreList(t, newStore)
+}
+
+func testSessionStoreUpdate(t *testing.T, newStore func(t *testing.T) (SessionStore, func())) {
+	ctx := context.Background()
+
+	created := time.Unix(0, 1571234567000000000)
+	session := Session{ID: "a", CID: big.NewInt(1), SID: big.NewInt(2), SKi: big.NewInt(3), Created: created, LastSeen: created}
+
+	t.Run("should set session and get the same", func(t *testing.T) {
+		r, cleanup := newStore(t)
+		defer cleanup()
+
+		err := r.Set(ctx, session)
+		if err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		got, err := r.Get(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, session) {
+			t.Errorf("Get() = %v, want %v", got, session)
+		}
+	})
+
+	t.Run("should not get deleted session", func(t *testing.T) {
+		r, cleanup := newStore(t)
+		defer cleanup()
+
+		r.Set(ctx, session)
+		err := r.Delete(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		_, err = r.Get(ctx, session.ID)
+		if errors.Cause(err) != ErrSessionNotFound {
+			t.Errorf("Get() error = %v, want %v", err, ErrSessionNotFound)
+		}
+		err = r.Delete(ctx, session.ID)
+		if err != nil {
+			t.Errorf("Delete() of unknown session error = %v", err)
+		}
+	})
+
+	t.Run("should update a session and abort an update returning an error", func(t *testing.T) {
+		r, cleanup := newStore(t)
+		defer cleanup()
+
+		r.Set(ctx, session)
+		err := r.Update(ctx, session.ID, func(s Session) (Session, error) {
+			s.Counter = 7
+			return s, nil
+		})
+		if err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		ErrTest := errors.New("unit test")
+		err = r.Update(ctx, session.ID, func(s Session) (Session, error) {
+			s.Counter = 8
+			return s, ErrTest
+		})
+		if errors.Cause(err) != ErrTest {
+			t.Errorf("Update() error = %v, want %v", err, ErrTest)
+		}
+
+		got, _ := r.Get(ctx, session.ID)
+		if got.Counter != 7 {
+			t.Errorf("Get() counter = %v, want 7", got.Counter)
+		}
+	})
+
+	t.Run("should not update an unknown session", func(t *testing.T) {
+		r, cleanup := newStore(t)
+		defer cleanup()
+
+		err := r.Update(ctx, session.ID, func(s Session) (Session, error) { return s, nil })
+		if errors.Cause(err) != ErrSessionNotFound {
+			t.Errorf("Update() error = %v, want %v", err, ErrSessionNotFound)
+		}
+	})
+
+	t.Run("should return the error of a done context", func(t *testing.T) {
+		r, cleanup := newStore(t)
+		defer cleanup()
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		if err := r.Set(ctx, session); err != context.Canceled {
+			t.Errorf("SessionStore.Set() error = %v wantError = %v", err, context.Canceled)
+		}
+		if _, err := r.Get(ctx, session.ID); err != context.Canceled {
+			t.Errorf("SessionStore.Get() error = %v wantError = %v", err, context.Canceled)
+		}
+		if err := r.Update(ctx, session.ID, func(s Session) (Session, error) { return s, nil }); err != context.Canceled {
+			t.Errorf("SessionStore.Update() error = %v wantError = %v", err, context.Canceled)
+		}
+		if err := r.Delete(ctx, session.ID); err != context.Canceled {
+			t.Errorf("SessionStore.Delete() error = %v wantError = %v", err, context.Canceled)
+		}
+		if _, err := r.List(ctx, session.CID); err != context.Canceled {
+			t.Errorf("SessionStore.List() error = %v wantError = %v", err, context.Canceled)
+		}
+		if err := r.Prune(ctx, func(Session) bool { return true }); err != context.Canceled {
+			t.Errorf("SessionStore.Prune() error = %v wantError = %v", err, context.Canceled)
+		}
+	})
+}
+
+func testSessionStoreList(t *testing.T, newStore func(t *testing.T) (SessionStore, func())) {
+	ctx := context.Background()
+
+	created := time.Unix(0, 1571234567000000000)
+	session := Session{ID: "a", CID: big.NewInt(1), SID: big.NewInt(2), SKi: big.NewInt(3), Created: created, LastSeen: created}
+
+	t.Run("should list sessions of an user ordered by creation", func(t *testing.T) {
+		r, cleanup := newStore(t)
+		defer cleanup()
+
+		later := session
+		later.ID, later.Created = "b", created.Add(time.Minute)
+		other := session
+		other.ID, other.CID = "c", big.NewInt(4)
+		for _, s := range []Session{later, session, other} {
+			if err := r.Set(ctx, s); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+		}
+
+		got, err := r.List(ctx, session.CID)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		if len(got) != 2 || got[0].ID != "a" || got[1].ID != "b" {
+			t.Errorf("List() = %v, want sessions a and b", got)
+		}
+	})
+
+	t.Run("should prune ended sessions and keep the others", func(t *testing.T) {
+		r, cleanup := newStore(t)
+		defer cleanup()
+
+		ended := session
+		ended.ID = "b"
+		for _, s := range []Session{session, ended} {
+			if err := r.Set(ctx, s); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+		}
+
+		err := r.Prune(ctx, func(s Session) bool { return s.ID == ended.ID })
+		if err != nil {
+			t.Fatalf("Prune() error = %v", err)
+		}
+		if _, err := r.Get(ctx, ended.ID); errors.Cause(err) != ErrSessionNotFound {
+			t.Errorf("Get() error = %v, want %v", err, ErrSessionNotFound)
+		}
+		if _, err := r.Get(ctx, session.ID); err != nil {
+			t.Errorf("Get() error = %v", err)
+		}
+	})
+}
+
+func TestInMemoryThrottleStore(t *testing.T) {
+	testThrottleStore(t, func(t *testing.T) (ThrottleStore, func()) {
+		return NewThrottleStore(), func() {}
+	})
+}
+
+func TestBoltThrottleStore(t *testing.T) {
+	testThrottleStore(t, func(t *testing.T) (ThrottleStore, func()) {
+		db, cleanup := openTempBolt(t)
+		return NewBoltThrottleStore(db), cleanup
+	})
+}
+
+func TestRedisThrottleStore(t *testing.T) {
+	testThrottleStore(t, func(t *testing.T) (ThrottleStore, func()) {
+		c, cleanup := openTempRedis(t)
+		return NewRedisThrottleStore(c), cleanup
+	})
+}
+
+// testThrottleStore is the conformance test suite every ThrottleStore has to pass.
+func testThrottleStore(t *testing.T, newStore func(t *testing.T) (ThrottleStore, func())) {
+	ctx := context.Background()
+
+	last := time.Unix(0, 1571234567000000000)
+	attempts := Attempts{Failures: 3, Last: last, Next: last.Add(time.Second)}
+	set := func(a Attempts) (Attempts, error) { return attempts, nil }
+
+	t.Run("should update attempts and pass the same to the next update", func(t *testing.T) {
+		r, cleanup := newStore(t)
+		defer cleanup()
+
+		err := r.Update(ctx, "key", func(a Attempts) (Attempts, error) {
+			if !reflect.DeepEqual(a, Attempts{}) {
+				t.Errorf("Update() initial attempts = %v, want zero", a)
+			}
+			return attempts, nil
+		})
+		if err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		r.Update(ctx, "key", func(a Attempts) (Attempts, error) {
+			if !a.Last.Equal(attempts.Last) || !a.Next.Equal(attempts.Next) || a.Failures != attempts.Failures {
+				t.Errorf("Update() attempts = %v, want %v", a, attempts)
+			}
+			return a, nil
+		})
+	})
+
+	t.Run("should abort an update returning an error", func(t *testing.T) {
+		r, cleanup := newStore(t)
+		defer cleanup()
+
+		ErrTest := errors.New("unit test")
+		r.Update(ctx, "key", set)
+		err := r.Update(ctx, "key", func(a Attempts) (Attempts, error) { return Attempts{}, ErrTest })
+		if errors.Cause(err) != ErrTest {
+			t.Errorf("Update() error = %v, want %v", err, ErrTest)
+		}
+		r.Update(ctx, "key", func(a Attempts) (Attempts, error) {
+			if a.Failures != attempts.Failures {
+				t.Errorf("Update() attempts = %v, want %v", a, attempts)
+			}
+			return a, nil
+		})
+	})
+
+	t.Run("should delete and prune attempts", func(t *testing.T) {
+		r, cleanup := newStore(t)
+		defer cleanup()
+
+		r.Update(ctx, "deleted", set)
+		r.Update(ctx, "pruned", set)
+		r.Update(ctx, "kept", func(a Attempts) (Attempts, error) { return Attempts{Failures: 1, Last: last.Add(time.Hour)}, nil })
+
+		if err := r.Delete(ctx, "deleted"); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if err := r.Prune(ctx, last.Add(time.Minute)); err != nil {
+			t.Fatalf("Prune() error = %v", err)
+		}
+
+		for key, want := range map[string]int{"deleted": 0, "pruned": 0, "kept": 1} {
+			r.Update(ctx, key, func(a Attempts) (Attempts, error) {
+				if a.Failures != want {
+					t.Errorf("Update(%s) failures = %v, want %v", key, a.Failures, want)
+				}
+				return a, nil
+			})
+		}
+	})
+
+	t.Run("should return the error of a done context", func(t *testing.T) {
+		r, cleanup := newStore(t)
+		defer cleanup()
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		if err := r.Update(ctx, "key", set); err != context.Canceled {
+			t.Errorf("ThrottleStore.Update() error = %v wantError = %v", err, context.Canceled)
+		}
+		if err := r.Delete(ctx, "key"); err != context.Canceled {
+			t.Errorf("ThrottleStore.Delete() error = %v wantError = %v", err, context.Canceled)
+		}
+		if err := r.Prune(ctx, last); err != context.Canceled {
+			t.Errorf("ThrottleStore.Prune() error = %v wantError = %v", err, context.Canceled)
+		}
+	})
+}
+
+func openTempBolt(t *testing.T) (*bolt.DB, func()) {
+	dir := tempDir(t)
+	db, err := OpenBolt(filepath.Join(dir, "ossvc.db"))
+	if err != nil {
+		t.Fatalf("OpenBolt() error = %v", err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func openTempRedis(t *testing.T) (*redis.Client, func()) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis.Run() error = %v", err)
+	}
+	c, err := OpenRedis("redis://" + s.Addr())
+	if err != nil {
+		s.Close()
+		t.Fatalf("OpenRedis() error = %v", err)
+	}
+	return c, func() {
+		c.Close()
+		s.Close()
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "ossvc")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() error = %v", err)
+	}
+	return dir
 }
@@ -1,9 +1,13 @@
 package service
 
 import (
-	"bytes"
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"math/big"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -15,179 +19,387 @@ var (
 	ErrMacMismatch = errors.New("MAC mismatch")
 	// ErrDomainNotFound is returned an existing user does not
 	ErrDomainNotFound = errors.New("domain not found")
+	// ErrDomainAlreadyExists is returned when an user adds the same domain twice
+	ErrDomainAlreadyExists = errors.New("domain already exists")
+	// ErrGroupMismatch is returned when a request uses other group parameters than the service
+	ErrGroupMismatch = errors.New("group mismatch")
+	// ErrInvalidElement is returned when a request contains a value which is not a group element
+	ErrInvalidElement = errors.New("invalid group element")
+	// ErrMetadataTooLong is returned when the metadata of a domain exceeds MaxMetadataLength
+	ErrMetadataTooLong = errors.New("metadata too long")
+	// ErrNoPreviousVersion is returned when a domain was never rotated or the grace period is over
+	ErrNoPreviousVersion = errors.New("no previous version")
+	// ErrEnrollmentInvalid is returned when an enrollment code is wrong, used or expired
+	ErrEnrollmentInvalid = errors.New("invalid enrollment code")
 )
+
+// MaxMetadataLength is the maximum length of the metadata stored with a domain.
+const MaxMetadataLength = 4096
+
 var (
 	one = big.NewInt(1)
 	two = big.NewInt(2)
 )
 
 // Service represents the interface provided to other layers.
+// All operations take the context of the request, which carries its deadline
+// and request scoped values like the request ID, see WithRequestID.
 type Service interface {
-	Register(cID *big.Int) error
+	Group() crypto.Group
+	PublicKey() *big.Int
+
+	RegisterExpK(ctx context.Context, b, q *big.Int) (bd, q0 *big.Int, proof crypto.Proof, err error)
+	Register(ctx context.Context, cID, verifier *big.Int) error
+	Enroll(ctx context.Context, cID, verifier *big.Int, code string, proof crypto.PossessionProof) error
 
-	ExpK(cID, cNonce, b, q *big.Int) (ski, sID, sNonce, bd, q0, kv *big.Int, err error)
-	Challenge(ski, g, q *big.Int) (r *big.Int, err error)
+	ExpK(ctx context.Context, cID, cNonce, b, q, x *big.Int) (ski, sID, sNonce, bd, q0, y *big.Int, proof crypto.Proof, err error)
+	Challenge(ctx context.Context, ski, g, q *big.Int) (r *big.Int, err error)
 
-	VerifyMAC(mac []byte, cID *big.Int, data ...[]byte) error
+	VerifyMAC(ctx context.Context, mac []byte, cID *big.Int, data ...[]byte) error
 
-	GetMetadata(cID *big.Int) (domains []string, err error)
+	GetMetadata(ctx context.Context, cID *big.Int) (domains []string, err error)
 
-	Add(cID *big.Int, domain string) (err error)
-	Get(cID *big.Int, domain string, bmk *big.Int, q *big.Int) (bj, qj *big.Int, err error)
+	Add(ctx context.Context, cID *big.Int, domain, metadata string) (err error)
+	Get(ctx context.Context, cID *big.Int, domain string, bmk *big.Int, q *big.Int, previous bool) (bj, qj *big.Int, metadata string, err error)
+	Rotate(ctx context.Context, cID *big.Int, domain string) (version int, previousUntil time.Time, err error)
+	Delete(ctx context.Context, cID *big.Int, domain string) (err error)
 }
 
 // Middleware is a chainable behavior modifier for Service.
 type Middleware func(Service) Service
 
-// UserRepository represents a store for user management - need to be implemented
+// UserRepository represents a store for user management - need to be implemented.
+// Add has to be an atomic insert-if-absent and returns ErrUserAlreadyExists otherwise.
+// Update has to atomically replace the user by the result of update and returns ErrUserNotFound
+// if there is none. Implementations return the error of ctx once it is done.
 type UserRepository interface {
-	Set(u User) error
-	Get(ID *big.Int) (User, error)
+	Set(ctx context.Context, u User) error
+	Add(ctx context.Context, u User) error
+	Update(ctx context.Context, ID *big.Int, update func(User) (User, error)) error
+	Get(ctx context.Context, ID *big.Int) (User, error)
 }
 
-// VaultRepository represents a store for domain management - need to be implemented
+// VaultRepository represents a store for domain management keyed by cID and domain.
+// Add has to be an atomic insert-if-absent and returns ErrDomainAlreadyExists otherwise.
+// Update has to atomically replace the vault by the result of update and returns ErrDomainNotFound
+// if there is none, as does Delete. Implementations return the error of ctx once it is done.
 type VaultRepository interface {
-	Add(d string, v Vault) error
-	Get(d string) (Vault, error)
-	GetDomains() ([]string, error)
+	Add(ctx context.Context, cID *big.Int, d string, v Vault) error
+	Update(ctx context.Context, cID *big.Int, d string, update func(Vault) (Vault, error)) error
+	Get(ctx context.Context, cID *big.Int, d string) (Vault, error)
+	GetDomains(ctx context.Context, cID *big.Int) ([]string, error)
+	Delete(ctx context.Context, cID *big.Int, d string) error
 }
 
 // OnlineSphinx provides all operations needed.
 type OnlineSphinx struct {
 	users  UserRepository
+	vaults VaultRepository
 	config Configuration
 }
 
 // New returns an Online SPHINX service - to share - pointer.
-func New(users UserRepository, cfg Configuration) *OnlineSphinx {
+func New(users UserRepository, vaults VaultRepository, cfg Configuration) *OnlineSphinx {
 	return &OnlineSphinx{
 		users:  users,
+		vaults: vaults,
 		config: cfg,
 	}
 }
 
-// Register an user with its cID.
-// Returns error if user with same cID already exists,
-// or if could not set user to repository.
-func (o *OnlineSphinx) Register(cID *big.Int) error {
+// Group returns the group parameters published to clients.
+func (o *OnlineSphinx) Group() crypto.Group {
+	return o.config.group
+}
 
-	kv, err := rand.Int(rand.Reader, o.config.max)
+// PublicKey returns the public key clients verify the proof of ExpK against.
+func (o *OnlineSphinx) PublicKey() *big.Int {
+	return o.config.pk
+}
+
+// RegisterExpK returns the VOPRF evaluation bd = b**k and q0 like ExpK,
+// so that a client derives the verifier of its master key before it registers.
+func (o *OnlineSphinx) RegisterExpK(ctx context.Context, b, q *big.Int) (bd, q0 *big.Int, proof crypto.Proof, err error) {
+	bd, proof, err = o.evaluate(q, b)
 	if err != nil {
-		return errors.Wrap(err, "Register: failed to generate random int kv")
+		return nil, nil, crypto.Proof{}, errors.Wrap(err, "RegisterExpK")
 	}
+	return bd, o.config.q0, proof, nil
+}
 
-	_, err = o.users.Get(cID)
-	switch err {
-	case ErrUserNotFound:
-		// continue
-	default:
-		return errors.Wrapf(err, "Register: failed to register user with cID=%v", cID)
+// Register an user with its cID and the verifier AK = G**ak of its authentication key.
+// Returns ErrUserAlreadyExists if user with same cID already exists,
+// or if could not add user to repository.
+func (o *OnlineSphinx) Register(ctx context.Context, cID, verifier *big.Int) error {
+	if !o.config.group.IsElement(verifier) {
+		return errors.Wrap(ErrInvalidElement, "Register: invalid verifier")
 	}
 
 	return errors.Wrapf(
-		o.users.Set(User{
-			cID:    cID,
-			kv:     kv,
-			vaults: make(map[string]Vault),
-		}), "Register: failed to users.set() with ID %v", cID)
+		o.users.Add(ctx, User{
+			cID:      cID,
+			verifier: verifier,
+		}), "Register: failed to add user with cID=%v", cID)
+}
+
+// enrollmentCodeBytes is the length of the random enrollment codes.
+const enrollmentCodeBytes = 16
+
+// IssueEnrollment returns a one-time code, which lets the user with cID enroll a verifier until validFor passed,
+// and replaces codes issued before. It is an operation of the operator, who hands the code out of band
+// to the user, e.g. to an user migrated from kv or who lost the master password, see Enroll.
+func (o *OnlineSphinx) IssueEnrollment(ctx context.Context, cID *big.Int, validFor time.Duration) (string, error) {
+	buf := make([]byte, enrollmentCodeBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.Wrap(err, "IssueEnrollment: failed to generate code")
+	}
+	code := hex.EncodeToString(buf)
+	hash := sha256.Sum256([]byte(code))
+
+	err := o.users.Update(ctx, cID, func(u User) (User, error) {
+		u.enrollment = hash[:]
+		u.enrollUntil = time.Now().Add(validFor)
+		return u, nil
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "IssueEnrollment: failed to update user with cID=%v", cID)
+	}
+	return code, nil
+}
+
+// Enroll sets the verifier AK = G**ak of an existing user, who authenticates with the code issued
+// by IssueEnrollment, and proves the possession of ak bound to cID. The code is used up, so that
+// neither a known cID nor a known code can replace the verifier. Returns ErrEnrollmentInvalid
+// if the code is wrong, used or expired.
+func (o *OnlineSphinx) Enroll(ctx context.Context, cID, verifier *big.Int, code string, proof crypto.PossessionProof) error {
+	if !o.config.group.IsElement(verifier) {
+		return errors.Wrap(ErrInvalidElement, "Enroll: invalid verifier")
+	}
+	if err := crypto.VerifyPossession(o.config.group, verifier, cID, proof); err != nil {
+		return errors.Wrapf(err, "Enroll: failed to verify possession of user with cID=%v", cID)
+	}
+
+	hash := sha256.Sum256([]byte(code))
+	now := time.Now()
+	err := o.users.Update(ctx, cID, func(u User) (User, error) {
+		if u.enrollment == nil || !hmac.Equal(u.enrollment, hash[:]) || now.After(u.enrollUntil) {
+			return u, ErrEnrollmentInvalid
+		}
+		u.verifier = verifier
+		u.enrollment = nil
+		u.enrollUntil = time.Time{}
+		return u, nil
+	})
+	return errors.Wrapf(err, "Enroll: failed to update user with cID=%v", cID)
 }
 
-// ExpK returns r**k mod |2q + 1|
-func (o *OnlineSphinx) ExpK(cID, cNonce, b, q *big.Int) (ski, sID, sNonce, bd, q0, kv *big.Int, err error) {
+// ExpK returns the VOPRF evaluation bd = b**k of RFC 9497 together with a proof
+// that bd has been computed with the key of PublicKey, and the ephemeral key Y = G**y of the service.
+// The session key ski follows from the ephemeral key x of the client and the verifier of the user,
+// see crypto.SessionKey, so only a client knowing the password derives it.
+// Users registered without verifier return ErrUserNotFound and have to enroll one, see IssueEnrollment.
+func (o *OnlineSphinx) ExpK(ctx context.Context, cID, cNonce, b, q, x *big.Int) (ski, sID, sNonce, bd, q0, y *big.Int, proof crypto.Proof, err error) {
+	err = o.verifyElement(q, x)
+	if err != nil {
+		err = errors.Wrap(err, "ExpK: invalid x")
+		return
+	}
+
+	bd, proof, err = o.evaluate(q, b)
+	if err != nil {
+		err = errors.Wrap(err, "ExpK")
+		return
+	}
 	sID = o.config.sID
 	q0 = o.config.q0
 
-	bd = crypto.ExpInGroup(b, o.config.k, q)
-
-	sNonce, err = rand.Int(rand.Reader, o.config.max)
+	sNonce, err = rand.Int(rand.Reader, o.config.group.Order())
 	if err != nil {
 		err = errors.Wrap(err, "ExpK: failed to generate random int sNonce")
 		return
 	}
 
-	u, err := o.users.Get(cID)
+	u, err := o.users.Get(ctx, cID)
 	if err != nil {
 		err = errors.Wrapf(err, "ExpK: failed to users.get() user with cID=%v", cID)
 		return
 	}
-	kv = u.kv
+	if u.verifier == nil {
+		err = errors.Wrapf(ErrUserNotFound, "ExpK: user with cID=%v has no verifier", cID)
+		return
+	}
 
-	ski = new(big.Int)
-	ski.SetBytes(crypto.HmacData(o.config.hash, kv.Bytes(), cID.Bytes(), sID.Bytes(), cNonce.Bytes(), sNonce.Bytes()))
+	g := o.config.group
+	ey, err := g.RandomScalar()
+	if err != nil {
+		err = errors.Wrap(err, "ExpK: failed to generate ephemeral key")
+		return
+	}
+	y = g.Exp(g.Generator(), ey)
 
+	t := crypto.Transcript{CID: cID, SID: sID, CNonce: cNonce, SNonce: sNonce, B: b, BD: bd, X: x, Y: y}
+	ski = crypto.SessionKey(o.config.hash, g, g.Exp(x, ey), g.Exp(u.verifier, ey), t)
 	return
 }
 
-// Challenge decrypts the vNonce, increments it and encrypts it again.
-func (o *OnlineSphinx) Challenge(ski, g, q *big.Int) (r *big.Int, err error) {
-	return crypto.ExpInGroup(g, ski, q), nil
+// evaluate returns the VOPRF evaluation bd = b**k together with its proof.
+func (o *OnlineSphinx) evaluate(q, b *big.Int) (bd *big.Int, proof crypto.Proof, err error) {
+	err = o.verifyElement(q, b)
+	if err != nil {
+		return nil, crypto.Proof{}, errors.Wrap(err, "invalid b")
+	}
+
+	bd = o.config.oprf.BlindEvaluate(o.config.k, b)
+	proof, err = o.config.oprf.Prove(o.config.k, []*big.Int{b}, []*big.Int{bd})
+	if err != nil {
+		return nil, crypto.Proof{}, errors.Wrap(err, "failed to prove evaluation")
+	}
+	return bd, proof, nil
+}
+
+// Challenge verifies that g is an element of the group and returns r = g**ski,
+// which proves the session key SKi to the client. Each session is challenged only once,
+// the transport confirms the login with the first challenge and ends the session on a failed one.
+func (o *OnlineSphinx) Challenge(ctx context.Context, ski, g, q *big.Int) (r *big.Int, err error) {
+	err = o.verifyElement(q, g)
+	if err != nil {
+		return nil, errors.Wrap(err, "Challenge: invalid g")
+	}
+	return o.config.group.Exp(g, ski), nil
 }
 
 // GetMetadata verifies hmac and returns all domains associated with client ID
-func (o *OnlineSphinx) GetMetadata(cID *big.Int) (domains []string, err error) {
-	u, err := o.users.Get(cID)
+func (o *OnlineSphinx) GetMetadata(ctx context.Context, cID *big.Int) (domains []string, err error) {
+	_, err = o.users.Get(ctx, cID)
 	if err != nil {
 		return nil, errors.Wrapf(err, "GetMetadata: failed to users.get() user with cID=%v", cID)
 	}
-	domains = make([]string, len(u.vaults))
-	var i int
-	for k := range u.vaults {
-		domains[i] = k
-		i++
-	}
 
-	return
+	domains, err = o.vaults.GetDomains(ctx, cID)
+	return domains, errors.Wrapf(err, "GetMetadata: failed to vaults.getDomains() of user with cID=%v", cID)
 }
 
 // VerifyMAC verifies client request by calculating MAC of the request and
 // comparign it with the one send by the client
-func (o *OnlineSphinx) VerifyMAC(mac []byte, ski *big.Int, data ...[]byte) error {
+func (o *OnlineSphinx) VerifyMAC(ctx context.Context, mac []byte, ski *big.Int, data ...[]byte) error {
 
 	vmac := crypto.HmacData(o.config.hash, ski.Bytes(), data...)
 
-	if bytes.Compare(mac, vmac) != 0 {
-		return errors.Wrapf(ErrMacMismatch, "VerifyMAC: given mac=%v and calculated mac=%v are different", mac, vmac)
+	if !hmac.Equal(mac, vmac) {
+		return errors.Wrap(ErrMacMismatch, "VerifyMAC: given and calculated mac are different")
 	}
 	return nil
 }
 
-// Add by generating random keys k, qj for specific 'domain'
-func (o *OnlineSphinx) Add(cID *big.Int, domain string) error {
+// Add by generating random keys k, qj for specific 'domain'.
+// metadata is opaque to the service and stored alongside, e.g. the password policy of the domain.
+func (o *OnlineSphinx) Add(ctx context.Context, cID *big.Int, domain, metadata string) error {
+	if len(metadata) > MaxMetadataLength {
+		return errors.Wrapf(ErrMetadataTooLong, "Add: %d bytes", len(metadata))
+	}
 
-	k, err := rand.Int(rand.Reader, o.config.max)
+	k, err := o.config.group.RandomScalar()
 	if err != nil {
 		return errors.Wrap(err, "Add: failed to generate random int k")
 	}
 
-	qj, err := rand.Int(rand.Reader, o.config.max)
+	qj, err := o.config.group.RandomElement()
 	if err != nil {
 		return errors.Wrap(err, "Add: failed to generate random int qj")
 	}
 
-	u, err := o.users.Get(cID)
+	_, err = o.users.Get(ctx, cID)
 	if err != nil {
 		return errors.Wrapf(err, "Add: failed to users.get() user with cID=%v", cID)
 	}
-	u.vaults[domain] = Vault{
-		k:  k,
-		qj: qj,
+
+	return errors.Wrapf(
+		o.vaults.Add(ctx, cID, domain, Vault{
+			k:        k,
+			qj:       qj,
+			metadata: metadata,
+		}), "Add: failed to vaults.add() user with cID=%v and domain=%v", cID, domain)
+}
+
+// Get return bmk**bj, qj and metadata associated with domain.
+// If previous is set it uses the vault before the last rotation as long as its grace period lasts.
+func (o *OnlineSphinx) Get(ctx context.Context, cID *big.Int, domain string, bmk, q *big.Int, previous bool) (bj, qj *big.Int, metadata string, err error) {
+	err = o.verifyElement(q, bmk)
+	if err != nil {
+		return nil, nil, "", errors.Wrap(err, "Get: invalid bmk")
+	}
+
+	v, err := o.vaults.Get(ctx, cID, domain)
+	if err != nil {
+		return nil, nil, "", errors.Wrapf(err, "Get: failed to vaults.get() user with cID=%v and domain=%v", cID, domain)
+	}
+
+	if previous {
+		if v.previous == nil || !time.Now().Before(v.previousUntil) {
+			return nil, nil, "", errors.Wrapf(ErrNoPreviousVersion, "Get: domain=%v", domain)
+		}
+		v = Vault{k: v.previous.k, qj: v.previous.qj, metadata: v.metadata}
 	}
 
-	return errors.Wrapf(o.users.Set(u), "Add: failed to users.add() user with cID=%v and domain=%v", cID, domain)
+	return o.config.group.Exp(bmk, v.k), v.qj, v.metadata, nil
 }
 
-// Get return bmk**bj and qj associated with domain
-func (o *OnlineSphinx) Get(cID *big.Int, domain string, bmk, q *big.Int) (bj, qj *big.Int, err error) {
+// Rotate replaces the keys k, qj of domain by new random keys, which changes the derived password.
+// The previous keys stay retrievable until previousUntil, so that the user can still log in
+// with the old password to change it.
+func (o *OnlineSphinx) Rotate(ctx context.Context, cID *big.Int, domain string) (version int, previousUntil time.Time, err error) {
+	k, err := o.config.group.RandomScalar()
+	if err != nil {
+		return 0, time.Time{}, errors.Wrap(err, "Rotate: failed to generate random int k")
+	}
 
-	u, err := o.users.Get(cID)
+	qj, err := o.config.group.RandomElement()
 	if err != nil {
-		return nil, nil, errors.Wrapf(err, "Get: failed to users.get() user with cID=%v", cID)
+		return 0, time.Time{}, errors.Wrap(err, "Rotate: failed to generate random int qj")
 	}
 
-	v, ok := u.vaults[domain]
-	if !ok {
-		return nil, nil, errors.Wrapf(ErrDomainNotFound, "Get: failed to get user with cID=%v and domain=%v", cID, domain)
+	_, err = o.users.Get(ctx, cID)
+	if err != nil {
+		return 0, time.Time{}, errors.Wrapf(err, "Rotate: failed to users.get() user with cID=%v", cID)
 	}
 
-	return crypto.ExpInGroup(bmk, v.k, q), v.qj, nil
+	previousUntil = time.Now().Add(o.config.grace).Round(0)
+	err = o.vaults.Update(ctx, cID, domain, func(v Vault) (Vault, error) {
+		version = v.version + 1
+		return Vault{
+			k:             k,
+			qj:            qj,
+			metadata:      v.metadata,
+			version:       version,
+			previous:      &Vault{k: v.k, qj: v.qj, version: v.version},
+			previousUntil: previousUntil,
+		}, nil
+	})
+	if err != nil {
+		return 0, time.Time{}, errors.Wrapf(err, "Rotate: failed to vaults.update() user with cID=%v and domain=%v", cID, domain)
+	}
+	return version, previousUntil, nil
+}
+
+// Delete removes domain and its keys, including the previous keys of a rotation.
+// The derived passwords are lost, adding domain again derives new ones.
+func (o *OnlineSphinx) Delete(ctx context.Context, cID *big.Int, domain string) error {
+	_, err := o.users.Get(ctx, cID)
+	if err != nil {
+		return errors.Wrapf(err, "Delete: failed to users.get() user with cID=%v", cID)
+	}
+
+	return errors.Wrapf(o.vaults.Delete(ctx, cID, domain), "Delete: failed to vaults.delete() user with cID=%v and domain=%v", cID, domain)
+}
+
+// verifyElement rejects requests for another group than the configured one,
+// as well as values outside of the prime order subgroup e.g. to prevent small subgroup attacks.
+func (o *OnlineSphinx) verifyElement(q, x *big.Int) error {
+	if q == nil || q.Cmp(o.config.group.Order()) != 0 {
+		return errors.Wrapf(ErrGroupMismatch, "service uses group %s", o.config.group.Name())
+	}
+	if !o.config.group.IsElement(x) {
+		return ErrInvalidElement
+	}
+	return nil
 }
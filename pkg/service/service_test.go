@@ -1,24 +1,76 @@
 package service
 
 import (
+	"context"
 	"crypto/sha256"
+	"fmt"
 	"math/big"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/pkg/errors"
 
 	"github.com/LAtanassov/go-online-sphinx/pkg/crypto"
 )
 
+// testGroup is a tiny safe prime group with q = 1019 and p = 2039.
+var testGroup = mustNewGroup("test", big.NewInt(1019))
+
+// testVerifier is a valid verifier and ephemeral key within testGroup.
+var testVerifier = big.NewInt(4)
+
+func mustNewGroup(name string, q *big.Int) crypto.Group {
+	g, err := crypto.NewGroup(name, q)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+func newTestConfiguration(t *testing.T) Configuration {
+	cfg, err := NewConfiguration(big.NewInt(1), big.NewInt(13), big.NewInt(4), testGroup, sha256.New)
+	if err != nil {
+		t.Fatalf("NewConfiguration() error = %v", err)
+	}
+	return cfg
+}
+
+func TestNewConfiguration(t *testing.T) {
+	tests := []struct {
+		name    string
+		k       *big.Int
+		q0      *big.Int
+		wantErr bool
+	}{
+		{"should accept valid key", big.NewInt(13), big.NewInt(4), false},
+		{"should reject zero key", big.NewInt(0), big.NewInt(4), true},
+		{"should reject key not smaller than q", big.NewInt(1019), big.NewInt(4), true},
+		{"should reject q0 outside of group", big.NewInt(13), big.NewInt(2038), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewConfiguration(big.NewInt(1), tt.k, tt.q0, testGroup, sha256.New)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestOnlineSphinx_ExpK(t *testing.T) {
 	t.Run("should return error if user does not exist", func(t *testing.T) {
 		// given
 		r := New(
 			NewUserRepository(),
-			NewConfiguration(big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1), sha256.New),
+			NewVaultRepository(),
+			newTestConfiguration(t),
 		)
 
 		// when
-		_, _, _, _, _, _, err := r.ExpK(big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1))
+		_, _, _, _, _, _, _, err := r.ExpK(context.Background(), big.NewInt(1), big.NewInt(1), big.NewInt(4), testGroup.Order(), testVerifier)
 		// then
 		if err == nil {
 			t.Errorf("Service.ExpK() error = %v wantError = %v", err, ErrUserNotFound)
@@ -27,17 +79,17 @@ func TestOnlineSphinx_ExpK(t *testing.T) {
 
 	t.Run("should b ** k mod q ", func(t *testing.T) {
 		// given
-		config := NewConfiguration(big.NewInt(1), big.NewInt(13), big.NewInt(1), big.NewInt(1), sha256.New)
-		r := New(NewUserRepository(), config)
-		r.Register(one)
+		config := newTestConfiguration(t)
+		r := New(NewUserRepository(), NewVaultRepository(), config)
+		r.Register(context.Background(), one, testVerifier)
 		cID := one
 		cNonce := one
-		b := big.NewInt(23)
-		q := big.NewInt(31)
+		b := big.NewInt(23 * 23)
+		q := testGroup.Order()
 		want := crypto.ExpInGroup(b, config.k, q)
 
 		// when
-		_, _, _, bd, _, _, err := r.ExpK(cID, cNonce, b, q)
+		_, _, _, bd, _, _, _, err := r.ExpK(context.Background(), cID, cNonce, b, q, testVerifier)
 		if err != nil {
 			t.Errorf("Service.ExpK() error = %v", err)
 		}
@@ -46,21 +98,271 @@ func TestOnlineSphinx_ExpK(t *testing.T) {
 			t.Errorf("Service.ExpK() want = %v but got %v", want, bd)
 		}
 	})
+
+	t.Run("should b ** k on P-256", func(t *testing.T) {
+		// given
+		g, err := crypto.GroupByName(crypto.P256)
+		if err != nil {
+			t.Fatalf("GroupByName() error = %v", err)
+		}
+		k, _ := g.RandomScalar()
+		q0, _ := g.RandomElement()
+		config, err := NewConfiguration(one, k, q0, g, sha256.New)
+		if err != nil {
+			t.Fatalf("NewConfiguration() error = %v", err)
+		}
+		r := New(NewUserRepository(), NewVaultRepository(), config)
+		r.Register(context.Background(), one, g.Generator())
+		b, _ := g.RandomElement()
+
+		// when
+		_, _, _, bd, _, _, proof, err := r.ExpK(context.Background(), one, one, b, g.Order(), g.Generator())
+		if err != nil {
+			t.Errorf("Service.ExpK() error = %v", err)
+		}
+
+		if want := g.Exp(b, k); bd.Cmp(want) != 0 {
+			t.Errorf("Service.ExpK() want = %v but got %v", want, bd)
+		}
+		err = crypto.NewOPRF(g, crypto.ModeVOPRF).Verify(r.PublicKey(), []*big.Int{b}, []*big.Int{bd}, proof)
+		if err != nil {
+			t.Errorf("Service.ExpK() proof does not verify against the public key error = %v", err)
+		}
+	})
+
+	t.Run("should return ErrGroupMismatch if q differs from the service group", func(t *testing.T) {
+		// given
+		r := New(NewUserRepository(), NewVaultRepository(), newTestConfiguration(t))
+		r.Register(context.Background(), one, testVerifier)
+
+		// when
+		_, _, _, _, _, _, _, err := r.ExpK(context.Background(), one, one, big.NewInt(4), big.NewInt(11), testVerifier)
+		// then
+		if errors.Cause(err) != ErrGroupMismatch {
+			t.Errorf("Service.ExpK() error = %v wantError = %v", err, ErrGroupMismatch)
+		}
+	})
+
+	t.Run("should return ErrInvalidElement if b is not a group element", func(t *testing.T) {
+		tests := []struct {
+			name string
+			b    *big.Int
+		}{
+			{"zero", big.NewInt(0)},
+			{"one", big.NewInt(1)},
+			{"p - 1", big.NewInt(2038)},
+			{"p", big.NewInt(2039)},
+			{"non residue", big.NewInt(7)},
+		}
+		r := New(NewUserRepository(), NewVaultRepository(), newTestConfiguration(t))
+		r.Register(context.Background(), one, testVerifier)
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// when
+				_, _, _, _, _, _, _, err := r.ExpK(context.Background(), one, one, tt.b, testGroup.Order(), testVerifier)
+				// then
+				if errors.Cause(err) != ErrInvalidElement {
+					t.Errorf("Service.ExpK() error = %v wantError = %v", err, ErrInvalidElement)
+				}
+			})
+		}
+	})
+}
+
+func TestOnlineSphinx_Register(t *testing.T) {
+	t.Run("should evaluate the blinded password before the registration", func(t *testing.T) {
+		// given
+		config := newTestConfiguration(t)
+		r := New(NewUserRepository(), NewVaultRepository(), config)
+		b := big.NewInt(23 * 23)
+
+		// when
+		bd, q0, proof, err := r.RegisterExpK(context.Background(), b, testGroup.Order())
+
+		// then
+		if err != nil {
+			t.Fatalf("Service.RegisterExpK() error = %v", err)
+		}
+		if want := testGroup.Exp(b, config.k); bd.Cmp(want) != 0 || q0.Cmp(config.q0) != 0 {
+			t.Errorf("Service.RegisterExpK() = %v, %v, want %v, %v", bd, q0, want, config.q0)
+		}
+		err = crypto.NewOPRF(testGroup, crypto.ModeVOPRF).Verify(r.PublicKey(), []*big.Int{b}, []*big.Int{bd}, proof)
+		if err != nil {
+			t.Errorf("Service.RegisterExpK() proof does not verify against the public key error = %v", err)
+		}
+	})
+
+	t.Run("should reject a verifier outside of the group", func(t *testing.T) {
+		r := New(NewUserRepository(), NewVaultRepository(), newTestConfiguration(t))
+
+		err := r.Register(context.Background(), one, big.NewInt(7))
+
+		if errors.Cause(err) != ErrInvalidElement {
+			t.Errorf("Service.Register() error = %v wantError = %v", err, ErrInvalidElement)
+		}
+	})
+
+	t.Run("should return ErrUserAlreadyExists and keep the verifier of an existing user", func(t *testing.T) {
+		// given
+		users := NewUserRepository()
+		r := New(users, NewVaultRepository(), newTestConfiguration(t))
+		r.Register(context.Background(), one, testVerifier)
+
+		// when
+		err := r.Register(context.Background(), one, testGroup.Exp(testVerifier, two))
+
+		// then
+		if errors.Cause(err) != ErrUserAlreadyExists {
+			t.Errorf("Service.Register() error = %v wantError = %v", err, ErrUserAlreadyExists)
+		}
+		if u, _ := users.Get(context.Background(), one); u.verifier.Cmp(testVerifier) != 0 {
+			t.Errorf("Service.Register() verifier = %v, want %v", u.verifier, testVerifier)
+		}
+	})
+}
+
+func TestOnlineSphinx_Enroll(t *testing.T) {
+	g, err := crypto.GroupByName(crypto.P256)
+	if err != nil {
+		t.Fatalf("GroupByName() error = %v", err)
+	}
+	k, _ := g.RandomScalar()
+	q0, _ := g.RandomElement()
+	config, err := NewConfiguration(one, k, q0, g, sha256.New)
+	if err != nil {
+		t.Fatalf("NewConfiguration() error = %v", err)
+	}
+	ak, _ := g.RandomScalar()
+	verifier := crypto.Verifier(g, ak)
+	proof, err := crypto.ProvePossession(g, ak, one)
+	if err != nil {
+		t.Fatalf("ProvePossession() error = %v", err)
+	}
+	otherProof, _ := crypto.ProvePossession(g, ak, two)
+
+	tests := []struct {
+		name     string
+		users    []User
+		validFor time.Duration
+		code     string
+		proof    crypto.PossessionProof
+		wantErr  error
+	}{
+		{"should enroll the verifier with the issued code", []User{{cID: one}}, time.Hour, "", proof, nil},
+		{"should replace the verifier with the issued code", []User{{cID: one, verifier: g.Generator()}}, time.Hour, "", proof, nil},
+		{"should reject the proof of another client", []User{{cID: one}}, time.Hour, "", otherProof, crypto.ErrProofInvalid},
+		{"should reject an user without issued code", []User{{cID: one}}, 0, "c0de", proof, ErrEnrollmentInvalid},
+		{"should reject a wrong code", []User{{cID: one}}, time.Hour, "c0de", proof, ErrEnrollmentInvalid},
+		{"should reject an expired code", []User{{cID: one}}, -time.Second, "", proof, ErrEnrollmentInvalid},
+		{"should return ErrUserNotFound for unknown users", nil, 0, "c0de", proof, ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			users := NewUserRepository()
+			for _, u := range tt.users {
+				users.Set(context.Background(), u)
+			}
+			r := New(users, NewVaultRepository(), config)
+			code := tt.code
+			if tt.validFor != 0 {
+				issued, err := r.IssueEnrollment(context.Background(), one, tt.validFor)
+				if err != nil {
+					t.Fatalf("IssueEnrollment() error = %v", err)
+				}
+				if code == "" {
+					code = issued
+				}
+			}
+
+			// when
+			err := r.Enroll(context.Background(), one, verifier, code, tt.proof)
+
+			// then
+			if errors.Cause(err) != tt.wantErr {
+				t.Fatalf("Service.Enroll() error = %v wantError = %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if _, _, _, _, _, _, _, err := r.ExpK(context.Background(), one, two, g.Generator(), g.Order(), g.Generator()); err != nil {
+				t.Errorf("Service.ExpK() after Enroll() error = %v", err)
+			}
+			if err := r.Enroll(context.Background(), one, verifier, code, tt.proof); errors.Cause(err) != ErrEnrollmentInvalid {
+				t.Errorf("Service.Enroll() with used code error = %v, want %v", err, ErrEnrollmentInvalid)
+			}
+		})
+	}
+
+	t.Run("should not issue codes of unknown users", func(t *testing.T) {
+		r := New(NewUserRepository(), NewVaultRepository(), config)
+		if _, err := r.IssueEnrollment(context.Background(), one, time.Hour); errors.Cause(err) != ErrUserNotFound {
+			t.Errorf("IssueEnrollment() error = %v, want %v", err, ErrUserNotFound)
+		}
+	})
+}
+
+func TestOnlineSphinx_ExpK_SessionKey(t *testing.T) {
+	g, err := crypto.GroupByName(crypto.P256)
+	if err != nil {
+		t.Fatalf("GroupByName() error = %v", err)
+	}
+	k, _ := g.RandomScalar()
+	q0, _ := g.RandomElement()
+	config, err := NewConfiguration(one, k, q0, g, sha256.New)
+	if err != nil {
+		t.Fatalf("NewConfiguration() error = %v", err)
+	}
+	ak, _ := g.RandomScalar()
+
+	t.Run("should derive the session key of a client knowing the authentication key", func(t *testing.T) {
+		// given
+		r := New(NewUserRepository(), NewVaultRepository(), config)
+		r.Register(context.Background(), one, crypto.Verifier(g, ak))
+		b, _ := g.RandomElement()
+		x, _ := g.RandomScalar()
+		X := g.Exp(g.Generator(), x)
+
+		// when
+		ski, sID, sNonce, bd, _, y, _, err := r.ExpK(context.Background(), one, two, b, g.Order(), X)
+
+		// then
+		if err != nil {
+			t.Fatalf("Service.ExpK() error = %v", err)
+		}
+		tr := crypto.Transcript{CID: one, SID: sID, CNonce: two, SNonce: sNonce, B: b, BD: bd, X: X, Y: y}
+		if want := crypto.SessionKey(sha256.New, g, g.Exp(y, x), g.Exp(y, ak), tr); ski.Cmp(want) != 0 {
+			t.Errorf("Service.ExpK() ski = %v, want %v", ski, want)
+		}
+	})
+
+	t.Run("should return ErrUserNotFound for users registered without verifier", func(t *testing.T) {
+		users := NewUserRepository()
+		users.Set(context.Background(), User{cID: one})
+		r := New(users, NewVaultRepository(), config)
+
+		_, _, _, _, _, _, _, err := r.ExpK(context.Background(), one, one, g.Generator(), g.Order(), g.Generator())
+
+		if errors.Cause(err) != ErrUserNotFound {
+			t.Errorf("Service.ExpK() error = %v wantError = %v", err, ErrUserNotFound)
+		}
+	})
 }
 
 func TestOnlineSphinx_Challenge(t *testing.T) {
 	t.Run("should return g ** k mod q", func(t *testing.T) {
 		s := New(
 			NewUserRepository(),
-			NewConfiguration(big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1), sha256.New),
+			NewVaultRepository(),
+			newTestConfiguration(t),
 		)
-		g := big.NewInt(42)
+		g := big.NewInt(42 * 42)
 		ski := big.NewInt(24)
-		q := big.NewInt(52)
+		q := testGroup.Order()
 
 		want := crypto.ExpInGroup(g, ski, q)
 
-		got, err := s.Challenge(ski, g, q)
+		got, err := s.Challenge(context.Background(), ski, g, q)
 		if err != nil {
 			t.Errorf("Service.Challenge() error = %v wantError = %v", err, ErrUserNotFound)
 		}
@@ -75,13 +377,14 @@ func TestOnlineSphinx_GetMetadata(t *testing.T) {
 		// given
 		s := New(
 			NewUserRepository(),
-			NewConfiguration(big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1), sha256.New),
+			NewVaultRepository(),
+			newTestConfiguration(t),
 		)
 		cID := big.NewInt(1)
 
-		s.Register(cID)
+		s.Register(context.Background(), cID, testVerifier)
 		// when
-		domains, err := s.GetMetadata(cID)
+		domains, err := s.GetMetadata(context.Background(), cID)
 		if err != nil {
 			t.Errorf("Service.GetMetadata() error = %v", err)
 		}
@@ -95,15 +398,81 @@ func TestOnlineSphinx_AddVault(t *testing.T) {
 	t.Run("should add vault", func(t *testing.T) {
 		s := New(
 			NewUserRepository(),
-			NewConfiguration(big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1), sha256.New),
+			NewVaultRepository(),
+			newTestConfiguration(t),
+		)
+		cID := big.NewInt(1)
+
+		s.Register(context.Background(), cID, testVerifier)
+		err := s.Add(context.Background(), cID, "domain", "")
+		if err != nil {
+			t.Errorf("Service.AddVault() error = %v", err)
+		}
+	})
+
+	t.Run("should not override an existing vault", func(t *testing.T) {
+		s := New(
+			NewUserRepository(),
+			NewVaultRepository(),
+			newTestConfiguration(t),
 		)
 		cID := big.NewInt(1)
 
-		s.Register(cID)
-		err := s.Add(cID, "domain")
+		s.Register(context.Background(), cID, testVerifier)
+		err := s.Add(context.Background(), cID, "domain", "")
 		if err != nil {
 			t.Errorf("Service.AddVault() error = %v", err)
 		}
+		err = s.Add(context.Background(), cID, "domain", "")
+		if errors.Cause(err) != ErrDomainAlreadyExists {
+			t.Errorf("Service.AddVault() error = %v wantError = %v", err, ErrDomainAlreadyExists)
+		}
+	})
+
+	t.Run("should return ErrMetadataTooLong", func(t *testing.T) {
+		s := New(
+			NewUserRepository(),
+			NewVaultRepository(),
+			newTestConfiguration(t),
+		)
+		cID := big.NewInt(1)
+
+		s.Register(context.Background(), cID, testVerifier)
+		err := s.Add(context.Background(), cID, "domain", strings.Repeat("m", MaxMetadataLength+1))
+		if errors.Cause(err) != ErrMetadataTooLong {
+			t.Errorf("Service.AddVault() error = %v wantError = %v", err, ErrMetadataTooLong)
+		}
+	})
+
+	t.Run("should not lose domains added concurrently", func(t *testing.T) {
+		s := New(
+			NewUserRepository(),
+			NewVaultRepository(),
+			newTestConfiguration(t),
+		)
+		cID := big.NewInt(1)
+		n := 10
+
+		s.Register(context.Background(), cID, testVerifier)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if err := s.Add(context.Background(), cID, fmt.Sprintf("domain-%d", i), ""); err != nil {
+					t.Errorf("Service.AddVault() error = %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		domains, err := s.GetMetadata(context.Background(), cID)
+		if err != nil {
+			t.Errorf("Service.GetMetadata() error = %v", err)
+		}
+		if len(domains) != n {
+			t.Errorf("Service.GetMetadata() want %v domains but got %v", n, domains)
+		}
 	})
 }
 
@@ -112,17 +481,136 @@ func TestOnlineSphinx_GetVault(t *testing.T) {
 		// given
 		s := New(
 			NewUserRepository(),
-			NewConfiguration(big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1), sha256.New),
+			NewVaultRepository(),
+			newTestConfiguration(t),
 		)
 
 		cID := big.NewInt(1)
-		s.Register(cID)
-		err := s.Add(cID, "domain")
+		s.Register(context.Background(), cID, testVerifier)
+		err := s.Add(context.Background(), cID, "domain", "metadata")
 		// when
-		_, _, err = s.Get(cID, "domain", big.NewInt(1), big.NewInt(2))
+		_, _, metadata, err := s.Get(context.Background(), cID, "domain", big.NewInt(4), testGroup.Order(), false)
 		if err != nil {
 			t.Errorf("Service.AddVault() error = %v", err)
 		}
+		if metadata != "metadata" {
+			t.Errorf("Service.Get() metadata = %v, want %v", metadata, "metadata")
+		}
+	})
+}
+
+func TestOnlineSphinx_Rotate(t *testing.T) {
+	newService := func(t *testing.T, grace time.Duration) *OnlineSphinx {
+		s := New(
+			NewUserRepository(),
+			NewVaultRepository(),
+			newTestConfiguration(t).WithGracePeriod(grace),
+		)
+		s.Register(context.Background(), big.NewInt(1), testVerifier)
+		if err := s.Add(context.Background(), big.NewInt(1), "domain", "metadata"); err != nil {
+			t.Fatalf("Service.Add() error = %v", err)
+		}
+		return s
+	}
+	cID, bmk, q := big.NewInt(1), big.NewInt(4), testGroup.Order()
+
+	t.Run("should rotate and keep the previous version", func(t *testing.T) {
+		// given
+		s := newService(t, time.Hour)
+		before, qjBefore, _, err := s.Get(context.Background(), cID, "domain", bmk, q, false)
+		if err != nil {
+			t.Fatalf("Service.Get() error = %v", err)
+		}
+
+		// when
+		version, previousUntil, err := s.Rotate(context.Background(), cID, "domain")
+
+		// then
+		if err != nil {
+			t.Fatalf("Service.Rotate() error = %v", err)
+		}
+		if version != 1 || !previousUntil.After(time.Now()) {
+			t.Errorf("Service.Rotate() = %v, %v", version, previousUntil)
+		}
+		previous, qjPrevious, metadata, err := s.Get(context.Background(), cID, "domain", bmk, q, true)
+		if err != nil {
+			t.Fatalf("Service.Get() error = %v", err)
+		}
+		if previous.Cmp(before) != 0 || qjPrevious.Cmp(qjBefore) != 0 || metadata != "metadata" {
+			t.Errorf("Service.Get() previous = %v, %v, %v", previous, qjPrevious, metadata)
+		}
+		version, _, err = s.Rotate(context.Background(), cID, "domain")
+		if err != nil || version != 2 {
+			t.Errorf("Service.Rotate() = %v, %v", version, err)
+		}
+	})
+
+	t.Run("should return ErrNoPreviousVersion after the grace period", func(t *testing.T) {
+		s := newService(t, 0)
+		_, _, err := s.Rotate(context.Background(), cID, "domain")
+		if err != nil {
+			t.Fatalf("Service.Rotate() error = %v", err)
+		}
+
+		_, _, _, err = s.Get(context.Background(), cID, "domain", bmk, q, true)
+		if errors.Cause(err) != ErrNoPreviousVersion {
+			t.Errorf("Service.Get() error = %v wantError = %v", err, ErrNoPreviousVersion)
+		}
+	})
+
+	t.Run("should return ErrNoPreviousVersion for domains never rotated", func(t *testing.T) {
+		s := newService(t, time.Hour)
+		_, _, _, err := s.Get(context.Background(), cID, "domain", bmk, q, true)
+		if errors.Cause(err) != ErrNoPreviousVersion {
+			t.Errorf("Service.Get() error = %v wantError = %v", err, ErrNoPreviousVersion)
+		}
+	})
+
+	t.Run("should return ErrDomainNotFound", func(t *testing.T) {
+		s := newService(t, time.Hour)
+		_, _, err := s.Rotate(context.Background(), cID, "unknown")
+		if errors.Cause(err) != ErrDomainNotFound {
+			t.Errorf("Service.Rotate() error = %v wantError = %v", err, ErrDomainNotFound)
+		}
+	})
+}
+
+func TestOnlineSphinx_Delete(t *testing.T) {
+	cID := big.NewInt(1)
+	s := New(
+		NewUserRepository(),
+		NewVaultRepository(),
+		newTestConfiguration(t),
+	)
+	s.Register(context.Background(), cID, testVerifier)
+	if err := s.Add(context.Background(), cID, "domain", "metadata"); err != nil {
+		t.Fatalf("Service.Add() error = %v", err)
+	}
+
+	t.Run("should delete the domain", func(t *testing.T) {
+		err := s.Delete(context.Background(), cID, "domain")
+		if err != nil {
+			t.Fatalf("Service.Delete() error = %v", err)
+		}
+
+		domains, err := s.GetMetadata(context.Background(), cID)
+		if err != nil || len(domains) != 0 {
+			t.Errorf("Service.GetMetadata() = %v, %v, want no domains", domains, err)
+		}
+	})
+
+	t.Run("should return ErrDomainNotFound", func(t *testing.T) {
+		err := s.Delete(context.Background(), cID, "unknown")
+		if errors.Cause(err) != ErrDomainNotFound {
+			t.Errorf("Service.Delete() error = %v wantError = %v", err, ErrDomainNotFound)
+		}
+	})
+
+	t.Run("should return ErrUserNotFound", func(t *testing.T) {
+		err := s.Delete(context.Background(), big.NewInt(2), "domain")
+		if errors.Cause(err) != ErrUserNotFound {
+			t.Errorf("Service.Delete() error = %v wantError = %v", err, ErrUserNotFound)
+		}
 	})
 }
 
@@ -130,12 +618,13 @@ func TestOnlineSphinx_VerifyMAC(t *testing.T) {
 	t.Run("should verify MAC", func(t *testing.T) {
 		s := New(
 			NewUserRepository(),
-			NewConfiguration(big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1), sha256.New),
+			NewVaultRepository(),
+			newTestConfiguration(t),
 		)
 
 		ski := big.NewInt(31)
 		want := crypto.HmacData(sha256.New, ski.Bytes(), []byte("data"))
-		err := s.VerifyMAC(want, ski, []byte("data"))
+		err := s.VerifyMAC(context.Background(), want, ski, []byte("data"))
 
 		if err != nil {
 			t.Errorf("Service.AddVault() error = %v", err)
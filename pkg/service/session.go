@@ -0,0 +1,331 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"math/big"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/pkg/errors"
+)
+
+// sessionName is the name of the session cookie and of the signed session tokens.
+const sessionName = "online-sphinx"
+
+// MinSessionKeyLength is the minimum length of session signing keys in bytes.
+const MinSessionKeyLength = 32
+
+const (
+	// DefaultSessionIdleTimeout ends sessions without requests.
+	DefaultSessionIdleTimeout = 15 * time.Minute
+	// DefaultSessionLifetime ends all sessions regardless of their activity.
+	DefaultSessionLifetime = 12 * time.Hour
+	// DefaultLoginTimeout ends sessions whose challenge does not follow ExpK in time.
+	DefaultLoginTimeout = time.Minute
+	// DefaultSessionPruneInterval is the interval of deleting ended sessions from the store.
+	DefaultSessionPruneInterval = 5 * time.Minute
+)
+
+var (
+	// ErrSessionNotFound is returned for unknown, expired and revoked sessions
+	ErrSessionNotFound = errors.New("session store: session not found")
+	// ErrWeakSessionKey is returned for session signing keys shorter than MinSessionKeyLength
+	ErrWeakSessionKey = errors.New("session store: weak session key")
+	// ErrLoginOutOfOrder is returned for login steps and operations the session has not reached or already passed
+	ErrLoginOutOfOrder = errors.New("login step out of order")
+	// ErrLoginTimeout is returned for challenges after the login window of the session closed
+	ErrLoginTimeout = errors.New("login timed out")
+)
+
+// SessionState is the progress of the login of a session.
+// ExpK creates sessions in StateExpKIssued, the verified challenge of the client
+// moves them to StateChallengeConfirmed, which is required by all operations.
+type SessionState string
+
+const (
+	// StateExpKIssued sessions wait for the challenge of the client.
+	StateExpKIssued SessionState = "expk_issued"
+	// StateChallengeConfirmed sessions completed the login.
+	StateChallengeConfirmed SessionState = "challenge_confirmed"
+)
+
+// Session is the server-side state of a login, clients only hold a signed token of its ID.
+// Counter is the highest counter of the authenticated requests accepted within the session.
+type Session struct {
+	ID       string
+	CID      *big.Int
+	SID      *big.Int
+	SKi      *big.Int
+	State    SessionState
+	Created  time.Time
+	LastSeen time.Time
+	Counter  uint64
+}
+
+// SessionStore represents a store for sessions keyed by their ID.
+// It has to be shared by all instances of the service.
+type SessionStore interface {
+	Set(ctx context.Context, s Session) error
+	// Update atomically replaces the session with id by the result of update, otherwise returns ErrSessionNotFound.
+	// Errors of update abort the update and are returned.
+	Update(ctx context.Context, id string, update func(Session) (Session, error)) error
+	Get(ctx context.Context, id string) (Session, error)
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, cID *big.Int) ([]Session, error)
+	// Prune deletes all sessions for which ended returns true.
+	Prune(ctx context.Context, ended func(Session) bool) error
+}
+
+// SessionConfig contains the signing keys and the expiry of sessions.
+// Keys are rotated by prepending a new key, the first key signs new tokens
+// and all keys verify tokens, so that existing sessions survive the rotation.
+type SessionConfig struct {
+	Keys         [][]byte
+	IdleTimeout  time.Duration
+	Lifetime     time.Duration
+	LoginTimeout time.Duration
+}
+
+// SessionManager creates, loads and revokes the sessions of HTTPTransport and GRPCTransport
+// and rejects replayed requests within them.
+type SessionManager struct {
+	store        SessionStore
+	codecs       []securecookie.Codec
+	idleTimeout  time.Duration
+	lifetime     time.Duration
+	loginTimeout time.Duration
+	now          func() time.Time
+}
+
+// NewSessionManager returns a SessionManager keeping sessions in store, unset timeouts
+// of cfg default to DefaultSessionIdleTimeout, DefaultSessionLifetime and DefaultLoginTimeout.
+func NewSessionManager(store SessionStore, cfg SessionConfig) (*SessionManager, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, errors.Wrap(ErrWeakSessionKey, "NewSessionManager: no session key")
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = DefaultSessionIdleTimeout
+	}
+	if cfg.Lifetime <= 0 {
+		cfg.Lifetime = DefaultSessionLifetime
+	}
+	if cfg.LoginTimeout <= 0 {
+		cfg.LoginTimeout = DefaultLoginTimeout
+	}
+
+	var codecs []securecookie.Codec
+	for i, key := range cfg.Keys {
+		if len(key) < MinSessionKeyLength {
+			return nil, errors.Wrapf(ErrWeakSessionKey, "NewSessionManager: key %d has %d bytes, want at least %d", i, len(key), MinSessionKeyLength)
+		}
+		codec := securecookie.New(key, nil)
+		codec.MaxAge(int(cfg.Lifetime / time.Second))
+		codecs = append(codecs, codec)
+	}
+
+	return &SessionManager{
+		store:        store,
+		codecs:       codecs,
+		idleTimeout:  cfg.IdleTimeout,
+		lifetime:     cfg.Lifetime,
+		loginTimeout: cfg.LoginTimeout,
+		now:          time.Now,
+	}, nil
+}
+
+// GenerateSessionKey returns a random session signing key, e.g. for services without configured keys.
+func GenerateSessionKey() []byte {
+	return securecookie.GenerateRandomKey(MinSessionKeyLength)
+}
+
+// Create stores a new session in StateExpKIssued with an opaque random ID and returns its signed token.
+func (m *SessionManager) Create(ctx context.Context, cID, sID, ski *big.Int) (string, error) {
+	id := make([]byte, 32)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", errors.Wrap(err, "Create: failed to generate session ID")
+	}
+
+	now := m.now()
+	s := Session{
+		ID:       base64.RawURLEncoding.EncodeToString(id),
+		CID:      cID,
+		SID:      sID,
+		SKi:      ski,
+		State:    StateExpKIssued,
+		Created:  now,
+		LastSeen: now,
+	}
+	err = m.store.Set(ctx, s)
+	if err != nil {
+		return "", errors.Wrap(err, "Create: failed to store session")
+	}
+
+	token, err := securecookie.EncodeMulti(sessionName, s.ID, m.codecs...)
+	if err != nil {
+		return "", errors.Wrap(err, "Create: failed to sign session")
+	}
+	return token, nil
+}
+
+// Load returns the session of token in state and marks it as seen.
+// Tokens with an invalid signature and expired sessions return ErrSessionNotFound,
+// sessions in another state ErrLoginOutOfOrder and sessions whose login window closed ErrLoginTimeout.
+func (m *SessionManager) Load(ctx context.Context, token string, state SessionState) (Session, error) {
+	var id string
+	err := securecookie.DecodeMulti(sessionName, token, &id, m.codecs...)
+	if err != nil {
+		return Session{}, errors.Wrap(ErrSessionNotFound, err.Error())
+	}
+
+	s, err := m.store.Get(ctx, id)
+	if err != nil {
+		return Session{}, errors.Wrap(err, "Load: failed to get session")
+	}
+
+	now := m.now()
+	if m.expired(s, now) {
+		m.store.Delete(ctx, id)
+		return Session{}, errors.Wrap(ErrSessionNotFound, "Load: session expired")
+	}
+	if m.loginExpired(s, now) {
+		m.store.Delete(ctx, id)
+		return Session{}, errors.Wrapf(ErrLoginTimeout, "Load: no challenge within %v", m.loginTimeout)
+	}
+	if s.State != state {
+		return Session{}, errors.Wrapf(ErrLoginOutOfOrder, "Load: session is %s, want %s", s.State, state)
+	}
+
+	err = m.store.Update(ctx, id, func(current Session) (Session, error) {
+		current.LastSeen = now
+		s = current
+		return current, nil
+	})
+	if err != nil {
+		return Session{}, errors.Wrap(err, "Load: failed to update session")
+	}
+	return s, nil
+}
+
+// Revoke ends the session of token, unknown sessions are ignored.
+func (m *SessionManager) Revoke(ctx context.Context, token string) error {
+	var id string
+	err := securecookie.DecodeMulti(sessionName, token, &id, m.codecs...)
+	if err != nil {
+		return nil
+	}
+	return m.store.Delete(ctx, id)
+}
+
+// RevokeByID ends the session with id of the user with cID,
+// unknown sessions and sessions of other users are ignored.
+func (m *SessionManager) RevokeByID(ctx context.Context, cID *big.Int, id string) error {
+	s, err := m.store.Get(ctx, id)
+	if errors.Cause(err) == ErrSessionNotFound {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "RevokeByID: failed to get session")
+	}
+	if s.CID.Cmp(cID) != 0 {
+		return nil
+	}
+	return errors.Wrap(m.store.Delete(ctx, id), "RevokeByID: failed to delete session")
+}
+
+// RevokeAll ends all sessions of the user with cID, e.g. after a credential change.
+func (m *SessionManager) RevokeAll(ctx context.Context, cID *big.Int) error {
+	sessions, err := m.store.List(ctx, cID)
+	if err != nil {
+		return errors.Wrap(err, "RevokeAll: failed to list sessions")
+	}
+	for _, s := range sessions {
+		err := m.store.Delete(ctx, s.ID)
+		if err != nil {
+			return errors.Wrap(err, "RevokeAll: failed to delete session")
+		}
+	}
+	return nil
+}
+
+// List returns the active sessions of the user with cID, expired sessions are removed.
+func (m *SessionManager) List(ctx context.Context, cID *big.Int) ([]Session, error) {
+	sessions, err := m.store.List(ctx, cID)
+	if err != nil {
+		return nil, errors.Wrap(err, "List: failed to list sessions")
+	}
+
+	now := m.now()
+	active := sessions[:0]
+	for _, s := range sessions {
+		if m.expired(s, now) {
+			m.store.Delete(ctx, s.ID)
+			continue
+		}
+		active = append(active, s)
+	}
+	return active, nil
+}
+
+// confirm moves s to StateChallengeConfirmed after the challenge of the client has been verified,
+// unless another request confirmed it before.
+func (m *SessionManager) confirm(ctx context.Context, s Session) error {
+	err := m.store.Update(ctx, s.ID, func(current Session) (Session, error) {
+		if current.State != StateExpKIssued {
+			return current, errors.Wrapf(ErrLoginOutOfOrder, "session is %s, want %s", current.State, StateExpKIssued)
+		}
+		current.State = StateChallengeConfirmed
+		return current, nil
+	})
+	return errors.Wrap(err, "confirm: failed to update session")
+}
+
+// abort ends s after a failed login step, so that it cannot be retried.
+func (m *SessionManager) abort(ctx context.Context, s Session) error {
+	return errors.Wrap(m.store.Delete(ctx, s.ID), "abort: failed to delete session")
+}
+
+// checkReplay returns ErrReplayedRequest unless counter and timestamp are fresh within s.
+func (m *SessionManager) checkReplay(ctx context.Context, s Session, counter uint64, timestamp time.Time) error {
+	return checkReplay(ctx, m.store, s.ID, counter, timestamp, m.now())
+}
+
+// Prune deletes the expired sessions and the sessions whose login window closed of all users,
+// which are otherwise only deleted when their client returns.
+func (m *SessionManager) Prune(ctx context.Context) error {
+	now := m.now()
+	err := m.store.Prune(ctx, func(s Session) bool {
+		return m.expired(s, now) || m.loginExpired(s, now)
+	})
+	return errors.Wrap(err, "Prune: failed to prune sessions")
+}
+
+// PruneEvery prunes sessions every interval, DefaultSessionPruneInterval if unset,
+// until ctx is done and reports errors to onErr.
+func (m *SessionManager) PruneEvery(ctx context.Context, interval time.Duration, onErr func(error)) {
+	if interval <= 0 {
+		interval = DefaultSessionPruneInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Prune(ctx); err != nil {
+				onErr(err)
+			}
+		}
+	}
+}
+
+func (m *SessionManager) expired(s Session, now time.Time) bool {
+	return now.Sub(s.LastSeen) > m.idleTimeout || now.Sub(s.Created) > m.lifetime
+}
+
+func (m *SessionManager) loginExpired(s Session, now time.Time) bool {
+	return s.State == StateExpKIssued && now.Sub(s.Created) > m.loginTimeout
+}
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func newTestSessionManager(t *testing.T) *SessionManager {
+	sm, err := NewSessionManager(NewSessionStore(), SessionConfig{Keys: [][]byte{GenerateSessionKey()}})
+	if err != nil {
+		t.Fatalf("NewSessionManager() error = %v", err)
+	}
+	return sm
+}
+
+// newSessionManagerAt returns a SessionManager of store signing with keys, whose clock stands still at now.
+func newSessionManagerAt(t *testing.T, now time.Time, store SessionStore, keys ...[]byte) *SessionManager {
+	sm, err := NewSessionManager(store, SessionConfig{Keys: keys, IdleTimeout: time.Minute, Lifetime: time.Hour})
+	if err != nil {
+		t.Fatalf("NewSessionManager() error = %v", err)
+	}
+	sm.now = func() time.Time { return now }
+	return sm
+}
+
+func TestNewSessionManager(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    [][]byte
+		wantErr error
+	}{
+		{"should accept a key", [][]byte{GenerateSessionKey()}, nil},
+		{"should accept rotated keys", [][]byte{GenerateSessionKey(), GenerateSessionKey()}, nil},
+		{"should reject no key", nil, ErrWeakSessionKey},
+		{"should reject a short key", [][]byte{GenerateSessionKey(), []byte("super-secret-key")}, ErrWeakSessionKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSessionManager(NewSessionStore(), SessionConfig{Keys: tt.keys})
+			if errors.Cause(err) != tt.wantErr {
+				t.Errorf("NewSessionManager() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSessionManager(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 10, 16, 12, 0, 0, 0, time.UTC)
+	cID, sID, ski := big.NewInt(1), big.NewInt(2), big.NewInt(3)
+	key := GenerateSessionKey()
+
+	t.Run("should load a created session", func(t *testing.T) {
+		// given
+		sm := newSessionManagerAt(t, now, NewSessionStore(), key)
+		token, err := sm.Create(ctx, cID, sID, ski)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		// when
+		s, err := sm.Load(ctx, token, StateExpKIssued)
+		// then
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if s.CID.Cmp(cID) != 0 || s.SID.Cmp(sID) != 0 || s.SKi.Cmp(ski) != 0 {
+			t.Errorf("Load() = %v, want cID %v sID %v SKi %v", s, cID, sID, ski)
+		}
+		if bytes.Contains([]byte(token), []byte(s.ID)) {
+			t.Errorf("Create() token %v contains the session ID in clear", token)
+		}
+	})
+
+	t.Run("should create opaque session IDs", func(t *testing.T) {
+		store := NewSessionStore()
+		sm := newSessionManagerAt(t, now, store, key)
+		sm.Create(ctx, cID, sID, ski)
+		sm.Create(ctx, cID, sID, ski)
+
+		sessions, _ := store.List(ctx, cID)
+		if len(sessions) != 2 || sessions[0].ID == sessions[1].ID || len(sessions[0].ID) < 32 {
+			t.Errorf("Create() sessions = %v, want two distinct random IDs", sessions)
+		}
+	})
+
+	t.Run("should reject a forged token", func(t *testing.T) {
+		sm := newSessionManagerAt(t, now, NewSessionStore(), key)
+		token, _ := newSessionManagerAt(t, now, NewSessionStore(), GenerateSessionKey()).Create(ctx, cID, sID, ski)
+
+		_, err := sm.Load(ctx, token, StateExpKIssued)
+		if errors.Cause(err) != ErrSessionNotFound {
+			t.Errorf("Load() error = %v, want %v", err, ErrSessionNotFound)
+		}
+	})
+
+	t.Run("should load sessions signed by a rotated key", func(t *testing.T) {
+		store := NewSessionStore()
+		token, _ := newSessionManagerAt(t, now, store, key).Create(ctx, cID, sID, ski)
+
+		_, err := newSessionManagerAt(t, now, store, GenerateSessionKey(), key).Load(ctx, token, StateExpKIssued)
+		if err != nil {
+			t.Errorf("Load() error = %v", err)
+		}
+	})
+}
+
+func TestSessionManager_Expiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 10, 16, 12, 0, 0, 0, time.UTC)
+	cID, sID, ski := big.NewInt(1), big.NewInt(2), big.NewInt(3)
+	key := GenerateSessionKey()
+
+	t.Run("should expire idle sessions", func(t *testing.T) {
+		sm := newSessionManagerAt(t, now, NewSessionStore(), key)
+		token, _ := sm.Create(ctx, cID, sID, ski)
+
+		sm.now = func() time.Time { return now.Add(2 * time.Minute) }
+		_, err := sm.Load(ctx, token, StateExpKIssued)
+		if errors.Cause(err) != ErrSessionNotFound {
+			t.Errorf("Load() error = %v, want %v", err, ErrSessionNotFound)
+		}
+	})
+
+	t.Run("should expire sessions after their lifetime", func(t *testing.T) {
+		sm := newSessionManagerAt(t, now, NewSessionStore(), key)
+		token, _ := sm.Create(ctx, cID, sID, ski)
+		s, _ := sm.Load(ctx, token, StateExpKIssued)
+		sm.confirm(ctx, s)
+		for d := 30 * time.Second; d <= time.Hour; d += 30 * time.Second {
+			sm.now = func() time.Time { return now.Add(d) }
+			if _, err := sm.Load(ctx, token, StateChallengeConfirmed); err != nil {
+				t.Fatalf("Load() after %v error = %v", d, err)
+			}
+		}
+
+		sm.now = func() time.Time { return now.Add(time.Hour + 30*time.Second) }
+		_, err := sm.Load(ctx, token, StateChallengeConfirmed)
+		if errors.Cause(err) != ErrSessionNotFound {
+			t.Errorf("Load() error = %v, want %v", err, ErrSessionNotFound)
+		}
+	})
+}
+
+func TestSessionManager_Login(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 10, 16, 12, 0, 0, 0, time.UTC)
+	cID, sID, ski := big.NewInt(1), big.NewInt(2), big.NewInt(3)
+	key := GenerateSessionKey()
+
+	t.Run("should enforce the order of login steps", func(t *testing.T) {
+		// given
+		sm := newSessionManagerAt(t, now, NewSessionStore(), key)
+		token, _ := sm.Create(ctx, cID, sID, ski)
+
+		// when operation before challenge
+		_, err := sm.Load(ctx, token, StateChallengeConfirmed)
+		// then
+		if errors.Cause(err) != ErrLoginOutOfOrder {
+			t.Errorf("Load() error = %v, want %v", err, ErrLoginOutOfOrder)
+		}
+
+		// when challenge after challenge
+		s, _ := sm.Load(ctx, token, StateExpKIssued)
+		sm.confirm(ctx, s)
+		_, err = sm.Load(ctx, token, StateExpKIssued)
+		// then
+		if errors.Cause(err) != ErrLoginOutOfOrder {
+			t.Errorf("Load() error = %v, want %v", err, ErrLoginOutOfOrder)
+		}
+		if err := sm.confirm(ctx, s); errors.Cause(err) != ErrLoginOutOfOrder {
+			t.Errorf("confirm() error = %v, want %v", err, ErrLoginOutOfOrder)
+		}
+		if _, err := sm.Load(ctx, token, StateChallengeConfirmed); err != nil {
+			t.Errorf("Load() error = %v", err)
+		}
+	})
+
+	t.Run("should end logins without challenge in time", func(t *testing.T) {
+		sm := newSessionManagerAt(t, now, NewSessionStore(), key)
+		sm.loginTimeout = 30 * time.Second
+		token, _ := sm.Create(ctx, cID, sID, ski)
+
+		sm.now = func() time.Time { return now.Add(45 * time.Second) }
+		_, err := sm.Load(ctx, token, StateExpKIssued)
+		if errors.Cause(err) != ErrLoginTimeout {
+			t.Errorf("Load() error = %v, want %v", err, ErrLoginTimeout)
+		}
+		_, err = sm.Load(ctx, token, StateExpKIssued)
+		if errors.Cause(err) != ErrSessionNotFound {
+			t.Errorf("Load() error = %v, want %v", err, ErrSessionNotFound)
+		}
+	})
+
+	t.Run("should end a session aborted by a failed login step", func(t *testing.T) {
+		sm := newSessionManagerAt(t, now, NewSessionStore(), key)
+		token, _ := sm.Create(ctx, cID, sID, ski)
+		s, _ := sm.Load(ctx, token, StateExpKIssued)
+
+		sm.abort(ctx, s)
+		_, err := sm.Load(ctx, token, StateExpKIssued)
+		if errors.Cause(err) != ErrSessionNotFound {
+			t.Errorf("Load() error = %v, want %v", err, ErrSessionNotFound)
+		}
+	})
+}
+
+func TestSessionManager_Revoke(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 10, 16, 12, 0, 0, 0, time.UTC)
+	cID, sID, ski := big.NewInt(1), big.NewInt(2), big.NewInt(3)
+	key := GenerateSessionKey()
+
+	t.Run("should revoke a session", func(t *testing.T) {
+		sm := newSessionManagerAt(t, now, NewSessionStore(), key)
+		token, _ := sm.Create(ctx, cID, sID, ski)
+
+		err := sm.Revoke(ctx, token)
+		if err != nil {
+			t.Fatalf("Revoke() error = %v", err)
+		}
+		_, err = sm.Load(ctx, token, StateExpKIssued)
+		if errors.Cause(err) != ErrSessionNotFound {
+			t.Errorf("Load() error = %v, want %v", err, ErrSessionNotFound)
+		}
+	})
+
+	t.Run("should revoke a session by ID only for its user", func(t *testing.T) {
+		sm := newSessionManagerAt(t, now, NewSessionStore(), key)
+		token, _ := sm.Create(ctx, cID, sID, ski)
+		s, _ := sm.Load(ctx, token, StateExpKIssued)
+
+		err := sm.RevokeByID(ctx, big.NewInt(4), s.ID)
+		if err != nil {
+			t.Fatalf("RevokeByID() of another user error = %v", err)
+		}
+		if _, err := sm.Load(ctx, token, StateExpKIssued); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+
+		err = sm.RevokeByID(ctx, cID, s.ID)
+		if err != nil {
+			t.Fatalf("RevokeByID() error = %v", err)
+		}
+		if _, err := sm.Load(ctx, token, StateExpKIssued); errors.Cause(err) != ErrSessionNotFound {
+			t.Errorf("Load() error = %v, want %v", err, ErrSessionNotFound)
+		}
+		if err := sm.RevokeByID(ctx, cID, "unknown"); err != nil {
+			t.Errorf("RevokeByID() of unknown session error = %v", err)
+		}
+	})
+
+	t.Run("should list and revoke all active sessions of an user", func(t *testing.T) {
+		sm := newSessionManagerAt(t, now, NewSessionStore(), key)
+		sm.Create(ctx, cID, sID, ski)
+		sm.now = func() time.Time { return now.Add(2 * time.Minute) }
+		token, _ := sm.Create(ctx, cID, sID, ski)
+		sm.Create(ctx, big.NewInt(4), sID, ski)
+
+		sessions, err := sm.List(ctx, cID)
+		if err != nil || len(sessions) != 1 {
+			t.Errorf("List() = %v, %v, want the active session", sessions, err)
+		}
+
+		err = sm.RevokeAll(ctx, cID)
+		if err != nil {
+			t.Fatalf("RevokeAll() error = %v", err)
+		}
+		if _, err := sm.Load(ctx, token, StateExpKIssued); errors.Cause(err) != ErrSessionNotFound {
+			t.Errorf("Load() error = %v, want %v", err, ErrSessionNotFound)
+		}
+	})
+}
+
+func TestSessionManager_Prune(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 10, 16, 12, 0, 0, 0, time.UTC)
+	cID, sID, ski := big.NewInt(1), big.NewInt(2), big.NewInt(3)
+	key := GenerateSessionKey()
+
+	t.Run("should prune expired sessions and closed login windows", func(t *testing.T) {
+		// given
+		store := NewSessionStore()
+		sm := newSessionManagerAt(t, now, store, key)
+		sm.loginTimeout = 30 * time.Second
+		idle, _ := sm.Create(ctx, cID, sID, ski)
+		unconfirmed, _ := sm.Create(ctx, cID, sID, ski)
+		sm.now = func() time.Time { return now.Add(50 * time.Second) }
+		active, _ := sm.Create(ctx, cID, sID, ski)
+		s, _ := sm.Load(ctx, active, StateExpKIssued)
+		sm.confirm(ctx, s)
+		sm.now = func() time.Time { return now.Add(90 * time.Second) }
+		sm.Load(ctx, active, StateChallengeConfirmed)
+		sm.now = func() time.Time { return now.Add(2 * time.Minute) }
+
+		// when
+		err := sm.Prune(ctx)
+
+		// then
+		if err != nil {
+			t.Fatalf("Prune() error = %v", err)
+		}
+		sessions, _ := store.List(ctx, cID)
+		if len(sessions) != 1 || sessions[0].ID != s.ID {
+			t.Errorf("List() = %v, want only session %v", sessions, s.ID)
+		}
+		for _, token := range []string{idle, unconfirmed} {
+			if _, err := sm.Load(ctx, token, StateExpKIssued); errors.Cause(err) != ErrSessionNotFound {
+				t.Errorf("Load() error = %v, want %v", err, ErrSessionNotFound)
+			}
+		}
+	})
+
+	t.Run("should prune until the context is done", func(t *testing.T) {
+		store := NewSessionStore()
+		sm := newSessionManagerAt(t, now, store, key)
+		sm.Create(ctx, cID, sID, ski)
+		sm.now = func() time.Time { return now.Add(2 * time.Minute) }
+		ctx, cancel := context.WithCancel(ctx)
+
+		done := make(chan struct{})
+		go func() {
+			sm.PruneEvery(ctx, time.Millisecond, func(err error) { t.Errorf("PruneEvery() error = %v", err) })
+			close(done)
+		}()
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if sessions, _ := store.List(context.Background(), cID); len(sessions) == 0 {
+				break
+			}
+		}
+		cancel()
+		<-done
+
+		if sessions, _ := store.List(context.Background(), cID); len(sessions) != 0 {
+			t.Errorf("List() = %v, want no sessions", sessions)
+		}
+	})
+}
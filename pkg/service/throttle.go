@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/pkg/errors"
+)
+
+// TooManyAttemptsError is returned for logins of a client ID or from a source
+// which have to back off after too many attempts without a confirmed challenge.
+type TooManyAttemptsError struct {
+	// RetryAfter is the time until the next attempt is allowed.
+	RetryAfter time.Duration
+}
+
+// Error returns the message including the retry hint.
+func (e *TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("too many login attempts, retry after %v", e.RetryAfter)
+}
+
+// Attempts are the logins of a client ID or a source without a confirmed challenge.
+type Attempts struct {
+	Failures int
+	// Last is the time of the last attempt, Next the earliest time of the next attempt.
+	Last time.Time
+	Next time.Time
+}
+
+// ThrottleStore represents a store for the attempts of clients IDs and sources keyed by a string.
+// It has to be shared by all instances of the service, otherwise attackers spread their guesses.
+type ThrottleStore interface {
+	// Update atomically replaces the attempts of key, initially zero, by the result of update.
+	// Errors of update abort the update and are returned.
+	Update(ctx context.Context, key string, update func(Attempts) (Attempts, error)) error
+	// Delete the attempts of key, unknown keys are ignored.
+	Delete(ctx context.Context, key string) error
+	// Prune deletes all attempts whose last attempt was before t.
+	Prune(ctx context.Context, t time.Time) error
+}
+
+// ThrottlePolicy limits the logins of a client ID or a source. The first FreeAttempts
+// logins without a confirmed challenge are not delayed, further logins have to wait
+// BaseDelay doubled with every attempt up to MaxDelay. After LockoutAttempts logins
+// are locked for Lockout. Attempts are forgotten after ResetAfter without login.
+type ThrottlePolicy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAttempts int
+	Lockout         time.Duration
+	ResetAfter      time.Duration
+}
+
+// delay returns the time to wait after the given number of failures.
+func (p ThrottlePolicy) delay(failures int) time.Duration {
+	if p.LockoutAttempts > 0 && failures >= p.LockoutAttempts {
+		return p.Lockout
+	}
+	n := failures - p.FreeAttempts - 1
+	if n < 0 {
+		return 0
+	}
+	if n > 30 {
+		return p.MaxDelay
+	}
+	d := p.BaseDelay << uint(n)
+	if d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return d
+}
+
+// ThrottleConfig contains the policies of client IDs and of sources, e.g. IP addresses.
+// Sources are only reset after ResetAfter, because a source may guess the passwords
+// of several users, so their policy should allow more attempts than the one of clients.
+type ThrottleConfig struct {
+	Client ThrottlePolicy
+	Source ThrottlePolicy
+}
+
+// DefaultThrottleConfig returns the policies of ossvc.
+func DefaultThrottleConfig() ThrottleConfig {
+	return ThrottleConfig{
+		Client: ThrottlePolicy{
+			FreeAttempts:    5,
+			BaseDelay:       time.Second,
+			MaxDelay:        time.Minute,
+			LockoutAttempts: 20,
+			Lockout:         15 * time.Minute,
+			ResetAfter:      24 * time.Hour,
+		},
+		Source: ThrottlePolicy{
+			FreeAttempts:    50,
+			BaseDelay:       time.Second,
+			MaxDelay:        time.Minute,
+			LockoutAttempts: 200,
+			Lockout:         15 * time.Minute,
+			ResetAfter:      time.Hour,
+		},
+	}
+}
+
+// Throttle bounds online guessing of passwords by limiting the logins of HTTPTransport
+// and GRPCTransport per client ID and per source. Every ExpK is an attempt,
+// a confirmed challenge resets the attempts of the client ID and takes back
+// the attempt of the source.
+// A nil *Throttle allows all attempts.
+type Throttle struct {
+	store     ThrottleStore
+	config    ThrottleConfig
+	throttled metrics.Counter
+	now       func() time.Time
+
+	mu     sync.Mutex
+	pruned time.Time
+}
+
+// NewThrottle returns a Throttle keeping attempts in store, which counts throttled logins
+// with the label "key" being "client" or "source".
+func NewThrottle(store ThrottleStore, cfg ThrottleConfig, throttled metrics.Counter) *Throttle {
+	return &Throttle{
+		store:     store,
+		config:    cfg,
+		throttled: throttled,
+		now:       time.Now,
+	}
+}
+
+// Attempt records a login of cID from source or returns a *TooManyAttemptsError
+// if either has to back off. Throttled logins are not recorded, so the attempt
+// of source is taken back if cID has to back off.
+func (t *Throttle) Attempt(ctx context.Context, cID *big.Int, source string) error {
+	if t == nil {
+		return nil
+	}
+	now := t.now()
+	t.prune(ctx, now)
+
+	err := t.attempt(ctx, "source:"+source, t.config.Source, now)
+	if err != nil {
+		t.throttled.With("key", "source").Add(1)
+		return errors.Wrapf(err, "Attempt: source %s", source)
+	}
+	err = t.attempt(ctx, "client:"+cID.Text(16), t.config.Client, now)
+	if err != nil {
+		t.throttled.With("key", "client").Add(1)
+		if err := t.takeBack(ctx, "source:"+source, t.config.Source); err != nil {
+			return errors.Wrapf(err, "Attempt: failed to take back the attempt of source %s", source)
+		}
+		return errors.Wrapf(err, "Attempt: cID %v", cID)
+	}
+	return nil
+}
+
+// Succeeded resets the attempts of cID and takes back the attempt of source
+// after a confirmed challenge, so that sources are throttled by their unconfirmed logins.
+func (t *Throttle) Succeeded(ctx context.Context, cID *big.Int, source string) error {
+	if t == nil {
+		return nil
+	}
+	err := t.takeBack(ctx, "source:"+source, t.config.Source)
+	if err != nil {
+		return errors.Wrapf(err, "Succeeded: source %s", source)
+	}
+	return t.store.Delete(ctx, "client:"+cID.Text(16))
+}
+
+// takeBack removes the last attempt of key, whose login did not count.
+func (t *Throttle) takeBack(ctx context.Context, key string, p ThrottlePolicy) error {
+	return t.store.Update(ctx, key, func(a Attempts) (Attempts, error) {
+		if a.Failures > 0 {
+			a.Failures--
+			a.Next = a.Last.Add(p.delay(a.Failures))
+		}
+		return a, nil
+	})
+}
+
+func (t *Throttle) attempt(ctx context.Context, key string, p ThrottlePolicy, now time.Time) error {
+	return t.store.Update(ctx, key, func(a Attempts) (Attempts, error) {
+		if now.Sub(a.Last) > p.ResetAfter {
+			a = Attempts{}
+		}
+		if now.Before(a.Next) {
+			return a, &TooManyAttemptsError{RetryAfter: a.Next.Sub(now)}
+		}
+		a.Failures++
+		a.Last = now
+		a.Next = now.Add(p.delay(a.Failures))
+		return a, nil
+	})
+}
+
+// prune forgets attempts older than both reset periods at most once per hour.
+func (t *Throttle) prune(ctx context.Context, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.pruned) < time.Hour {
+		return
+	}
+	reset := t.config.Client.ResetAfter
+	if t.config.Source.ResetAfter > reset {
+		reset = t.config.Source.ResetAfter
+	}
+	if t.store.Prune(ctx, now.Add(-reset)) == nil {
+		t.pruned = now
+	}
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/pkg/errors"
+)
+
+// labelCounter counts per label values, e.g. counts["key=client"].
+type labelCounter struct {
+	lvs    []string
+	counts map[string]float64
+}
+
+func (c *labelCounter) With(lvs ...string) metrics.Counter {
+	return &labelCounter{lvs: append(c.lvs, lvs...), counts: c.counts}
+}
+
+func (c *labelCounter) Add(delta float64) {
+	c.counts[strings.Join(c.lvs, "=")] += delta
+}
+
+// newThrottleAt returns a Throttle of cfg, whose clock stands at now, and the counts of throttled logins.
+func newThrottleAt(now time.Time, cfg ThrottleConfig) (*Throttle, map[string]float64) {
+	counter := &labelCounter{counts: make(map[string]float64)}
+	th := NewThrottle(NewThrottleStore(), cfg, counter)
+	th.now = func() time.Time { return now }
+	return th, counter.counts
+}
+
+func TestThrottlePolicy_delay(t *testing.T) {
+	p := ThrottlePolicy{FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: 10 * time.Second, LockoutAttempts: 8, Lockout: time.Hour}
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{"should not delay free attempts", 2, 0},
+		{"should delay the first throttled attempt by the base delay", 3, time.Second},
+		{"should double the delay", 5, 4 * time.Second},
+		{"should limit the delay", 7, 10 * time.Second},
+		{"should lock out", 8, time.Hour},
+		{"should stay locked out", 100, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.delay(tt.failures); got != tt.want {
+				t.Errorf("delay(%v) = %v, want %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 10, 16, 12, 0, 0, 0, time.UTC)
+	cID := big.NewInt(1)
+	policy := ThrottlePolicy{FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Minute, LockoutAttempts: 4, Lockout: time.Hour, ResetAfter: 24 * time.Hour}
+	unlimited := ThrottlePolicy{ResetAfter: time.Hour}
+
+	t.Run("should back off after the free attempts", func(t *testing.T) {
+		// given
+		th, counter := newThrottleAt(now, ThrottleConfig{Client: policy, Source: unlimited})
+		th.Attempt(ctx, cID, "source")
+		th.Attempt(ctx, cID, "source")
+		th.Attempt(ctx, cID, "source")
+
+		// when
+		err := th.Attempt(ctx, cID, "source")
+
+		// then
+		e, ok := errors.Cause(err).(*TooManyAttemptsError)
+		if !ok || e.RetryAfter != time.Second {
+			t.Fatalf("Attempt() error = %v, want retry after %v", err, time.Second)
+		}
+		if counter["key=client"] != 1 {
+			t.Errorf("throttled = %v, want 1 client", counter)
+		}
+
+		th.now = func() time.Time { return now.Add(time.Second) }
+		if err := th.Attempt(ctx, cID, "source"); err != nil {
+			t.Errorf("Attempt() after back off error = %v", err)
+		}
+	})
+
+	t.Run("should lock out", func(t *testing.T) {
+		th, _ := newThrottleAt(now, ThrottleConfig{Client: policy, Source: unlimited})
+		for i := 0; i < 4; i++ {
+			at := now.Add(time.Duration(i) * time.Minute)
+			th.now = func() time.Time { return at }
+			th.Attempt(ctx, cID, "source")
+		}
+
+		err := th.Attempt(ctx, cID, "source")
+
+		e, ok := errors.Cause(err).(*TooManyAttemptsError)
+		if !ok || e.RetryAfter != time.Hour {
+			t.Errorf("Attempt() error = %v, want retry after %v", err, time.Hour)
+		}
+	})
+
+	t.Run("should reset the client after a confirmed challenge", func(t *testing.T) {
+		th, _ := newThrottleAt(now, ThrottleConfig{Client: policy, Source: unlimited})
+		th.Attempt(ctx, cID, "source")
+		th.Attempt(ctx, cID, "source")
+		th.Attempt(ctx, cID, "source")
+
+		err := th.Succeeded(ctx, cID, "source")
+		if err != nil {
+			t.Fatalf("Succeeded() error = %v", err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if err := th.Attempt(ctx, cID, "source"); err != nil {
+				t.Errorf("Attempt() %d error = %v", i, err)
+			}
+		}
+	})
+
+	t.Run("should forget attempts after the reset period", func(t *testing.T) {
+		th, _ := newThrottleAt(now, ThrottleConfig{Client: policy, Source: unlimited})
+		for i := 0; i < 4; i++ {
+			at := now.Add(time.Duration(i) * time.Minute)
+			th.now = func() time.Time { return at }
+			th.Attempt(ctx, cID, "source")
+		}
+
+		th.now = func() time.Time { return now.Add(25 * time.Hour) }
+		if err := th.Attempt(ctx, cID, "source"); err != nil {
+			t.Errorf("Attempt() error = %v", err)
+		}
+	})
+
+	t.Run("should allow all attempts without throttle", func(t *testing.T) {
+		var th *Throttle
+		if err := th.Attempt(ctx, cID, "source"); err != nil {
+			t.Errorf("Attempt() error = %v", err)
+		}
+		if err := th.Succeeded(ctx, cID, "source"); err != nil {
+			t.Errorf("Succeeded() error = %v", err)
+		}
+	})
+}
+
+func TestThrottle_Source(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2019, 10, 16, 12, 0, 0, 0, time.UTC)
+	cID := big.NewInt(1)
+	policy := ThrottlePolicy{FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Minute, LockoutAttempts: 4, Lockout: time.Hour, ResetAfter: 24 * time.Hour}
+	unlimited := ThrottlePolicy{ResetAfter: time.Hour}
+
+	t.Run("should throttle a source guessing several clients", func(t *testing.T) {
+		th, counter := newThrottleAt(now, ThrottleConfig{Client: unlimited, Source: policy})
+		for i := int64(0); i < 3; i++ {
+			th.Attempt(ctx, big.NewInt(i), "source")
+		}
+
+		err := th.Attempt(ctx, big.NewInt(3), "source")
+		if _, ok := errors.Cause(err).(*TooManyAttemptsError); !ok {
+			t.Errorf("Attempt() error = %v, want *TooManyAttemptsError", err)
+		}
+		if err := th.Attempt(ctx, big.NewInt(3), "other source"); err != nil {
+			t.Errorf("Attempt() of other source error = %v", err)
+		}
+		if counter["key=source"] != 1 || counter["key=client"] != 0 {
+			t.Errorf("throttled = %v, want 1 source", counter)
+		}
+	})
+
+	t.Run("should count only the unconfirmed logins of a source", func(t *testing.T) {
+		th, _ := newThrottleAt(now, ThrottleConfig{Client: unlimited, Source: policy})
+		for i := int64(0); i < 10; i++ {
+			if err := th.Attempt(ctx, big.NewInt(i), "source"); err != nil {
+				t.Fatalf("Attempt() %d error = %v", i, err)
+			}
+			if err := th.Succeeded(ctx, big.NewInt(i), "source"); err != nil {
+				t.Fatalf("Succeeded() %d error = %v", i, err)
+			}
+		}
+		th.Succeeded(ctx, big.NewInt(10), "source")
+
+		for i := int64(10); i < 13; i++ {
+			th.Attempt(ctx, big.NewInt(i), "source")
+		}
+		err := th.Attempt(ctx, big.NewInt(13), "source")
+		if _, ok := errors.Cause(err).(*TooManyAttemptsError); !ok {
+			t.Errorf("Attempt() error = %v, want *TooManyAttemptsError", err)
+		}
+	})
+
+	t.Run("should not record the source of a throttled client", func(t *testing.T) {
+		// given
+		source := ThrottlePolicy{FreeAttempts: 4, BaseDelay: time.Hour, MaxDelay: time.Hour, ResetAfter: time.Hour}
+		th, _ := newThrottleAt(now, ThrottleConfig{Client: policy, Source: source})
+		th.Attempt(ctx, cID, "source")
+		th.Attempt(ctx, cID, "source")
+		th.Attempt(ctx, cID, "source")
+
+		// when
+		for i := 0; i < 3; i++ {
+			if _, ok := errors.Cause(th.Attempt(ctx, cID, "source")).(*TooManyAttemptsError); !ok {
+				t.Fatalf("Attempt() %d of throttled client, want *TooManyAttemptsError", i)
+			}
+		}
+
+		// then
+		th.now = func() time.Time { return now.Add(time.Second) }
+		if err := th.Attempt(ctx, big.NewInt(2), "source"); err != nil {
+			t.Errorf("Attempt() of other client error = %v", err)
+		}
+	})
+}
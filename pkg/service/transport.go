@@ -1,105 +1,194 @@
 package service
 
 import (
-	"fmt"
+	"context"
 	"math/big"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
+	"github.com/LAtanassov/go-online-sphinx/pkg/crypto"
 	"github.com/pkg/errors"
 
-	"github.com/gorilla/sessions"
-
 	"github.com/go-kit/kit/log"
 )
 
-// TODO: not global and load key from ENV
-// refactoring necessary to increase testability
-var (
-	key   = []byte("super-secret-key")
-	store = sessions.NewCookieStore(key)
-)
-
 // ErrLoginRequired is return probably because of missing session
 var ErrLoginRequired = errors.New("login required")
 
 // HTTPTransport implements all HTTP Handler
 type HTTPTransport struct {
-	service Service
-	logger  log.Logger
+	service  Service
+	sessions *SessionManager
+	throttle *Throttle
+	redactor *Redactor
+	logger   log.Logger
+
+	forwardedFor string
 }
 
-// NewHTTPTransport ...
-func NewHTTPTransport(s Service, l log.Logger) *HTTPTransport {
+// NewHTTPTransport returns the HTTP handlers of s keeping their sessions in sm,
+// which log failed requests with the LogError redaction policy.
+func NewHTTPTransport(s Service, sm *SessionManager, l log.Logger) *HTTPTransport {
 	return &HTTPTransport{
-		service: s,
-		logger:  l,
+		service:  s,
+		sessions: sm,
+		redactor: NewRedactor(LogError, nil),
+		logger:   l,
 	}
 }
 
+// WithRedactor logs the failed requests of h according to the redaction policy r and returns h.
+func (h *HTTPTransport) WithRedactor(r *Redactor) *HTTPTransport {
+	h.redactor = r
+	return h
+}
+
+// WithThrottle limits the logins of h by th and returns h.
+func (h *HTTPTransport) WithThrottle(th *Throttle) *HTTPTransport {
+	h.throttle = th
+	return h
+}
+
+// WithForwardedFor throttles logins by the address in the request header forwardedFor,
+// e.g. X-Forwarded-For, instead of the remote address and returns h.
+// Set it only behind a proxy overwriting or appending to the header, clients spoof it otherwise.
+func (h *HTTPTransport) WithForwardedFor(forwardedFor string) *HTTPTransport {
+	h.forwardedFor = forwardedFor
+	return h
+}
+
 // MakeRegisterHandler ...
 func (h *HTTPTransport) MakeRegisterHandler() http.Handler {
 	return post("/v1/register", func(resp http.ResponseWriter, req *http.Request) {
 		regReq, err := contract.UnmarshalRegisterRequest(req.Body)
 		if err != nil {
-			h.logger.Log("handler", "register", "error", fmt.Sprintf("+%v", errors.Wrapf(err, "UnmarshalRegisterRequest() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "register", errors.Wrapf(err, "UnmarshalRegisterRequest() failed"))
+			encodeError(resp, badRequest(err))
 			return
 		}
 		defer req.Body.Close()
 
-		err = h.service.Register(regReq.CID)
+		err = h.service.Register(req.Context(), regReq.CID, regReq.Verifier)
 		if err != nil {
-			h.logger.Log("handler", "register", "error", fmt.Sprintf("+%v", errors.Wrap(err, "Register() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "register", errors.Wrap(err, "Register() failed"))
+			encodeError(resp, err)
 			return
 		}
+
+		g := h.service.Group()
+		resp.Header().Set("Content-Type", "application/json; charset=utf-8")
 		resp.WriteHeader(http.StatusCreated)
+		err = contract.MarshalGroupResponse(resp, contract.GroupResponse{Name: g.Name(), Q: g.Order(), PK: h.service.PublicKey()})
+		if err != nil {
+			h.logError(req.Context(), "register", errors.Wrap(err, "MarshalGroupResponse() failed"))
+		}
+	})
+}
+
+// MakeEnrollHandler sets the verifier of a registered client authenticated by its enrollment code.
+func (h *HTTPTransport) MakeEnrollHandler() http.Handler {
+	return post("/v1/enroll", func(resp http.ResponseWriter, req *http.Request) {
+		enrollReq, err := contract.UnmarshalEnrollRequest(req.Body)
+		if err != nil {
+			h.logError(req.Context(), "enroll", errors.Wrap(err, "UnmarshalEnrollRequest() failed"))
+			encodeError(resp, badRequest(err))
+			return
+		}
+		defer req.Body.Close()
+
+		err = h.service.Enroll(req.Context(), enrollReq.CID, enrollReq.Verifier, enrollReq.Code, possessionProof(enrollReq))
+		if err != nil {
+			h.logError(req.Context(), "enroll", errors.Wrap(err, "Enroll() failed"))
+			encodeError(resp, err)
+			return
+		}
+		resp.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// MakeRegisterExpKHandler evaluates the blinded password of a client before it registers.
+func (h *HTTPTransport) MakeRegisterExpKHandler() http.Handler {
+	return post("/v1/register/expk", func(resp http.ResponseWriter, req *http.Request) {
+		regReq, err := contract.UnmarshalRegisterExpKRequest(req.Body)
+		if err != nil {
+			h.logError(req.Context(), "register_expk", errors.Wrap(err, "UnmarshalRegisterExpKRequest() failed"))
+			encodeError(resp, badRequest(err))
+			return
+		}
+		defer req.Body.Close()
+
+		bd, q0, proof, err := h.service.RegisterExpK(req.Context(), regReq.B, regReq.Q)
+		if err != nil {
+			h.logError(req.Context(), "register_expk", errors.Wrap(err, "RegisterExpK() failed"))
+			encodeError(resp, err)
+			return
+		}
+
+		resp.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err = contract.MarshalRegisterExpKResponse(resp, contract.RegisterExpKResponse{BD: bd, Q0: q0, ProofC: proof.C, ProofS: proof.S})
+		if err != nil {
+			h.logError(req.Context(), "register_expk", errors.Wrap(err, "MarshalRegisterExpKResponse() failed"))
+		}
+	})
+}
+
+// MakeGroupHandler publishes the group parameters used by the service.
+func (h *HTTPTransport) MakeGroupHandler() http.Handler {
+	return get("/v1/group", func(resp http.ResponseWriter, req *http.Request) {
+		g := h.service.Group()
+		resp.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err := contract.MarshalGroupResponse(resp, contract.GroupResponse{Name: g.Name(), Q: g.Order(), PK: h.service.PublicKey()})
+		if err != nil {
+			h.logError(req.Context(), "group", errors.Wrap(err, "MarshalGroupResponse() failed"))
+			encodeError(resp, err)
+			return
+		}
 	})
 }
 
 // MakeExpKHandler ...
 func (h *HTTPTransport) MakeExpKHandler() http.Handler {
 	return post("/v1/login/expk", func(resp http.ResponseWriter, req *http.Request) {
-		session, err := store.New(req, "online-sphinx")
+		expkReq, err := contract.UnmarshalExpKRequest(req.Body)
 		if err != nil {
-			h.logger.Log("handler", "expk", "error", fmt.Sprintf("+%v", errors.Wrap(err, "session.Get() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "expk", errors.Wrap(err, "UnmarshalExpKRequest() failed"))
+			encodeError(resp, badRequest(err))
 			return
 		}
+		defer req.Body.Close()
 
-		expkReq, err := contract.UnmarshalExpKRequest(req.Body)
+		err = h.throttle.Attempt(req.Context(), expkReq.CID, remoteHost(req, h.forwardedFor))
 		if err != nil {
-			h.logger.Log("handler", "expk", "error", fmt.Sprintf("+%v", errors.Wrap(err, "UnmarshalExpKRequest() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "expk", err)
+			encodeError(resp, err)
 			return
 		}
-		defer req.Body.Close()
 
-		ski, sID, sNonce, bd, q0, kv, err := h.service.ExpK(expkReq.CID, expkReq.CNonce, expkReq.B, expkReq.Q)
+		ski, sID, sNonce, bd, q0, y, proof, err := h.service.ExpK(req.Context(), expkReq.CID, expkReq.CNonce, expkReq.B, expkReq.Q, expkReq.X)
 		if err != nil {
-			h.logger.Log("handler", "expk", "error", fmt.Sprintf("+%v", errors.Wrap(err, "ExpK() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "expk", errors.Wrap(err, "ExpK() failed"))
+			encodeError(resp, err)
 			return
 		}
 
-		session.Values["sID"] = sID.Text(16)
-		session.Values["cID"] = expkReq.CID.Text(16)
-		session.Values["SKi"] = ski.Text(16)
-		err = session.Save(req, resp)
+		token, err := h.sessions.Create(req.Context(), expkReq.CID, sID, ski)
 		if err != nil {
-			h.logger.Log("handler", "expk", "error", fmt.Sprintf("+%v", errors.Wrap(err, "session.Save() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "expk", errors.Wrap(err, "sessions.Create() failed"))
+			encodeError(resp, err)
 			return
 		}
+		http.SetCookie(resp, &http.Cookie{Name: sessionName, Value: token, Path: "/", HttpOnly: true})
 
 		resp.Header().Set("Content-Type", "application/json; charset=utf-8")
-		err = contract.MarshalExpKResponse(resp, contract.ExpKResponse{SID: sID, SNonce: sNonce, BD: bd, Q0: q0, KV: kv})
+		err = contract.MarshalExpKResponse(resp, contract.ExpKResponse{SID: sID, SNonce: sNonce, BD: bd, Q0: q0, Y: y, ProofC: proof.C, ProofS: proof.S})
 		if err != nil {
-			h.logger.Log("handler", "expk", "error", fmt.Sprintf("+%v", err))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "expk", err)
+			encodeError(resp, err)
 			return
 		}
 	})
@@ -108,59 +197,86 @@ func (h *HTTPTransport) MakeExpKHandler() http.Handler {
 // MakeChallengeHandler ...
 func (h *HTTPTransport) MakeChallengeHandler() http.Handler {
 	return post("/v1/login/challenge", func(resp http.ResponseWriter, req *http.Request) {
-		session, err := store.Get(req, "online-sphinx")
+		session, err := h.session(req, StateExpKIssued)
 		if err != nil {
-			h.logger.Log("handler", "challenge", "error", fmt.Sprintf("+%v", errors.Wrap(err, "session.Get() failed")))
-			contract.MarshalError(resp, errors.Wrap(ErrLoginRequired, "called /v1/login/challenge without session"))
+			h.logError(req.Context(), "challenge", err)
+			encodeError(resp, err)
 			return
 		}
 
-		skiHex, ok := session.Values["SKi"].(string)
-		if !ok {
-			h.logger.Log("handler", "challenge", "error", fmt.Sprintf("+%v", errors.Wrap(ErrLoginRequired, "session.Values() retrieved SKi failed")))
-			contract.MarshalError(resp, errors.Wrap(ErrLoginRequired, "called /v1/login/challenge without session"))
-			return
-		}
-		ski := new(big.Int)
-		ski.SetString(skiHex, 16)
-
 		challReq, err := contract.UnmarshalChallengeRequest(req.Body)
 		if err != nil {
-			h.logger.Log("handler", "challenge", "error", fmt.Sprintf("+%v", errors.Wrap(err, "UnmarshalChallengeRequest failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "challenge", errors.Wrap(err, "UnmarshalChallengeRequest failed"))
+			encodeError(resp, badRequest(err))
 			return
 		}
 		defer req.Body.Close()
 
-		r, err := h.service.Challenge(ski, challReq.G, challReq.Q)
+		r, err := challenge(req.Context(), h.service, h.sessions, h.throttle, session, challReq, remoteHost(req, h.forwardedFor))
 		if err != nil {
-			h.logger.Log("handler", "challenge", "error", fmt.Sprintf("+%v", errors.Wrap(err, "Challenge() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "challenge", err)
+			encodeError(resp, err)
 			return
 		}
 
 		resp.Header().Set("Content-Type", "application/json; charset=utf-8")
 		err = contract.MarshalChallengeResponse(resp, contract.ChallengeResponse{R: r})
 		if err != nil {
-			h.logger.Log("handler", "challenge", "error", fmt.Sprintf("+%v", errors.Wrap(err, "MarshalChallengeResponse() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "challenge", errors.Wrap(err, "MarshalChallengeResponse() failed"))
+			encodeError(resp, err)
 			return
 		}
 	})
 }
 
-// MakeLogoutHandler ...
+// challenge verifies the proof of the client, answers its challenge and confirms the login,
+// which resets the login attempts of the client and takes back the attempt of source in th.
+// A session is challenged only once and failed challenges end it,
+// so that the service is no oracle of g**SKi.
+func challenge(ctx context.Context, s Service, sm *SessionManager, th *Throttle, session Session, challReq contract.ChallengeRequest, source string) (*big.Int, error) {
+	err := s.VerifyMAC(ctx, challReq.MAC, session.SKi, challReq.Canonical())
+	if err != nil {
+		sm.abort(ctx, session)
+		return nil, errors.Wrap(err, "VerifyMAC() failed")
+	}
+
+	err = sm.checkReplay(ctx, session, challReq.Counter, challReq.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = th.Succeeded(ctx, session.CID, source)
+	if err != nil {
+		return nil, errors.Wrap(err, "Succeeded() failed")
+	}
+
+	err = sm.confirm(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := s.Challenge(ctx, session.SKi, challReq.G, challReq.Q)
+	if err != nil {
+		sm.abort(ctx, session)
+		return nil, errors.Wrap(err, "Challenge() failed")
+	}
+	return r, nil
+}
+
+// MakeLogoutHandler revokes the session and drops its cookie.
 func (h *HTTPTransport) MakeLogoutHandler() http.Handler {
 	return post("/v1/logout", func(resp http.ResponseWriter, req *http.Request) {
-		session, err := store.Get(req, "online-sphinx")
-		if err != nil {
-			resp.WriteHeader(http.StatusOK)
-			return
-		}
 		defer req.Body.Close()
 
-		session.Options.MaxAge = -1
-		session.Save(req, resp)
+		if c, err := req.Cookie(sessionName); err == nil {
+			err = h.sessions.Revoke(req.Context(), c.Value)
+			if err != nil {
+				h.logError(req.Context(), "logout", errors.Wrap(err, "sessions.Revoke() failed"))
+				encodeError(resp, err)
+				return
+			}
+		}
+		http.SetCookie(resp, &http.Cookie{Name: sessionName, Path: "/", HttpOnly: true, MaxAge: -1})
 
 		resp.WriteHeader(http.StatusOK)
 	})
@@ -169,58 +285,47 @@ func (h *HTTPTransport) MakeLogoutHandler() http.Handler {
 // MakeMetadataHandler ...
 func (h *HTTPTransport) MakeMetadataHandler() http.Handler {
 	return post("/v1/metadata", func(resp http.ResponseWriter, req *http.Request) {
-		session, err := store.Get(req, "online-sphinx")
+		session, err := h.session(req, StateChallengeConfirmed)
 		if err != nil {
-			h.logger.Log("handler", "metadata", "error", fmt.Sprintf("+%v", errors.Wrap(err, "session.Get() failed")))
-			contract.MarshalError(resp, err)
-			return
-		}
-
-		cIDHex, ok := session.Values["cID"].(string)
-		if !ok {
-			h.logger.Log("handler", "metadata", "error", fmt.Sprintf("+%v", errors.Wrap(ErrLoginRequired, "session.Values() retrieve cID failed")))
-			contract.MarshalError(resp, ErrLoginRequired)
+			h.logError(req.Context(), "metadata", err)
+			encodeError(resp, err)
 			return
 		}
-		cID := new(big.Int)
-		cID.SetString(cIDHex, 16)
 
-		skiHex, ok := session.Values["SKi"].(string)
-		if !ok {
-			h.logger.Log("handler", "metadata", "error", fmt.Sprintf("+%v", errors.Wrap(ErrLoginRequired, "session.Values() retrieve SKi failed")))
-			contract.MarshalError(resp, ErrLoginRequired)
+		metaReq, err := contract.UnmarshalMetadataRequest(req.Body)
+		if err != nil {
+			h.logError(req.Context(), "metadata", errors.Wrap(err, "UnmarshalMetadataRequest() failed"))
+			encodeError(resp, badRequest(err))
 			return
 		}
-		ski := new(big.Int)
-		ski.SetString(skiHex, 16)
+		defer req.Body.Close()
 
-		metaReq, err := contract.UnmarshalMetadataRequest(req.Body)
+		err = h.service.VerifyMAC(req.Context(), metaReq.MAC, session.SKi, metaReq.Canonical())
 		if err != nil {
-			h.logger.Log("handler", "metadata", "error", fmt.Sprintf("+%v", errors.Wrap(err, "UnmarshalMetadataRequest() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "metadata", errors.Wrap(err, "VerifyMAC() failed"))
+			encodeError(resp, err)
 			return
 		}
-		defer req.Body.Close()
 
-		err = h.service.VerifyMAC(metaReq.MAC, ski, []byte("metadata"))
+		err = h.sessions.checkReplay(req.Context(), session, metaReq.Counter, metaReq.Timestamp)
 		if err != nil {
-			h.logger.Log("handler", "metadata", "error", fmt.Sprintf("+%v", errors.Wrap(err, "VerifyMAC() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "metadata", err)
+			encodeError(resp, err)
 			return
 		}
 
-		domains, err := h.service.GetMetadata(cID)
+		domains, err := h.service.GetMetadata(req.Context(), session.CID)
 		if err != nil {
-			h.logger.Log("handler", "metadata", "error", fmt.Sprintf("+%v", errors.Wrap(err, "GetMetadata() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "metadata", errors.Wrap(err, "GetMetadata() failed"))
+			encodeError(resp, err)
 			return
 		}
 
 		resp.Header().Set("Content-Type", "application/json; charset=utf-8")
 		err = contract.MarshalMetadataResponse(resp, contract.MetadataResponse{Domains: domains})
 		if err != nil {
-			h.logger.Log("handler", "metadata", "error", fmt.Sprintf("+%v", errors.Wrap(err, "MarshalMetadataResponse() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "metadata", errors.Wrap(err, "MarshalMetadataResponse() failed"))
+			encodeError(resp, err)
 			return
 		}
 	})
@@ -230,49 +335,39 @@ func (h *HTTPTransport) MakeMetadataHandler() http.Handler {
 func (h *HTTPTransport) MakeAddHandler() http.Handler {
 	return post("/v1/add", func(resp http.ResponseWriter, req *http.Request) {
 
-		session, err := store.Get(req, "online-sphinx")
+		session, err := h.session(req, StateChallengeConfirmed)
 		if err != nil {
-			h.logger.Log("handler", "add", "error", fmt.Sprintf("+%v", errors.Wrap(err, "session.Get() failed")))
-			contract.MarshalError(resp, err)
-			return
-		}
-
-		cIDHex, ok := session.Values["cID"].(string)
-		if !ok {
-			h.logger.Log("handler", "add", "error", fmt.Sprintf("+%v", errors.Wrap(ErrLoginRequired, "session.Values() retrieve cID failed")))
-			contract.MarshalError(resp, ErrLoginRequired)
+			h.logError(req.Context(), "add", err)
+			encodeError(resp, err)
 			return
 		}
-		cID := new(big.Int)
-		cID.SetString(cIDHex, 16)
 
-		skiHex, ok := session.Values["SKi"].(string)
-		if !ok {
-			h.logger.Log("handler", "add", "error", fmt.Sprintf("+%v", errors.Wrap(ErrLoginRequired, "session.Values() retrieve SKi failed")))
-			contract.MarshalError(resp, ErrLoginRequired)
+		addReq, err := contract.UnmarshalAddRequest(req.Body)
+		if err != nil {
+			h.logError(req.Context(), "add", errors.Wrap(err, "UnmarshalAddRequest() failed"))
+			encodeError(resp, badRequest(err))
 			return
 		}
-		ski := new(big.Int)
-		ski.SetString(skiHex, 16)
+		defer req.Body.Close()
 
-		addReq, err := contract.UnmarshalAddRequest(req.Body)
+		err = h.service.VerifyMAC(req.Context(), addReq.MAC, session.SKi, addReq.Canonical())
 		if err != nil {
-			h.logger.Log("handler", "add", "error", fmt.Sprintf("+%v", errors.Wrap(err, "UnmarshalAddRequest() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "add", errors.Wrap(err, "VerifyMAC() failed"))
+			encodeError(resp, err)
 			return
 		}
-		defer req.Body.Close()
 
-		err = h.service.VerifyMAC(addReq.MAC, ski, []byte(addReq.Domain))
+		err = h.sessions.checkReplay(req.Context(), session, addReq.Counter, addReq.Timestamp)
 		if err != nil {
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "add", err)
+			encodeError(resp, err)
 			return
 		}
 
-		err = h.service.Add(cID, addReq.Domain)
+		err = h.service.Add(req.Context(), session.CID, addReq.Domain, addReq.Metadata)
 		if err != nil {
-			h.logger.Log("handler", "add", "error", fmt.Sprintf("+%v", errors.Wrap(err, "Add() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "add", errors.Wrap(err, "Add() failed"))
+			encodeError(resp, err)
 			return
 		}
 
@@ -284,62 +379,276 @@ func (h *HTTPTransport) MakeAddHandler() http.Handler {
 func (h *HTTPTransport) MakeGetHandler() http.Handler {
 	return post("/v1/get", func(resp http.ResponseWriter, req *http.Request) {
 
-		session, err := store.Get(req, "online-sphinx")
+		session, err := h.session(req, StateChallengeConfirmed)
 		if err != nil {
-			h.logger.Log("handler", "get", "error", fmt.Sprintf("+%v", errors.Wrap(err, "session.Get() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "get", err)
+			encodeError(resp, err)
 			return
 		}
 
-		cIDHex, ok := session.Values["cID"].(string)
-		if !ok {
-			h.logger.Log("handler", "get", "error", fmt.Sprintf("+%v", errors.Wrap(ErrLoginRequired, "session.Values() retrieve cID failed")))
-			contract.MarshalError(resp, ErrLoginRequired)
+		getReq, err := contract.UnmarshalGetRequest(req.Body)
+		if err != nil {
+			h.logError(req.Context(), "get", errors.Wrap(err, "UnmarshalGetRequest() failed"))
+			encodeError(resp, badRequest(err))
 			return
 		}
-		cID := new(big.Int)
-		cID.SetString(cIDHex, 16)
+		defer req.Body.Close()
 
-		skiHex, ok := session.Values["SKi"].(string)
-		if !ok {
-			h.logger.Log("handler", "get", "error", fmt.Sprintf("+%v", errors.Wrap(ErrLoginRequired, "session.Values() retrieve SKi failed")))
-			contract.MarshalError(resp, ErrLoginRequired)
+		err = h.service.VerifyMAC(req.Context(), getReq.MAC, session.SKi, getReq.Canonical())
+		if err != nil {
+			h.logError(req.Context(), "get", errors.Wrap(err, "VerifyMAC() failed"))
+			encodeError(resp, err)
 			return
 		}
-		ski := new(big.Int)
-		ski.SetString(skiHex, 16)
 
-		getReq, err := contract.UnmarshalGetRequest(req.Body)
+		err = h.sessions.checkReplay(req.Context(), session, getReq.Counter, getReq.Timestamp)
 		if err != nil {
-			h.logger.Log("handler", "get", "error", fmt.Sprintf("+%v", errors.Wrap(err, "UnmarshalGetRequest() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "get", err)
+			encodeError(resp, err)
+			return
+		}
+		bj, qj, metadata, err := h.service.Get(req.Context(), session.CID, getReq.Domain, getReq.BMK, getReq.Q, getReq.Previous)
+		if err != nil {
+			h.logError(req.Context(), "get", errors.Wrap(err, "Get() failed"))
+			encodeError(resp, err)
+			return
+		}
+
+		err = contract.MarshalGetResponse(resp, contract.GetResponse{Bj: bj, Qj: qj, Metadata: metadata})
+		if err != nil {
+			h.logError(req.Context(), "get", errors.Wrap(err, "Get() failed"))
+			encodeError(resp, err)
+			return
+		}
+
+	})
+}
+
+// MakeRotateHandler ...
+func (h *HTTPTransport) MakeRotateHandler() http.Handler {
+	return post("/v1/rotate", func(resp http.ResponseWriter, req *http.Request) {
+
+		session, err := h.session(req, StateChallengeConfirmed)
+		if err != nil {
+			h.logError(req.Context(), "rotate", err)
+			encodeError(resp, err)
+			return
+		}
+
+		rotReq, err := contract.UnmarshalRotateRequest(req.Body)
+		if err != nil {
+			h.logError(req.Context(), "rotate", errors.Wrap(err, "UnmarshalRotateRequest() failed"))
+			encodeError(resp, badRequest(err))
 			return
 		}
 		defer req.Body.Close()
 
-		err = h.service.VerifyMAC(getReq.MAC, ski, getReq.BMK.Bytes())
+		err = h.service.VerifyMAC(req.Context(), rotReq.MAC, session.SKi, rotReq.Canonical())
 		if err != nil {
-			h.logger.Log("handler", "get", "error", fmt.Sprintf("+%v", errors.Wrap(err, "VerifyMAC() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "rotate", errors.Wrap(err, "VerifyMAC() failed"))
+			encodeError(resp, err)
 			return
 		}
-		bj, qj, err := h.service.Get(cID, getReq.Domain, getReq.BMK, getReq.Q)
+
+		err = h.sessions.checkReplay(req.Context(), session, rotReq.Counter, rotReq.Timestamp)
 		if err != nil {
-			h.logger.Log("handler", "get", "error", fmt.Sprintf("+%v", errors.Wrap(err, "Get() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "rotate", err)
+			encodeError(resp, err)
 			return
 		}
 
-		err = contract.MarshalGetResponse(resp, contract.GetResponse{Bj: bj, Qj: qj})
+		version, previousUntil, err := h.service.Rotate(req.Context(), session.CID, rotReq.Domain)
 		if err != nil {
-			h.logger.Log("handler", "get", "error", fmt.Sprintf("+%v", errors.Wrap(err, "Get() failed")))
-			contract.MarshalError(resp, err)
+			h.logError(req.Context(), "rotate", errors.Wrap(err, "Rotate() failed"))
+			encodeError(resp, err)
 			return
 		}
 
+		err = contract.MarshalRotateResponse(resp, contract.RotateResponse{Version: version, PreviousUntil: previousUntil})
+		if err != nil {
+			h.logError(req.Context(), "rotate", errors.Wrap(err, "MarshalRotateResponse() failed"))
+		}
 	})
 }
 
+// MakeDeleteHandler ...
+func (h *HTTPTransport) MakeDeleteHandler() http.Handler {
+	return post("/v1/delete", func(resp http.ResponseWriter, req *http.Request) {
+
+		session, err := h.session(req, StateChallengeConfirmed)
+		if err != nil {
+			h.logError(req.Context(), "delete", err)
+			encodeError(resp, err)
+			return
+		}
+
+		delReq, err := contract.UnmarshalDeleteRequest(req.Body)
+		if err != nil {
+			h.logError(req.Context(), "delete", errors.Wrap(err, "UnmarshalDeleteRequest() failed"))
+			encodeError(resp, badRequest(err))
+			return
+		}
+		defer req.Body.Close()
+
+		err = h.service.VerifyMAC(req.Context(), delReq.MAC, session.SKi, delReq.Canonical())
+		if err != nil {
+			h.logError(req.Context(), "delete", errors.Wrap(err, "VerifyMAC() failed"))
+			encodeError(resp, err)
+			return
+		}
+
+		err = h.sessions.checkReplay(req.Context(), session, delReq.Counter, delReq.Timestamp)
+		if err != nil {
+			h.logError(req.Context(), "delete", err)
+			encodeError(resp, err)
+			return
+		}
+
+		err = h.service.Delete(req.Context(), session.CID, delReq.Domain)
+		if err != nil {
+			h.logError(req.Context(), "delete", errors.Wrap(err, "Delete() failed"))
+			encodeError(resp, err)
+			return
+		}
+
+		resp.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// MakeSessionsHandler lists the active sessions of the user.
+func (h *HTTPTransport) MakeSessionsHandler() http.Handler {
+	return post("/v1/sessions", func(resp http.ResponseWriter, req *http.Request) {
+
+		session, err := h.session(req, StateChallengeConfirmed)
+		if err != nil {
+			h.logError(req.Context(), "sessions", err)
+			encodeError(resp, err)
+			return
+		}
+
+		sessReq, err := contract.UnmarshalSessionsRequest(req.Body)
+		if err != nil {
+			h.logError(req.Context(), "sessions", errors.Wrap(err, "UnmarshalSessionsRequest() failed"))
+			encodeError(resp, badRequest(err))
+			return
+		}
+		defer req.Body.Close()
+
+		err = h.service.VerifyMAC(req.Context(), sessReq.MAC, session.SKi, sessReq.Canonical())
+		if err != nil {
+			h.logError(req.Context(), "sessions", errors.Wrap(err, "VerifyMAC() failed"))
+			encodeError(resp, err)
+			return
+		}
+
+		err = h.sessions.checkReplay(req.Context(), session, sessReq.Counter, sessReq.Timestamp)
+		if err != nil {
+			h.logError(req.Context(), "sessions", err)
+			encodeError(resp, err)
+			return
+		}
+
+		infos, err := sessionInfos(req.Context(), h.sessions, session)
+		if err != nil {
+			h.logError(req.Context(), "sessions", err)
+			encodeError(resp, err)
+			return
+		}
+
+		resp.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err = contract.MarshalSessionsResponse(resp, contract.SessionsResponse{Sessions: infos})
+		if err != nil {
+			h.logError(req.Context(), "sessions", errors.Wrap(err, "MarshalSessionsResponse() failed"))
+			encodeError(resp, err)
+			return
+		}
+	})
+}
+
+// MakeRevokeSessionsHandler ends a session of the user or all of them,
+// in which case the cookie of the revoked session of the request is dropped.
+func (h *HTTPTransport) MakeRevokeSessionsHandler() http.Handler {
+	return post("/v1/sessions/revoke", func(resp http.ResponseWriter, req *http.Request) {
+
+		session, err := h.session(req, StateChallengeConfirmed)
+		if err != nil {
+			h.logError(req.Context(), "revoke_sessions", err)
+			encodeError(resp, err)
+			return
+		}
+
+		revReq, err := contract.UnmarshalRevokeSessionsRequest(req.Body)
+		if err != nil {
+			h.logError(req.Context(), "revoke_sessions", errors.Wrap(err, "UnmarshalRevokeSessionsRequest() failed"))
+			encodeError(resp, badRequest(err))
+			return
+		}
+		defer req.Body.Close()
+
+		err = h.service.VerifyMAC(req.Context(), revReq.MAC, session.SKi, revReq.Canonical())
+		if err != nil {
+			h.logError(req.Context(), "revoke_sessions", errors.Wrap(err, "VerifyMAC() failed"))
+			encodeError(resp, err)
+			return
+		}
+
+		err = h.sessions.checkReplay(req.Context(), session, revReq.Counter, revReq.Timestamp)
+		if err != nil {
+			h.logError(req.Context(), "revoke_sessions", err)
+			encodeError(resp, err)
+			return
+		}
+
+		err = revokeSessions(req.Context(), h.sessions, session, revReq)
+		if err != nil {
+			h.logError(req.Context(), "revoke_sessions", err)
+			encodeError(resp, err)
+			return
+		}
+		if revReq.All || revReq.ID == session.ID {
+			http.SetCookie(resp, &http.Cookie{Name: sessionName, Path: "/", HttpOnly: true, MaxAge: -1})
+		}
+
+		resp.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// sessionInfos returns the active sessions of the user of session without their keys.
+func sessionInfos(ctx context.Context, sm *SessionManager, session Session) ([]contract.SessionInfo, error) {
+	sessions, err := sm.List(ctx, session.CID)
+	if err != nil {
+		return nil, errors.Wrap(err, "sessions.List() failed")
+	}
+
+	infos := make([]contract.SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		infos = append(infos, contract.SessionInfo{ID: s.ID, Created: s.Created, LastSeen: s.LastSeen, Current: s.ID == session.ID})
+	}
+	return infos, nil
+}
+
+// revokeSessions ends the session of revReq or all sessions of the user of session.
+func revokeSessions(ctx context.Context, sm *SessionManager, session Session, revReq contract.RevokeSessionsRequest) error {
+	if revReq.All {
+		return errors.Wrap(sm.RevokeAll(ctx, session.CID), "sessions.RevokeAll() failed")
+	}
+	return errors.Wrap(sm.RevokeByID(ctx, session.CID, revReq.ID), "sessions.RevokeByID() failed")
+}
+
+// possessionProof returns the proof of possession of enrollReq.
+func possessionProof(enrollReq contract.EnrollRequest) crypto.PossessionProof {
+	return crypto.PossessionProof{E: enrollReq.E, Proof: crypto.Proof{C: enrollReq.ProofC, S: enrollReq.ProofS}}
+}
+
+// session returns the session of the cookie of req, which has to be in state.
+func (h *HTTPTransport) session(req *http.Request, state SessionState) (Session, error) {
+	c, err := req.Cookie(sessionName)
+	if err != nil {
+		return Session{}, errors.Wrapf(ErrLoginRequired, "called %s without session", req.URL.Path)
+	}
+	return h.sessions.Load(req.Context(), c.Value, state)
+}
+
 // MakeLivenessHandler returns liveness handler
 func (h *HTTPTransport) MakeLivenessHandler() http.Handler {
 	return get("/_status/liveness", func(w http.ResponseWriter, r *http.Request) {
@@ -361,7 +670,8 @@ func MakeAccessControl(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, "+contract.RequestIDHeader)
+		w.Header().Set("Access-Control-Expose-Headers", contract.RequestIDHeader)
 
 		if r.Method == "OPTIONS" {
 			return
@@ -371,9 +681,131 @@ func MakeAccessControl(h http.Handler) http.Handler {
 	})
 }
 
+// MakeRequestContext assigns a request ID to every request, the one of header contract.RequestIDHeader
+// if valid, which is returned in the same header of the response. Requests are cancelled after timeout
+// or when the client closes the connection, which aborts them in the service and the repositories.
+func MakeRequestContext(h http.Handler, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(contract.RequestIDHeader)
+		if !contract.ValidRequestID(id) {
+			id = contract.NewRequestID()
+		}
+		w.Header().Set(contract.RequestIDHeader, id)
+
+		ctx, cancel := context.WithTimeout(contract.WithRequestID(r.Context(), id), timeout)
+		defer cancel()
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// logError logs the request of handler failing with err, redacted by the cause and code of err.
+func (h *HTTPTransport) logError(ctx context.Context, handler string, err error) {
+	h.logger.Log("handler", handler, "request", contract.RequestID(ctx), "code", errorCode(err), "error", h.redactor.Error(err))
+}
+
+// encodeError writes err as contract.Error with the code of the service error causing it.
+func encodeError(w http.ResponseWriter, err error) {
+	contract.MarshalError(w, contractError(err))
+}
+
+// contractError maps the errors of the service to errors of the contract,
+// all other errors are internal and their messages are not revealed to clients.
+func contractError(err error) error {
+	cause := errors.Cause(err)
+	if e, ok := cause.(*TooManyAttemptsError); ok {
+		ce := contract.NewError(contract.CodeTooManyAttempts, "too many login attempts")
+		ce.RetryAfter = e.RetryAfter
+		return ce
+	}
+	switch cause {
+	case context.DeadlineExceeded, context.Canceled:
+		return contract.NewError(contract.CodeUnavailable, "request timed out")
+	case ErrLoginRequired, ErrSessionNotFound:
+		return contract.NewError(contract.CodeLoginRequired, ErrLoginRequired.Error())
+	case ErrMacMismatch:
+		return contract.NewError(contract.CodeMACMismatch, cause.Error())
+	case ErrReplayedRequest:
+		return contract.NewError(contract.CodeReplayedRequest, cause.Error())
+	case ErrLoginOutOfOrder:
+		return contract.NewError(contract.CodeLoginOutOfOrder, cause.Error())
+	case ErrLoginTimeout:
+		return contract.NewError(contract.CodeLoginTimeout, cause.Error())
+	case ErrUserNotFound:
+		return contract.NewError(contract.CodeUserNotFound, cause.Error())
+	case ErrUserAlreadyExists:
+		return contract.NewError(contract.CodeUserAlreadyExists, cause.Error())
+	case ErrEnrollmentInvalid:
+		return contract.NewError(contract.CodeEnrollmentInvalid, cause.Error())
+	case crypto.ErrProofInvalid:
+		return contract.NewError(contract.CodeBadRequest, "invalid proof of possession")
+	case ErrDomainNotFound:
+		return contract.NewError(contract.CodeDomainNotFound, cause.Error())
+	case ErrDomainAlreadyExists:
+		return contract.NewError(contract.CodeDomainAlreadyExists, cause.Error())
+	case ErrNoPreviousVersion:
+		return contract.NewError(contract.CodeNoPreviousVersion, cause.Error())
+	case ErrGroupMismatch:
+		return contract.NewError(contract.CodeGroupMismatch, cause.Error())
+	case ErrInvalidElement:
+		return contract.NewError(contract.CodeInvalidElement, cause.Error())
+	case ErrMetadataTooLong:
+		return contract.NewError(contract.CodeMetadataTooLong, cause.Error())
+	}
+	return err
+}
+
+// remoteHost returns the IP address of the client of req, which is the last address
+// of the header forwardedFor appended by the proxy, if set and present.
+func remoteHost(req *http.Request, forwardedFor string) string {
+	if forwardedFor != "" {
+		if host := lastAddress(req.Header.Get(forwardedFor)); host != "" {
+			return host
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// lastAddress returns the last entry of the comma separated addresses of a forwarded-for header.
+func lastAddress(header string) string {
+	addrs := strings.Split(header, ",")
+	return strings.TrimSpace(addrs[len(addrs)-1])
+}
+
+// errorCode returns the contract code of err.
+func errorCode(err error) contract.Code {
+	if e, ok := errors.Cause(contractError(err)).(*contract.Error); ok {
+		return e.Code
+	}
+	return contract.CodeInternal
+}
+
+// badRequest marks errors of decoding a request.
+func badRequest(err error) error {
+	if errors.Cause(err) == contract.ErrRequestTooLarge {
+		return contract.NewError(contract.CodeRequestTooLarge, err.Error())
+	}
+	return contract.NewError(contract.CodeBadRequest, err.Error())
+}
+
+// limitRequest rejects requests announcing a body larger than contract.MaxRequestSize
+// before reading it, larger bodies without Content-Length are rejected while decoding.
+func limitRequest(f http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		if req.ContentLength > contract.MaxRequestSize {
+			encodeError(resp, badRequest(errors.Wrapf(contract.ErrRequestTooLarge, "Content-Length %d", req.ContentLength)))
+			return
+		}
+		f(resp, req)
+	}
+}
+
 func post(path string, f http.HandlerFunc) *mux.Router {
 	r := mux.NewRouter()
-	r.HandleFunc(path, f).Methods("POST")
+	r.HandleFunc(path, limitRequest(f)).Methods("POST")
 	return r
 }
 
@@ -0,0 +1,495 @@
+package service
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
+	"github.com/LAtanassov/go-online-sphinx/pkg/pb"
+)
+
+// GRPCTransport implements pb.OnlineSphinxServer over Service, side by side with HTTPTransport,
+// e.g. pb.RegisterOnlineSphinxServer(server, NewGRPCTransport(s, sm, l)).
+// Session tokens are exchanged as metadata pb.SessionKey, sessions are shared with an
+// HTTPTransport using the same SessionManager.
+type GRPCTransport struct {
+	service  Service
+	sessions *SessionManager
+	throttle *Throttle
+	redactor *Redactor
+	logger   log.Logger
+
+	forwardedFor string
+}
+
+var _ pb.OnlineSphinxServer = (*GRPCTransport)(nil)
+
+// NewGRPCTransport returns the gRPC service of s keeping its sessions in sm.
+func NewGRPCTransport(s Service, sm *SessionManager, l log.Logger) *GRPCTransport {
+	return &GRPCTransport{
+		service:  s,
+		sessions: sm,
+		redactor: NewRedactor(LogError, nil),
+		logger:   l,
+	}
+}
+
+// WithRedactor logs the failed requests of t according to the redaction policy r and returns t.
+func (t *GRPCTransport) WithRedactor(r *Redactor) *GRPCTransport {
+	t.redactor = r
+	return t
+}
+
+// WithThrottle limits the logins of t by th and returns t.
+func (t *GRPCTransport) WithThrottle(th *Throttle) *GRPCTransport {
+	t.throttle = th
+	return t
+}
+
+// WithForwardedFor throttles logins by the address in the metadata forwardedFor,
+// e.g. x-forwarded-for, instead of the peer address and returns t.
+// Set it only behind a proxy overwriting or appending to the metadata, clients spoof it otherwise.
+func (t *GRPCTransport) WithForwardedFor(forwardedFor string) *GRPCTransport {
+	t.forwardedFor = forwardedFor
+	return t
+}
+
+// Group publishes the group parameters used by the service.
+func (t *GRPCTransport) Group(ctx context.Context, req *pb.GroupRequest) (*pb.GroupResponse, error) {
+	return t.groupResponse(), nil
+}
+
+// RegisterExpK ...
+func (t *GRPCTransport) RegisterExpK(ctx context.Context, req *pb.RegisterExpKRequest) (*pb.RegisterExpKResponse, error) {
+	regReq := contract.RegisterExpKRequest{B: intOf(req.B), Q: intOf(req.Q)}
+	if err := regReq.Validate(); err != nil {
+		return nil, t.error(ctx, "register_expk", badRequest(err))
+	}
+
+	bd, q0, proof, err := t.service.RegisterExpK(ctx, regReq.B, regReq.Q)
+	if err != nil {
+		return nil, t.error(ctx, "register_expk", errors.Wrap(err, "RegisterExpK() failed"))
+	}
+	return &pb.RegisterExpKResponse{Bd: bd.Bytes(), Q0: q0.Bytes(), ProofC: proof.C.Bytes(), ProofS: proof.S.Bytes()}, nil
+}
+
+// Register ...
+func (t *GRPCTransport) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.GroupResponse, error) {
+	regReq := contract.RegisterRequest{CID: intOf(req.CId), Verifier: intOf(req.Verifier)}
+	if err := regReq.Validate(); err != nil {
+		return nil, t.error(ctx, "register", badRequest(err))
+	}
+
+	err := t.service.Register(ctx, regReq.CID, regReq.Verifier)
+	if err != nil {
+		return nil, t.error(ctx, "register", errors.Wrap(err, "Register() failed"))
+	}
+	return t.groupResponse(), nil
+}
+
+// Enroll ...
+func (t *GRPCTransport) Enroll(ctx context.Context, req *pb.EnrollRequest) (*pb.EnrollResponse, error) {
+	enrollReq := contract.EnrollRequest{
+		CID: intOf(req.CId), Code: req.Code, Verifier: intOf(req.Verifier), E: intOf(req.E),
+		ProofC: new(big.Int).SetBytes(req.ProofC), ProofS: new(big.Int).SetBytes(req.ProofS),
+	}
+	if err := enrollReq.Validate(); err != nil {
+		return nil, t.error(ctx, "enroll", badRequest(err))
+	}
+
+	err := t.service.Enroll(ctx, enrollReq.CID, enrollReq.Verifier, enrollReq.Code, possessionProof(enrollReq))
+	if err != nil {
+		return nil, t.error(ctx, "enroll", errors.Wrap(err, "Enroll() failed"))
+	}
+	return &pb.EnrollResponse{}, nil
+}
+
+// ExpK ...
+func (t *GRPCTransport) ExpK(ctx context.Context, req *pb.ExpKRequest) (*pb.ExpKResponse, error) {
+	expkReq := contract.ExpKRequest{CID: intOf(req.CId), CNonce: new(big.Int).SetBytes(req.CNonce), B: intOf(req.B), Q: intOf(req.Q), X: intOf(req.X)}
+	if err := expkReq.Validate(); err != nil {
+		return nil, t.error(ctx, "expk", badRequest(err))
+	}
+
+	if err := t.throttle.Attempt(ctx, expkReq.CID, peerHost(ctx, t.forwardedFor)); err != nil {
+		return nil, t.error(ctx, "expk", err)
+	}
+
+	ski, sID, sNonce, bd, q0, y, proof, err := t.service.ExpK(ctx, expkReq.CID, expkReq.CNonce, expkReq.B, expkReq.Q, expkReq.X)
+	if err != nil {
+		return nil, t.error(ctx, "expk", errors.Wrap(err, "ExpK() failed"))
+	}
+
+	token, err := t.sessions.Create(ctx, expkReq.CID, sID, ski)
+	if err != nil {
+		return nil, t.error(ctx, "expk", errors.Wrap(err, "sessions.Create() failed"))
+	}
+	err = grpc.SetHeader(ctx, metadata.Pairs(pb.SessionKey, token))
+	if err != nil {
+		return nil, t.error(ctx, "expk", errors.Wrap(err, "grpc.SetHeader() failed"))
+	}
+
+	expkResp := &pb.ExpKResponse{
+		SId:    sID.Bytes(),
+		SNonce: sNonce.Bytes(),
+		Bd:     bd.Bytes(),
+		Q0:     q0.Bytes(),
+		Y:      y.Bytes(),
+	}
+	if proof.C != nil && proof.S != nil {
+		expkResp.ProofC = proof.C.Bytes()
+		expkResp.ProofS = proof.S.Bytes()
+	}
+	return expkResp, nil
+}
+
+// Challenge ...
+func (t *GRPCTransport) Challenge(ctx context.Context, req *pb.ChallengeRequest) (*pb.ChallengeResponse, error) {
+	session, err := t.session(ctx, StateExpKIssued)
+	if err != nil {
+		return nil, t.error(ctx, "challenge", err)
+	}
+
+	challReq := contract.ChallengeRequest{MAC: req.Mac, Counter: req.Counter, Timestamp: contract.FromUnixMilli(req.Timestamp), G: intOf(req.G), Q: intOf(req.Q)}
+	if err := challReq.Validate(); err != nil {
+		return nil, t.error(ctx, "challenge", badRequest(err))
+	}
+
+	r, err := challenge(ctx, t.service, t.sessions, t.throttle, session, challReq, peerHost(ctx, t.forwardedFor))
+	if err != nil {
+		return nil, t.error(ctx, "challenge", err)
+	}
+	return &pb.ChallengeResponse{R: r.Bytes()}, nil
+}
+
+// Logout revokes the session sent as metadata pb.SessionKey.
+func (t *GRPCTransport) Logout(ctx context.Context, req *pb.LogoutRequest) (*pb.LogoutResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, token := range md.Get(pb.SessionKey) {
+		err := t.sessions.Revoke(ctx, token)
+		if err != nil {
+			return nil, t.error(ctx, "logout", errors.Wrap(err, "sessions.Revoke() failed"))
+		}
+	}
+	return &pb.LogoutResponse{}, nil
+}
+
+// Sessions ...
+func (t *GRPCTransport) Sessions(ctx context.Context, req *pb.SessionsRequest) (*pb.SessionsResponse, error) {
+	session, err := t.session(ctx, StateChallengeConfirmed)
+	if err != nil {
+		return nil, t.error(ctx, "sessions", err)
+	}
+
+	sessReq := contract.SessionsRequest{MAC: req.Mac, Counter: req.Counter, Timestamp: contract.FromUnixMilli(req.Timestamp)}
+	if err := sessReq.Validate(); err != nil {
+		return nil, t.error(ctx, "sessions", badRequest(err))
+	}
+
+	err = t.service.VerifyMAC(ctx, sessReq.MAC, session.SKi, sessReq.Canonical())
+	if err != nil {
+		return nil, t.error(ctx, "sessions", errors.Wrap(err, "VerifyMAC() failed"))
+	}
+	err = t.sessions.checkReplay(ctx, session, sessReq.Counter, sessReq.Timestamp)
+	if err != nil {
+		return nil, t.error(ctx, "sessions", err)
+	}
+
+	infos, err := sessionInfos(ctx, t.sessions, session)
+	if err != nil {
+		return nil, t.error(ctx, "sessions", err)
+	}
+	resp := &pb.SessionsResponse{}
+	for _, s := range infos {
+		resp.Sessions = append(resp.Sessions, &pb.SessionInfo{Id: s.ID, Created: s.Created.UnixNano(), LastSeen: s.LastSeen.UnixNano(), Current: s.Current})
+	}
+	return resp, nil
+}
+
+// RevokeSessions ...
+func (t *GRPCTransport) RevokeSessions(ctx context.Context, req *pb.RevokeSessionsRequest) (*pb.RevokeSessionsResponse, error) {
+	session, err := t.session(ctx, StateChallengeConfirmed)
+	if err != nil {
+		return nil, t.error(ctx, "revoke_sessions", err)
+	}
+
+	revReq := contract.RevokeSessionsRequest{MAC: req.Mac, Counter: req.Counter, Timestamp: contract.FromUnixMilli(req.Timestamp), ID: req.Id, All: req.All}
+	if err := revReq.Validate(); err != nil {
+		return nil, t.error(ctx, "revoke_sessions", badRequest(err))
+	}
+
+	err = t.service.VerifyMAC(ctx, revReq.MAC, session.SKi, revReq.Canonical())
+	if err != nil {
+		return nil, t.error(ctx, "revoke_sessions", errors.Wrap(err, "VerifyMAC() failed"))
+	}
+	err = t.sessions.checkReplay(ctx, session, revReq.Counter, revReq.Timestamp)
+	if err != nil {
+		return nil, t.error(ctx, "revoke_sessions", err)
+	}
+
+	err = revokeSessions(ctx, t.sessions, session, revReq)
+	if err != nil {
+		return nil, t.error(ctx, "revoke_sessions", err)
+	}
+	return &pb.RevokeSessionsResponse{}, nil
+}
+
+// Metadata ...
+func (t *GRPCTransport) Metadata(ctx context.Context, req *pb.MetadataRequest) (*pb.MetadataResponse, error) {
+	session, err := t.session(ctx, StateChallengeConfirmed)
+	if err != nil {
+		return nil, t.error(ctx, "metadata", err)
+	}
+
+	metaReq := contract.MetadataRequest{MAC: req.Mac, Counter: req.Counter, Timestamp: contract.FromUnixMilli(req.Timestamp)}
+	if err := metaReq.Validate(); err != nil {
+		return nil, t.error(ctx, "metadata", badRequest(err))
+	}
+
+	err = t.service.VerifyMAC(ctx, metaReq.MAC, session.SKi, metaReq.Canonical())
+	if err != nil {
+		return nil, t.error(ctx, "metadata", errors.Wrap(err, "VerifyMAC() failed"))
+	}
+	err = t.sessions.checkReplay(ctx, session, metaReq.Counter, metaReq.Timestamp)
+	if err != nil {
+		return nil, t.error(ctx, "metadata", err)
+	}
+
+	domains, err := t.service.GetMetadata(ctx, session.CID)
+	if err != nil {
+		return nil, t.error(ctx, "metadata", errors.Wrap(err, "GetMetadata() failed"))
+	}
+	return &pb.MetadataResponse{Domains: domains}, nil
+}
+
+// Add ...
+func (t *GRPCTransport) Add(ctx context.Context, req *pb.AddRequest) (*pb.AddResponse, error) {
+	session, err := t.session(ctx, StateChallengeConfirmed)
+	if err != nil {
+		return nil, t.error(ctx, "add", err)
+	}
+
+	addReq := contract.AddRequest{MAC: req.Mac, Counter: req.Counter, Timestamp: contract.FromUnixMilli(req.Timestamp), Domain: req.Domain, Metadata: req.Metadata}
+	if err := addReq.Validate(); err != nil {
+		return nil, t.error(ctx, "add", badRequest(err))
+	}
+
+	err = t.service.VerifyMAC(ctx, addReq.MAC, session.SKi, addReq.Canonical())
+	if err != nil {
+		return nil, t.error(ctx, "add", errors.Wrap(err, "VerifyMAC() failed"))
+	}
+	err = t.sessions.checkReplay(ctx, session, addReq.Counter, addReq.Timestamp)
+	if err != nil {
+		return nil, t.error(ctx, "add", err)
+	}
+
+	err = t.service.Add(ctx, session.CID, addReq.Domain, addReq.Metadata)
+	if err != nil {
+		return nil, t.error(ctx, "add", errors.Wrap(err, "Add() failed"))
+	}
+	return &pb.AddResponse{}, nil
+}
+
+// Get ...
+func (t *GRPCTransport) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	session, err := t.session(ctx, StateChallengeConfirmed)
+	if err != nil {
+		return nil, t.error(ctx, "get", err)
+	}
+
+	getReq := contract.GetRequest{MAC: req.Mac, Counter: req.Counter, Timestamp: contract.FromUnixMilli(req.Timestamp), Domain: req.Domain, BMK: intOf(req.Bmk), Q: intOf(req.Q), Previous: req.Previous}
+	if err := getReq.Validate(); err != nil {
+		return nil, t.error(ctx, "get", badRequest(err))
+	}
+
+	err = t.service.VerifyMAC(ctx, getReq.MAC, session.SKi, getReq.Canonical())
+	if err != nil {
+		return nil, t.error(ctx, "get", errors.Wrap(err, "VerifyMAC() failed"))
+	}
+	err = t.sessions.checkReplay(ctx, session, getReq.Counter, getReq.Timestamp)
+	if err != nil {
+		return nil, t.error(ctx, "get", err)
+	}
+
+	bj, qj, metadata, err := t.service.Get(ctx, session.CID, getReq.Domain, getReq.BMK, getReq.Q, getReq.Previous)
+	if err != nil {
+		return nil, t.error(ctx, "get", errors.Wrap(err, "Get() failed"))
+	}
+	return &pb.GetResponse{Bj: bj.Bytes(), Qj: qj.Bytes(), Metadata: metadata}, nil
+}
+
+// Rotate ...
+func (t *GRPCTransport) Rotate(ctx context.Context, req *pb.RotateRequest) (*pb.RotateResponse, error) {
+	session, err := t.session(ctx, StateChallengeConfirmed)
+	if err != nil {
+		return nil, t.error(ctx, "rotate", err)
+	}
+
+	rotReq := contract.RotateRequest{MAC: req.Mac, Counter: req.Counter, Timestamp: contract.FromUnixMilli(req.Timestamp), Domain: req.Domain}
+	if err := rotReq.Validate(); err != nil {
+		return nil, t.error(ctx, "rotate", badRequest(err))
+	}
+
+	err = t.service.VerifyMAC(ctx, rotReq.MAC, session.SKi, rotReq.Canonical())
+	if err != nil {
+		return nil, t.error(ctx, "rotate", errors.Wrap(err, "VerifyMAC() failed"))
+	}
+	err = t.sessions.checkReplay(ctx, session, rotReq.Counter, rotReq.Timestamp)
+	if err != nil {
+		return nil, t.error(ctx, "rotate", err)
+	}
+
+	version, previousUntil, err := t.service.Rotate(ctx, session.CID, rotReq.Domain)
+	if err != nil {
+		return nil, t.error(ctx, "rotate", errors.Wrap(err, "Rotate() failed"))
+	}
+	return &pb.RotateResponse{Version: int64(version), PreviousUntil: previousUntil.UnixNano()}, nil
+}
+
+// Delete ...
+func (t *GRPCTransport) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	session, err := t.session(ctx, StateChallengeConfirmed)
+	if err != nil {
+		return nil, t.error(ctx, "delete", err)
+	}
+
+	delReq := contract.DeleteRequest{MAC: req.Mac, Counter: req.Counter, Timestamp: contract.FromUnixMilli(req.Timestamp), Domain: req.Domain}
+	if err := delReq.Validate(); err != nil {
+		return nil, t.error(ctx, "delete", badRequest(err))
+	}
+
+	err = t.service.VerifyMAC(ctx, delReq.MAC, session.SKi, delReq.Canonical())
+	if err != nil {
+		return nil, t.error(ctx, "delete", errors.Wrap(err, "VerifyMAC() failed"))
+	}
+	err = t.sessions.checkReplay(ctx, session, delReq.Counter, delReq.Timestamp)
+	if err != nil {
+		return nil, t.error(ctx, "delete", err)
+	}
+
+	err = t.service.Delete(ctx, session.CID, delReq.Domain)
+	if err != nil {
+		return nil, t.error(ctx, "delete", errors.Wrap(err, "Delete() failed"))
+	}
+	return &pb.DeleteResponse{}, nil
+}
+
+func (t *GRPCTransport) groupResponse() *pb.GroupResponse {
+	g := t.service.Group()
+	resp := &pb.GroupResponse{Name: g.Name(), Q: g.Order().Bytes()}
+	if pk := t.service.PublicKey(); pk != nil {
+		resp.Pk = pk.Bytes()
+	}
+	return resp
+}
+
+// peerHost returns the IP address of the client of ctx, which is the last address
+// of the metadata forwardedFor appended by the proxy, if set and present.
+func peerHost(ctx context.Context, forwardedFor string) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok && forwardedFor != "" {
+		if values := md.Get(forwardedFor); len(values) > 0 {
+			if host := lastAddress(strings.Join(values, ",")); host != "" {
+				return host
+			}
+		}
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// error logs err and returns it as gRPC status, the contract code and retry hint are sent as trailer.
+func (t *GRPCTransport) error(ctx context.Context, handler string, err error) error {
+	e, ok := errors.Cause(contractError(err)).(*contract.Error)
+	if !ok {
+		e = contract.NewError(contract.CodeInternal, "internal error")
+	}
+	t.logger.Log("handler", handler, "transport", "grpc", "request", contract.RequestID(ctx), "code", e.Code, "error", t.redactor.Error(err))
+
+	md := metadata.Pairs(pb.CodeKey, string(e.Code))
+	if e.RetryAfter > 0 {
+		md.Append(pb.RetryAfterKey, strconv.FormatInt(int64(e.RetryAfter/time.Millisecond), 10))
+	}
+	grpc.SetTrailer(ctx, md)
+
+	return status.Error(grpcCode(e.Code), e.Message)
+}
+
+// UnaryRequestContext returns an interceptor assigning a request ID to every call, the one of the
+// request metadata pb.RequestIDKey if valid, which is returned as response header. Calls are cancelled
+// after timeout or the deadline of the client, which aborts them in the service and the repositories.
+func UnaryRequestContext(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var id string
+		if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get(pb.RequestIDKey)) > 0 {
+			id = md.Get(pb.RequestIDKey)[0]
+		}
+		if !contract.ValidRequestID(id) {
+			id = contract.NewRequestID()
+		}
+		grpc.SetHeader(ctx, metadata.Pairs(pb.RequestIDKey, id))
+
+		ctx, cancel := context.WithTimeout(contract.WithRequestID(ctx, id), timeout)
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// grpcCode maps the contract codes to the closest gRPC status codes.
+func grpcCode(c contract.Code) codes.Code {
+	switch c {
+	case contract.CodeUnavailable:
+		return codes.Unavailable
+	case contract.CodeBadRequest, contract.CodeGroupMismatch, contract.CodeInvalidElement, contract.CodeMetadataTooLong:
+		return codes.InvalidArgument
+	case contract.CodeRequestTooLarge, contract.CodeTooManyAttempts:
+		return codes.ResourceExhausted
+	case contract.CodeLoginRequired, contract.CodeMACMismatch, contract.CodeReplayedRequest, contract.CodeLoginTimeout:
+		return codes.Unauthenticated
+	case contract.CodeLoginOutOfOrder:
+		return codes.FailedPrecondition
+	case contract.CodeEnrollmentInvalid:
+		return codes.PermissionDenied
+	case contract.CodeUserNotFound, contract.CodeDomainNotFound, contract.CodeNoPreviousVersion:
+		return codes.NotFound
+	case contract.CodeUserAlreadyExists, contract.CodeDomainAlreadyExists:
+		return codes.AlreadyExists
+	}
+	return codes.Internal
+}
+
+// session returns the session sent as metadata pb.SessionKey, which has to be in state.
+func (t *GRPCTransport) session(ctx context.Context, state SessionState) (Session, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens := md.Get(pb.SessionKey)
+	if len(tokens) == 0 {
+		return Session{}, errors.Wrap(ErrLoginRequired, "called without session")
+	}
+	return t.sessions.Load(ctx, tokens[0], state)
+}
+
+// intOf returns the big-endian integer b or nil if b is empty, so that missing values are rejected.
+func intOf(b []byte) *big.Int {
+	if len(b) == 0 {
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
+	"github.com/LAtanassov/go-online-sphinx/pkg/crypto"
+	"github.com/LAtanassov/go-online-sphinx/pkg/pb"
+)
+
+func newTestGRPCClient(t *testing.T, s Service) (pb.OnlineSphinxClient, func()) {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(UnaryRequestContext(time.Minute)))
+	pb.RegisterOnlineSphinxServer(server, NewGRPCTransport(s, newTestSessionManager(t), log.NewNopLogger()))
+	go server.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		t.Fatalf("grpc.Dial() error = %v", err)
+	}
+	return pb.NewOnlineSphinxClient(conn), func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func TestGRPCTransport(t *testing.T) {
+	s := New(
+		NewUserRepository(),
+		NewVaultRepository(),
+		newTestConfiguration(t),
+	)
+	clt, stop := newTestGRPCClient(t, s)
+	defer stop()
+	ctx := context.Background()
+
+	t.Run("should register, login, add and get a domain and revoke the session", func(t *testing.T) {
+		// given
+		cID, cNonce, b := big.NewInt(1), big.NewInt(2), big.NewInt(4)
+		ak, x := big.NewInt(5), big.NewInt(6)
+		X := testGroup.Exp(testGroup.Generator(), x)
+		_, err := clt.Register(ctx, &pb.RegisterRequest{CId: cID.Bytes(), Verifier: crypto.Verifier(testGroup, ak).Bytes()})
+		if err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+
+		// when
+		var header metadata.MD
+		expk, err := clt.ExpK(ctx, &pb.ExpKRequest{CId: cID.Bytes(), CNonce: cNonce.Bytes(), B: b.Bytes(), Q: testGroup.Order().Bytes(), X: X.Bytes()}, grpc.Header(&header))
+		if err != nil {
+			t.Fatalf("ExpK() error = %v", err)
+		}
+		if len(header.Get(pb.SessionKey)) != 1 {
+			t.Fatalf("ExpK() header = %v, want session", header)
+		}
+		Y := new(big.Int).SetBytes(expk.Y)
+		tr := crypto.Transcript{CID: cID, SID: new(big.Int).SetBytes(expk.SId), CNonce: cNonce, SNonce: new(big.Int).SetBytes(expk.SNonce), B: b, BD: new(big.Int).SetBytes(expk.Bd), X: X, Y: Y}
+		ski := crypto.SessionKey(sha256.New, testGroup, testGroup.Exp(Y, x), testGroup.Exp(Y, ak), tr).Bytes()
+		ctx := metadata.AppendToOutgoingContext(ctx, pb.SessionKey, header.Get(pb.SessionKey)[0])
+
+		now := time.Now()
+		addReq := contract.AddRequest{Counter: 2, Timestamp: now, Domain: "domain", Metadata: "metadata"}
+		add := &pb.AddRequest{Mac: crypto.HmacData(sha256.New, ski, addReq.Canonical()), Counter: 2, Timestamp: contract.UnixMilli(now), Domain: "domain", Metadata: "metadata"}
+		_, err = clt.Add(ctx, add)
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("Add() before Challenge error = %v, want %v", err, codes.FailedPrecondition)
+		}
+		challReq := contract.ChallengeRequest{Counter: 1, Timestamp: now, G: big.NewInt(4), Q: testGroup.Order()}
+		_, err = clt.Challenge(ctx, &pb.ChallengeRequest{Mac: crypto.HmacData(sha256.New, ski, challReq.Canonical()), Counter: 1, Timestamp: contract.UnixMilli(now), G: challReq.G.Bytes(), Q: challReq.Q.Bytes()})
+		if err != nil {
+			t.Fatalf("Challenge() error = %v", err)
+		}
+		_, err = clt.Add(ctx, add)
+		if err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		var trailer metadata.MD
+		_, err = clt.Add(ctx, add, grpc.Trailer(&trailer))
+		if c := trailer.Get(pb.CodeKey); status.Code(err) != codes.Unauthenticated || len(c) != 1 || c[0] != string(contract.CodeReplayedRequest) {
+			t.Fatalf("Add() replayed error = %v, trailer %v, want %v", err, trailer, contract.CodeReplayedRequest)
+		}
+		getReq := contract.GetRequest{Counter: 3, Timestamp: now, Domain: "domain", BMK: big.NewInt(4), Q: testGroup.Order()}
+		got, err := clt.Get(ctx, &pb.GetRequest{Mac: crypto.HmacData(sha256.New, ski, getReq.Canonical()), Counter: 3, Timestamp: contract.UnixMilli(now), Domain: "domain", Bmk: getReq.BMK.Bytes(), Q: getReq.Q.Bytes()})
+
+		// then
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if got.Metadata != "metadata" || !testGroup.IsElement(new(big.Int).SetBytes(got.Bj)) {
+			t.Errorf("Get() = %v, want element and metadata", got)
+		}
+		sessReq := contract.SessionsRequest{Counter: 4, Timestamp: now}
+		sessions, err := clt.Sessions(ctx, &pb.SessionsRequest{Mac: crypto.HmacData(sha256.New, ski, sessReq.Canonical()), Counter: 4, Timestamp: contract.UnixMilli(now)})
+		if err != nil || len(sessions.Sessions) != 1 || !sessions.Sessions[0].Current {
+			t.Fatalf("Sessions() = %v, %v, want the current session", sessions, err)
+		}
+		revReq := contract.RevokeSessionsRequest{Counter: 5, Timestamp: now, All: true}
+		_, err = clt.RevokeSessions(ctx, &pb.RevokeSessionsRequest{Mac: crypto.HmacData(sha256.New, ski, revReq.Canonical()), Counter: 5, Timestamp: contract.UnixMilli(now), All: true})
+		if err != nil {
+			t.Fatalf("RevokeSessions() error = %v", err)
+		}
+		sessReq = contract.SessionsRequest{Counter: 6, Timestamp: now}
+		_, err = clt.Sessions(ctx, &pb.SessionsRequest{Mac: crypto.HmacData(sha256.New, ski, sessReq.Canonical()), Counter: 6, Timestamp: contract.UnixMilli(now)})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Sessions() after RevokeSessions() error = %v, want %v", err, codes.Unauthenticated)
+		}
+	})
+}
+
+func TestGRPCTransport_Requests(t *testing.T) {
+	s := New(
+		NewUserRepository(),
+		NewVaultRepository(),
+		newTestConfiguration(t),
+	)
+	clt, stop := newTestGRPCClient(t, s)
+	defer stop()
+	ctx := context.Background()
+
+	t.Run("should return the request ID of the client or a new one", func(t *testing.T) {
+		for id, valid := range map[string]bool{"request-1": true, "request 1": false} {
+			// when
+			var header metadata.MD
+			_, err := clt.Group(metadata.AppendToOutgoingContext(ctx, pb.RequestIDKey, id), &pb.GroupRequest{}, grpc.Header(&header))
+
+			// then
+			got := header.Get(pb.RequestIDKey)
+			if err != nil || len(got) != 1 || (got[0] == id) != valid || !contract.ValidRequestID(got[0]) {
+				t.Errorf("Group() request ID = %v, %v, want %q valid %v", got, err, id, valid)
+			}
+		}
+	})
+
+	t.Run("should not enroll a registered user without code", func(t *testing.T) {
+		// given
+		cID, ak := big.NewInt(7), big.NewInt(5)
+		verifier := crypto.Verifier(testGroup, ak)
+		_, err := clt.Register(ctx, &pb.RegisterRequest{CId: cID.Bytes(), Verifier: verifier.Bytes()})
+		if err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+		proof, _ := crypto.ProvePossession(testGroup, ak, cID)
+
+		// when
+		_, err = clt.Enroll(ctx, &pb.EnrollRequest{CId: cID.Bytes(), Code: "c0de", Verifier: verifier.Bytes(), E: proof.E.Bytes(), ProofC: proof.C.Bytes(), ProofS: proof.S.Bytes()})
+
+		// then
+		if status.Code(err) != codes.PermissionDenied {
+			t.Errorf("Enroll() error = %v, want %v", err, codes.PermissionDenied)
+		}
+	})
+
+	t.Run("should require login with code in trailer", func(t *testing.T) {
+		// when
+		var trailer metadata.MD
+		_, err := clt.Get(ctx, &pb.GetRequest{Mac: []byte("mac"), Counter: 1, Timestamp: contract.UnixMilli(time.Now()), Domain: "domain", Bmk: big.NewInt(4).Bytes(), Q: testGroup.Order().Bytes()}, grpc.Trailer(&trailer))
+
+		// then
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Get() error = %v, want %v", err, codes.Unauthenticated)
+		}
+		if c := trailer.Get(pb.CodeKey); len(c) != 1 || c[0] != string(contract.CodeLoginRequired) {
+			t.Errorf("Get() trailer = %v, want %v", trailer, contract.CodeLoginRequired)
+		}
+	})
+
+	t.Run("should reject invalid requests", func(t *testing.T) {
+		// when
+		_, err := clt.ExpK(ctx, &pb.ExpKRequest{CId: big.NewInt(1).Bytes(), B: big.NewInt(1).Bytes(), Q: testGroup.Order().Bytes(), X: big.NewInt(4).Bytes()})
+
+		// then
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("ExpK() error = %v, want %v", err, codes.InvalidArgument)
+		}
+	})
+}
+
+func TestPeerHost(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 4711}
+	tests := []struct {
+		name         string
+		forwardedFor string
+		md           metadata.MD
+		want         string
+	}{
+		{"should use the peer address without trusted metadata", "", metadata.Pairs("x-forwarded-for", "198.51.100.1"), "192.0.2.1"},
+		{"should ignore missing trusted metadata", "X-Forwarded-For", metadata.MD{}, "192.0.2.1"},
+		{"should use the address appended by the proxy", "X-Forwarded-For", metadata.Pairs("x-forwarded-for", "203.0.113.7", "x-forwarded-for", "198.51.100.1"), "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+			ctx = metadata.NewIncomingContext(ctx, tt.md)
+
+			// when
+			got := peerHost(ctx, tt.forwardedFor)
+
+			// then
+			if got != tt.want {
+				t.Errorf("peerHost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,29 +1,35 @@
 package service
 
 import (
+	"context"
 	"crypto/sha256"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/LAtanassov/go-online-sphinx/pkg/contract"
+	"github.com/LAtanassov/go-online-sphinx/pkg/crypto"
 	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
 )
 
 func TestMakeRegisterHandler(t *testing.T) {
 	s := New(
 		NewUserRepository(),
-		NewConfiguration(big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1), sha256.New),
+		NewVaultRepository(),
+		newTestConfiguration(t),
 	)
 	ct := "application/json"
 
 	t.Run("should register a user", func(t *testing.T) {
 
-		ts := httptest.NewServer(NewHTTPTransport(s, log.NewNopLogger()).MakeRegisterHandler())
+		ts := httptest.NewServer(NewHTTPTransport(s, newTestSessionManager(t), log.NewNopLogger()).MakeRegisterHandler())
 		defer ts.Close()
 
-		r, err := contract.MarshalRegisterRequest(contract.RegisterRequest{CID: big.NewInt(1)})
+		r, err := contract.MarshalRegisterRequest(contract.RegisterRequest{CID: big.NewInt(1), Verifier: testVerifier})
 		if err != nil {
 			t.Errorf("MarshalRegisterRequest() error = %v", err)
 		}
@@ -34,25 +40,174 @@ func TestMakeRegisterHandler(t *testing.T) {
 		}
 	})
 
+	t.Run("should reject a malformed request", func(t *testing.T) {
+
+		ts := httptest.NewServer(NewHTTPTransport(s, newTestSessionManager(t), log.NewNopLogger()).MakeRegisterHandler())
+		defer ts.Close()
+
+		resp, err := http.Post(ts.URL+"/v1/register", ct, strings.NewReader(`{"CID":"not hex"}`))
+		if err != nil {
+			t.Fatalf("http.Post() error = %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("http.Post() status = %v, want %v", resp.StatusCode, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("should reject a request exceeding the size limit", func(t *testing.T) {
+
+		ts := httptest.NewServer(NewHTTPTransport(s, newTestSessionManager(t), log.NewNopLogger()).MakeRegisterHandler())
+		defer ts.Close()
+
+		resp, err := http.Post(ts.URL+"/v1/register", ct, strings.NewReader(`{"CID":"`+strings.Repeat("1", contract.MaxRequestSize)+`"}`))
+		if err != nil {
+			t.Fatalf("http.Post() error = %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("http.Post() status = %v, want %v", resp.StatusCode, http.StatusRequestEntityTooLarge)
+		}
+	})
+}
+
+func TestMakeEnrollHandler(t *testing.T) {
+	ct := "application/json"
+	ak := big.NewInt(5)
+	proof, err := crypto.ProvePossession(testGroup, ak, one)
+	if err != nil {
+		t.Fatalf("ProvePossession() error = %v", err)
+	}
+	enrollReq := contract.EnrollRequest{CID: one, Verifier: crypto.Verifier(testGroup, ak), E: proof.E, ProofC: proof.C, ProofS: proof.S}
+
+	tests := []struct {
+		name       string
+		issue      bool
+		wantStatus int
+	}{
+		{"should enroll an user with the issued code", true, http.StatusNoContent},
+		{"should reject an user without issued code", false, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			users := NewUserRepository()
+			users.Set(context.Background(), User{cID: one})
+			s := New(users, NewVaultRepository(), newTestConfiguration(t))
+			ts := httptest.NewServer(NewHTTPTransport(s, newTestSessionManager(t), log.NewNopLogger()).MakeEnrollHandler())
+			defer ts.Close()
+			enrollReq.Code = "c0de"
+			if tt.issue {
+				if enrollReq.Code, err = s.IssueEnrollment(context.Background(), one, time.Hour); err != nil {
+					t.Fatalf("IssueEnrollment() error = %v", err)
+				}
+			}
+			r, err := contract.MarshalEnrollRequest(enrollReq)
+			if err != nil {
+				t.Fatalf("MarshalEnrollRequest() error = %v", err)
+			}
+
+			// when
+			resp, err := http.Post(ts.URL+"/v1/enroll", ct, r)
+
+			// then
+			if err != nil {
+				t.Fatalf("http.Post() error = %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("http.Post() status = %v, want %v", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestMakeRegisterExpKHandler(t *testing.T) {
+	s := New(
+		NewUserRepository(),
+		NewVaultRepository(),
+		newTestConfiguration(t),
+	)
+	ct := "application/json"
+
+	t.Run("should evaluate the blinded password without user and session", func(t *testing.T) {
+		// given
+		ts := httptest.NewServer(NewHTTPTransport(s, newTestSessionManager(t), log.NewNopLogger()).MakeRegisterExpKHandler())
+		defer ts.Close()
+
+		r, err := contract.MarshalRegisterExpKRequest(contract.RegisterExpKRequest{B: big.NewInt(4), Q: testGroup.Order()})
+		if err != nil {
+			t.Fatalf("contract.MarshalRegisterExpKRequest() error = %v", err)
+		}
+
+		// when
+		resp, err := http.Post(ts.URL+"/v1/register/expk", ct, r)
+
+		// then
+		if err != nil {
+			t.Fatalf("http.Post() error = %v", err)
+		}
+		defer resp.Body.Close()
+		if len(resp.Cookies()) != 0 {
+			t.Errorf("http.Post() cookies = %v, want none", resp.Cookies())
+		}
+		regResp, err := contract.UnmarshalRegisterExpKResponse(resp.Body)
+		if err != nil {
+			t.Fatalf("contract.UnmarshalRegisterExpKResponse() error = %v", err)
+		}
+		if !testGroup.IsElement(regResp.BD) {
+			t.Errorf("MakeRegisterExpKHandler() bd = %v, want group element", regResp.BD)
+		}
+	})
+}
+
+func TestMakeGroupHandler(t *testing.T) {
+	s := New(
+		NewUserRepository(),
+		NewVaultRepository(),
+		newTestConfiguration(t),
+	)
+
+	t.Run("should publish the service group", func(t *testing.T) {
+
+		ts := httptest.NewServer(NewHTTPTransport(s, newTestSessionManager(t), log.NewNopLogger()).MakeGroupHandler())
+		defer ts.Close()
+
+		resp, err := http.Get(ts.URL + "/v1/group")
+		if err != nil {
+			t.Fatalf("http.Get() error = %v", err)
+		}
+		defer resp.Body.Close()
+
+		g, err := contract.UnmarshalGroupResponse(resp.Body)
+		if err != nil {
+			t.Fatalf("UnmarshalGroupResponse() error = %v", err)
+		}
+		if g.Name != testGroup.Name() || g.Q.Cmp(testGroup.Order()) != 0 || g.PK.Cmp(s.PublicKey()) != 0 {
+			t.Errorf("MakeGroupHandler() got = %v want = %v", g, testGroup)
+		}
+	})
 }
 
 func TestMakeExpKHandler(t *testing.T) {
 	s := New(
 		NewUserRepository(),
-		NewConfiguration(big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1), sha256.New),
+		NewVaultRepository(),
+		newTestConfiguration(t),
 	)
 	ct := "application/json"
 
 	t.Run("should exponent to k given blinded secret", func(t *testing.T) {
 
-		ts := httptest.NewServer(NewHTTPTransport(s, log.NewNopLogger()).MakeExpKHandler())
+		ts := httptest.NewServer(NewHTTPTransport(s, newTestSessionManager(t), log.NewNopLogger()).MakeExpKHandler())
 		defer ts.Close()
 
 		r, err := contract.MarshalExpKRequest(contract.ExpKRequest{
 			CID:    big.NewInt(1),
 			CNonce: big.NewInt(2),
-			B:      big.NewInt(3),
-			Q:      big.NewInt(4),
+			B:      big.NewInt(4),
+			Q:      testGroup.Order(),
+			X:      testVerifier,
 		})
 		if err != nil {
 			t.Errorf("contract.MarshalExpKRequest() error = %v", err)
@@ -63,23 +218,57 @@ func TestMakeExpKHandler(t *testing.T) {
 			t.Errorf("http.Post() error = %v", err)
 		}
 	})
+
+	t.Run("should throttle logins with a retry hint", func(t *testing.T) {
+		// given
+		cfg := ThrottleConfig{
+			Client: ThrottlePolicy{FreeAttempts: 1, BaseDelay: time.Minute, MaxDelay: time.Minute, ResetAfter: time.Hour},
+			Source: ThrottlePolicy{ResetAfter: time.Hour},
+		}
+		th := NewThrottle(NewThrottleStore(), cfg, &labelCounter{counts: make(map[string]float64)})
+		ts := httptest.NewServer(NewHTTPTransport(s, newTestSessionManager(t), log.NewNopLogger()).WithThrottle(th).MakeExpKHandler())
+		defer ts.Close()
+
+		// when
+		for i := 0; i < 2; i++ {
+			r, _ := contract.MarshalExpKRequest(contract.ExpKRequest{CID: big.NewInt(1), CNonce: big.NewInt(2), B: big.NewInt(4), Q: testGroup.Order(), X: testVerifier})
+			resp, err := http.Post(ts.URL+"/v1/login/expk", ct, r)
+			if err != nil {
+				t.Fatalf("http.Post() error = %v", err)
+			}
+			resp.Body.Close()
+		}
+		r, _ := contract.MarshalExpKRequest(contract.ExpKRequest{CID: big.NewInt(1), CNonce: big.NewInt(2), B: big.NewInt(4), Q: testGroup.Order(), X: testVerifier})
+		resp, err := http.Post(ts.URL+"/v1/login/expk", ct, r)
+		if err != nil {
+			t.Fatalf("http.Post() error = %v", err)
+		}
+		defer resp.Body.Close()
+
+		// then
+		e, ok := contract.UnmarshalIfError(resp).(*contract.Error)
+		if !ok || resp.StatusCode != http.StatusTooManyRequests || e.Code != contract.CodeTooManyAttempts || e.RetryAfter <= 0 {
+			t.Errorf("MakeExpKHandler() = %v, %v, want %v with retry hint", resp.StatusCode, e, contract.CodeTooManyAttempts)
+		}
+	})
 }
 
 func TestMakeChallengeHandler(t *testing.T) {
 	s := New(
 		NewUserRepository(),
-		NewConfiguration(big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1), sha256.New),
+		NewVaultRepository(),
+		newTestConfiguration(t),
 	)
 	ct := "application/json"
 
 	t.Run("should respond to a given challenge", func(t *testing.T) {
 
-		ts := httptest.NewServer(NewHTTPTransport(s, log.NewNopLogger()).MakeChallengeHandler())
+		ts := httptest.NewServer(NewHTTPTransport(s, newTestSessionManager(t), log.NewNopLogger()).MakeChallengeHandler())
 		defer ts.Close()
 
 		r, err := contract.MarshalChallengeRequest(contract.ChallengeRequest{
-			G: big.NewInt(3),
-			Q: big.NewInt(4),
+			G: big.NewInt(4),
+			Q: testGroup.Order(),
 		})
 		if err != nil {
 			t.Errorf("contract.MarshalChallengeRequest() error = %v", err)
@@ -90,18 +279,243 @@ func TestMakeChallengeHandler(t *testing.T) {
 			t.Errorf("http.Post() error = %v", err)
 		}
 	})
+
+	ski := big.NewInt(42)
+	post := func(t *testing.T, url, cookie string, challReq contract.ChallengeRequest) error {
+		r, err := contract.MarshalChallengeRequest(challReq)
+		if err != nil {
+			t.Fatalf("contract.MarshalChallengeRequest() error = %v", err)
+		}
+		req, _ := http.NewRequest(http.MethodPost, url+"/v1/login/challenge", r)
+		req.Header.Set("Content-Type", ct)
+		req.AddCookie(&http.Cookie{Name: sessionName, Value: cookie})
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("http.Do() error = %v", err)
+		}
+		defer resp.Body.Close()
+		return contract.UnmarshalIfError(resp)
+	}
+	signed := func(counter uint64) contract.ChallengeRequest {
+		challReq := contract.ChallengeRequest{Counter: counter, Timestamp: time.Now(), G: big.NewInt(4), Q: testGroup.Order()}
+		challReq.MAC = crypto.HmacData(sha256.New, ski.Bytes(), challReq.Canonical())
+		return challReq
+	}
+	code := func(err error) contract.Code {
+		if e, ok := err.(*contract.Error); ok {
+			return e.Code
+		}
+		return ""
+	}
+
+	t.Run("should confirm a signed challenge once", func(t *testing.T) {
+		// given
+		sm := newTestSessionManager(t)
+		ts := httptest.NewServer(NewHTTPTransport(s, sm, log.NewNopLogger()).MakeChallengeHandler())
+		defer ts.Close()
+		cookie, _ := sm.Create(context.Background(), big.NewInt(1), big.NewInt(1), ski)
+
+		// when
+		err := post(t, ts.URL, cookie, signed(1))
+		repeated := post(t, ts.URL, cookie, signed(2))
+
+		// then
+		if err != nil {
+			t.Errorf("http.Do() error = %v", err)
+		}
+		if code(repeated) != contract.CodeLoginOutOfOrder {
+			t.Errorf("http.Do() repeated error = %v, want %v", repeated, contract.CodeLoginOutOfOrder)
+		}
+		if _, err := sm.Load(context.Background(), cookie, StateChallengeConfirmed); err != nil {
+			t.Errorf("Load() error = %v", err)
+		}
+	})
+
+	t.Run("should end the login on an invalid proof", func(t *testing.T) {
+		// given
+		sm := newTestSessionManager(t)
+		ts := httptest.NewServer(NewHTTPTransport(s, sm, log.NewNopLogger()).MakeChallengeHandler())
+		defer ts.Close()
+		cookie, _ := sm.Create(context.Background(), big.NewInt(1), big.NewInt(1), ski)
+		challReq := signed(1)
+		challReq.MAC = []byte("mac")
+
+		// when
+		err := post(t, ts.URL, cookie, challReq)
+
+		// then
+		if code(err) != contract.CodeMACMismatch {
+			t.Errorf("http.Do() error = %v, want %v", err, contract.CodeMACMismatch)
+		}
+		if _, err := sm.Load(context.Background(), cookie, StateExpKIssued); errors.Cause(err) != ErrSessionNotFound {
+			t.Errorf("Load() error = %v, want %v", err, ErrSessionNotFound)
+		}
+	})
+}
+
+func TestMakeLogoutHandler(t *testing.T) {
+	s := New(
+		NewUserRepository(),
+		NewVaultRepository(),
+		newTestConfiguration(t),
+	)
+
+	t.Run("should revoke the session", func(t *testing.T) {
+		// given
+		sm := newTestSessionManager(t)
+		ts := httptest.NewServer(NewHTTPTransport(s, sm, log.NewNopLogger()).MakeLogoutHandler())
+		defer ts.Close()
+
+		token, err := sm.Create(context.Background(), big.NewInt(1), big.NewInt(1), big.NewInt(42))
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/logout", nil)
+		req.AddCookie(&http.Cookie{Name: sessionName, Value: token})
+
+		// when
+		resp, err := http.DefaultClient.Do(req)
+
+		// then
+		if err != nil {
+			t.Fatalf("http.Do() error = %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("http.Do() status = %v, want %v", resp.StatusCode, http.StatusOK)
+		}
+		if _, err := sm.Load(context.Background(), token, StateExpKIssued); errors.Cause(err) != ErrSessionNotFound {
+			t.Errorf("Load() error = %v, want %v", err, ErrSessionNotFound)
+		}
+	})
+}
+
+func TestMakeSessionsHandler(t *testing.T) {
+	s := New(
+		NewUserRepository(),
+		NewVaultRepository(),
+		newTestConfiguration(t),
+	)
+	ctx := context.Background()
+	ct := "application/json"
+
+	t.Run("should list the sessions of the user and mark the current one", func(t *testing.T) {
+		// given
+		sm := newTestSessionManager(t)
+		ts := httptest.NewServer(NewHTTPTransport(s, sm, log.NewNopLogger()).MakeSessionsHandler())
+		defer ts.Close()
+
+		ski := big.NewInt(42)
+		sm.Create(ctx, big.NewInt(1), big.NewInt(1), ski)
+		sm.Create(ctx, big.NewInt(2), big.NewInt(1), ski)
+		cookie, _ := sm.Create(ctx, big.NewInt(1), big.NewInt(1), ski)
+		session, _ := sm.Load(ctx, cookie, StateExpKIssued)
+		sm.confirm(ctx, session)
+		sessReq := contract.SessionsRequest{Counter: 1, Timestamp: time.Now()}
+		sessReq.MAC = crypto.HmacData(sha256.New, ski.Bytes(), sessReq.Canonical())
+		r, _ := contract.MarshalSessionsRequest(sessReq)
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/sessions", r)
+		req.Header.Set("Content-Type", ct)
+		req.AddCookie(&http.Cookie{Name: sessionName, Value: cookie})
+
+		// when
+		resp, err := http.DefaultClient.Do(req)
+
+		// then
+		if err != nil {
+			t.Fatalf("http.Do() error = %v", err)
+		}
+		defer resp.Body.Close()
+		if err := contract.UnmarshalIfError(resp); err != nil {
+			t.Fatalf("http.Do() error = %v", err)
+		}
+		got, err := contract.UnmarshalSessionsResponse(resp.Body)
+		if err != nil {
+			t.Fatalf("contract.UnmarshalSessionsResponse() error = %v", err)
+		}
+		if len(got.Sessions) != 2 || got.Sessions[0].Current || !got.Sessions[1].Current || got.Sessions[1].ID != session.ID {
+			t.Errorf("http.Do() sessions = %v, want two with session %v current", got.Sessions, session.ID)
+		}
+	})
+}
+
+func TestMakeRevokeSessionsHandler(t *testing.T) {
+	s := New(
+		NewUserRepository(),
+		NewVaultRepository(),
+		newTestConfiguration(t),
+	)
+	ctx := context.Background()
+	ct := "application/json"
+
+	// revokeOther is replaced by the ID of the other session of the user
+	const revokeOther = "other"
+	tests := []struct {
+		name        string
+		id          string
+		all         bool
+		wantOther   bool
+		wantCurrent bool
+	}{
+		{"should revoke another session of the user", revokeOther, false, false, true},
+		{"should ignore an unknown session", "unknown", false, true, true},
+		{"should revoke all sessions of the user", "", true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			sm := newTestSessionManager(t)
+			ts := httptest.NewServer(NewHTTPTransport(s, sm, log.NewNopLogger()).MakeRevokeSessionsHandler())
+			defer ts.Close()
+
+			ski := big.NewInt(42)
+			otherCookie, _ := sm.Create(ctx, big.NewInt(1), big.NewInt(1), ski)
+			other, _ := sm.Load(ctx, otherCookie, StateExpKIssued)
+			cookie, _ := sm.Create(ctx, big.NewInt(1), big.NewInt(1), ski)
+			current, _ := sm.Load(ctx, cookie, StateExpKIssued)
+			sm.confirm(ctx, current)
+			revReq := contract.RevokeSessionsRequest{ID: tt.id, All: tt.all, Counter: 1, Timestamp: time.Now()}
+			if tt.id == revokeOther {
+				revReq.ID = other.ID
+			}
+			revReq.MAC = crypto.HmacData(sha256.New, ski.Bytes(), revReq.Canonical())
+			r, _ := contract.MarshalRevokeSessionsRequest(revReq)
+			req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/sessions/revoke", r)
+			req.Header.Set("Content-Type", ct)
+			req.AddCookie(&http.Cookie{Name: sessionName, Value: cookie})
+
+			// when
+			resp, err := http.DefaultClient.Do(req)
+
+			// then
+			if err != nil {
+				t.Fatalf("http.Do() error = %v", err)
+			}
+			defer resp.Body.Close()
+			if err := contract.UnmarshalIfError(resp); err != nil {
+				t.Fatalf("http.Do() error = %v", err)
+			}
+			if _, err := sm.Load(ctx, otherCookie, StateExpKIssued); (err == nil) != tt.wantOther {
+				t.Errorf("Load() of other session error = %v, want session %v", err, tt.wantOther)
+			}
+			if _, err := sm.Load(ctx, cookie, StateChallengeConfirmed); (err == nil) != tt.wantCurrent {
+				t.Errorf("Load() of current session error = %v, want session %v", err, tt.wantCurrent)
+			}
+		})
+	}
 }
 
 func TestMakeMetadataHandler(t *testing.T) {
 	s := New(
 		NewUserRepository(),
-		NewConfiguration(big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1), sha256.New),
+		NewVaultRepository(),
+		newTestConfiguration(t),
 	)
 	ct := "application/json"
 
 	t.Run("should return metadata", func(t *testing.T) {
 
-		ts := httptest.NewServer(NewHTTPTransport(s, log.NewNopLogger()).MakeMetadataHandler())
+		ts := httptest.NewServer(NewHTTPTransport(s, newTestSessionManager(t), log.NewNopLogger()).MakeMetadataHandler())
 		defer ts.Close()
 
 		r, err := contract.MarshalMetadataRequest(contract.MetadataRequest{
@@ -116,18 +530,98 @@ func TestMakeMetadataHandler(t *testing.T) {
 			t.Errorf("http.Post() error = %v", err)
 		}
 	})
+
+	t.Run("should reject a replayed request", func(t *testing.T) {
+		// given
+		sm := newTestSessionManager(t)
+		ts := httptest.NewServer(NewHTTPTransport(s, sm, log.NewNopLogger()).MakeMetadataHandler())
+		defer ts.Close()
+
+		err := s.Register(context.Background(), big.NewInt(1), testVerifier)
+		if err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+		ski := big.NewInt(42)
+		cookie, err := sm.Create(context.Background(), big.NewInt(1), big.NewInt(1), ski)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		session, _ := sm.Load(context.Background(), cookie, StateExpKIssued)
+		sm.confirm(context.Background(), session)
+		metaReq := contract.MetadataRequest{Counter: 1, Timestamp: time.Now()}
+		metaReq.MAC = crypto.HmacData(sha256.New, ski.Bytes(), metaReq.Canonical())
+		post := func() *http.Response {
+			r, err := contract.MarshalMetadataRequest(metaReq)
+			if err != nil {
+				t.Fatalf("contract.MarshalMetadataRequest() error = %v", err)
+			}
+			req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/metadata", r)
+			req.Header.Set("Content-Type", ct)
+			req.AddCookie(&http.Cookie{Name: "online-sphinx", Value: cookie})
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("http.Do() error = %v", err)
+			}
+			return resp
+		}
+
+		// when
+		first := post()
+		defer first.Body.Close()
+		replayed := post()
+		defer replayed.Body.Close()
+
+		// then
+		if first.StatusCode != http.StatusOK {
+			t.Errorf("http.Do() status = %v, want %v", first.StatusCode, http.StatusOK)
+		}
+		err = contract.UnmarshalIfError(replayed)
+		if e, ok := err.(*contract.Error); !ok || e.Code != contract.CodeReplayedRequest {
+			t.Errorf("http.Do() replayed error = %v, want %v", err, contract.CodeReplayedRequest)
+		}
+	})
+
+	t.Run("should reject requests before the challenge", func(t *testing.T) {
+		// given
+		sm := newTestSessionManager(t)
+		ts := httptest.NewServer(NewHTTPTransport(s, sm, log.NewNopLogger()).MakeMetadataHandler())
+		defer ts.Close()
+
+		ski := big.NewInt(42)
+		cookie, _ := sm.Create(context.Background(), big.NewInt(1), big.NewInt(1), ski)
+		metaReq := contract.MetadataRequest{Counter: 1, Timestamp: time.Now()}
+		metaReq.MAC = crypto.HmacData(sha256.New, ski.Bytes(), metaReq.Canonical())
+		r, _ := contract.MarshalMetadataRequest(metaReq)
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/metadata", r)
+		req.Header.Set("Content-Type", ct)
+		req.AddCookie(&http.Cookie{Name: sessionName, Value: cookie})
+
+		// when
+		resp, err := http.DefaultClient.Do(req)
+
+		// then
+		if err != nil {
+			t.Fatalf("http.Do() error = %v", err)
+		}
+		defer resp.Body.Close()
+		err = contract.UnmarshalIfError(resp)
+		if e, ok := err.(*contract.Error); !ok || e.Code != contract.CodeLoginOutOfOrder {
+			t.Errorf("http.Do() error = %v, want %v", err, contract.CodeLoginOutOfOrder)
+		}
+	})
 }
 
 func TestMakeAddHandler(t *testing.T) {
 	s := New(
 		NewUserRepository(),
-		NewConfiguration(big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1), sha256.New),
+		NewVaultRepository(),
+		newTestConfiguration(t),
 	)
 	ct := "application/json"
 
 	t.Run("should add vault", func(t *testing.T) {
 
-		ts := httptest.NewServer(NewHTTPTransport(s, log.NewNopLogger()).MakeAddHandler())
+		ts := httptest.NewServer(NewHTTPTransport(s, newTestSessionManager(t), log.NewNopLogger()).MakeAddHandler())
 		defer ts.Close()
 
 		r, err := contract.MarshalAddRequest(contract.AddRequest{
@@ -148,13 +642,14 @@ func TestMakeAddHandler(t *testing.T) {
 func TestMakeGetHandler(t *testing.T) {
 	s := New(
 		NewUserRepository(),
-		NewConfiguration(big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1), sha256.New),
+		NewVaultRepository(),
+		newTestConfiguration(t),
 	)
 	ct := "application/json"
 
 	t.Run("should add vault", func(t *testing.T) {
 
-		ts := httptest.NewServer(NewHTTPTransport(s, log.NewNopLogger()).MakeGetHandler())
+		ts := httptest.NewServer(NewHTTPTransport(s, newTestSessionManager(t), log.NewNopLogger()).MakeGetHandler())
 		defer ts.Close()
 
 		r, err := contract.MarshalGetRequest(contract.GetRequest{
@@ -173,3 +668,177 @@ func TestMakeGetHandler(t *testing.T) {
 		}
 	})
 }
+
+func TestMakeRotateHandler(t *testing.T) {
+	s := New(
+		NewUserRepository(),
+		NewVaultRepository(),
+		newTestConfiguration(t),
+	)
+	ct := "application/json"
+
+	t.Run("should require login", func(t *testing.T) {
+
+		ts := httptest.NewServer(NewHTTPTransport(s, newTestSessionManager(t), log.NewNopLogger()).MakeRotateHandler())
+		defer ts.Close()
+
+		r, err := contract.MarshalRotateRequest(contract.RotateRequest{
+			MAC:    []byte("mac"),
+			Domain: "domain",
+		})
+		if err != nil {
+			t.Errorf("contract.MarshalRotateRequest() error = %v", err)
+		}
+
+		resp, err := http.Post(ts.URL+"/v1/rotate", ct, r)
+		if err != nil {
+			t.Fatalf("http.Post() error = %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("http.Post() status = %v, want %v", resp.StatusCode, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestMakeDeleteHandler(t *testing.T) {
+	s := New(
+		NewUserRepository(),
+		NewVaultRepository(),
+		newTestConfiguration(t),
+	)
+	ct := "application/json"
+
+	t.Run("should require login", func(t *testing.T) {
+
+		ts := httptest.NewServer(NewHTTPTransport(s, newTestSessionManager(t), log.NewNopLogger()).MakeDeleteHandler())
+		defer ts.Close()
+
+		r, err := contract.MarshalDeleteRequest(contract.DeleteRequest{
+			MAC:    []byte("mac"),
+			Domain: "domain",
+		})
+		if err != nil {
+			t.Errorf("contract.MarshalDeleteRequest() error = %v", err)
+		}
+
+		resp, err := http.Post(ts.URL+"/v1/delete", ct, r)
+		if err != nil {
+			t.Fatalf("http.Post() error = %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("http.Post() status = %v, want %v", resp.StatusCode, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestMakeRequestContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		id     string
+		wantID bool
+	}{
+		{"should keep the request ID of the client", "request-1", true},
+		{"should replace an invalid request ID", "request 1", false},
+		{"should assign a request ID", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			var got string
+			var deadline bool
+			h := MakeRequestContext(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = contract.RequestID(r.Context())
+				_, deadline = r.Context().Deadline()
+			}), time.Minute)
+			req := httptest.NewRequest("GET", "/v1/group", nil)
+			req.Header.Set(contract.RequestIDHeader, tt.id)
+			w := httptest.NewRecorder()
+
+			// when
+			h.ServeHTTP(w, req)
+
+			// then
+			if !contract.ValidRequestID(got) || (got == tt.id) != tt.wantID || w.Header().Get(contract.RequestIDHeader) != got {
+				t.Errorf("MakeRequestContext() request ID = %q, response %q, client %q", got, w.Header().Get(contract.RequestIDHeader), tt.id)
+			}
+			if !deadline {
+				t.Errorf("MakeRequestContext() without deadline")
+			}
+		})
+	}
+}
+
+func TestContractError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want contract.Code
+	}{
+		{"should map ErrLoginRequired", errors.Wrap(ErrLoginRequired, "context"), contract.CodeLoginRequired},
+		{"should map ErrSessionNotFound", errors.Wrap(ErrSessionNotFound, "context"), contract.CodeLoginRequired},
+		{"should map ErrMacMismatch", ErrMacMismatch, contract.CodeMACMismatch},
+		{"should map ErrReplayedRequest", errors.Wrap(ErrReplayedRequest, "context"), contract.CodeReplayedRequest},
+		{"should map ErrLoginOutOfOrder", errors.Wrap(ErrLoginOutOfOrder, "context"), contract.CodeLoginOutOfOrder},
+		{"should map ErrLoginTimeout", errors.Wrap(ErrLoginTimeout, "context"), contract.CodeLoginTimeout},
+		{"should map ErrUserNotFound", ErrUserNotFound, contract.CodeUserNotFound},
+		{"should map ErrUserAlreadyExists", errors.Wrap(ErrUserAlreadyExists, "context"), contract.CodeUserAlreadyExists},
+		{"should map ErrEnrollmentInvalid", errors.Wrap(ErrEnrollmentInvalid, "context"), contract.CodeEnrollmentInvalid},
+		{"should map invalid proofs", errors.Wrap(crypto.ErrProofInvalid, "context"), contract.CodeBadRequest},
+		{"should map ErrDomainNotFound", ErrDomainNotFound, contract.CodeDomainNotFound},
+		{"should map ErrDomainAlreadyExists", ErrDomainAlreadyExists, contract.CodeDomainAlreadyExists},
+		{"should map ErrNoPreviousVersion", ErrNoPreviousVersion, contract.CodeNoPreviousVersion},
+		{"should map ErrGroupMismatch", ErrGroupMismatch, contract.CodeGroupMismatch},
+		{"should map ErrInvalidElement", ErrInvalidElement, contract.CodeInvalidElement},
+		{"should map ErrMetadataTooLong", ErrMetadataTooLong, contract.CodeMetadataTooLong},
+		{"should map TooManyAttemptsError", errors.Wrap(&TooManyAttemptsError{RetryAfter: time.Second}, "context"), contract.CodeTooManyAttempts},
+		{"should map timeouts", errors.Wrap(context.DeadlineExceeded, "context"), contract.CodeUnavailable},
+		{"should map malformed requests", badRequest(errors.New("unexpected EOF")), contract.CodeBadRequest},
+		{"should map other errors", errors.New("disk full"), contract.CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			encodeError(w, tt.err)
+
+			err := contract.UnmarshalIfError(w.Result())
+			e, ok := err.(*contract.Error)
+			if !ok || e.Code != tt.want {
+				t.Errorf("encodeError() = %v, want code %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteHost(t *testing.T) {
+	tests := []struct {
+		name         string
+		forwardedFor string
+		header       string
+		want         string
+	}{
+		{"should use the remote address without trusted header", "", "198.51.100.1", "192.0.2.1"},
+		{"should ignore a missing trusted header", "X-Forwarded-For", "", "192.0.2.1"},
+		{"should use the trusted header", "X-Forwarded-For", "198.51.100.1", "198.51.100.1"},
+		{"should use the address appended by the proxy", "X-Forwarded-For", "203.0.113.7, 198.51.100.1", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			req := httptest.NewRequest(http.MethodPost, "/v1/login/expk", nil)
+			req.RemoteAddr = "192.0.2.1:4711"
+			if tt.header != "" {
+				req.Header.Set("X-Forwarded-For", tt.header)
+			}
+
+			// when
+			got := remoteHost(req, tt.forwardedFor)
+
+			// then
+			if got != tt.want {
+				t.Errorf("remoteHost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}